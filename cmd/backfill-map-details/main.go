@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/geobuff/api/repo"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	err := godotenv.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	err = repo.OpenConnection()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("successfully connected to database")
+
+	count, err := repo.BackfillMapElementDetails()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("successfully simplified %d map elements\n", count)
+}
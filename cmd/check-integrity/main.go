@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/geobuff/api/repo"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// Prints every integrity issue and exits with a non-zero status if there are
+// any, so it can fail a pipeline.
+func main() {
+	err := godotenv.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	err = repo.OpenConnection()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("successfully connected to database")
+
+	report, err := repo.CheckIntegrity()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Printf("%s\t%s %s\t%s\n", issue.Check, issue.Entity, issue.Key, issue.Message)
+	}
+
+	if len(report.Issues) > 0 {
+		fmt.Printf("found %d integrity issues\n", len(report.Issues))
+		os.Exit(1)
+	}
+	fmt.Println("no integrity issues found")
+}
@@ -0,0 +1 @@
+ALTER TABLE triviaQuestions DROP column manualTriviaQuestionId;
@@ -0,0 +1,3 @@
+ALTER TABLE triviaQuestions ADD column manualTriviaQuestionId INTEGER references manualTriviaQuestions(id) ON DELETE SET NULL;
+
+UPDATE triviaQuestions t SET manualTriviaQuestionId = m.id FROM manualTriviaQuestions m WHERE m.typeId = t.typeId AND m.question = t.question;
@@ -0,0 +1,12 @@
+CREATE TABLE triviaQuestionResults (
+    id SERIAL PRIMARY KEY,
+    triviaQuestionId INTEGER references triviaQuestions(id) ON DELETE SET NULL,
+    manualTriviaQuestionId INTEGER references manualTriviaQuestions(id) ON DELETE SET NULL,
+    answerText TEXT NOT NULL,
+    isCorrect BOOLEAN NOT NULL,
+    skipped BOOLEAN NOT NULL,
+    added TIMESTAMP NOT NULL
+);
+
+CREATE INDEX triviaQuestionResults_triviaQuestionId_idx ON triviaQuestionResults(triviaQuestionId);
+CREATE INDEX triviaQuestionResults_manualTriviaQuestionId_idx ON triviaQuestionResults(manualTriviaQuestionId);
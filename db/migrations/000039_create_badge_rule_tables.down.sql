@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS userBadges;
+DROP TABLE IF EXISTS badgeRules;
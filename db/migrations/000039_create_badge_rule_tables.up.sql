@@ -0,0 +1,21 @@
+CREATE TABLE badgeRules (
+    id SERIAL PRIMARY KEY,
+    badgeId INTEGER references badges(id) ON DELETE CASCADE NOT NULL,
+    metric TEXT NOT NULL,
+    threshold INTEGER
+);
+
+CREATE TABLE userBadges (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER references users(id) ON DELETE CASCADE NOT NULL,
+    badgeId INTEGER references badges(id) ON DELETE CASCADE NOT NULL,
+    progress INTEGER NOT NULL,
+    awardedAt TIMESTAMP,
+    UNIQUE (userId, badgeId)
+);
+
+INSERT INTO badgeRules (badgeId, metric, threshold)
+SELECT id,
+    CASE typeId WHEN 1 THEN 'leaderboard_entries' WHEN 4 THEN 'community_quizzes_created' ELSE 'badge_quizzes_completed' END,
+    CASE WHEN typeId IN (1, 4) THEN 1 END
+FROM badges;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS userStreakFreezes;
+DROP TABLE IF EXISTS userTriviaPlays;
+ALTER TABLE users DROP column streakFreezes;
+ALTER TABLE users DROP column timezone;
@@ -0,0 +1,23 @@
+ALTER TABLE users ADD column timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD column streakFreezes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE userTriviaPlays (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER references users(id) ON DELETE CASCADE NOT NULL,
+    triviaId INTEGER references trivia(id) ON DELETE SET NULL,
+    triviaDate DATE NOT NULL,
+    score INTEGER NOT NULL,
+    maxScore INTEGER NOT NULL,
+    playedOn DATE NOT NULL,
+    added TIMESTAMP NOT NULL,
+    UNIQUE (userId, triviaDate)
+);
+
+CREATE INDEX userTriviaPlays_triviaDate_idx ON userTriviaPlays(triviaDate);
+
+CREATE TABLE userStreakFreezes (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER references users(id) ON DELETE CASCADE NOT NULL,
+    frozenOn DATE NOT NULL,
+    UNIQUE (userId, frozenOn)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS quizCountryScores;
+DROP INDEX IF EXISTS leaderboard_quizId_score_idx;
+DROP INDEX IF EXISTS users_countryCode_xp_idx;
+DROP INDEX IF EXISTS users_xp_idx;
@@ -0,0 +1,22 @@
+CREATE INDEX users_xp_idx ON users (xp DESC, id);
+CREATE INDEX users_countryCode_xp_idx ON users (countryCode, xp DESC, id);
+CREATE INDEX leaderboard_quizId_score_idx ON leaderboard (quizId, score DESC, time);
+
+CREATE TABLE quizCountryScores (
+    id SERIAL PRIMARY KEY,
+    quizId INTEGER references quizzes(id) ON DELETE CASCADE NOT NULL,
+    countryCode TEXT NOT NULL,
+    entries INTEGER NOT NULL,
+    averageScore DOUBLE PRECISION NOT NULL,
+    averageTime DOUBLE PRECISION NOT NULL,
+    updated TIMESTAMP NOT NULL,
+    UNIQUE (quizId, countryCode)
+);
+
+INSERT INTO quizCountryScores (quizId, countryCode, entries, averageScore, averageTime, updated)
+SELECT quizId, countryCode, MAX(entries), AVG(score), AVG(time), NOW() FROM (
+    SELECT l.quizId, u.countryCode, l.score, l.time,
+        COUNT(*) OVER (PARTITION BY l.quizId, u.countryCode) entries,
+        ROW_NUMBER() OVER (PARTITION BY l.quizId, u.countryCode ORDER BY l.score DESC, l.time) position
+    FROM leaderboard l JOIN users u ON u.id = l.userId
+) ranked WHERE position <= 10 GROUP BY quizId, countryCode;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stripeEvents;
+DROP INDEX IF EXISTS orders_checkoutSessionId_idx;
+ALTER TABLE orders DROP column IF EXISTS checkoutSessionId;
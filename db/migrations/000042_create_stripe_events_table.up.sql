@@ -0,0 +1,10 @@
+ALTER TABLE orders ADD column checkoutSessionId TEXT;
+CREATE UNIQUE INDEX orders_checkoutSessionId_idx ON orders (checkoutSessionId);
+
+CREATE TABLE stripeEvents (
+    id SERIAL PRIMARY KEY,
+    eventId TEXT UNIQUE NOT NULL,
+    type TEXT NOT NULL,
+    orderId INTEGER references orders(id) ON DELETE SET NULL,
+    processed TIMESTAMP NOT NULL
+);
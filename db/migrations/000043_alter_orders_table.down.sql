@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_statusId_reservedUntil_idx;
+ALTER TABLE orders DROP column reservedUntil;
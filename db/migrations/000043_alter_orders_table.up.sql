@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD column reservedUntil TIMESTAMP;
+CREATE INDEX orders_statusId_reservedUntil_idx ON orders (statusId, reservedUntil);
@@ -0,0 +1,13 @@
+ALTER TABLE orderItems DROP column total;
+ALTER TABLE orderItems DROP column discount;
+ALTER TABLE orderItems DROP column subtotal;
+ALTER TABLE orderItems DROP column unitPrice;
+
+ALTER TABLE orders DROP column total;
+ALTER TABLE orders DROP column shippingTotal;
+ALTER TABLE orders DROP column discountTotal;
+ALTER TABLE orders DROP column subtotal;
+
+ALTER TABLE discounts DROP column customerLimit;
+ALTER TABLE discounts DROP column usageLimit;
+ALTER TABLE discounts DROP column expires;
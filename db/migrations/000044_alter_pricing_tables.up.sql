@@ -0,0 +1,13 @@
+ALTER TABLE discounts ADD column expires TIMESTAMP;
+ALTER TABLE discounts ADD column usageLimit INTEGER;
+ALTER TABLE discounts ADD column customerLimit INTEGER;
+
+ALTER TABLE orders ADD column subtotal DECIMAL(12,2);
+ALTER TABLE orders ADD column discountTotal DECIMAL(12,2);
+ALTER TABLE orders ADD column shippingTotal DECIMAL(12,2);
+ALTER TABLE orders ADD column total DECIMAL(12,2);
+
+ALTER TABLE orderItems ADD column unitPrice DECIMAL(12,2);
+ALTER TABLE orderItems ADD column subtotal DECIMAL(12,2);
+ALTER TABLE orderItems ADD column discount DECIMAL(12,2);
+ALTER TABLE orderItems ADD column total DECIMAL(12,2);
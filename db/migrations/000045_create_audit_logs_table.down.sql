@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS discounts_code_idx;
+DROP INDEX IF EXISTS merch_route_idx;
+DROP TABLE IF EXISTS auditLogs;
@@ -0,0 +1,13 @@
+CREATE TABLE auditLogs (
+    id SERIAL PRIMARY KEY,
+    userId INTEGER references users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entityId INTEGER NOT NULL,
+    details TEXT NOT NULL,
+    added TIMESTAMP NOT NULL
+);
+
+CREATE INDEX auditLogs_entity_idx ON auditLogs (entity, entityId);
+CREATE UNIQUE INDEX merch_route_idx ON merch (route);
+CREATE UNIQUE INDEX discounts_code_idx ON discounts (code);
@@ -0,0 +1,15 @@
+ALTER TABLE orders DROP column refundedAt;
+ALTER TABLE orders DROP column cancelledAt;
+ALTER TABLE orders DROP column deliveredAt;
+ALTER TABLE orders DROP column shippedAt;
+ALTER TABLE orders DROP column packedAt;
+ALTER TABLE orders DROP column paidAt;
+ALTER TABLE orders DROP column refundId;
+ALTER TABLE orders DROP column paymentIntentId;
+ALTER TABLE orders DROP column trackingNumber;
+ALTER TABLE orders DROP column carrier;
+
+UPDATE orders SET statusId = 3 WHERE statusId IN (4, 5);
+UPDATE orders SET statusId = 2 WHERE statusId = 7;
+UPDATE orders SET statusId = 1 WHERE statusId = 6;
+DELETE FROM orderStatus WHERE id > 3;
@@ -0,0 +1,16 @@
+INSERT INTO orderStatus (id, status)
+SELECT v.id, v.status FROM (VALUES (4, 'Packed'), (5, 'Delivered'), (6, 'Cancelled'), (7, 'Refunded')) v(id, status)
+WHERE EXISTS (SELECT 1 FROM orderStatus WHERE id = 3);
+
+SELECT setval(pg_get_serial_sequence('orderstatus', 'id'), COALESCE((SELECT MAX(id) FROM orderStatus), 1), (SELECT MAX(id) FROM orderStatus) IS NOT NULL);
+
+ALTER TABLE orders ADD column carrier TEXT;
+ALTER TABLE orders ADD column trackingNumber TEXT;
+ALTER TABLE orders ADD column paymentIntentId TEXT;
+ALTER TABLE orders ADD column refundId TEXT;
+ALTER TABLE orders ADD column paidAt TIMESTAMP;
+ALTER TABLE orders ADD column packedAt TIMESTAMP;
+ALTER TABLE orders ADD column shippedAt TIMESTAMP;
+ALTER TABLE orders ADD column deliveredAt TIMESTAMP;
+ALTER TABLE orders ADD column cancelledAt TIMESTAMP;
+ALTER TABLE orders ADD column refundedAt TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_cancelToken_idx;
+ALTER TABLE orders DROP column cancelToken;
@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD column cancelToken TEXT;
+CREATE UNIQUE INDEX orders_cancelToken_idx ON orders (cancelToken);
@@ -0,0 +1,8 @@
+ALTER TABLE orders DROP column countryCode;
+ALTER TABLE orders DROP column currency;
+ALTER TABLE merch DROP column weight;
+DROP TABLE IF EXISTS shippingRates;
+DROP TABLE IF EXISTS shippingOptionCountries;
+DROP TABLE IF EXISTS merchPrices;
+DROP TABLE IF EXISTS currencyCountries;
+DROP TABLE IF EXISTS currencies;
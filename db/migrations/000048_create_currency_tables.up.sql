@@ -0,0 +1,44 @@
+CREATE TABLE currencies (
+    code TEXT PRIMARY KEY,
+    rate DECIMAL(12,6) NOT NULL,
+    roundingIncrement DECIMAL(12,2) NOT NULL DEFAULT 0.01,
+    priceEnding DECIMAL(3,2)
+);
+
+INSERT INTO currencies (code, rate) VALUES ('NZD', 1);
+
+CREATE TABLE currencyCountries (
+    countryCode TEXT PRIMARY KEY,
+    currencyCode TEXT references currencies(code) NOT NULL
+);
+
+CREATE TABLE merchPrices (
+    id SERIAL PRIMARY KEY,
+    merchId INTEGER references merch(id) NOT NULL,
+    currencyCode TEXT references currencies(code) NOT NULL,
+    price DECIMAL(12,2) NOT NULL,
+    UNIQUE (merchId, currencyCode)
+);
+
+CREATE TABLE shippingOptionCountries (
+    id SERIAL PRIMARY KEY,
+    shippingOptionId INTEGER references shippingOptions(id) NOT NULL,
+    countryCode TEXT NOT NULL,
+    UNIQUE (shippingOptionId, countryCode)
+);
+
+CREATE TABLE shippingRates (
+    id SERIAL PRIMARY KEY,
+    shippingOptionId INTEGER references shippingOptions(id) NOT NULL,
+    currencyCode TEXT references currencies(code) NOT NULL,
+    maxQuantity INTEGER,
+    maxWeight INTEGER,
+    price DECIMAL(12,2) NOT NULL
+);
+
+ALTER TABLE merch ADD column weight INTEGER;
+ALTER TABLE orders ADD column currency TEXT NOT NULL DEFAULT 'NZD';
+ALTER TABLE orders ADD column countryCode TEXT;
+
+INSERT INTO shippingOptionCountries (shippingOptionId, countryCode)
+SELECT id, 'nz' FROM shippingOptions WHERE name ILIKE 'NZ-Wide%';
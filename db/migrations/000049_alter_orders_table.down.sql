@@ -0,0 +1,8 @@
+UPDATE orders SET address = concat_ws(', ', addressLine1, nullif(addressLine2, ''), city, nullif(trim(concat_ws(' ', region, postcode)), '')) WHERE address IS NULL;
+
+ALTER TABLE orders ALTER column address SET NOT NULL;
+ALTER TABLE orders DROP column postcode;
+ALTER TABLE orders DROP column region;
+ALTER TABLE orders DROP column city;
+ALTER TABLE orders DROP column addressLine2;
+ALTER TABLE orders DROP column addressLine1;
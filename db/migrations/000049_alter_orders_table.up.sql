@@ -0,0 +1,8 @@
+ALTER TABLE orders ADD column addressLine1 TEXT;
+ALTER TABLE orders ADD column addressLine2 TEXT;
+ALTER TABLE orders ADD column city TEXT;
+ALTER TABLE orders ADD column region TEXT;
+ALTER TABLE orders ADD column postcode TEXT;
+ALTER TABLE orders ALTER column address DROP NOT NULL;
+
+UPDATE orders SET countryCode = 'nz' WHERE countryCode IS NULL;
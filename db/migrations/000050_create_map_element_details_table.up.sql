@@ -0,0 +1,8 @@
+CREATE TABLE mapElementDetails (
+    id SERIAL PRIMARY KEY,
+    elementId INTEGER references mapElements(id) ON DELETE CASCADE NOT NULL,
+    detail TEXT NOT NULL,
+    d TEXT NOT NULL,
+    points TEXT NOT NULL,
+    UNIQUE (elementId, detail)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mapRevisions;
+ALTER TABLE maps DROP column revision;
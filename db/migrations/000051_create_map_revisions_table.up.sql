@@ -0,0 +1,14 @@
+ALTER TABLE maps ADD column revision INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE mapRevisions (
+    id SERIAL PRIMARY KEY,
+    mapId INTEGER references maps(id) ON DELETE CASCADE NOT NULL,
+    revision INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    viewBox TEXT NOT NULL,
+    elements JSONB NOT NULL,
+    userId INTEGER references users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    added TIMESTAMP NOT NULL,
+    UNIQUE (mapId, revision)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mappingEntryNeighbours;
+DROP TABLE IF EXISTS mappingEntryAttributes;
+DROP TABLE IF EXISTS mappingAttributes;
@@ -0,0 +1,27 @@
+CREATE TABLE mappingAttributes (
+    id SERIAL PRIMARY KEY,
+    key TEXT UNIQUE NOT NULL,
+    label TEXT NOT NULL,
+    valueType TEXT NOT NULL CHECK (valueType IN ('text', 'number'))
+);
+
+CREATE TABLE mappingEntryAttributes (
+    id SERIAL PRIMARY KEY,
+    entryId INTEGER references mappingEntries(id) ON DELETE CASCADE NOT NULL,
+    attributeId INTEGER references mappingAttributes(id) ON DELETE CASCADE NOT NULL,
+    textValue TEXT,
+    numberValue DOUBLE PRECISION,
+    UNIQUE (entryId, attributeId)
+);
+
+CREATE TABLE mappingEntryNeighbours (
+    entryId INTEGER references mappingEntries(id) ON DELETE CASCADE NOT NULL,
+    neighbourId INTEGER references mappingEntries(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (entryId, neighbourId),
+    CHECK (entryId <> neighbourId)
+);
+
+INSERT INTO mappingAttributes (key, label, valueType) VALUES
+('capital', 'Capital', 'text'),
+('population', 'Population', 'number'),
+('area', 'Area (km²)', 'number');
@@ -0,0 +1,13 @@
+CREATE TABLE triviaQuestionTemplates (
+    id SERIAL PRIMARY KEY,
+    typeId INTEGER references triviaQuestionType(id) NOT NULL,
+    categoryId INTEGER references triviaQuestionCategory(id) NOT NULL,
+    mappingKey TEXT NOT NULL,
+    map TEXT NOT NULL DEFAULT '',
+    question TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    explainer TEXT NOT NULL DEFAULT '',
+    distractors INTEGER NOT NULL DEFAULT 3,
+    isActive BOOLEAN NOT NULL DEFAULT TRUE,
+    lastUpdated TIMESTAMP NOT NULL
+);
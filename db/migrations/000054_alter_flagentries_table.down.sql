@@ -0,0 +1 @@
+ALTER TABLE flagEntries DROP COLUMN IF EXISTS assetKey;
@@ -0,0 +1 @@
+ALTER TABLE flagEntries ADD COLUMN assetKey TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+CREATE TABLE media (
+    id SERIAL PRIMARY KEY,
+    key TEXT UNIQUE NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    contentType TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    attributionName TEXT NOT NULL DEFAULT '',
+    attributionUrl TEXT NOT NULL DEFAULT '',
+    userId INTEGER references users(id) ON DELETE SET NULL,
+    added TIMESTAMP NOT NULL
+);
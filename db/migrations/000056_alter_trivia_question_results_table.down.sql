@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS triviaQuestionResults_userTriviaPlayId_triviaQuestionId_idx;
+ALTER TABLE triviaQuestionResults DROP column userTriviaPlayId;
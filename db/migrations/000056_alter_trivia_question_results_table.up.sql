@@ -0,0 +1,3 @@
+ALTER TABLE triviaQuestionResults ADD column userTriviaPlayId INTEGER references userTriviaPlays(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX triviaQuestionResults_userTriviaPlayId_triviaQuestionId_idx ON triviaQuestionResults(userTriviaPlayId, triviaQuestionId);
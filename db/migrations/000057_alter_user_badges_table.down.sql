@@ -0,0 +1 @@
+ALTER TABLE userBadges DROP column total;
@@ -0,0 +1 @@
+ALTER TABLE userBadges ADD column total INTEGER;
@@ -17,7 +17,6 @@ require (
 	github.com/rs/cors v1.9.0
 	github.com/sendgrid/rest v2.6.9+incompatible
 	github.com/sendgrid/sendgrid-go v3.12.0+incompatible
-	github.com/stripe/stripe-go v70.15.0+incompatible
 	github.com/stripe/stripe-go/v72 v72.122.0
 	golang.org/x/crypto v0.11.0
 	golang.org/x/text v0.11.0
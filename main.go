@@ -68,12 +68,31 @@ func main() {
 	ts := utils.NewTranslationService()
 	es := utils.NewEmailService()
 	vs := utils.NewValidationService()
-	server := src.NewServer(ts, es, vs)
+	ps := utils.NewPricingService()
+	pp := utils.NewStripePaymentProvider(os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET"))
+	ms := newMediaStore()
+	server := src.NewServer(ts, es, vs, ps, pp, ms)
 	fmt.Println("successfully initialized server")
 
 	log.Fatal(server.Start())
 }
 
+// newMediaStore keeps uploads in an S3 compatible bucket when BLOB_STORE is
+// s3, and on disk otherwise.
+func newMediaStore() utils.IMediaStore {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return utils.NewS3MediaStore(utils.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("BLOB_STORE_URL"),
+		})
+	}
+	return utils.NewLocalBlobStore(os.Getenv("BLOB_STORE_PATH"), os.Getenv("BLOB_STORE_URL"))
+}
+
 var loadConfig = func() error {
 	return godotenv.Load()
 }
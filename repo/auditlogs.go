@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AUDIT_ACTION_CREATE = "create"
+	AUDIT_ACTION_UPDATE = "update"
+	AUDIT_ACTION_DELETE = "delete"
+)
+
+const (
+	AUDIT_ENTITY_MERCH       = "merch"
+	AUDIT_ENTITY_MERCH_SIZE  = "merchSize"
+	AUDIT_ENTITY_MERCH_IMAGE = "merchImage"
+	AUDIT_ENTITY_DISCOUNT    = "discount"
+	AUDIT_ENTITY_MAP         = "map"
+)
+
+type AuditLog struct {
+	ID       int           `json:"id"`
+	UserID   sql.NullInt64 `json:"userId"`
+	Action   string        `json:"action"`
+	Entity   string        `json:"entity"`
+	EntityID int           `json:"entityId"`
+	Details  string        `json:"details"`
+	Added    time.Time     `json:"added"`
+}
+
+type GetAuditLogsFilterParams struct {
+	Entity   string `json:"entity"`
+	EntityID int    `json:"entityId"`
+	Page     int    `json:"page"`
+	Limit    int    `json:"limit"`
+}
+
+var GetAuditLogs = func(filterParams GetAuditLogsFilterParams) ([]AuditLog, error) {
+	statement := "SELECT id, userId, action, entity, entityId, details, added FROM auditLogs WHERE ($1 = '' OR entity = $1) AND ($2 = 0 OR entityId = $2) ORDER BY added DESC, id DESC LIMIT $3 OFFSET $4;"
+	rows, err := Connection.Query(statement, filterParams.Entity, filterParams.EntityID, filterParams.Limit, filterParams.Page*filterParams.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs = []AuditLog{}
+	for rows.Next() {
+		var log AuditLog
+		if err = rows.Scan(&log.ID, &log.UserID, &log.Action, &log.Entity, &log.EntityID, &log.Details, &log.Added); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+var GetFirstAuditLogID = func(filterParams GetAuditLogsFilterParams) (int, error) {
+	statement := "SELECT id FROM auditLogs WHERE ($1 = '' OR entity = $1) AND ($2 = 0 OR entityId = $2) ORDER BY added DESC, id DESC LIMIT 1 OFFSET $3;"
+	var id int
+	err := Connection.QueryRow(statement, filterParams.Entity, filterParams.EntityID, (filterParams.Page+1)*filterParams.Limit).Scan(&id)
+	return id, err
+}
+
+// insertAuditLog records an admin change alongside the change itself, so it
+// should be called with the same transaction.
+func insertAuditLog(db executor, userID int, action, entity string, entityID int, details interface{}) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	statement := "INSERT INTO auditLogs (userId, action, entity, entityId, details, added) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
+	var id int
+	return db.QueryRow(statement, userID, action, entity, entityID, string(encoded), time.Now()).Scan(&id)
+}
+
+// IsUniqueViolation reports whether err was caused by a unique constraint.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// IsForeignKeyViolation reports whether err was caused by a row still being
+// referenced elsewhere, e.g. merch that has been ordered.
+func IsForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
@@ -0,0 +1,376 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	BADGE_EVENT_LEADERBOARD_SUBMIT  = "leaderboard_submit"
+	BADGE_EVENT_TRIVIA_PLAY         = "trivia_play"
+	BADGE_EVENT_QUIZ_PUBLISH        = "quiz_publish"
+	BADGE_EVENT_XP_UPDATE           = "xp_update"
+	BADGE_EVENT_COMMUNITY_QUIZ_PLAY = "community_quiz_play"
+)
+
+const (
+	BADGE_METRIC_LEADERBOARD_ENTRIES       = "leaderboard_entries"
+	BADGE_METRIC_BADGE_QUIZZES_COMPLETED   = "badge_quizzes_completed"
+	BADGE_METRIC_TOP_TEN_ENTRIES           = "top_ten_entries"
+	BADGE_METRIC_COMMUNITY_QUIZZES_CREATED = "community_quizzes_created"
+	BADGE_METRIC_COMMUNITY_QUIZ_PLAYS      = "community_quiz_plays"
+	BADGE_METRIC_XP                        = "xp"
+	BADGE_METRIC_TRIVIA_PLAYS              = "trivia_plays"
+	BADGE_METRIC_TRIVIA_PERFECT_SCORES     = "trivia_perfect_scores"
+	BADGE_METRIC_TRIVIA_STREAK             = "trivia_streak"
+)
+
+type BadgeRule struct {
+	ID        int           `json:"id"`
+	BadgeID   int           `json:"badgeId"`
+	Metric    string        `json:"metric"`
+	Threshold sql.NullInt64 `json:"threshold"`
+}
+
+// badgeMetric describes how to measure a user against a rule. The target is
+// only used when the rule does not define its own threshold.
+type badgeMetric struct {
+	events   []string
+	progress func(stats *userBadgeStats, badge Badge) (int, error)
+	target   func(quizzes badgeQuizCounts, badge Badge) int
+}
+
+// badgeQuizCounts holds how many quizzes each badge and continent has, loaded
+// in one query rather than one per badge.
+type badgeQuizCounts struct {
+	badges     map[int]int
+	continents map[int]int
+}
+
+var badgeMetrics = map[string]badgeMetric{
+	BADGE_METRIC_LEADERBOARD_ENTRIES: {
+		events: []string{BADGE_EVENT_LEADERBOARD_SUBMIT},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			entries, err := stats.leaderboardEntries()
+			return len(entries), err
+		},
+		target: fixedBadgeTarget(1),
+	},
+	BADGE_METRIC_BADGE_QUIZZES_COMPLETED: {
+		events: []string{BADGE_EVENT_LEADERBOARD_SUBMIT},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			entries, err := stats.leaderboardEntries()
+			if err != nil {
+				return 0, err
+			}
+
+			var count int
+			for _, val := range entries {
+				if val.BadgeID == badge.ID {
+					count = count + 1
+				}
+			}
+			return count, nil
+		},
+		target: func(quizzes badgeQuizCounts, badge Badge) int {
+			if badge.ContinentID.Valid {
+				return quizzes.continents[int(badge.ContinentID.Int64)]
+			}
+			return quizzes.badges[badge.ID]
+		},
+	},
+	BADGE_METRIC_TOP_TEN_ENTRIES: {
+		events: []string{BADGE_EVENT_LEADERBOARD_SUBMIT},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			entries, err := stats.leaderboardEntries()
+			if err != nil {
+				return 0, err
+			}
+
+			var count int
+			for _, val := range entries {
+				if val.Rank <= 10 {
+					count = count + 1
+				}
+			}
+			return count, nil
+		},
+		target: fixedBadgeTarget(1),
+	},
+	BADGE_METRIC_COMMUNITY_QUIZZES_CREATED: {
+		events: []string{BADGE_EVENT_QUIZ_PUBLISH},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			count, err := GetUserCommunityQuizCount(stats.userID)
+			if err == sql.ErrNoRows {
+				return 0, nil
+			}
+			return count, err
+		},
+		target: fixedBadgeTarget(1),
+	},
+	BADGE_METRIC_COMMUNITY_QUIZ_PLAYS: {
+		events: []string{BADGE_EVENT_COMMUNITY_QUIZ_PLAY},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			var plays int
+			err := Connection.QueryRow("SELECT COALESCE(SUM(p.plays), 0) FROM communityquizplays p JOIN communityquizzes q ON q.id = p.communityQuizId WHERE q.userId = $1;", stats.userID).Scan(&plays)
+			return plays, err
+		},
+		target: fixedBadgeTarget(100),
+	},
+	BADGE_METRIC_XP: {
+		events: []string{BADGE_EVENT_XP_UPDATE},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			var xp int
+			err := Connection.QueryRow("SELECT xp FROM users WHERE id = $1;", stats.userID).Scan(&xp)
+			return xp, err
+		},
+		target: fixedBadgeTarget(1000),
+	},
+	BADGE_METRIC_TRIVIA_PLAYS: {
+		events: []string{BADGE_EVENT_TRIVIA_PLAY},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			plays, _, err := getUserTriviaPlayCounts(stats.userID)
+			return plays, err
+		},
+		target: fixedBadgeTarget(30),
+	},
+	BADGE_METRIC_TRIVIA_PERFECT_SCORES: {
+		events: []string{BADGE_EVENT_TRIVIA_PLAY},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			_, perfect, err := getUserTriviaPlayCounts(stats.userID)
+			return perfect, err
+		},
+		target: fixedBadgeTarget(1),
+	},
+	BADGE_METRIC_TRIVIA_STREAK: {
+		events: []string{BADGE_EVENT_TRIVIA_PLAY},
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) {
+			streak, err := GetUserTriviaStreak(stats.userID)
+			return streak.Longest, err
+		},
+		target: fixedBadgeTarget(7),
+	},
+}
+
+func fixedBadgeTarget(target int) func(quizzes badgeQuizCounts, badge Badge) int {
+	return func(quizzes badgeQuizCounts, badge Badge) int {
+		return target
+	}
+}
+
+func getBadgeQuizCounts() (badgeQuizCounts, error) {
+	quizzes := badgeQuizCounts{badges: make(map[int]int), continents: make(map[int]int)}
+	rows, err := Connection.Query("SELECT badgeId, continentId, COUNT(id) FROM quizzes GROUP BY badgeId, continentId;")
+	if err != nil {
+		return quizzes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var badgeID, continentID sql.NullInt64
+		var count int
+		if err = rows.Scan(&badgeID, &continentID, &count); err != nil {
+			return quizzes, err
+		}
+
+		if badgeID.Valid {
+			quizzes.badges[int(badgeID.Int64)] += count
+		}
+
+		if continentID.Valid {
+			quizzes.continents[int(continentID.Int64)] += count
+		}
+	}
+	return quizzes, rows.Err()
+}
+
+// userBadgeStats lazily loads the data shared between metrics so that each
+// query runs at most once per evaluation.
+type userBadgeStats struct {
+	userID  int
+	entries []UserLeaderboardEntryDto
+}
+
+func (s *userBadgeStats) leaderboardEntries() ([]UserLeaderboardEntryDto, error) {
+	if s.entries != nil {
+		return s.entries, nil
+	}
+
+	entries, err := GetUserLeaderboardEntries(s.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.entries = entries
+	return entries, nil
+}
+
+var EvaluateUserBadges = func(userID int, event string) error {
+	badges, err := getAllBadges()
+	if err != nil {
+		return err
+	}
+
+	rules, err := getBadgeRules()
+	if err != nil {
+		return err
+	}
+
+	quizzes, err := getBadgeQuizCounts()
+	if err != nil {
+		return err
+	}
+
+	return evaluateUserBadges(userID, event, badges, rules, quizzes)
+}
+
+func BackfillUserBadges() (int, error) {
+	badges, err := getAllBadges()
+	if err != nil {
+		return 0, err
+	}
+
+	rules, err := getBadgeRules()
+	if err != nil {
+		return 0, err
+	}
+
+	quizzes, err := getBadgeQuizCounts()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := Connection.Query("SELECT id FROM users ORDER BY id;")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var userIDs = []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		if err := evaluateUserBadges(userID, "", badges, rules, quizzes); err != nil {
+			return 0, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// evaluateUserBadges stores the progress and total for each badge the event
+// affects, so listing a user's badges doesn't have to work them out again.
+func evaluateUserBadges(userID int, event string, badges []Badge, rules map[int][]BadgeRule, quizzes badgeQuizCounts) error {
+	stats := &userBadgeStats{userID: userID}
+	for _, badge := range badges {
+		badgeRules := rules[badge.ID]
+		if len(badgeRules) == 0 || !badgeRulesTriggeredBy(badgeRules, event) {
+			continue
+		}
+
+		progress, total, err := evaluateBadge(stats, badge, badgeRules, quizzes)
+		if err != nil {
+			return err
+		}
+
+		var awardedAt sql.NullTime
+		if total > 0 && progress >= total {
+			awardedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		statement := "INSERT INTO userBadges (userId, badgeId, progress, total, awardedAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (userId, badgeId) DO UPDATE SET progress = EXCLUDED.progress, total = EXCLUDED.total, awardedAt = COALESCE(userBadges.awardedAt, EXCLUDED.awardedAt) RETURNING id;"
+		var id int
+		if err := Connection.QueryRow(statement, userID, badge.ID, progress, total, awardedAt).Scan(&id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateBadge requires every rule of a badge to be met. Progress for each
+// rule is capped at its target so one rule can't make up for another.
+func evaluateBadge(stats *userBadgeStats, badge Badge, rules []BadgeRule, quizzes badgeQuizCounts) (int, int, error) {
+	var progress, total int
+	for _, rule := range rules {
+		metric, found := badgeMetrics[rule.Metric]
+		if !found {
+			return 0, 0, fmt.Errorf("unknown badge metric %s", rule.Metric)
+		}
+
+		target := getRuleTarget(metric, rule, badge, quizzes)
+		value, err := metric.progress(stats, badge)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if value > target {
+			value = target
+		}
+
+		progress = progress + value
+		total = total + target
+	}
+	return progress, total, nil
+}
+
+func getBadgeTotal(badge Badge, rules []BadgeRule, quizzes badgeQuizCounts) (int, error) {
+	var total int
+	for _, rule := range rules {
+		metric, found := badgeMetrics[rule.Metric]
+		if !found {
+			return 0, fmt.Errorf("unknown badge metric %s", rule.Metric)
+		}
+		total = total + getRuleTarget(metric, rule, badge, quizzes)
+	}
+	return total, nil
+}
+
+func getRuleTarget(metric badgeMetric, rule BadgeRule, badge Badge, quizzes badgeQuizCounts) int {
+	if rule.Threshold.Valid {
+		return int(rule.Threshold.Int64)
+	}
+	return metric.target(quizzes, badge)
+}
+
+func badgeRulesTriggeredBy(rules []BadgeRule, event string) bool {
+	if event == "" {
+		return true
+	}
+
+	for _, rule := range rules {
+		for _, val := range badgeMetrics[rule.Metric].events {
+			if val == event {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getBadgeRules() (map[int][]BadgeRule, error) {
+	rows, err := Connection.Query("SELECT id, badgeId, metric, threshold FROM badgeRules ORDER BY id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[int][]BadgeRule)
+	for rows.Next() {
+		var rule BadgeRule
+		if err = rows.Scan(&rule.ID, &rule.BadgeID, &rule.Metric, &rule.Threshold); err != nil {
+			return nil, err
+		}
+		rules[rule.BadgeID] = append(rules[rule.BadgeID], rule)
+	}
+	return rules, rows.Err()
+}
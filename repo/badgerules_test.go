@@ -0,0 +1,266 @@
+package repo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestEvaluateBadge(t *testing.T) {
+	savedBadgeMetrics := badgeMetrics
+	defer func() { badgeMetrics = savedBadgeMetrics }()
+
+	badgeMetrics = map[string]badgeMetric{}
+	for metric, val := range savedBadgeMetrics {
+		badgeMetrics[metric] = val
+	}
+	badgeMetrics["failing"] = badgeMetric{
+		progress: func(stats *userBadgeStats, badge Badge) (int, error) { return 0, errors.New("test") },
+		target:   fixedBadgeTarget(1),
+	}
+
+	stats := func() *userBadgeStats {
+		return &userBadgeStats{
+			userID: 1,
+			entries: []UserLeaderboardEntryDto{
+				{QuizID: 1, BadgeID: 2, Rank: 3},
+				{QuizID: 2, BadgeID: 2, Rank: 40},
+				{QuizID: 3, BadgeID: 5, Rank: 1},
+			},
+		}
+	}
+
+	tt := []struct {
+		name     string
+		badge    Badge
+		rules    []BadgeRule
+		progress int
+		total    int
+		err      bool
+	}{
+		{
+			name:     "default target",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_LEADERBOARD_ENTRIES}},
+			progress: 1,
+			total:    1,
+		},
+		{
+			name:     "threshold overrides target",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_TOP_TEN_ENTRIES, Threshold: sql.NullInt64{Int64: 5, Valid: true}}},
+			progress: 2,
+			total:    5,
+		},
+		{
+			name:     "progress only counts the badge's quizzes",
+			badge:    Badge{ID: 2},
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_BADGE_QUIZZES_COMPLETED, Threshold: sql.NullInt64{Int64: 4, Valid: true}}},
+			progress: 2,
+			total:    4,
+		},
+		{
+			name: "progress capped per rule",
+			rules: []BadgeRule{
+				{Metric: BADGE_METRIC_LEADERBOARD_ENTRIES, Threshold: sql.NullInt64{Int64: 2, Valid: true}},
+				{Metric: BADGE_METRIC_TOP_TEN_ENTRIES, Threshold: sql.NullInt64{Int64: 3, Valid: true}},
+			},
+			progress: 4,
+			total:    5,
+		},
+		{
+			name:  "unknown metric",
+			rules: []BadgeRule{{Metric: "unknown"}},
+			err:   true,
+		},
+		{
+			name:  "error on progress",
+			rules: []BadgeRule{{Metric: BADGE_METRIC_LEADERBOARD_ENTRIES}, {Metric: "failing"}},
+			err:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			progress, total, err := evaluateBadge(stats(), tc.badge, tc.rules, badgeQuizCounts{})
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error; got %v/%v", progress, total)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			if progress != tc.progress || total != tc.total {
+				t.Errorf("expected %v/%v; got %v/%v", tc.progress, tc.total, progress, total)
+			}
+		})
+	}
+}
+
+func TestGetBadgeTotal(t *testing.T) {
+	tt := []struct {
+		name  string
+		rules []BadgeRule
+		total int
+		err   bool
+	}{
+		{
+			name:  "no rules",
+			total: 0,
+		},
+		{
+			name:  "default targets",
+			rules: []BadgeRule{{Metric: BADGE_METRIC_XP}, {Metric: BADGE_METRIC_TRIVIA_PLAYS}},
+			total: 1030,
+		},
+		{
+			name:  "thresholds",
+			rules: []BadgeRule{{Metric: BADGE_METRIC_XP, Threshold: sql.NullInt64{Int64: 50, Valid: true}}, {Metric: BADGE_METRIC_TRIVIA_STREAK}},
+			total: 57,
+		},
+		{
+			name:  "unknown metric",
+			rules: []BadgeRule{{Metric: BADGE_METRIC_XP}, {Metric: "unknown"}},
+			err:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			total, err := getBadgeTotal(Badge{ID: 1}, tc.rules, badgeQuizCounts{})
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error; got %v", total)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			if total != tc.total {
+				t.Errorf("expected %v; got %v", tc.total, total)
+			}
+		})
+	}
+}
+
+func TestBadgeRulesTriggeredBy(t *testing.T) {
+	tt := []struct {
+		name     string
+		rules    []BadgeRule
+		event    string
+		expected bool
+	}{
+		{
+			name:     "backfill triggers everything",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_XP}},
+			event:    "",
+			expected: true,
+		},
+		{
+			name:     "matching event",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_XP}},
+			event:    BADGE_EVENT_XP_UPDATE,
+			expected: true,
+		},
+		{
+			name:     "any rule matching",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_XP}, {Metric: BADGE_METRIC_TRIVIA_PLAYS}},
+			event:    BADGE_EVENT_TRIVIA_PLAY,
+			expected: true,
+		},
+		{
+			name:     "other event",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_TRIVIA_STREAK}},
+			event:    BADGE_EVENT_LEADERBOARD_SUBMIT,
+			expected: false,
+		},
+		{
+			name:     "community quiz plays only on plays",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_COMMUNITY_QUIZ_PLAYS}},
+			event:    BADGE_EVENT_XP_UPDATE,
+			expected: false,
+		},
+		{
+			name:     "community quiz play",
+			rules:    []BadgeRule{{Metric: BADGE_METRIC_COMMUNITY_QUIZ_PLAYS}},
+			event:    BADGE_EVENT_COMMUNITY_QUIZ_PLAY,
+			expected: true,
+		},
+		{
+			name:     "unknown metric",
+			rules:    []BadgeRule{{Metric: "unknown"}},
+			event:    BADGE_EVENT_XP_UPDATE,
+			expected: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := badgeRulesTriggeredBy(tc.rules, tc.event); result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetUserBadges(t *testing.T) {
+	recorder := useRecordingConnection(t)
+
+	badgeColumns := []string{"id", "typeId", "continentId", "name", "description", "imageUrl", "background", "border", "progress", "total", "awardedAt"}
+	recorder.queueRows(badgeColumns,
+		[]driver.Value{int64(1), int64(1), nil, "Stored", "", "", "", "", int64(9), int64(4), nil},
+		[]driver.Value{int64(2), int64(2), int64(3), "Oceania", "", "", "", "", int64(0), nil, nil},
+		[]driver.Value{int64(3), int64(2), int64(5), "Europe", "", "", "", "", int64(0), nil, nil},
+	)
+	recorder.queueRows([]string{"id", "badgeId", "metric", "threshold"},
+		[]driver.Value{int64(1), int64(2), BADGE_METRIC_BADGE_QUIZZES_COMPLETED, nil},
+		[]driver.Value{int64(2), int64(3), BADGE_METRIC_BADGE_QUIZZES_COMPLETED, nil},
+	)
+	recorder.queueRows([]string{"badgeId", "continentId", "count"},
+		[]driver.Value{nil, int64(3), int64(6)},
+		[]driver.Value{int64(2), int64(3), int64(2)},
+		[]driver.Value{nil, int64(5), int64(12)},
+	)
+
+	badges, err := GetUserBadges(1)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if len(recorder.statements) != 3 {
+		t.Errorf("expected badges, rules and quiz counts to be loaded once each; got %v", recorder.statements)
+	}
+
+	expected := []struct{ progress, total int }{{4, 4}, {0, 8}, {0, 12}}
+	for index, badge := range badges {
+		if badge.Progress != expected[index].progress || badge.Total != expected[index].total {
+			t.Errorf("expected badge %d at %v/%v; got %v/%v", badge.ID, expected[index].progress, expected[index].total, badge.Progress, badge.Total)
+		}
+	}
+}
+
+func TestGetUserBadgesStoredTotals(t *testing.T) {
+	recorder := useRecordingConnection(t)
+	recorder.queueRows([]string{"id", "typeId", "continentId", "name", "description", "imageUrl", "background", "border", "progress", "total", "awardedAt"},
+		[]driver.Value{int64(1), int64(1), nil, "Stored", "", "", "", "", int64(2), int64(4), nil},
+	)
+
+	badges, err := GetUserBadges(1)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if len(recorder.statements) != 1 {
+		t.Errorf("expected only the user's badges to be loaded; got %v", recorder.statements)
+	}
+
+	if len(badges) != 1 || badges[0].Progress != 2 || badges[0].Total != 4 {
+		t.Errorf("expected 2/4; got %v", badges)
+	}
+}
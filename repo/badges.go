@@ -2,7 +2,6 @@ package repo
 
 import (
 	"database/sql"
-	"errors"
 )
 
 type Badge struct {
@@ -17,14 +16,15 @@ type Badge struct {
 }
 
 type BadgeDto struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	ImageUrl    string `json:"imageUrl"`
-	Background  string `json:"background"`
-	Border      string `json:"border"`
-	Progress    int    `json:"progress"`
-	Total       int    `json:"total"`
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	ImageUrl    string       `json:"imageUrl"`
+	Background  string       `json:"background"`
+	Border      string       `json:"border"`
+	Progress    int          `json:"progress"`
+	Total       int          `json:"total"`
+	AwardedAt   sql.NullTime `json:"awardedAt"`
 }
 
 type CreateQuizBadgeDto struct {
@@ -50,85 +50,89 @@ func GetBadges() ([]CreateQuizBadgeDto, error) {
 	return badges, rows.Err()
 }
 
+// GetUserBadges uses the totals stored when the user's badges were last
+// evaluated. Only badges the user hasn't been evaluated for yet have their
+// total worked out here.
 var GetUserBadges = func(userId int) ([]BadgeDto, error) {
-	leaderboardEntries, err := GetUserLeaderboardEntries(userId)
+	badges, dtos, totals, err := getUserBadgeRows(userId)
 	if err != nil {
 		return nil, err
 	}
 
-	communityQuizCount, err := GetUserCommunityQuizCount(userId)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
+	var rules map[int][]BadgeRule
+	var quizzes badgeQuizCounts
+	for index, badge := range badges {
+		dto := &dtos[index]
+		if totals[index].Valid {
+			dto.Total = int(totals[index].Int64)
+		} else {
+			if rules == nil {
+				if rules, err = getBadgeRules(); err != nil {
+					return nil, err
+				}
+
+				if quizzes, err = getBadgeQuizCounts(); err != nil {
+					return nil, err
+				}
+			}
+
+			if dto.Total, err = getBadgeTotal(badge, rules[badge.ID], quizzes); err != nil {
+				return nil, err
+			}
+		}
+
+		if dto.AwardedAt.Valid || dto.Progress > dto.Total {
+			dto.Progress = dto.Total
+		}
 	}
+	return dtos, nil
+}
 
-	rows, err := Connection.Query("SELECT * FROM badges;")
+func getUserBadgeRows(userId int) ([]Badge, []BadgeDto, []sql.NullInt64, error) {
+	rows, err := Connection.Query("SELECT b.id, b.typeId, b.continentId, b.name, b.description, b.imageUrl, b.background, b.border, COALESCE(u.progress, 0), u.total, u.awardedAt FROM badges b LEFT JOIN userBadges u ON u.badgeId = b.id AND u.userId = $1 ORDER BY b.id;", userId)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	defer rows.Close()
 
-	var badges = []BadgeDto{}
+	var badges = []Badge{}
+	var dtos = []BadgeDto{}
+	var totals = []sql.NullInt64{}
 	for rows.Next() {
 		var badge Badge
-		if err = rows.Scan(&badge.ID, &badge.TypeID, &badge.ContinentID, &badge.Name, &badge.Description, &badge.ImageUrl, &badge.Background, &badge.Border); err != nil {
-			return nil, err
-		}
-
-		total, err := getTotal(badge.TypeID, badge.ID, badge.ContinentID)
-		if err != nil {
-			return nil, err
-		}
-
-		dto := BadgeDto{
-			ID:          badge.ID,
-			Name:        badge.Name,
-			Description: badge.Description,
-			ImageUrl:    badge.ImageUrl,
-			Background:  badge.Background,
-			Border:      badge.Border,
-			Progress:    getProgress(leaderboardEntries, badge.ID, badge.TypeID, communityQuizCount),
-			Total:       total,
+		var dto BadgeDto
+		var total sql.NullInt64
+		if err = rows.Scan(&badge.ID, &badge.TypeID, &badge.ContinentID, &badge.Name, &badge.Description, &badge.ImageUrl, &badge.Background, &badge.Border, &dto.Progress, &total, &dto.AwardedAt); err != nil {
+			return nil, nil, nil, err
 		}
 
-		badges = append(badges, dto)
+		dto.ID = badge.ID
+		dto.Name = badge.Name
+		dto.Description = badge.Description
+		dto.ImageUrl = badge.ImageUrl
+		dto.Background = badge.Background
+		dto.Border = badge.Border
+		badges = append(badges, badge)
+		dtos = append(dtos, dto)
+		totals = append(totals, total)
 	}
-	return badges, rows.Err()
+	return badges, dtos, totals, rows.Err()
 }
 
-func getTotal(typeID, badgeID int, continentID sql.NullInt64) (int, error) {
-	switch typeID {
-	case BADGE_TYPE_LEADERBOARD_SUBMIT, BADGE_TYPE_COMMUNITY_QUIZ:
-		return 1, nil
-	case BADGE_TYPE_WORLD:
-		return getWorldQuizCount(badgeID)
-	case BADGE_TYPE_CONTINENT:
-		return getContinentQuizCount(int(continentID.Int64))
-	default:
-		return 0, errors.New("invalid type id passed to getTotal")
+func getAllBadges() ([]Badge, error) {
+	rows, err := Connection.Query("SELECT * FROM badges;")
+	if err != nil {
+		return nil, err
 	}
-}
+	defer rows.Close()
 
-func getProgress(entries []UserLeaderboardEntryDto, badgeId, typeID, communityQuizCount int) int {
-	switch typeID {
-	case BADGE_TYPE_LEADERBOARD_SUBMIT:
-		if len(entries) > 0 {
-			return 1
-		}
-		return 0
-	case BADGE_TYPE_WORLD, BADGE_TYPE_CONTINENT:
-		var count int
-		for _, val := range entries {
-			if val.BadgeID == badgeId {
-				count = count + 1
-			}
-		}
-		return count
-	case BADGE_TYPE_COMMUNITY_QUIZ:
-		if communityQuizCount > 0 {
-			return 1
+	var badges = []Badge{}
+	for rows.Next() {
+		var badge Badge
+		if err = rows.Scan(&badge.ID, &badge.TypeID, &badge.ContinentID, &badge.Name, &badge.Description, &badge.ImageUrl, &badge.Background, &badge.Border); err != nil {
+			return nil, err
 		}
-		return 0
-	default:
-		return 0
+		badges = append(badges, badge)
 	}
+	return badges, rows.Err()
 }
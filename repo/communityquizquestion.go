@@ -76,6 +76,10 @@ type UpdateCommunityQuizQuestionDto struct {
 }
 
 func InsertCommunityQuizQuestion(quizID int, question CreateCommunityQuizQuestionDto) (int, error) {
+	if err := fillImageDetails(Connection, question.ImageUrl, &question.ImageWidth, &question.ImageHeight, &question.ImageAttributeName, &question.ImageAttributeURL); err != nil {
+		return 0, err
+	}
+
 	statement := "INSERT INTO communityquizquestions (communityquizid, typeid, question, map, highlighted, flagcode, imageurl, imageAttributeName, imageAttributeUrl, imageWidth, imageHeight, imageAlt, explainer) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id;"
 	var id int
 	err := Connection.QueryRow(statement, quizID, question.TypeID, question.Question, question.Map, question.Highlighted, question.FlagCode, question.ImageUrl, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.Explainer).Scan(&id)
@@ -83,6 +87,10 @@ func InsertCommunityQuizQuestion(quizID int, question CreateCommunityQuizQuestio
 }
 
 func UpdateCommunityQuizQuestion(questionID int, question UpdateCommunityQuizQuestionDto) error {
+	if err := fillImageDetails(Connection, question.ImageUrl, &question.ImageWidth, &question.ImageHeight, &question.ImageAttributeName, &question.ImageAttributeURL); err != nil {
+		return err
+	}
+
 	statement := "UPDATE communityquizquestions SET typeid = $1, question = $2, map = $3, highlighted = $4, flagcode = $5, imageurl = $6, imageAttributeName = $7, imageAttributeUrl = $8, imageWidth = $9, imageHeight = $10, imageAlt = $11, explainer = $12 WHERE id = $13 RETURNING id;"
 	var id int
 	return Connection.QueryRow(statement, question.TypeID, question.Question, question.Map, question.Highlighted, question.FlagCode, question.ImageUrl, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.Explainer, questionID).Scan(&id)
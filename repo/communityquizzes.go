@@ -233,3 +233,9 @@ func GetUserCommunityQuizCount(userID int) (int, error) {
 	err := Connection.QueryRow("SELECT COUNT(id) FROM communityquizzes WHERE userid = $1;", userID).Scan(&count)
 	return count, err
 }
+
+var GetCommunityQuizUserID = func(quizID int) (int, error) {
+	var userID int
+	err := Connection.QueryRow("SELECT userId FROM communityquizzes WHERE id = $1;", quizID).Scan(&userID)
+	return userID, err
+}
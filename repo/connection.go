@@ -7,6 +7,12 @@ import (
 
 var Connection *sql.DB
 
+type executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 var OpenConnection = func() error {
 	connection, err := sql.Open("postgres", os.Getenv("CONNECTION_STRING"))
 	if err != nil {
@@ -16,3 +22,16 @@ var OpenConnection = func() error {
 	Connection = connection
 	return Connection.Ping()
 }
+
+func withTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := Connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recordedStatement is a statement run against the recording database.
+type recordedStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// recordedResult is a set of rows for the recording database to return.
+type recordedResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// recordingDriver stands in for postgres, recording every statement. Queries
+// return the queued results in order, then a single row with an incrementing
+// id, which is enough for inserts that return their id.
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []recordedStatement
+	nextID     int64
+	committed  bool
+	results    []recordedResult
+}
+
+var recordingDriverCount int32
+
+// useRecordingConnection points Connection at a new recording database for
+// the rest of the test.
+func useRecordingConnection(t *testing.T) *recordingDriver {
+	name := fmt.Sprintf("recording-%d", atomic.AddInt32(&recordingDriverCount, 1))
+	recorder := &recordingDriver{}
+	sql.Register(name, recorder)
+
+	connection, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("could not open recording connection: %v", err)
+	}
+
+	saved := Connection
+	Connection = connection
+	t.Cleanup(func() {
+		Connection = saved
+		connection.Close()
+	})
+	return recorder
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{driver: d}, nil
+}
+
+type recordingConn struct {
+	driver *recordingDriver
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return &recordingTx{driver: c.driver}, nil
+}
+
+type recordingTx struct {
+	driver *recordingDriver
+}
+
+func (tx *recordingTx) Commit() error {
+	tx.driver.mu.Lock()
+	defer tx.driver.mu.Unlock()
+	tx.driver.committed = true
+	return nil
+}
+
+func (tx *recordingTx) Rollback() error {
+	return errors.New("recording transactions can't be rolled back")
+}
+
+type recordingStmt struct {
+	driver *recordingDriver
+	query  string
+}
+
+func (s *recordingStmt) Close() error {
+	return nil
+}
+
+func (s *recordingStmt) NumInput() int {
+	return -1
+}
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.record(s.query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	id := s.driver.record(s.query, args)
+	if result, found := s.driver.nextResult(); found {
+		return &recordingRows{columns: result.columns, values: result.rows}, nil
+	}
+	return &recordingRows{columns: []string{"id"}, values: [][]driver.Value{{id}}}, nil
+}
+
+// queueRows sets the rows returned by the next query that has nothing queued.
+func (d *recordingDriver) queueRows(columns []string, rows ...[]driver.Value) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results = append(d.results, recordedResult{columns: columns, rows: rows})
+}
+
+func (d *recordingDriver) nextResult() (recordedResult, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.results) == 0 {
+		return recordedResult{}, false
+	}
+	result := d.results[0]
+	d.results = d.results[1:]
+	return result, true
+}
+
+func (d *recordingDriver) record(query string, args []driver.Value) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	d.statements = append(d.statements, recordedStatement{query: query, args: args})
+	return d.nextID
+}
+
+type recordingRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *recordingRows) Columns() []string {
+	return r.columns
+}
+
+func (r *recordingRows) Close() error {
+	return nil
+}
+
+func (r *recordingRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/geobuff/api/utils"
+)
+
+var GetCurrencies = func() ([]utils.Currency, error) {
+	rows, err := Connection.Query("SELECT code, rate, roundingIncrement, priceEnding FROM currencies ORDER BY code;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var currencies = []utils.Currency{}
+	for rows.Next() {
+		var currency utils.Currency
+		if err = rows.Scan(&currency.Code, &currency.Rate, &currency.RoundingIncrement, &currency.PriceEnding); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, rows.Err()
+}
+
+var GetCurrency = func(code string) (utils.Currency, error) {
+	statement := "SELECT code, rate, roundingIncrement, priceEnding FROM currencies WHERE code = $1;"
+	var currency utils.Currency
+	err := Connection.QueryRow(statement, strings.ToUpper(code)).Scan(&currency.Code, &currency.Rate, &currency.RoundingIncrement, &currency.PriceEnding)
+	return currency, err
+}
+
+// GetCountryCurrency returns the currency customers in the country pay in,
+// falling back to the base currency for countries without one.
+var GetCountryCurrency = func(countryCode string) (utils.Currency, error) {
+	statement := "SELECT c.code, c.rate, c.roundingIncrement, c.priceEnding FROM currencyCountries cc JOIN currencies c ON c.code = cc.currencyCode WHERE cc.countryCode = $1;"
+	var currency utils.Currency
+	err := Connection.QueryRow(statement, strings.ToLower(countryCode)).Scan(&currency.Code, &currency.Rate, &currency.RoundingIncrement, &currency.PriceEnding)
+	if err == sql.ErrNoRows {
+		return GetCurrency(utils.BASE_CURRENCY)
+	}
+	return currency, err
+}
+
+// GetMerchPrices returns the prices set explicitly for the currency, keyed by
+// merch id. Merch without one is converted from its base price.
+var GetMerchPrices = func(currencyCode string) (map[int]float64, error) {
+	rows, err := Connection.Query("SELECT merchId, price FROM merchPrices WHERE currencyCode = $1;", currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[int]float64)
+	for rows.Next() {
+		var merchID int
+		var price float64
+		if err = rows.Scan(&merchID, &price); err != nil {
+			return nil, err
+		}
+		prices[merchID] = price
+	}
+	return prices, rows.Err()
+}
@@ -1,12 +1,30 @@
 package repo
 
-import "database/sql"
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/geobuff/api/utils"
+)
 
 type Discount struct {
-	ID      int           `json:"id"`
-	MerchID sql.NullInt64 `json:"merchId"`
-	Code    string        `json:"code"`
-	Amount  float64       `json:"amount"`
+	ID            int           `json:"id"`
+	MerchID       sql.NullInt64 `json:"merchId"`
+	Code          string        `json:"code"`
+	Amount        float64       `json:"amount"`
+	Expires       sql.NullTime  `json:"expires"`
+	UsageLimit    sql.NullInt64 `json:"usageLimit"`
+	CustomerLimit sql.NullInt64 `json:"customerLimit"`
+}
+
+type DiscountDto struct {
+	MerchID       sql.NullInt64 `json:"merchId"`
+	Code          string        `json:"code" validate:"required"`
+	Amount        float64       `json:"amount" validate:"gt=0"`
+	Expires       sql.NullTime  `json:"expires"`
+	UsageLimit    sql.NullInt64 `json:"usageLimit"`
+	CustomerLimit sql.NullInt64 `json:"customerLimit"`
 }
 
 var GetDiscounts = func() ([]Discount, error) {
@@ -19,7 +37,7 @@ var GetDiscounts = func() ([]Discount, error) {
 	var discounts = []Discount{}
 	for rows.Next() {
 		var discount Discount
-		if err = rows.Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount); err != nil {
+		if err = rows.Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount, &discount.Expires, &discount.UsageLimit, &discount.CustomerLimit); err != nil {
 			return nil, err
 		}
 		discounts = append(discounts, discount)
@@ -30,13 +48,128 @@ var GetDiscounts = func() ([]Discount, error) {
 var GetDiscount = func(id int) (Discount, error) {
 	statement := "SELECT * from discounts WHERE id = $1;"
 	var discount Discount
-	err := Connection.QueryRow(statement, id).Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount)
+	err := Connection.QueryRow(statement, id).Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount, &discount.Expires, &discount.UsageLimit, &discount.CustomerLimit)
 	return discount, err
 }
 
 var GetDiscountByCode = func(code string) (Discount, error) {
 	statement := "SELECT * from discounts WHERE code = $1;"
 	var discount Discount
-	err := Connection.QueryRow(statement, code).Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount)
+	err := Connection.QueryRow(statement, code).Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount, &discount.Expires, &discount.UsageLimit, &discount.CustomerLimit)
 	return discount, err
 }
+
+var CreateDiscount = func(userID int, discount DiscountDto) (Discount, error) {
+	var entry Discount
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO discounts (merchId, code, amount, expires, usageLimit, customerLimit) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, merchId, code, amount, expires, usageLimit, customerLimit;"
+		err := tx.QueryRow(statement, discount.MerchID, discount.Code, discount.Amount, discount.Expires, discount.UsageLimit, discount.CustomerLimit).Scan(&entry.ID, &entry.MerchID, &entry.Code, &entry.Amount, &entry.Expires, &entry.UsageLimit, &entry.CustomerLimit)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_CREATE, AUDIT_ENTITY_DISCOUNT, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var UpdateDiscount = func(userID, discountID int, discount DiscountDto) (Discount, error) {
+	var entry Discount
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "UPDATE discounts SET merchId = $2, code = $3, amount = $4, expires = $5, usageLimit = $6, customerLimit = $7 WHERE id = $1 RETURNING id, merchId, code, amount, expires, usageLimit, customerLimit;"
+		err := tx.QueryRow(statement, discountID, discount.MerchID, discount.Code, discount.Amount, discount.Expires, discount.UsageLimit, discount.CustomerLimit).Scan(&entry.ID, &entry.MerchID, &entry.Code, &entry.Amount, &entry.Expires, &entry.UsageLimit, &entry.CustomerLimit)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_UPDATE, AUDIT_ENTITY_DISCOUNT, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var DeleteDiscount = func(userID, discountID int) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		var entry Discount
+		statement := "DELETE FROM discounts WHERE id = $1 RETURNING id, merchId, code, amount, expires, usageLimit, customerLimit;"
+		err := tx.QueryRow(statement, discountID).Scan(&entry.ID, &entry.MerchID, &entry.Code, &entry.Amount, &entry.Expires, &entry.UsageLimit, &entry.CustomerLimit)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_DELETE, AUDIT_ENTITY_DISCOUNT, entry.ID, entry)
+	})
+}
+
+// GetDiscountUsage counts the orders that have used the discount, overall and
+// for the given email. Only paid orders and pending ones still holding a
+// reservation count, so abandoned, cancelled and refunded checkouts give their
+// use back.
+var GetDiscountUsage = func(discountID int, email string) (int, int, error) {
+	return getDiscountUsage(Connection, discountID, email, time.Now())
+}
+
+func getDiscountUsage(db executor, discountID int, email string, now time.Time) (int, int, error) {
+	rows, err := db.Query("SELECT statusId, email, reservedUntil FROM orders WHERE discountId = $1;", discountID)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var uses, customerUses int
+	for rows.Next() {
+		var statusID int
+		var orderEmail string
+		var reservedUntil sql.NullTime
+		if err = rows.Scan(&statusID, &orderEmail, &reservedUntil); err != nil {
+			return 0, 0, err
+		}
+
+		if !orderUsesDiscount(statusID, reservedUntil, now) {
+			continue
+		}
+
+		uses++
+		if strings.EqualFold(orderEmail, email) {
+			customerUses++
+		}
+	}
+	return uses, customerUses, rows.Err()
+}
+
+func orderUsesDiscount(statusID int, reservedUntil sql.NullTime, now time.Time) bool {
+	switch statusID {
+	case ORDER_STATUS_PAYMENT_RECEIVED, ORDER_STATUS_PACKED, ORDER_STATUS_SHIPPED, ORDER_STATUS_DELIVERED:
+		return true
+	case ORDER_STATUS_PENDING:
+		return reservedUntil.Valid && reservedUntil.Time.After(now)
+	default:
+		return false
+	}
+}
+
+func (d Discount) PricingInput(uses, customerUses int) utils.PricingDiscountInput {
+	return utils.PricingDiscountInput{
+		ID:            d.ID,
+		MerchID:       d.MerchID,
+		Code:          d.Code,
+		Amount:        d.Amount,
+		Expires:       d.Expires,
+		UsageLimit:    d.UsageLimit,
+		CustomerLimit: d.CustomerLimit,
+		Uses:          uses,
+		CustomerUses:  customerUses,
+	}
+}
+
+// lockDiscount re-checks the discount limits while holding a row lock so that
+// concurrent checkouts can't push a discount past its limits.
+func lockDiscount(tx *sql.Tx, discountID int, email string) error {
+	var discount Discount
+	err := tx.QueryRow("SELECT id, merchId, code, amount, expires, usageLimit, customerLimit FROM discounts WHERE id = $1 FOR UPDATE;", discountID).Scan(&discount.ID, &discount.MerchID, &discount.Code, &discount.Amount, &discount.Expires, &discount.UsageLimit, &discount.CustomerLimit)
+	if err != nil {
+		return err
+	}
+
+	uses, customerUses, err := getDiscountUsage(tx, discountID, email, time.Now())
+	if err != nil {
+		return err
+	}
+	return utils.CheckDiscountLimits(discount.PricingInput(uses, customerUses), time.Now())
+}
@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+func TestGetDiscountUsage(t *testing.T) {
+	recorder := useRecordingConnection(t)
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorder.queueRows([]string{"statusid", "email", "reservedUntil"},
+		[]driver.Value{int64(ORDER_STATUS_PAYMENT_RECEIVED), "Test@gmail.com", nil},
+		[]driver.Value{int64(ORDER_STATUS_DELIVERED), "other@gmail.com", nil},
+		[]driver.Value{int64(ORDER_STATUS_PENDING), "test@gmail.com", now.Add(time.Minute)},
+		[]driver.Value{int64(ORDER_STATUS_PENDING), "test@gmail.com", now.Add(-time.Minute)},
+		[]driver.Value{int64(ORDER_STATUS_PENDING), "test@gmail.com", nil},
+		[]driver.Value{int64(ORDER_STATUS_CANCELLED), "test@gmail.com", nil},
+		[]driver.Value{int64(ORDER_STATUS_REFUNDED), "test@gmail.com", nil},
+	)
+
+	uses, customerUses, err := getDiscountUsage(Connection, 3, "test@gmail.com", now)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if uses != 3 {
+		t.Errorf("expected 3 uses; got %v", uses)
+	}
+
+	if customerUses != 2 {
+		t.Errorf("expected 2 customer uses; got %v", customerUses)
+	}
+
+	if len(recorder.statements) != 1 || recorder.statements[0].args[0] != int64(3) {
+		t.Errorf("expected usage for discount 3; got %v", recorder.statements)
+	}
+}
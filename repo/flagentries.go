@@ -1,10 +1,17 @@
 package repo
 
+import "database/sql"
+
+// FLAG_IN_USE_FILTER matches entries e whose code is a user's or avatar's
+// country and isn't also in another group.
+const FLAG_IN_USE_FILTER = "NOT EXISTS (SELECT 1 FROM flagEntries o WHERE o.code = e.code AND o.groupId <> e.groupId) AND (EXISTS (SELECT 1 FROM users u WHERE u.countrycode = e.code) OR EXISTS (SELECT 1 FROM avatars a WHERE a.countrycode = e.code))"
+
 type FlagEntry struct {
-	ID      int    `json:"id"`
-	GroupID int    `json:"groupId"`
-	Code    string `json:"code"`
-	Url     string `json:"url"`
+	ID       int    `json:"id"`
+	GroupID  int    `json:"groupId"`
+	Code     string `json:"code"`
+	Url      string `json:"url"`
+	AssetKey string `json:"assetKey"`
 }
 
 type CreateFlagEntryDto struct {
@@ -12,8 +19,8 @@ type CreateFlagEntryDto struct {
 	Url  string `json:"url"`
 }
 
-func GetFlagEntries(key string) ([]FlagEntry, error) {
-	rows, err := Connection.Query("SELECT e.id, e.groupId, e.code, e.url from flagEntries e JOIN flagGroups g ON g.id = e.groupId WHERE g.key = $1;", key)
+var GetFlagEntries = func(key string) ([]FlagEntry, error) {
+	rows, err := Connection.Query("SELECT e.id, e.groupId, e.code, e.url, e.assetKey from flagEntries e JOIN flagGroups g ON g.id = e.groupId WHERE g.key = $1;", key)
 	if err != nil {
 		return nil, err
 	}
@@ -22,7 +29,7 @@ func GetFlagEntries(key string) ([]FlagEntry, error) {
 	var entries = []FlagEntry{}
 	for rows.Next() {
 		var entry FlagEntry
-		if err = rows.Scan(&entry.ID, &entry.GroupID, &entry.Code, &entry.Url); err != nil {
+		if err = rows.Scan(&entry.ID, &entry.GroupID, &entry.Code, &entry.Url, &entry.AssetKey); err != nil {
 			return nil, err
 		}
 		entries = append(entries, entry)
@@ -38,7 +45,85 @@ func GetFlagUrl(code string) (string, error) {
 }
 
 func CreateFlagEntry(groupId int, entry CreateFlagEntryDto) error {
+	return insertFlagEntry(Connection, groupId, entry)
+}
+
+func insertFlagEntry(db executor, groupId int, entry CreateFlagEntryDto) error {
 	statement := "INSERT INTO flagEntries (groupId, code, url) VALUES ($1, $2, $3) RETURNING id;"
 	var id string
-	return Connection.QueryRow(statement, groupId, entry.Code, entry.Url).Scan(&id)
+	return db.QueryRow(statement, groupId, entry.Code, entry.Url).Scan(&id)
+}
+
+// SetFlagEntryAsset points the entry at an uploaded flag, adding the entry if
+// the group doesn't have it yet. It returns the asset key of the flag it
+// replaced, if that was uploaded too.
+var SetFlagEntryAsset = func(groupId int, code, url, assetKey string) (string, error) {
+	var replaced string
+	err := withTransaction(func(tx *sql.Tx) error {
+		var err error
+		replaced, err = setFlagEntryUrl(tx, groupId, code, url, assetKey)
+		return err
+	})
+	return replaced, err
+}
+
+func setFlagEntryUrl(tx *sql.Tx, groupId int, code, url, assetKey string) (string, error) {
+	var id int
+	var replaced string
+	err := tx.QueryRow("SELECT id, assetKey FROM flagEntries WHERE groupId = $1 AND code = $2 FOR UPDATE;", groupId, code).Scan(&id, &replaced)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec("INSERT INTO flagEntries (groupId, code, url, assetKey) VALUES ($1, $2, $3, $4);", groupId, code, url, assetKey)
+		return "", err
+	} else if err != nil {
+		return "", err
+	}
+
+	if _, err = tx.Exec("UPDATE flagEntries SET url = $2, assetKey = $3 WHERE id = $1;", id, url, assetKey); err != nil {
+		return "", err
+	}
+
+	if replaced == assetKey {
+		return "", nil
+	}
+	return replaced, nil
+}
+
+// DeleteFlagEntry returns the asset key of the deleted flag, if it was
+// uploaded.
+var DeleteFlagEntry = func(key, code string) (string, error) {
+	var assetKey string
+	err := withTransaction(func(tx *sql.Tx) error {
+		var id int
+		var inUse bool
+		statement := "SELECT e.id, e.assetKey, " + FLAG_IN_USE_FILTER + " FROM flagEntries e JOIN flagGroups g ON g.id = e.groupId WHERE g.key = $1 AND e.code = $2 FOR UPDATE OF e;"
+		if err := tx.QueryRow(statement, key, code).Scan(&id, &assetKey, &inUse); err != nil {
+			return err
+		}
+
+		if inUse {
+			return ErrFlagInUse
+		}
+
+		_, err := tx.Exec("DELETE FROM flagEntries WHERE id = $1;", id)
+		return err
+	})
+	return assetKey, err
+}
+
+func getFlagCodes() (map[string]bool, error) {
+	rows, err := Connection.Query("SELECT code FROM flagEntries;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err = rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes[code] = true
+	}
+	return codes, rows.Err()
 }
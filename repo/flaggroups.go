@@ -1,5 +1,12 @@
 package repo
 
+import (
+	"database/sql"
+	"errors"
+)
+
+var ErrFlagInUse = errors.New("flag is in use by a user or avatar")
+
 type FlagGroup struct {
 	ID    int    `json:"id"`
 	Key   string `json:"key"`
@@ -12,6 +19,13 @@ type CreateFlagsDto struct {
 	Entries []CreateFlagEntryDto `json:"entries"`
 }
 
+// UpdateFlagsDto renames the group and adds or replaces the url of each entry
+// by code. Entries that aren't listed are left alone.
+type UpdateFlagsDto struct {
+	Label   string               `json:"label" validate:"required"`
+	Entries []CreateFlagEntryDto `json:"entries"`
+}
+
 func GetFlagGroups() ([]FlagGroup, error) {
 	rows, err := Connection.Query("SELECT * from flagGroups;")
 	if err != nil {
@@ -30,17 +44,97 @@ func GetFlagGroups() ([]FlagGroup, error) {
 	return groups, rows.Err()
 }
 
-func CreateFlags(flags CreateFlagsDto) error {
-	statement := "INSERT INTO flagGroups (key, label) VALUES ($1, $2) RETURNING id;"
-	var groupId int
-	if err := Connection.QueryRow(statement, flags.Key, flags.Label).Scan(&groupId); err != nil {
-		return err
-	}
+var GetFlagGroup = func(key string) (FlagGroup, error) {
+	var group FlagGroup
+	err := Connection.QueryRow("SELECT id, key, label FROM flagGroups WHERE key = $1;", key).Scan(&group.ID, &group.Key, &group.Label)
+	return group, err
+}
 
-	for _, entry := range flags.Entries {
-		if err := CreateFlagEntry(groupId, entry); err != nil {
+var CreateFlags = func(flags CreateFlagsDto) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO flagGroups (key, label) VALUES ($1, $2) RETURNING id;"
+		var groupId int
+		if err := tx.QueryRow(statement, flags.Key, flags.Label).Scan(&groupId); err != nil {
 			return err
 		}
-	}
-	return nil
+
+		for _, entry := range flags.Entries {
+			if err := insertFlagEntry(tx, groupId, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateFlags returns the asset keys of uploaded flags that were replaced by
+// a url, so the files can be removed.
+var UpdateFlags = func(key string, flags UpdateFlagsDto) ([]string, error) {
+	var replaced = []string{}
+	err := withTransaction(func(tx *sql.Tx) error {
+		var groupId int
+		if err := tx.QueryRow("UPDATE flagGroups SET label = $2 WHERE key = $1 RETURNING id;", key, flags.Label).Scan(&groupId); err != nil {
+			return err
+		}
+
+		for _, entry := range flags.Entries {
+			assetKey, err := setFlagEntryUrl(tx, groupId, entry.Code, entry.Url, "")
+			if err != nil {
+				return err
+			}
+
+			if assetKey != "" {
+				replaced = append(replaced, assetKey)
+			}
+		}
+		return nil
+	})
+	return replaced, err
+}
+
+// DeleteFlags removes the group and its entries, returning the asset keys of
+// any uploaded flags. Groups with a flag used by a user or avatar that no
+// other group has can't be deleted.
+var DeleteFlags = func(key string) ([]string, error) {
+	var assetKeys = []string{}
+	err := withTransaction(func(tx *sql.Tx) error {
+		var groupId int
+		if err := tx.QueryRow("SELECT id FROM flagGroups WHERE key = $1 FOR UPDATE;", key).Scan(&groupId); err != nil {
+			return err
+		}
+
+		var inUse bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM flagEntries e WHERE e.groupId = $1 AND "+FLAG_IN_USE_FILTER+");", groupId).Scan(&inUse); err != nil {
+			return err
+		}
+
+		if inUse {
+			return ErrFlagInUse
+		}
+
+		rows, err := tx.Query("DELETE FROM flagEntries WHERE groupId = $1 RETURNING assetKey;", groupId)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var assetKey string
+			if err = rows.Scan(&assetKey); err != nil {
+				return err
+			}
+
+			if assetKey != "" {
+				assetKeys = append(assetKeys, assetKey)
+			}
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM flagGroups WHERE id = $1;", groupId)
+		return err
+	})
+	return assetKeys, err
 }
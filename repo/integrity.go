@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"github.com/geobuff/api/utils"
+	"github.com/lib/pq"
+)
+
+// CheckIntegrity loads maps, mappings, flags, quizzes and trivia questions
+// and reports anything that refers to rows that don't exist.
+var CheckIntegrity = func() (utils.IntegrityReport, error) {
+	var data utils.IntegrityData
+	var err error
+	if data.Maps, err = getIntegrityMaps(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+
+	if data.Mappings, err = getIntegrityMappings(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+
+	if data.FlagGroups, err = getFlagGroupKeys(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+
+	if data.FlagCodes, err = getFlagCodes(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+
+	if data.Quizzes, err = getIntegrityQuizzes(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+
+	if data.TriviaQuestions, err = getIntegrityTriviaQuestions(); err != nil {
+		return utils.IntegrityReport{}, err
+	}
+	return utils.CheckIntegrity(data), nil
+}
+
+func getIntegrityMaps() ([]utils.IntegrityMap, error) {
+	rows, err := Connection.Query("SELECT m.classname, m.key, COALESCE(e.elementid, ''), COALESCE(e.name, '') FROM maps m LEFT JOIN mapElements e ON e.mapId = m.id AND e.clippathid = '' ORDER BY m.classname, e.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var maps = []utils.IntegrityMap{}
+	for rows.Next() {
+		var className, key string
+		var element utils.IntegrityMapElement
+		if err = rows.Scan(&className, &key, &element.ID, &element.Name); err != nil {
+			return nil, err
+		}
+
+		if len(maps) == 0 || maps[len(maps)-1].ClassName != className {
+			maps = append(maps, utils.IntegrityMap{ClassName: className, Key: key})
+		}
+
+		if element.ID != "" || element.Name != "" {
+			maps[len(maps)-1].Elements = append(maps[len(maps)-1].Elements, element)
+		}
+	}
+	return maps, rows.Err()
+}
+
+func getIntegrityMappings() ([]utils.IntegrityMapping, error) {
+	rows, err := Connection.Query("SELECT g.key, e.code, e.name, e.svgname, e.alternativenames FROM mappingGroups g JOIN mappingEntries e ON e.groupId = g.id ORDER BY g.key, e.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings = []utils.IntegrityMapping{}
+	for rows.Next() {
+		var key string
+		var entry utils.IntegrityMappingEntry
+		var alternativeNames pq.StringArray
+		if err = rows.Scan(&key, &entry.Code, &entry.Name, &entry.SVGName, &alternativeNames); err != nil {
+			return nil, err
+		}
+		entry.AlternativeNames = alternativeNames
+
+		if len(mappings) == 0 || mappings[len(mappings)-1].Key != key {
+			mappings = append(mappings, utils.IntegrityMapping{Key: key})
+		}
+		mappings[len(mappings)-1].Entries = append(mappings[len(mappings)-1].Entries, entry)
+	}
+	return mappings, rows.Err()
+}
+
+func getFlagGroupKeys() (map[string]bool, error) {
+	rows, err := Connection.Query("SELECT key FROM flagGroups;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
+
+func getIntegrityQuizzes() ([]utils.IntegrityQuiz, error) {
+	rows, err := Connection.Query("SELECT id, name, typeId, hasFlags, mapSVG, apiPath FROM quizzes ORDER BY id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quizzes = []utils.IntegrityQuiz{}
+	for rows.Next() {
+		var quiz utils.IntegrityQuiz
+		var typeID int
+		if err = rows.Scan(&quiz.ID, &quiz.Name, &typeID, &quiz.HasFlags, &quiz.MapSVG, &quiz.APIPath); err != nil {
+			return nil, err
+		}
+		quiz.IsMap = typeID == QUIZ_TYPE_MAP
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, rows.Err()
+}
+
+// getIntegrityTriviaQuestions loads both generated trivia questions and the
+// manual question bank, with the flag codes used by their answers.
+func getIntegrityTriviaQuestions() ([]utils.IntegrityTriviaQuestion, error) {
+	statement := "SELECT false, q.id, q.question, COALESCE(q.map, ''), COALESCE(q.highlighted, ''), array_remove(array_append(array_agg(COALESCE(a.flagCode, '')), COALESCE(q.flagCode, '')), '') FROM triviaQuestions q LEFT JOIN triviaAnswers a ON a.triviaQuestionId = q.id GROUP BY q.id UNION ALL SELECT true, q.id, q.question, COALESCE(q.map, ''), COALESCE(q.highlighted, ''), array_remove(array_append(array_agg(COALESCE(a.flagCode, '')), COALESCE(q.flagCode, '')), '') FROM manualTriviaQuestions q LEFT JOIN manualTriviaAnswers a ON a.manualTriviaQuestionId = q.id GROUP BY q.id ORDER BY 1, 2;"
+	rows, err := Connection.Query(statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions = []utils.IntegrityTriviaQuestion{}
+	for rows.Next() {
+		var question utils.IntegrityTriviaQuestion
+		var flagCodes pq.StringArray
+		if err = rows.Scan(&question.Manual, &question.ID, &question.Question, &question.Map, &question.Highlighted, &flagCodes); err != nil {
+			return nil, err
+		}
+		question.FlagCodes = flagCodes
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
@@ -136,17 +136,37 @@ var InsertLeaderboardEntry = func(entry LeaderboardEntry) (int, error) {
 	statement := "INSERT INTO leaderboard (quizId, userId, score, time, added) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
 	var id int
 	err := Connection.QueryRow(statement, entry.QuizID, entry.UserID, entry.Score, entry.Time, entry.Added).Scan(&id)
-	return id, err
+	if err != nil {
+		return id, err
+	}
+
+	refreshUserQuizCountryScore(entry.QuizID, entry.UserID)
+	return id, nil
 }
 
+// UpdateLeaderboardEntry also refreshes the quiz and country the entry used to
+// count towards when it's moved to another quiz or user.
 var UpdateLeaderboardEntry = func(entry LeaderboardEntry) error {
-	statement := "UPDATE leaderboard set quizId = $2, userId = $3, score = $4, time = $5, added = $6 where id = $1 RETURNING id;"
-	var id int
-	return Connection.QueryRow(statement, entry.ID, entry.QuizID, entry.UserID, entry.Score, entry.Time, entry.Added).Scan(&id)
+	statement := "UPDATE leaderboard l set quizId = $2, userId = $3, score = $4, time = $5, added = $6 FROM (SELECT id, quizId, userId FROM leaderboard WHERE id = $1 FOR UPDATE) previous WHERE l.id = previous.id RETURNING previous.quizId, previous.userId;"
+	var previousQuizID, previousUserID int
+	if err := Connection.QueryRow(statement, entry.ID, entry.QuizID, entry.UserID, entry.Score, entry.Time, entry.Added).Scan(&previousQuizID, &previousUserID); err != nil {
+		return err
+	}
+
+	refreshUserQuizCountryScore(entry.QuizID, entry.UserID)
+	if previousQuizID != entry.QuizID || previousUserID != entry.UserID {
+		refreshUserQuizCountryScore(previousQuizID, previousUserID)
+	}
+	return nil
 }
 
 var DeleteLeaderboardEntry = func(entryID int) error {
-	statement := "DELETE FROM leaderboard WHERE id = $1 RETURNING id;"
-	var id int
-	return Connection.QueryRow(statement, entryID).Scan(&id)
+	statement := "DELETE FROM leaderboard WHERE id = $1 RETURNING quizId, userId;"
+	var quizID, userID int
+	if err := Connection.QueryRow(statement, entryID).Scan(&quizID, &userID); err != nil {
+		return err
+	}
+
+	refreshUserQuizCountryScore(quizID, userID)
+	return nil
 }
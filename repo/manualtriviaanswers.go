@@ -22,9 +22,13 @@ type UpdateManualTriviaAnswerDto struct {
 }
 
 func CreateManualTriviaAnswer(questionID int, answer CreateManualTriviaAnswerDto) error {
+	return insertManualTriviaAnswer(Connection, questionID, answer)
+}
+
+func insertManualTriviaAnswer(db executor, questionID int, answer CreateManualTriviaAnswerDto) error {
 	statement := "INSERT INTO manualtriviaanswers (manualtriviaquestionid, text, iscorrect, flagcode) VALUES ($1, $2, $3, $4) RETURNING id;"
 	var id int
-	return Connection.QueryRow(statement, questionID, answer.Text, answer.IsCorrect, answer.FlagCode).Scan(&id)
+	return db.QueryRow(statement, questionID, answer.Text, answer.IsCorrect, answer.FlagCode).Scan(&id)
 }
 
 func UpdateManualTriviaAnswer(answer UpdateManualTriviaAnswerDto) error {
@@ -34,7 +38,11 @@ func UpdateManualTriviaAnswer(answer UpdateManualTriviaAnswerDto) error {
 }
 
 func GetManualTriviaAnswers(questionID int) ([]ManualTriviaAnswer, error) {
-	rows, err := Connection.Query("SELECT * FROM manualtriviaanswers WHERE manualtriviaquestionid = $1;", questionID)
+	return getManualTriviaAnswers(Connection, questionID)
+}
+
+func getManualTriviaAnswers(db executor, questionID int) ([]ManualTriviaAnswer, error) {
+	rows, err := db.Query("SELECT * FROM manualtriviaanswers WHERE manualtriviaquestionid = $1;", questionID)
 	if err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/geobuff/api/utils"
 	"github.com/lib/pq"
 )
 
@@ -146,15 +147,23 @@ var GetFirstManualTriviaQuestionID = func(filterParams GetManualTriviaQuestionEn
 }
 
 func CreateManualTriviaQuestion(question CreateManualTriviaQuestionDto) error {
+	return insertManualTriviaQuestion(Connection, question)
+}
+
+func insertManualTriviaQuestion(db executor, question CreateManualTriviaQuestionDto) error {
+	if err := fillImageDetails(db, question.ImageURL, &question.ImageWidth, &question.ImageHeight, &question.ImageAttributeName, &question.ImageAttributeURL); err != nil {
+		return err
+	}
+
 	statement := "INSERT INTO manualtriviaquestions (typeid, categoryid, question, map, highlighted, flagcode, imageurl, imageAttributeName, imageAttributeUrl, imageWidth, imageHeight, imageAlt, quizDate, explainer, lastupdated) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id;"
 	var id int
-	err := Connection.QueryRow(statement, question.TypeID, question.CategoryID, strings.TrimSpace(question.Question), question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.QuizDate, question.Explainer, time.Now()).Scan(&id)
+	err := db.QueryRow(statement, question.TypeID, question.CategoryID, strings.TrimSpace(question.Question), question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.QuizDate, question.Explainer, time.Now()).Scan(&id)
 	if err != nil {
 		return err
 	}
 
 	for _, val := range question.Answers {
-		if err = CreateManualTriviaAnswer(id, val); err != nil {
+		if err = insertManualTriviaAnswer(db, id, val); err != nil {
 			return err
 		}
 	}
@@ -162,14 +171,13 @@ func CreateManualTriviaQuestion(question CreateManualTriviaQuestionDto) error {
 }
 
 func ValidateCreateQuestion(question CreateManualTriviaQuestionDto) error {
-	var id int
-	err := Connection.QueryRow("SELECT id FROM manualtriviaquestions WHERE question ILIKE '%' || $1 || '%';", question.Question).Scan(&id)
-	if err != nil && err != sql.ErrNoRows {
+	existing, err := getManualTriviaQuestionTexts()
+	if err != nil {
 		return err
 	}
 
-	if err == nil {
-		return fmt.Errorf("question with text %s already exists", question.Question)
+	if match, found := findSimilarQuestion(question.Question, existing); found {
+		return fmt.Errorf("question with text %s is too similar to existing question %s", question.Question, match)
 	}
 
 	visited := make(map[string]bool)
@@ -184,6 +192,10 @@ func ValidateCreateQuestion(question CreateManualTriviaQuestionDto) error {
 }
 
 func UpdateManualTriviaQuestion(questionID int, question UpdateManualTriviaQuestionDto) error {
+	if err := fillImageDetails(Connection, question.ImageURL, &question.ImageWidth, &question.ImageHeight, &question.ImageAttributeName, &question.ImageAttributeURL); err != nil {
+		return err
+	}
+
 	statement := "UPDATE manualtriviaquestions SET typeid = $2, categoryid = $3, question = $4, map = $5, highlighted = $6, flagcode = $7, imageurl = $8, imageAttributeName = $9, imageAttributeUrl = $10, imageWidth = $11, imageHeight = $12, imageAlt = $13, quizDate = $14, explainer = $15, lastupdated = $16 WHERE id = $1 RETURNING id;"
 	var id int
 	err := Connection.QueryRow(statement, questionID, question.TypeID, question.CategoryID, strings.TrimSpace(question.Question), question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.QuizDate, question.Explainer, time.Now()).Scan(&id)
@@ -269,3 +281,272 @@ func GetTodaysManualTriviaQuestions() ([]ManualTriviaQuestion, error) {
 	}
 	return questions, rows.Err()
 }
+
+const MANUAL_TRIVIA_QUESTION_SIMILARITY_THRESHOLD = 0.9
+
+type ManualTriviaQuestionBankDto struct {
+	Type               string                        `json:"type"`
+	Category           string                        `json:"category"`
+	Question           string                        `json:"question"`
+	Map                string                        `json:"map"`
+	Highlighted        string                        `json:"highlighted"`
+	FlagCode           string                        `json:"flagCode"`
+	ImageURL           string                        `json:"imageUrl"`
+	ImageAttributeName string                        `json:"imageAttributeName"`
+	ImageAttributeURL  string                        `json:"imageAttributeUrl"`
+	ImageWidth         int                           `json:"imageWidth"`
+	ImageHeight        int                           `json:"imageHeight"`
+	ImageAlt           string                        `json:"imageAlt"`
+	Explainer          string                        `json:"explainer"`
+	QuizDate           string                        `json:"quizDate"`
+	Answers            []CreateManualTriviaAnswerDto `json:"answers"`
+}
+
+type ImportManualTriviaQuestionRowDto struct {
+	Row      int      `json:"row"`
+	Question string   `json:"question"`
+	Errors   []string `json:"errors"`
+}
+
+type ImportManualTriviaQuestionsResultDto struct {
+	DryRun   bool                               `json:"dryRun"`
+	Total    int                                `json:"total"`
+	Valid    int                                `json:"valid"`
+	Imported int                                `json:"imported"`
+	Rows     []ImportManualTriviaQuestionRowDto `json:"rows"`
+}
+
+type manualTriviaQuestionText struct {
+	ID       int
+	Question string
+}
+
+var GetManualTriviaQuestionBank = func(filterParams GetManualTriviaQuestionEntriesFilterParams) ([]ManualTriviaQuestionBankDto, error) {
+	statement := "SELECT q.id, t.name, c.name, q.question, COALESCE(q.map, ''), COALESCE(q.highlighted, ''), COALESCE(q.flagcode, ''), COALESCE(q.imageurl, ''), q.imageAttributeName, q.imageAttributeUrl, q.imageWidth, q.imageHeight, q.imageAlt, COALESCE(q.explainer, ''), q.quizDate FROM manualtriviaquestions q JOIN triviaquestiontype t ON t.id = q.typeid JOIN triviaquestioncategory c ON c.id = q.categoryid WHERE q.question ILIKE '%' || $1 || '%' " + getTypeFilter(filterParams.TypeID) + getCategoryFilter(filterParams.CategoryID) + " ORDER BY q.id;"
+	rows, err := Connection.Query(statement, filterParams.Question)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions = []ManualTriviaQuestionBankDto{}
+	for rows.Next() {
+		var id int
+		var quizDate sql.NullTime
+		var question ManualTriviaQuestionBankDto
+		if err = rows.Scan(&id, &question.Type, &question.Category, &question.Question, &question.Map, &question.Highlighted, &question.FlagCode, &question.ImageURL, &question.ImageAttributeName, &question.ImageAttributeURL, &question.ImageWidth, &question.ImageHeight, &question.ImageAlt, &question.Explainer, &quizDate); err != nil {
+			return nil, err
+		}
+
+		if quizDate.Valid {
+			question.QuizDate = quizDate.Time.Format("2006-01-02")
+		}
+
+		answers, err := GetManualTriviaAnswers(id)
+		if err != nil {
+			return nil, err
+		}
+
+		question.Answers = []CreateManualTriviaAnswerDto{}
+		for _, answer := range answers {
+			question.Answers = append(question.Answers, CreateManualTriviaAnswerDto{
+				Text:      answer.Text,
+				IsCorrect: answer.IsCorrect,
+				FlagCode:  answer.FlagCode,
+			})
+		}
+
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+var ValidateManualTriviaQuestionImport = func(questions []ManualTriviaQuestionBankDto) ([]ImportManualTriviaQuestionRowDto, []CreateManualTriviaQuestionDto, error) {
+	types, err := GetTriviaQuestionTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categories, err := GetTriviaQuestionCategories(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flagCodes, err := getFlagCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapElements, err := getMapElementNames()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := getManualTriviaQuestionTexts()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results = []ImportManualTriviaQuestionRowDto{}
+	var valid = []CreateManualTriviaQuestionDto{}
+	for index, question := range questions {
+		result := ImportManualTriviaQuestionRowDto{
+			Row:      index + 1,
+			Question: question.Question,
+			Errors:   []string{},
+		}
+
+		create := CreateManualTriviaQuestionDto{
+			Question:           strings.TrimSpace(question.Question),
+			Map:                question.Map,
+			Highlighted:        question.Highlighted,
+			FlagCode:           question.FlagCode,
+			ImageURL:           question.ImageURL,
+			ImageAttributeName: question.ImageAttributeName,
+			ImageAttributeURL:  question.ImageAttributeURL,
+			ImageWidth:         question.ImageWidth,
+			ImageHeight:        question.ImageHeight,
+			ImageAlt:           question.ImageAlt,
+			Explainer:          question.Explainer,
+			Answers:            question.Answers,
+		}
+
+		for _, val := range types {
+			if strings.EqualFold(val.Name, strings.TrimSpace(question.Type)) {
+				create.TypeID = val.ID
+			}
+		}
+
+		for _, val := range categories {
+			if strings.EqualFold(val.Name, strings.TrimSpace(question.Category)) {
+				create.CategoryID = val.ID
+			}
+		}
+
+		if create.TypeID == 0 {
+			result.Errors = append(result.Errors, fmt.Sprintf("invalid type %s", question.Type))
+		}
+
+		if create.CategoryID == 0 {
+			result.Errors = append(result.Errors, fmt.Sprintf("invalid category %s", question.Category))
+		}
+
+		if create.Question == "" {
+			result.Errors = append(result.Errors, "question is required")
+		} else if match, found := findSimilarQuestion(create.Question, existing); found {
+			result.Errors = append(result.Errors, fmt.Sprintf("question is too similar to existing question %s", match))
+		}
+
+		switch create.TypeID {
+		case QUESTION_TYPE_IMAGE:
+			if create.ImageURL == "" {
+				result.Errors = append(result.Errors, "image questions require an imageUrl")
+			}
+		case QUESTION_TYPE_FLAG:
+			if create.FlagCode == "" {
+				result.Errors = append(result.Errors, "flag questions require a flagCode")
+			}
+		case QUESTION_TYPE_MAP:
+			elements, found := mapElements[create.Map]
+			if !found {
+				result.Errors = append(result.Errors, fmt.Sprintf("invalid map %s", create.Map))
+			} else if create.Highlighted != "" && !elements[create.Highlighted] {
+				result.Errors = append(result.Errors, fmt.Sprintf("map %s has no element named %s", create.Map, create.Highlighted))
+			}
+		}
+
+		if create.FlagCode != "" && !flagCodes[create.FlagCode] {
+			result.Errors = append(result.Errors, fmt.Sprintf("invalid flag code %s", create.FlagCode))
+		}
+
+		if question.QuizDate != "" {
+			quizDate, err := time.Parse("2006-01-02", question.QuizDate)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("invalid quiz date %s", question.QuizDate))
+			} else {
+				create.QuizDate = sql.NullTime{Time: quizDate, Valid: true}
+			}
+		}
+
+		result.Errors = append(result.Errors, validateImportAnswers(question.Answers, flagCodes)...)
+
+		// Only rows that will be imported count as duplicates of later rows.
+		if len(result.Errors) == 0 {
+			valid = append(valid, create)
+			existing = append(existing, manualTriviaQuestionText{Question: create.Question})
+		}
+		results = append(results, result)
+	}
+
+	return results, valid, nil
+}
+
+func validateImportAnswers(answers []CreateManualTriviaAnswerDto, flagCodes map[string]bool) []string {
+	var errors = []string{}
+	if len(answers) < 2 {
+		errors = append(errors, "at least two answers are required")
+	}
+
+	var correct int
+	visited := make(map[string]bool)
+	for _, answer := range answers {
+		if answer.IsCorrect {
+			correct = correct + 1
+		}
+
+		normalised := utils.NormaliseText(answer.Text)
+		if normalised == "" {
+			errors = append(errors, "answer text is required")
+		} else if visited[normalised] {
+			errors = append(errors, fmt.Sprintf("duplicate answers with text %s", answer.Text))
+		}
+		visited[normalised] = true
+
+		if answer.FlagCode != "" && !flagCodes[answer.FlagCode] {
+			errors = append(errors, fmt.Sprintf("invalid answer flag code %s", answer.FlagCode))
+		}
+	}
+
+	if correct != 1 {
+		errors = append(errors, fmt.Sprintf("expected exactly one correct answer; got %d", correct))
+	}
+	return errors
+}
+
+var ImportManualTriviaQuestions = func(questions []CreateManualTriviaQuestionDto) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		for _, question := range questions {
+			if err := insertManualTriviaQuestion(tx, question); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func getManualTriviaQuestionTexts() ([]manualTriviaQuestionText, error) {
+	rows, err := Connection.Query("SELECT id, question FROM manualtriviaquestions;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions = []manualTriviaQuestionText{}
+	for rows.Next() {
+		var question manualTriviaQuestionText
+		if err = rows.Scan(&question.ID, &question.Question); err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+func findSimilarQuestion(question string, existing []manualTriviaQuestionText) (string, bool) {
+	for _, val := range existing {
+		if utils.TextSimilarity(question, val.Question) >= MANUAL_TRIVIA_QUESTION_SIMILARITY_THRESHOLD {
+			return val.Question, true
+		}
+	}
+	return "", false
+}
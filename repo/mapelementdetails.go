@@ -0,0 +1,73 @@
+package repo
+
+import "github.com/geobuff/api/utils"
+
+type mapElementGeometry struct {
+	id        int
+	typeName  string
+	d         string
+	points    string
+	transform string
+	viewBox   string
+}
+
+// simplifiedMapElementTypes are the element types with geometry worth
+// simplifying, the rest are drawn the same at every detail level.
+var simplifiedMapElementTypes = map[string]bool{
+	utils.SVG_ELEMENT_PATH:     true,
+	utils.SVG_ELEMENT_POLYGON:  true,
+	utils.SVG_ELEMENT_POLYLINE: true,
+}
+
+// createMapElementDetails stores a simplified copy of the element's geometry
+// for each detail level below full. Geometry that can't be parsed is stored
+// as it is so every level can still draw the element.
+func createMapElementDetails(db executor, element mapElementGeometry) error {
+	if !simplifiedMapElementTypes[element.typeName] {
+		return nil
+	}
+
+	for _, detail := range utils.SimplifiedMapDetails {
+		simplified, err := utils.SimplifySVGElement(utils.SVGElement{Type: element.typeName, D: element.d, Points: element.points, Transform: element.transform}, element.viewBox, detail)
+		if err != nil {
+			simplified = utils.SVGElement{D: element.d, Points: element.points}
+		}
+
+		var id int
+		statement := "INSERT INTO mapElementDetails (elementId, detail, d, points) VALUES ($1, $2, $3, $4) ON CONFLICT (elementId, detail) DO UPDATE SET d = $3, points = $4 RETURNING id;"
+		if err := db.QueryRow(statement, element.id, detail, simplified.D, simplified.Points).Scan(&id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillMapElementDetails simplifies elements imported before detail levels
+// were stored, returning how many were updated.
+func BackfillMapElementDetails() (int, error) {
+	rows, err := Connection.Query("SELECT e.id, t.name, e.d, e.points, e.transform, m.viewBox FROM mapElements e JOIN mapElementType t ON t.id = e.typeId JOIN maps m ON m.id = e.mapId WHERE t.name IN ($1, $2, $3) AND NOT EXISTS (SELECT 1 FROM mapElementDetails d WHERE d.elementId = e.id);", utils.SVG_ELEMENT_PATH, utils.SVG_ELEMENT_POLYGON, utils.SVG_ELEMENT_POLYLINE)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var elements []mapElementGeometry
+	for rows.Next() {
+		var element mapElementGeometry
+		if err = rows.Scan(&element.id, &element.typeName, &element.d, &element.points, &element.transform, &element.viewBox); err != nil {
+			return 0, err
+		}
+		elements = append(elements, element)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for index, element := range elements {
+		if err := createMapElementDetails(Connection, element); err != nil {
+			return index, err
+		}
+	}
+	return len(elements), nil
+}
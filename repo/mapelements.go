@@ -1,5 +1,7 @@
 package repo
 
+import "github.com/geobuff/api/utils"
+
 type MapElement struct {
 	ID         int    `json:"id"`
 	MapID      int    `json:"mapId"`
@@ -79,7 +81,18 @@ type UpdateMapElementDto struct {
 }
 
 func GetMapElements(mapId int) ([]MapElementDto, error) {
-	rows, err := Connection.Query("SELECT e.id, e.mapid, t.name, e.elementid, e.name, e.d, e.points, e.x, e.y, e.width, e.height, e.cx, e.cy, e.r, e.transform, e.xlinkhref, e.clippath, e.clippathid, e.x1, e.y1, e.x2, e.y2 FROM mapElements e JOIN mapElementType t ON t.id = e.typeid WHERE e.mapId = $1;", mapId)
+	return GetMapElementsWithDetail(mapId, utils.MAP_DETAIL_FULL)
+}
+
+// GetMapElementsWithDetail returns the elements with their simplified geometry
+// for the detail level, falling back to the full geometry where there isn't
+// any.
+func GetMapElementsWithDetail(mapId int, detail string) ([]MapElementDto, error) {
+	return getMapElements(Connection, mapId, detail)
+}
+
+func getMapElements(db executor, mapId int, detail string) ([]MapElementDto, error) {
+	rows, err := db.Query("SELECT e.id, e.mapid, t.name, e.elementid, e.name, COALESCE(d.d, e.d), COALESCE(d.points, e.points), e.x, e.y, e.width, e.height, e.cx, e.cy, e.r, e.transform, e.xlinkhref, e.clippath, e.clippathid, e.x1, e.y1, e.x2, e.y2 FROM mapElements e JOIN mapElementType t ON t.id = e.typeid LEFT JOIN mapElementDetails d ON d.elementId = e.id AND d.detail = $2 WHERE e.mapId = $1;", mapId, detail)
 	if err != nil {
 		return nil, err
 	}
@@ -116,15 +129,38 @@ func GetHighlightedElements(mapId int) ([]HighlightedRegionDto, error) {
 	return regions, rows.Err()
 }
 
-func CreateMapElement(mapId int, element MapElementDto) error {
-	typeId, err := GetMapElementTypeId(element.Type)
+// createMapElement stores the element along with its simplified geometry for
+// each detail level, which is worked out relative to the map's viewBox.
+func createMapElement(db executor, mapId int, viewBox string, element MapElementDto) (int, error) {
+	typeId, err := getMapElementTypeId(db, element.Type)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	var id int
 	statement := "INSERT INTO mapelements (mapid, typeid, elementid, name, d, points, x, y, width, height, cx, cy, r, transform, xlinkhref, clippath, clippathid, x1, y1, x2, y2) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21) RETURNING id;"
-	return Connection.QueryRow(statement, mapId, typeId, element.ID, element.Name, element.D, element.Points, element.X, element.Y, element.Width, element.Height, element.Cx, element.Cy, element.R, element.Transform, element.XlinkHref, element.ClipPath, element.ClipPathId, element.X1, element.Y1, element.X2, element.Y2).Scan(&id)
+	if err := db.QueryRow(statement, mapId, typeId, element.ID, element.Name, element.D, element.Points, element.X, element.Y, element.Width, element.Height, element.Cx, element.Cy, element.R, element.Transform, element.XlinkHref, element.ClipPath, element.ClipPathId, element.X1, element.Y1, element.X2, element.Y2).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, createMapElementDetails(db, mapElementGeometry{id: id, typeName: element.Type, d: element.D, points: element.Points, transform: element.Transform, viewBox: viewBox})
+}
+
+// replaceMapElement overwrites an element of the map in place, keeping its id,
+// and recalculates its simplified geometry.
+func replaceMapElement(db executor, mapId, entryId int, viewBox string, element MapElementDto) error {
+	typeId, err := getMapElementTypeId(db, element.Type)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	statement := "UPDATE mapelements SET typeid = $3, elementid = $4, name = $5, d = $6, points = $7, x = $8, y = $9, width = $10, height = $11, cx = $12, cy = $13, r = $14, transform = $15, xlinkhref = $16, clippath = $17, clippathid = $18, x1 = $19, y1 = $20, x2 = $21, y2 = $22 WHERE id = $1 AND mapid = $2 RETURNING id;"
+	if err := db.QueryRow(statement, entryId, mapId, typeId, element.ID, element.Name, element.D, element.Points, element.X, element.Y, element.Width, element.Height, element.Cx, element.Cy, element.R, element.Transform, element.XlinkHref, element.ClipPath, element.ClipPathId, element.X1, element.Y1, element.X2, element.Y2).Scan(&id); err != nil {
+		return err
+	}
+
+	element.EntryID = id
+	return refreshMapElementDetails(db, viewBox, element)
 }
 
 func DeleteMapElements(mapId int) error {
@@ -136,3 +172,28 @@ func UpdateMapElement(entryID int, entry UpdateMapElementDto) error {
 	var id int
 	return Connection.QueryRow("UPDATE mapelements SET name = $2, elementid = $3 WHERE id = $1 RETURNING id;", entryID, entry.Name, entry.ElementID).Scan(&id)
 }
+
+func getMapElementNames() (map[string]map[string]bool, error) {
+	rows, err := Connection.Query("SELECT m.classname, COALESCE(e.name, '') FROM maps m LEFT JOIN mapElements e ON e.mapId = m.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]map[string]bool)
+	for rows.Next() {
+		var className, name string
+		if err = rows.Scan(&className, &name); err != nil {
+			return nil, err
+		}
+
+		if _, found := names[className]; !found {
+			names[className] = make(map[string]bool)
+		}
+
+		if name != "" {
+			names[className][name] = true
+		}
+	}
+	return names, rows.Err()
+}
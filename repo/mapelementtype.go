@@ -6,7 +6,11 @@ type MapElementType struct {
 }
 
 func GetMapElementTypeId(name string) (int, error) {
+	return getMapElementTypeId(Connection, name)
+}
+
+func getMapElementTypeId(db executor, name string) (int, error) {
 	var id int
-	err := Connection.QueryRow("SELECT id FROM mapelementtype WHERE name = $1;", name).Scan(&id)
+	err := db.QueryRow("SELECT id FROM mapelementtype WHERE name = $1;", name).Scan(&id)
 	return id, err
 }
@@ -0,0 +1,312 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Attribute value types. Number attributes can be filtered by range.
+const (
+	MAPPING_ATTRIBUTE_TEXT   = "text"
+	MAPPING_ATTRIBUTE_NUMBER = "number"
+)
+
+type MappingAttribute struct {
+	ID        int    `json:"id"`
+	Key       string `json:"key"`
+	Label     string `json:"label"`
+	ValueType string `json:"valueType"`
+}
+
+// MappingEntryAttributesDto is one row of an attribute import. Attribute
+// values can be strings or numbers, an empty value removes the attribute.
+// Neighbours replace the entry's neighbours when given, nil leaves them alone.
+type MappingEntryAttributesDto struct {
+	Code       string                 `json:"code"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Neighbours []string               `json:"neighbours"`
+}
+
+type ImportMappingAttributesRowDto struct {
+	Row    int      `json:"row"`
+	Code   string   `json:"code"`
+	Errors []string `json:"errors"`
+}
+
+type ImportMappingAttributesResultDto struct {
+	DryRun   bool                            `json:"dryRun"`
+	Total    int                             `json:"total"`
+	Valid    int                             `json:"valid"`
+	Imported int                             `json:"imported"`
+	Rows     []ImportMappingAttributesRowDto `json:"rows"`
+}
+
+// UpdateMappingEntryAttributesDto is a validated import row, with attributes
+// and neighbours resolved to ids.
+type UpdateMappingEntryAttributesDto struct {
+	EntryID    int
+	Values     map[int]interface{}
+	Neighbours []int
+}
+
+var GetMappingAttributes = func() ([]MappingAttribute, error) {
+	rows, err := Connection.Query("SELECT id, key, label, valueType FROM mappingAttributes ORDER BY id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attributes = []MappingAttribute{}
+	for rows.Next() {
+		var attribute MappingAttribute
+		if err = rows.Scan(&attribute.ID, &attribute.Key, &attribute.Label, &attribute.ValueType); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes, rows.Err()
+}
+
+// getMappingEntryAttributes returns the attribute values for each entry in
+// the group by entry id, as a float64 for numbers and a string for text.
+func getMappingEntryAttributes(key string) (map[int]map[string]interface{}, error) {
+	rows, err := Connection.Query("SELECT a.entryId, t.key, t.valueType, a.textValue, a.numberValue FROM mappingEntryAttributes a JOIN mappingAttributes t ON t.id = a.attributeId JOIN mappingEntries e ON e.id = a.entryId JOIN mappingGroups g ON g.id = e.groupId WHERE g.key = $1;", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := make(map[int]map[string]interface{})
+	for rows.Next() {
+		var entryID int
+		var attribute, valueType string
+		var text sql.NullString
+		var number sql.NullFloat64
+		if err = rows.Scan(&entryID, &attribute, &valueType, &text, &number); err != nil {
+			return nil, err
+		}
+
+		if _, found := attributes[entryID]; !found {
+			attributes[entryID] = make(map[string]interface{})
+		}
+
+		if valueType == MAPPING_ATTRIBUTE_NUMBER && number.Valid {
+			attributes[entryID][attribute] = number.Float64
+		} else if text.Valid {
+			attributes[entryID][attribute] = text.String
+		}
+	}
+	return attributes, rows.Err()
+}
+
+// getMappingEntryNeighbours returns the codes of each entry's neighbours by
+// entry id.
+func getMappingEntryNeighbours(key string) (map[int][]string, error) {
+	rows, err := Connection.Query("SELECT n.entryId, ne.code FROM mappingEntryNeighbours n JOIN mappingEntries e ON e.id = n.entryId JOIN mappingGroups g ON g.id = e.groupId JOIN mappingEntries ne ON ne.id = n.neighbourId WHERE g.key = $1 ORDER BY ne.code;", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	neighbours := make(map[int][]string)
+	for rows.Next() {
+		var entryID int
+		var code string
+		if err = rows.Scan(&entryID, &code); err != nil {
+			return nil, err
+		}
+		neighbours[entryID] = append(neighbours[entryID], code)
+	}
+	return neighbours, rows.Err()
+}
+
+// ValidateMappingAttributeImport checks each row refers to an entry in the
+// group, only uses known attributes with values of the right type and that
+// neighbours are other entries in the group.
+var ValidateMappingAttributeImport = func(key string, rows []MappingEntryAttributesDto) ([]ImportMappingAttributesRowDto, []UpdateMappingEntryAttributesDto, error) {
+	attributes, err := GetMappingAttributes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := GetMappingEntries(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attributesByKey := make(map[string]MappingAttribute)
+	for _, attribute := range attributes {
+		attributesByKey[attribute.Key] = attribute
+	}
+
+	entriesByCode := make(map[string]int)
+	for _, entry := range entries {
+		entriesByCode[strings.ToLower(entry.Code)] = entry.ID
+	}
+
+	var results = []ImportMappingAttributesRowDto{}
+	var valid = []UpdateMappingEntryAttributesDto{}
+	for index, row := range rows {
+		result := ImportMappingAttributesRowDto{
+			Row:    index + 1,
+			Code:   row.Code,
+			Errors: []string{},
+		}
+
+		update := UpdateMappingEntryAttributesDto{Values: make(map[int]interface{})}
+		entryID, found := entriesByCode[strings.ToLower(strings.TrimSpace(row.Code))]
+		if !found {
+			result.Errors = append(result.Errors, fmt.Sprintf("mapping %s has no entry with code %s", key, row.Code))
+		}
+		update.EntryID = entryID
+
+		for name, raw := range row.Attributes {
+			attribute, found := attributesByKey[name]
+			if !found {
+				result.Errors = append(result.Errors, fmt.Sprintf("invalid attribute %s", name))
+				continue
+			}
+
+			value, err := parseMappingAttributeValue(attribute, raw)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			update.Values[attribute.ID] = value
+		}
+
+		if row.Neighbours != nil {
+			update.Neighbours = []int{}
+			for _, code := range row.Neighbours {
+				neighbourID, found := entriesByCode[strings.ToLower(strings.TrimSpace(code))]
+				if !found {
+					result.Errors = append(result.Errors, fmt.Sprintf("invalid neighbour %s", code))
+				} else if neighbourID == entryID {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s can't neighbour itself", code))
+				} else {
+					update.Neighbours = append(update.Neighbours, neighbourID)
+				}
+			}
+		}
+
+		if len(result.Errors) == 0 {
+			valid = append(valid, update)
+		}
+		results = append(results, result)
+	}
+	return results, valid, nil
+}
+
+// parseMappingAttributeValue converts an imported value to a string or
+// float64 to match the attribute. Empty values are returned as nil.
+func parseMappingAttributeValue(attribute MappingAttribute, raw interface{}) (interface{}, error) {
+	switch value := raw.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if attribute.ValueType == MAPPING_ATTRIBUTE_NUMBER {
+			return value, nil
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case string:
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil
+		}
+
+		if attribute.ValueType != MAPPING_ATTRIBUTE_NUMBER {
+			return value, nil
+		}
+
+		number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number; got %s", attribute.Key, value)
+		}
+		return number, nil
+	default:
+		return nil, fmt.Errorf("invalid value for %s", attribute.Key)
+	}
+}
+
+// ImportMappingAttributes saves the validated rows. Neighbours are stored in
+// both directions, so replacing an entry's neighbours also removes it from
+// the entries it no longer borders. Two entries border each other if either
+// row lists the other, so a dataset only listing each border once still
+// imports every edge.
+var ImportMappingAttributes = func(updates []UpdateMappingEntryAttributesDto) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		for _, update := range updates {
+			for attributeID, value := range update.Values {
+				if value == nil {
+					if _, err := tx.Exec("DELETE FROM mappingEntryAttributes WHERE entryId = $1 AND attributeId = $2;", update.EntryID, attributeID); err != nil {
+						return err
+					}
+					continue
+				}
+
+				var text sql.NullString
+				var number sql.NullFloat64
+				if parsed, ok := value.(float64); ok {
+					number = sql.NullFloat64{Float64: parsed, Valid: true}
+				} else {
+					text = sql.NullString{String: value.(string), Valid: true}
+				}
+
+				statement := "INSERT INTO mappingEntryAttributes (entryId, attributeId, textValue, numberValue) VALUES ($1, $2, $3, $4) ON CONFLICT (entryId, attributeId) DO UPDATE SET textValue = $3, numberValue = $4;"
+				if _, err := tx.Exec(statement, update.EntryID, attributeID, text, number); err != nil {
+					return err
+				}
+			}
+
+		}
+
+		entryIDs, edges := getMappingNeighbourEdges(updates)
+		if len(entryIDs) == 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec("DELETE FROM mappingEntryNeighbours WHERE entryId = ANY($1) OR neighbourId = ANY($1);", pq.Array(entryIDs)); err != nil {
+			return err
+		}
+
+		for _, edge := range edges {
+			statement := "INSERT INTO mappingEntryNeighbours (entryId, neighbourId) VALUES ($1, $2), ($2, $1) ON CONFLICT DO NOTHING;"
+			if _, err := tx.Exec(statement, edge[0], edge[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// getMappingNeighbourEdges returns the entries whose neighbours are being
+// replaced and every border between them and their neighbours, once each with
+// the lower id first.
+func getMappingNeighbourEdges(updates []UpdateMappingEntryAttributesDto) ([]int, [][2]int) {
+	var entryIDs = []int{}
+	var edges = [][2]int{}
+	found := make(map[[2]int]bool)
+	for _, update := range updates {
+		if update.Neighbours == nil {
+			continue
+		}
+		entryIDs = append(entryIDs, update.EntryID)
+
+		for _, neighbourID := range update.Neighbours {
+			edge := [2]int{update.EntryID, neighbourID}
+			if neighbourID < update.EntryID {
+				edge = [2]int{neighbourID, update.EntryID}
+			}
+
+			if edge[0] != edge[1] && !found[edge] {
+				found[edge] = true
+				edges = append(edges, edge)
+			}
+		}
+	}
+	return entryIDs, edges
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestImportMappingAttributesNeighbours(t *testing.T) {
+	tt := []struct {
+		name    string
+		updates []UpdateMappingEntryAttributesDto
+		deleted string
+		edges   []string
+	}{
+		{
+			name: "one sided dataset",
+			updates: []UpdateMappingEntryAttributesDto{
+				{EntryID: 1, Neighbours: []int{2}},
+				{EntryID: 2, Neighbours: []int{3}},
+				{EntryID: 3, Neighbours: []int{}},
+			},
+			deleted: "{1,2,3}",
+			edges:   []string{"1-2", "2-3"},
+		},
+		{
+			name: "both sides listed",
+			updates: []UpdateMappingEntryAttributesDto{
+				{EntryID: 5, Neighbours: []int{4, 5}},
+				{EntryID: 4, Neighbours: []int{5}},
+			},
+			deleted: "{5,4}",
+			edges:   []string{"4-5"},
+		},
+		{
+			name: "neighbours left alone",
+			updates: []UpdateMappingEntryAttributesDto{
+				{EntryID: 1, Values: map[int]interface{}{}},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := useRecordingConnection(t)
+			if err := ImportMappingAttributes(tc.updates); err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			var deleted []driver.Value
+			var edges []string
+			for _, statement := range recorder.statements {
+				if strings.HasPrefix(statement.query, "DELETE FROM mappingEntryNeighbours") {
+					deleted = append(deleted, statement.args[0])
+				} else if strings.HasPrefix(statement.query, "INSERT INTO mappingEntryNeighbours") {
+					edges = append(edges, fmt.Sprintf("%v-%v", statement.args[0], statement.args[1]))
+				}
+			}
+
+			if tc.deleted == "" && len(deleted) > 0 || tc.deleted != "" && (len(deleted) != 1 || deleted[0] != tc.deleted) {
+				t.Errorf("expected neighbours of %s to be replaced once; got %v", tc.deleted, deleted)
+			}
+
+			if strings.Join(edges, ",") != strings.Join(tc.edges, ",") {
+				t.Errorf("expected edges %v; got %v", tc.edges, edges)
+			}
+		})
+	}
+}
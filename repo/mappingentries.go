@@ -18,15 +18,17 @@ type MappingEntry struct {
 }
 
 type MappingEntryDto struct {
-	ID               int             `json:"id"`
-	GroupID          int             `json:"groupId"`
-	Name             string          `json:"name"`
-	Code             string          `json:"code"`
-	FlagUrl          string          `json:"flagUrl"`
-	SVGName          string          `json:"svgName"`
-	AlternativeNames *pq.StringArray `json:"alternativeNames"`
-	Prefixes         *pq.StringArray `json:"prefixes"`
-	Grouping         string          `json:"grouping"`
+	ID               int                    `json:"id"`
+	GroupID          int                    `json:"groupId"`
+	Name             string                 `json:"name"`
+	Code             string                 `json:"code"`
+	FlagUrl          string                 `json:"flagUrl"`
+	SVGName          string                 `json:"svgName"`
+	AlternativeNames *pq.StringArray        `json:"alternativeNames"`
+	Prefixes         *pq.StringArray        `json:"prefixes"`
+	Grouping         string                 `json:"grouping"`
+	Attributes       map[string]interface{} `json:"attributes"`
+	Neighbours       []string               `json:"neighbours"`
 }
 
 type CreateMappingEntryDto struct {
@@ -44,7 +46,7 @@ type UpdateMappingEntryDto struct {
 	Grouping         string   `json:"grouping"`
 }
 
-func GetMappingEntries(key string) ([]MappingEntryDto, error) {
+var GetMappingEntries = func(key string) ([]MappingEntryDto, error) {
 	rows, err := Connection.Query("SELECT m.id, m.groupid, m.name, m.code, COALESCE(f.url, ''), m.svgname, lower(m.alternativenames::text)::text[], lower(m.prefixes::text)::text[], m.grouping from mappingEntries m JOIN mappingGroups g ON g.id = m.groupId LEFT JOIN flagEntries f ON f.code = m.code WHERE g.key = $1;", key)
 	if err != nil {
 		return nil, err
@@ -59,13 +61,39 @@ func GetMappingEntries(key string) ([]MappingEntryDto, error) {
 		}
 		entries = append(entries, entry)
 	}
-	return entries, rows.Err()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	attributes, err := getMappingEntryAttributes(key)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbours, err := getMappingEntryNeighbours(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, entry := range entries {
+		entries[index].Attributes = map[string]interface{}{}
+		if values, found := attributes[entry.ID]; found {
+			entries[index].Attributes = values
+		}
+
+		entries[index].Neighbours = []string{}
+		if codes, found := neighbours[entry.ID]; found {
+			entries[index].Neighbours = codes
+		}
+	}
+	return entries, nil
 }
 
-func CreateMappingEntry(groupId int, entry CreateMappingEntryDto) error {
+func createMappingEntry(db executor, groupId int, entry CreateMappingEntryDto) error {
 	var id int
 	statement := "INSERT INTO mappingentries (groupid, name, code, svgname, alternativenames, prefixes, grouping) values ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
-	return Connection.QueryRow(statement, groupId, strings.ToLower(entry.Name), entry.Code, entry.Name, pq.Array([]string{}), pq.Array([]string{}), "").Scan(&id)
+	return db.QueryRow(statement, groupId, strings.ToLower(entry.Name), entry.Code, entry.Name, pq.Array([]string{}), pq.Array([]string{}), "").Scan(&id)
 }
 
 func DeleteMappingEntries(groupId int) error {
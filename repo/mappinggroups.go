@@ -40,15 +40,15 @@ func GetMappingGroups() ([]MappingGroup, error) {
 	return groups, rows.Err()
 }
 
-func CreateMappings(mappings CreateMappingsDto) error {
+func createMappings(db executor, mappings CreateMappingsDto) error {
 	var id int
 	statement := "INSERT INTO mappinggroups (key, label) values ($1, $2) RETURNING id;"
-	if err := Connection.QueryRow(statement, mappings.Key, mappings.Label).Scan(&id); err != nil {
+	if err := db.QueryRow(statement, mappings.Key, mappings.Label).Scan(&id); err != nil {
 		return err
 	}
 
 	for _, entry := range mappings.Entries {
-		if err := CreateMappingEntry(id, entry); err != nil {
+		if err := createMappingEntry(db, id, entry); err != nil {
 			return err
 		}
 	}
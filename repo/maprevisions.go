@@ -0,0 +1,267 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/geobuff/api/utils"
+)
+
+// Reasons a map revision was stored.
+const (
+	MAP_REVISION_ORIGINAL        = "original"
+	MAP_REVISION_CREATED         = "created"
+	MAP_REVISION_UPDATED         = "updated"
+	MAP_REVISION_ELEMENT_ADDED   = "elementAdded"
+	MAP_REVISION_ELEMENT_CHANGED = "elementChanged"
+	MAP_REVISION_ELEMENT_DELETED = "elementDeleted"
+	MAP_REVISION_IMPORTED        = "imported"
+	MAP_REVISION_ROLLED_BACK     = "rolledBack"
+)
+
+type MapRevision struct {
+	Revision int             `json:"revision"`
+	Label    string          `json:"label"`
+	ViewBox  string          `json:"viewBox"`
+	Elements []MapElementDto `json:"elements"`
+	UserID   sql.NullInt64   `json:"userId"`
+	Reason   string          `json:"reason"`
+	Added    time.Time       `json:"added"`
+}
+
+type MapRevisionDto struct {
+	Revision     int           `json:"revision"`
+	Label        string        `json:"label"`
+	ViewBox      string        `json:"viewBox"`
+	ElementCount int           `json:"elementCount"`
+	UserID       sql.NullInt64 `json:"userId"`
+	Reason       string        `json:"reason"`
+	Added        time.Time     `json:"added"`
+}
+
+type UpdateMapDto struct {
+	Label   string `json:"label" validate:"required"`
+	ViewBox string `json:"viewBox" validate:"required"`
+}
+
+type ImportMapDto struct {
+	ViewBox  string          `json:"viewBox"`
+	Elements []MapElementDto `json:"elements"`
+}
+
+type mapAuditDetails struct {
+	ClassName string      `json:"className"`
+	Revision  int         `json:"revision"`
+	Reason    string      `json:"reason"`
+	Change    interface{} `json:"change,omitempty"`
+}
+
+var GetMapRevisions = func(className string) ([]MapRevisionDto, error) {
+	statement := "SELECT r.revision, r.label, r.viewBox, jsonb_array_length(r.elements), r.userId, r.reason, r.added FROM mapRevisions r JOIN maps m ON m.id = r.mapId WHERE m.classname = $1 ORDER BY r.revision DESC;"
+	rows, err := Connection.Query(statement, className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions = []MapRevisionDto{}
+	for rows.Next() {
+		var revision MapRevisionDto
+		if err = rows.Scan(&revision.Revision, &revision.Label, &revision.ViewBox, &revision.ElementCount, &revision.UserID, &revision.Reason, &revision.Added); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+var GetMapRevision = func(className string, revision int) (MapRevision, error) {
+	return getMapRevision(Connection, className, revision)
+}
+
+func getMapRevision(db executor, className string, revision int) (MapRevision, error) {
+	statement := "SELECT r.revision, r.label, r.viewBox, r.elements, r.userId, r.reason, r.added FROM mapRevisions r JOIN maps m ON m.id = r.mapId WHERE m.classname = $1 AND r.revision = $2;"
+	var result MapRevision
+	var elements []byte
+	err := db.QueryRow(statement, className, revision).Scan(&result.Revision, &result.Label, &result.ViewBox, &elements, &result.UserID, &result.Reason, &result.Added)
+	if err != nil {
+		return MapRevision{}, err
+	}
+	return result, json.Unmarshal(elements, &result.Elements)
+}
+
+// UpdateMap changes the map's label and viewBox. The key and class name are
+// left alone as quizzes and mappings refer to the map by them.
+var UpdateMap = func(userID int, className string, dto UpdateMapDto) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_UPDATED, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		viewBoxChanged := m.ViewBox != dto.ViewBox
+		m.Label, m.ViewBox = dto.Label, dto.ViewBox
+		if !viewBoxChanged {
+			return dto, nil
+		}
+
+		// Simplified geometry depends on the viewBox so it has to be redone.
+		for _, element := range m.Elements {
+			if err := refreshMapElementDetails(tx, m.ViewBox, element); err != nil {
+				return nil, err
+			}
+		}
+		return dto, nil
+	})
+}
+
+var AddMapElement = func(userID int, className string, element MapElementDto) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_ELEMENT_ADDED, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		_, err := createMapElement(tx, m.ID, m.ViewBox, element)
+		return mapElementChange(element), err
+	})
+}
+
+var ReplaceMapElement = func(userID int, className string, entryID int, element MapElementDto) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_ELEMENT_CHANGED, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		return mapElementChange(element), replaceMapElement(tx, m.ID, entryID, m.ViewBox, element)
+	})
+}
+
+var DeleteMapElement = func(userID int, className string, entryID int) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_ELEMENT_DELETED, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		var element MapElementDto
+		err := tx.QueryRow("DELETE FROM mapElements WHERE id = $1 AND mapId = $2 RETURNING elementId, name;", entryID, m.ID).Scan(&element.ID, &element.Name)
+		return mapElementChange(element), err
+	})
+}
+
+// ImportMap replaces the map's geometry with a new revision of its SVG.
+// Elements are matched to the current ones on their id, which for mapped
+// regions is the mapping code, so they keep their row.
+var ImportMap = func(userID int, className string, dto ImportMapDto) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_IMPORTED, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		m.ViewBox = dto.ViewBox
+		return map[string]int{"elements": len(dto.Elements)}, syncMapElements(tx, *m, dto.Elements)
+	})
+}
+
+// RollbackMap restores the label, viewBox and elements stored for an earlier
+// revision. The rollback is itself a new revision so it can be undone.
+var RollbackMap = func(userID int, className string, revision int) (MapDto, error) {
+	return editMap(userID, className, MAP_REVISION_ROLLED_BACK, func(tx *sql.Tx, m *MapDto) (interface{}, error) {
+		previous, err := getMapRevision(tx, className, revision)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Label, m.ViewBox = previous.Label, previous.ViewBox
+		return map[string]int{"revision": revision}, syncMapElements(tx, *m, previous.Elements)
+	})
+}
+
+// editMap applies a change to the map as a new revision. The current state is
+// stored first in case the map predates revisions, then the result of the
+// change is stored under the next revision number.
+func editMap(userID int, className, reason string, edit func(tx *sql.Tx, m *MapDto) (interface{}, error)) (MapDto, error) {
+	var result MapDto
+	err := withTransaction(func(tx *sql.Tx) error {
+		m, err := getMap(tx, className, utils.MAP_DETAIL_FULL, true)
+		if err != nil {
+			return err
+		}
+
+		statement := "INSERT INTO mapRevisions (mapId, revision, label, viewBox, elements, userId, reason, added) VALUES ($1, $2, $3, $4, $5, NULL, $6, $7) ON CONFLICT (mapId, revision) DO NOTHING;"
+		elements, err := json.Marshal(m.Elements)
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(statement, m.ID, m.Revision, m.Label, m.ViewBox, string(elements), MAP_REVISION_ORIGINAL, time.Now()); err != nil {
+			return err
+		}
+
+		change, err := edit(tx, &m)
+		if err != nil {
+			return err
+		}
+
+		statement = "UPDATE maps SET label = $2, viewbox = $3, revision = revision + 1 WHERE id = $1 RETURNING revision;"
+		if err = tx.QueryRow(statement, m.ID, m.Label, m.ViewBox).Scan(&m.Revision); err != nil {
+			return err
+		}
+
+		if m.Elements, err = getMapElements(tx, m.ID, utils.MAP_DETAIL_FULL); err != nil {
+			return err
+		}
+
+		if err = createMapRevision(tx, userID, m, reason); err != nil {
+			return err
+		}
+
+		result = m
+		return insertAuditLog(tx, userID, AUDIT_ACTION_UPDATE, AUDIT_ENTITY_MAP, m.ID, mapAuditDetails{ClassName: m.ClassName, Revision: m.Revision, Reason: reason, Change: change})
+	})
+	return result, err
+}
+
+func createMapRevision(db executor, userID int, m MapDto, reason string) error {
+	elements, err := json.Marshal(m.Elements)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	statement := "INSERT INTO mapRevisions (mapId, revision, label, viewBox, elements, userId, reason, added) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;"
+	return db.QueryRow(statement, m.ID, m.Revision, m.Label, m.ViewBox, string(elements), userID, reason, time.Now()).Scan(&id)
+}
+
+// syncMapElements makes the map's elements match the given ones. Elements with
+// an id that's already on the map are updated in place, the rest are added and
+// anything left over is deleted.
+func syncMapElements(db executor, m MapDto, elements []MapElementDto) error {
+	existing := make(map[string]MapElementDto)
+	for _, element := range m.Elements {
+		if element.ID != "" {
+			existing[strings.ToLower(element.ID)] = element
+		}
+	}
+
+	kept := make(map[int]bool)
+	for _, element := range elements {
+		current, found := existing[strings.ToLower(element.ID)]
+		if element.ID == "" || !found {
+			if _, err := createMapElement(db, m.ID, m.ViewBox, element); err != nil {
+				return err
+			}
+			continue
+		}
+
+		delete(existing, strings.ToLower(element.ID))
+		kept[current.EntryID] = true
+		if err := replaceMapElement(db, m.ID, current.EntryID, m.ViewBox, element); err != nil {
+			return err
+		}
+	}
+
+	for _, element := range m.Elements {
+		if kept[element.EntryID] {
+			continue
+		}
+
+		if _, err := db.Exec("DELETE FROM mapElements WHERE id = $1;", element.EntryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func refreshMapElementDetails(db executor, viewBox string, element MapElementDto) error {
+	if _, err := db.Exec("DELETE FROM mapElementDetails WHERE elementId = $1;", element.EntryID); err != nil {
+		return err
+	}
+	return createMapElementDetails(db, mapElementGeometry{id: element.EntryID, typeName: element.Type, d: element.D, points: element.Points, transform: element.Transform, viewBox: viewBox})
+}
+
+// mapElementChange is what's kept in the audit log for an element change, the
+// geometry itself is in the revision.
+func mapElementChange(element MapElementDto) map[string]string {
+	return map[string]string{"id": element.ID, "name": element.Name}
+}
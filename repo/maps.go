@@ -1,5 +1,11 @@
 package repo
 
+import (
+	"database/sql"
+
+	"github.com/geobuff/api/utils"
+)
+
 type Map struct {
 	ID        int    `json:"id"`
 	Key       string `json:"key"`
@@ -14,6 +20,7 @@ type MapDto struct {
 	ClassName string          `json:"className"`
 	Label     string          `json:"label"`
 	ViewBox   string          `json:"viewBox"`
+	Revision  int             `json:"revision"`
 	Elements  []MapElementDto `json:"elements"`
 }
 
@@ -57,15 +64,30 @@ func GetMaps() ([]GetMapsDto, error) {
 	return maps, rows.Err()
 }
 
-func GetMap(className string) (MapDto, error) {
-	statement := "SELECT * from maps WHERE classname = $1;"
+var GetMap = func(className string) (MapDto, error) {
+	return GetMapWithDetail(className, utils.MAP_DETAIL_FULL)
+}
+
+var GetMapWithDetail = func(className, detail string) (MapDto, error) {
+	return getMap(Connection, className, detail, false)
+}
+
+// getMap loads the map and its elements. With lock set the map row is locked
+// until the transaction ends so concurrent edits can't claim the same
+// revision.
+func getMap(db executor, className, detail string, lock bool) (MapDto, error) {
+	statement := "SELECT id, key, classname, label, viewbox, revision from maps WHERE classname = $1;"
+	if lock {
+		statement = "SELECT id, key, classname, label, viewbox, revision from maps WHERE classname = $1 FOR UPDATE;"
+	}
+
 	var m MapDto
-	err := Connection.QueryRow(statement, className).Scan(&m.ID, &m.Key, &m.ClassName, &m.Label, &m.ViewBox)
+	err := db.QueryRow(statement, className).Scan(&m.ID, &m.Key, &m.ClassName, &m.Label, &m.ViewBox, &m.Revision)
 	if err != nil {
 		return MapDto{}, err
 	}
 
-	elements, err := GetMapElements(m.ID)
+	elements, err := getMapElements(db, m.ID, detail)
 	if err != nil {
 		return MapDto{}, err
 	}
@@ -75,9 +97,9 @@ func GetMap(className string) (MapDto, error) {
 }
 
 func GetMapUsingKey(key string) (MapDto, error) {
-	statement := "SELECT * from maps WHERE key = $1;"
+	statement := "SELECT id, key, classname, label, viewbox, revision from maps WHERE key = $1;"
 	var m MapDto
-	err := Connection.QueryRow(statement, key).Scan(&m.ID, &m.Key, &m.ClassName, &m.Label, &m.ViewBox)
+	err := Connection.QueryRow(statement, key).Scan(&m.ID, &m.Key, &m.ClassName, &m.Label, &m.ViewBox, &m.Revision)
 	if err != nil {
 		return MapDto{}, err
 	}
@@ -107,19 +129,38 @@ func GetMapHighlightedRegions(className string) ([]HighlightedRegionDto, error)
 	return regions, nil
 }
 
-func CreateMap(svgMap MapDto) error {
-	var id int
-	statement := "INSERT INTO maps (key, classname, label, viewbox) values ($1, $2, $3, $4) RETURNING id;"
-	if err := Connection.QueryRow(statement, svgMap.Key, svgMap.ClassName, svgMap.Label, svgMap.ViewBox).Scan(&id); err != nil {
-		return err
-	}
+// CreateMap stores a new map along with its mappings and quiz, so a map is
+// never left without the quiz that plays it. The map starts at revision 1.
+var CreateMap = func(userID int, svgMap MapDto, mappings CreateMappingsDto, quiz CreateQuizDto) (MapDto, error) {
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO maps (key, classname, label, viewbox) values ($1, $2, $3, $4) RETURNING id, revision;"
+		if err := tx.QueryRow(statement, svgMap.Key, svgMap.ClassName, svgMap.Label, svgMap.ViewBox).Scan(&svgMap.ID, &svgMap.Revision); err != nil {
+			return err
+		}
+
+		for index, element := range svgMap.Elements {
+			id, err := createMapElement(tx, svgMap.ID, svgMap.ViewBox, element)
+			if err != nil {
+				return err
+			}
+			svgMap.Elements[index].EntryID = id
+			svgMap.Elements[index].MapID = svgMap.ID
+		}
 
-	for _, element := range svgMap.Elements {
-		if err := CreateMapElement(id, element); err != nil {
+		if err := createMappings(tx, mappings); err != nil {
 			return err
 		}
-	}
-	return nil
+
+		if _, err := createQuiz(tx, quiz); err != nil {
+			return err
+		}
+
+		if err := createMapRevision(tx, userID, svgMap, MAP_REVISION_CREATED); err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_CREATE, AUDIT_ENTITY_MAP, svgMap.ID, mapAuditDetails{ClassName: svgMap.ClassName, Revision: svgMap.Revision, Reason: MAP_REVISION_CREATED})
+	})
+	return svgMap, err
 }
 
 func GetMapId(key string) (int, error) {
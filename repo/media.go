@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MEDIA_GC_GRACE_PERIOD gives an upload time to be saved on whatever it was
+// uploaded for before it counts as unused.
+const MEDIA_GC_GRACE_PERIOD = 24 * time.Hour
+
+// MEDIA_IN_USE_FILTER matches media m whose url is used by anything with an
+// image.
+const MEDIA_IN_USE_FILTER = "(EXISTS (SELECT 1 FROM quizzes WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM badges WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM avatars WHERE primaryImageUrl = m.url OR secondaryImageUrl = m.url) OR EXISTS (SELECT 1 FROM triviaQuestions WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM manualTriviaQuestions WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM communityQuizQuestions WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM merchImages WHERE imageUrl = m.url) OR EXISTS (SELECT 1 FROM merch WHERE sizeGuideImageUrl = m.url) OR EXISTS (SELECT 1 FROM shippingOptions WHERE imageUrl = m.url))"
+
+type Media struct {
+	ID              int           `json:"id"`
+	Key             string        `json:"key"`
+	URL             string        `json:"url"`
+	ContentType     string        `json:"contentType"`
+	Size            int           `json:"size"`
+	Width           int           `json:"width"`
+	Height          int           `json:"height"`
+	AttributionName string        `json:"attributionName"`
+	AttributionURL  string        `json:"attributionUrl"`
+	UserID          sql.NullInt64 `json:"userId"`
+	Added           time.Time     `json:"added"`
+}
+
+type CreateMediaDto struct {
+	Key             string
+	URL             string
+	ContentType     string
+	Size            int
+	Width           int
+	Height          int
+	AttributionName string
+	AttributionURL  string
+	UserID          int
+}
+
+// CreateMedia records an upload, returning false instead if there's already
+// media with the same key. Uploading it again counts as adding it again, so
+// the existing media isn't cleaned up before it's used.
+var CreateMedia = func(dto CreateMediaDto) (Media, bool, error) {
+	statement := "INSERT INTO media (key, url, contentType, size, width, height, attributionName, attributionUrl, userId, added) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, 0), $10) ON CONFLICT (key) DO NOTHING RETURNING id, key, url, contentType, size, width, height, attributionName, attributionUrl, userId, added;"
+	var media Media
+	err := Connection.QueryRow(statement, dto.Key, dto.URL, dto.ContentType, dto.Size, dto.Width, dto.Height, dto.AttributionName, dto.AttributionURL, dto.UserID, time.Now()).Scan(&media.ID, &media.Key, &media.URL, &media.ContentType, &media.Size, &media.Width, &media.Height, &media.AttributionName, &media.AttributionURL, &media.UserID, &media.Added)
+	if err == sql.ErrNoRows {
+		statement = "UPDATE media SET added = $2 WHERE key = $1 RETURNING id, key, url, contentType, size, width, height, attributionName, attributionUrl, userId, added;"
+		err = Connection.QueryRow(statement, dto.Key, time.Now()).Scan(&media.ID, &media.Key, &media.URL, &media.ContentType, &media.Size, &media.Width, &media.Height, &media.AttributionName, &media.AttributionURL, &media.UserID, &media.Added)
+		return media, false, err
+	}
+	return media, err == nil, err
+}
+
+var DeleteMedia = func(id int) error {
+	return Connection.QueryRow("DELETE FROM media WHERE id = $1 RETURNING id;", id).Scan(&id)
+}
+
+// GetUnusedMedia returns media added before the cutoff that nothing uses.
+var GetUnusedMedia = func(cutoff time.Time) ([]Media, error) {
+	statement := "SELECT m.id, m.key, m.url, m.contentType, m.size, m.width, m.height, m.attributionName, m.attributionUrl, m.userId, m.added FROM media m WHERE m.added < $1 AND NOT " + MEDIA_IN_USE_FILTER + " ORDER BY m.id;"
+	rows, err := Connection.Query(statement, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media = []Media{}
+	for rows.Next() {
+		var val Media
+		if err = rows.Scan(&val.ID, &val.Key, &val.URL, &val.ContentType, &val.Size, &val.Width, &val.Height, &val.AttributionName, &val.AttributionURL, &val.UserID, &val.Added); err != nil {
+			return nil, err
+		}
+		media = append(media, val)
+	}
+	return media, rows.Err()
+}
+
+// DeleteUnusedMedia removes the media if it's still unused, returning false if
+// something has started using it since it was found.
+var DeleteUnusedMedia = func(id int) (bool, error) {
+	err := Connection.QueryRow("DELETE FROM media m WHERE m.id = $1 AND NOT "+MEDIA_IN_USE_FILTER+" RETURNING m.id;", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var GetMediaKeys = func() (map[string]bool, error) {
+	rows, err := Connection.Query("SELECT key FROM media;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
+
+// fillImageDetails copies the size and attribution of an uploaded image onto
+// whatever is using it, leaving anything already set alone. Images that
+// weren't uploaded are left as they are.
+func fillImageDetails(db executor, url string, width, height *int, attributionName, attributionURL *string) error {
+	if url == "" || *width > 0 && *height > 0 && *attributionName != "" && *attributionURL != "" {
+		return nil
+	}
+
+	var media Media
+	err := db.QueryRow("SELECT width, height, attributionName, attributionUrl FROM media WHERE url = $1;", url).Scan(&media.Width, &media.Height, &media.AttributionName, &media.AttributionURL)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if *width <= 0 || *height <= 0 {
+		*width, *height = media.Width, media.Height
+	}
+
+	if *attributionName == "" {
+		*attributionName = media.AttributionName
+	}
+
+	if *attributionURL == "" {
+		*attributionURL = media.AttributionURL
+	}
+	return nil
+}
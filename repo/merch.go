@@ -13,6 +13,7 @@ type Merch struct {
 	Price             sql.NullFloat64 `json:"price"`
 	ExternalLink      sql.NullString  `json:"externalLink"`
 	Route             string          `json:"route"`
+	Weight            sql.NullInt64   `json:"weight"`
 }
 
 type MerchDto struct {
@@ -23,11 +24,23 @@ type MerchDto struct {
 	Price             sql.NullFloat64 `json:"price"`
 	ExternalLink      sql.NullString  `json:"externalLink"`
 	Route             string          `json:"route"`
+	Weight            sql.NullInt64   `json:"weight"`
+	Currency          string          `json:"currency"`
 	Sizes             []MerchSize     `json:"sizes"`
 	Images            []MerchImage    `json:"images"`
 	SoldOut           bool            `json:"soldOut"`
 }
 
+type MerchDetailsDto struct {
+	Name              string          `json:"name" validate:"required"`
+	Description       string          `json:"description" validate:"required"`
+	SizeGuideImageUrl sql.NullString  `json:"sizeGuideImageUrl"`
+	Price             sql.NullFloat64 `json:"price"`
+	ExternalLink      sql.NullString  `json:"externalLink"`
+	Route             string          `json:"route" validate:"required,route"`
+	Weight            sql.NullInt64   `json:"weight"`
+}
+
 type CartItemDto struct {
 	ID          int     `json:"id"`
 	Name        string  `json:"name"`
@@ -40,7 +53,7 @@ type CartItemDto struct {
 }
 
 var GetMerch = func() ([]MerchDto, error) {
-	rows, err := Connection.Query("SELECT * FROM merch ORDER BY id;")
+	rows, err := Connection.Query("SELECT id, name, description, sizeGuideImageUrl, price, externalLink, route, weight FROM merch ORDER BY id;")
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +62,7 @@ var GetMerch = func() ([]MerchDto, error) {
 	var merch = []MerchDto{}
 	for rows.Next() {
 		var entry MerchDto
-		if err = rows.Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route); err != nil {
+		if err = rows.Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route, &entry.Weight); err != nil {
 			return nil, err
 		}
 
@@ -71,10 +84,10 @@ var GetMerch = func() ([]MerchDto, error) {
 	return merch, rows.Err()
 }
 
-func GetMerchItem(id int) (*MerchDto, error) {
-	statement := "SELECT * from merch WHERE id = $1;"
+var GetMerchItem = func(id int) (*MerchDto, error) {
+	statement := "SELECT id, name, description, sizeGuideImageUrl, price, externalLink, route, weight FROM merch WHERE id = $1;"
 	var entry MerchDto
-	if err := Connection.QueryRow(statement, id).Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route); err != nil {
+	if err := Connection.QueryRow(statement, id).Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route, &entry.Weight); err != nil {
 		return nil, err
 	}
 
@@ -121,3 +134,55 @@ func GetMerchRoutes() ([]string, error) {
 	}
 	return routes, rows.Err()
 }
+
+var CreateMerch = func(userID int, merch MerchDetailsDto) (Merch, error) {
+	var entry Merch
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO merch (name, description, sizeGuideImageUrl, price, externalLink, route, weight) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, name, description, sizeGuideImageUrl, price, externalLink, route, weight;"
+		err := tx.QueryRow(statement, merch.Name, merch.Description, merch.SizeGuideImageUrl, merch.Price, merch.ExternalLink, merch.Route, merch.Weight).Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route, &entry.Weight)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_CREATE, AUDIT_ENTITY_MERCH, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var UpdateMerch = func(userID, merchID int, merch MerchDetailsDto) (Merch, error) {
+	var entry Merch
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "UPDATE merch SET name = $2, description = $3, sizeGuideImageUrl = $4, price = $5, externalLink = $6, route = $7, weight = $8 WHERE id = $1 RETURNING id, name, description, sizeGuideImageUrl, price, externalLink, route, weight;"
+		err := tx.QueryRow(statement, merchID, merch.Name, merch.Description, merch.SizeGuideImageUrl, merch.Price, merch.ExternalLink, merch.Route, merch.Weight).Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route, &entry.Weight)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_UPDATE, AUDIT_ENTITY_MERCH, entry.ID, entry)
+	})
+	return entry, err
+}
+
+// DeleteMerch removes the item along with its sizes, images, discounts and
+// currency prices. It fails with a foreign key violation if the item has ever
+// been ordered.
+var DeleteMerch = func(userID, merchID int) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		for _, statement := range []string{
+			"DELETE FROM merchImages WHERE merchId = $1;",
+			"DELETE FROM merchSizes WHERE merchId = $1;",
+			"DELETE FROM discounts WHERE merchId = $1;",
+			"DELETE FROM merchPrices WHERE merchId = $1;",
+		} {
+			if _, err := tx.Exec(statement, merchID); err != nil {
+				return err
+			}
+		}
+
+		var entry Merch
+		statement := "DELETE FROM merch WHERE id = $1 RETURNING id, name, description, sizeGuideImageUrl, price, externalLink, route, weight;"
+		err := tx.QueryRow(statement, merchID).Scan(&entry.ID, &entry.Name, &entry.Description, &entry.SizeGuideImageUrl, &entry.Price, &entry.ExternalLink, &entry.Route, &entry.Weight)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_DELETE, AUDIT_ENTITY_MERCH, entry.ID, entry)
+	})
+}
@@ -1,5 +1,7 @@
 package repo
 
+import "database/sql"
+
 type MerchImage struct {
 	ID        int    `json:"id"`
 	MerchID   int    `json:"merchId"`
@@ -24,3 +26,100 @@ func getMerchImages(merchID int) ([]MerchImage, error) {
 	}
 	return images, rows.Err()
 }
+
+type MerchImageDto struct {
+	MerchID   int    `json:"merchId" validate:"required"`
+	ImageUrl  string `json:"imageUrl" validate:"required"`
+	IsPrimary bool   `json:"isPrimary"`
+}
+
+// CreateMerchImage adds an image to the item. The first image of an item is
+// always primary, and making an image primary demotes the previous one.
+var CreateMerchImage = func(userID int, image MerchImageDto) (MerchImage, error) {
+	var entry MerchImage
+	err := withTransaction(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(id) FROM merchImages WHERE merchId = $1;", image.MerchID).Scan(&count); err != nil {
+			return err
+		}
+
+		statement := "INSERT INTO merchImages (merchId, imageUrl, isPrimary) VALUES ($1, $2, $3) RETURNING id, merchId, imageUrl, isPrimary;"
+		err := tx.QueryRow(statement, image.MerchID, image.ImageUrl, image.IsPrimary || count == 0).Scan(&entry.ID, &entry.MerchID, &entry.ImageUrl, &entry.IsPrimary)
+		if err != nil {
+			return err
+		}
+
+		if err = setPrimaryMerchImage(tx, entry); err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_CREATE, AUDIT_ENTITY_MERCH_IMAGE, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var UpdateMerchImage = func(userID, imageID int, image MerchImageDto) (MerchImage, error) {
+	var entry MerchImage
+	err := withTransaction(func(tx *sql.Tx) error {
+		var previous MerchImage
+		err := tx.QueryRow("SELECT id, merchId, imageUrl, isPrimary FROM merchImages WHERE id = $1 FOR UPDATE;", imageID).Scan(&previous.ID, &previous.MerchID, &previous.ImageUrl, &previous.IsPrimary)
+		if err != nil {
+			return err
+		}
+
+		statement := "UPDATE merchImages SET merchId = $2, imageUrl = $3, isPrimary = $4 WHERE id = $1 RETURNING id, merchId, imageUrl, isPrimary;"
+		err = tx.QueryRow(statement, imageID, image.MerchID, image.ImageUrl, image.IsPrimary).Scan(&entry.ID, &entry.MerchID, &entry.ImageUrl, &entry.IsPrimary)
+		if err != nil {
+			return err
+		}
+
+		if err = setPrimaryMerchImage(tx, entry); err != nil {
+			return err
+		}
+
+		if previous.IsPrimary && (!entry.IsPrimary || previous.MerchID != entry.MerchID) {
+			if err = ensurePrimaryMerchImage(tx, previous.MerchID); err != nil {
+				return err
+			}
+		}
+
+		if err = tx.QueryRow("SELECT isPrimary FROM merchImages WHERE id = $1;", imageID).Scan(&entry.IsPrimary); err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_UPDATE, AUDIT_ENTITY_MERCH_IMAGE, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var DeleteMerchImage = func(userID, imageID int) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		var entry MerchImage
+		err := tx.QueryRow("DELETE FROM merchImages WHERE id = $1 RETURNING id, merchId, imageUrl, isPrimary;", imageID).Scan(&entry.ID, &entry.MerchID, &entry.ImageUrl, &entry.IsPrimary)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsPrimary {
+			if err = ensurePrimaryMerchImage(tx, entry.MerchID); err != nil {
+				return err
+			}
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_DELETE, AUDIT_ENTITY_MERCH_IMAGE, entry.ID, entry)
+	})
+}
+
+func setPrimaryMerchImage(tx *sql.Tx, image MerchImage) error {
+	if !image.IsPrimary {
+		return nil
+	}
+
+	_, err := tx.Exec("UPDATE merchImages SET isPrimary = FALSE WHERE merchId = $1 AND id != $2;", image.MerchID, image.ID)
+	return err
+}
+
+// ensurePrimaryMerchImage promotes the oldest image when an item is left
+// without a primary one, since order item lookups join on it.
+func ensurePrimaryMerchImage(tx *sql.Tx, merchID int) error {
+	statement := "UPDATE merchImages SET isPrimary = TRUE WHERE id = (SELECT id FROM merchImages WHERE merchId = $1 ORDER BY id LIMIT 1) AND NOT EXISTS (SELECT 1 FROM merchImages WHERE merchId = $1 AND isPrimary);"
+	_, err := tx.Exec(statement, merchID)
+	return err
+}
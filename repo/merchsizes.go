@@ -1,5 +1,11 @@
 package repo
 
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
 type MerchSize struct {
 	ID       int    `json:"id"`
 	MerchID  int    `json:"merchId"`
@@ -25,10 +31,112 @@ func getMerchSizes(merchID int) ([]MerchSize, error) {
 	return sizes, rows.Err()
 }
 
-func ReduceMerchItemQuantity(sizeID, decrease int) error {
+func reduceMerchItemQuantity(db executor, sizeID, decrease int) error {
 	statement := "UPDATE merchsizes SET quantity = quantity - $1 WHERE id = $2 RETURNING id;"
 	var id int
-	return Connection.QueryRow(statement, decrease, sizeID).Scan(&id)
+	return db.QueryRow(statement, decrease, sizeID).Scan(&id)
+}
+
+type MerchSizeDto struct {
+	MerchID  int    `json:"merchId" validate:"required"`
+	Size     string `json:"size" validate:"required"`
+	Quantity *int   `json:"quantity" validate:"required,min=0"`
+}
+
+var CreateMerchSize = func(userID int, size MerchSizeDto) (MerchSize, error) {
+	var entry MerchSize
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO merchSizes (merchId, size, quantity) VALUES ($1, $2, $3) RETURNING id, merchId, size, quantity;"
+		err := tx.QueryRow(statement, size.MerchID, size.Size, *size.Quantity).Scan(&entry.ID, &entry.MerchID, &entry.Size, &entry.Quantity)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_CREATE, AUDIT_ENTITY_MERCH_SIZE, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var UpdateMerchSize = func(userID, sizeID int, size MerchSizeDto) (MerchSize, error) {
+	var entry MerchSize
+	err := withTransaction(func(tx *sql.Tx) error {
+		statement := "UPDATE merchSizes SET merchId = $2, size = $3, quantity = $4 WHERE id = $1 RETURNING id, merchId, size, quantity;"
+		err := tx.QueryRow(statement, sizeID, size.MerchID, size.Size, *size.Quantity).Scan(&entry.ID, &entry.MerchID, &entry.Size, &entry.Quantity)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_UPDATE, AUDIT_ENTITY_MERCH_SIZE, entry.ID, entry)
+	})
+	return entry, err
+}
+
+var DeleteMerchSize = func(userID, sizeID int) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		var entry MerchSize
+		err := tx.QueryRow("DELETE FROM merchSizes WHERE id = $1 RETURNING id, merchId, size, quantity;", sizeID).Scan(&entry.ID, &entry.MerchID, &entry.Size, &entry.Quantity)
+		if err != nil {
+			return err
+		}
+		return insertAuditLog(tx, userID, AUDIT_ACTION_DELETE, AUDIT_ENTITY_MERCH_SIZE, entry.ID, entry)
+	})
+}
+
+// InsufficientStockError is returned when a checkout asks for more of a size
+// than is currently available.
+type InsufficientStockError struct {
+	SizeID    int
+	Size      string
+	Requested int
+	Available int
+}
+
+func (e InsufficientStockError) Error() string {
+	if e.Size == "" {
+		return fmt.Sprintf("size %d is not available", e.SizeID)
+	}
+	return fmt.Sprintf("size %s is not available (requested %d, %d left)", e.Size, e.Requested, e.Available)
+}
+
+// reserveMerchSizes takes the requested quantities out of stock. Rows are
+// locked in size order so that concurrent checkouts can't deadlock or oversell.
+func reserveMerchSizes(tx *sql.Tx, items []CheckoutItemDto) error {
+	requested := make(map[int]int)
+	merchIDs := make(map[int]int)
+	for _, item := range items {
+		requested[item.SizeID] = requested[item.SizeID] + item.Quantity
+		merchIDs[item.SizeID] = item.ID
+	}
+
+	sizeIDs := make([]int, 0, len(requested))
+	for sizeID := range requested {
+		sizeIDs = append(sizeIDs, sizeID)
+	}
+	sort.Ints(sizeIDs)
+
+	for _, sizeID := range sizeIDs {
+		var size string
+		var quantity int
+		err := tx.QueryRow("SELECT size, quantity FROM merchsizes WHERE id = $1 AND merchid = $2 FOR UPDATE;", sizeID, merchIDs[sizeID]).Scan(&size, &quantity)
+		if err == sql.ErrNoRows {
+			return InsufficientStockError{SizeID: sizeID, Requested: requested[sizeID]}
+		} else if err != nil {
+			return err
+		}
+
+		if requested[sizeID] <= 0 || quantity < requested[sizeID] {
+			return InsufficientStockError{SizeID: sizeID, Size: size, Requested: requested[sizeID], Available: quantity}
+		}
+
+		if err = reduceMerchItemQuantity(tx, sizeID, requested[sizeID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restockOrderItems(db executor, orderID int) error {
+	statement := "UPDATE merchsizes s SET quantity = s.quantity + i.total FROM (SELECT sizeId, SUM(quantity) total FROM orderItems WHERE orderId = $1 GROUP BY sizeId) i WHERE s.id = i.sizeId;"
+	_, err := db.Exec(statement, orderID)
+	return err
 }
 
 func MerchExists(items []CartItemDto) (bool, error) {
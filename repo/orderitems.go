@@ -1,5 +1,11 @@
 package repo
 
+import (
+	"database/sql"
+
+	"github.com/geobuff/api/utils"
+)
+
 type OrderItem struct {
 	ID       int `json:"id"`
 	OrderID  int `json:"orderId"`
@@ -9,22 +15,26 @@ type OrderItem struct {
 }
 
 type OrderItemDto struct {
-	MerchID  int    `json:"merchId"`
-	ItemName string `json:"itemName"`
-	SizeID   int    `json:"sizeId"`
-	SizeName string `json:"sizeName"`
-	ImageUrl string `json:"imageUrl"`
-	Quantity int    `json:"quantity"`
+	MerchID   int             `json:"merchId"`
+	ItemName  string          `json:"itemName"`
+	SizeID    int             `json:"sizeId"`
+	SizeName  string          `json:"sizeName"`
+	ImageUrl  string          `json:"imageUrl"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice sql.NullFloat64 `json:"unitPrice"`
+	Subtotal  sql.NullFloat64 `json:"subtotal"`
+	Discount  sql.NullFloat64 `json:"discount"`
+	Total     sql.NullFloat64 `json:"total"`
 }
 
-func insertOrderItem(item CheckoutItemDto, orderId int) error {
-	statement := "INSERT INTO orderItems (orderid, merchid, sizeid, quantity) VALUES ($1, $2, $3, $4) RETURNING id;"
+func insertOrderItem(db executor, line utils.PriceLine, orderId int) error {
+	statement := "INSERT INTO orderItems (orderid, merchid, sizeid, quantity, unitPrice, subtotal, discount, total) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;"
 	var id int
-	return Connection.QueryRow(statement, orderId, item.ID, item.SizeID, item.Quantity).Scan(&id)
+	return db.QueryRow(statement, orderId, line.MerchID, line.SizeID, line.Quantity, line.UnitPrice, line.Subtotal, line.Discount, line.Total).Scan(&id)
 }
 
 func GetOrderItems(orderID int) ([]OrderItemDto, error) {
-	statement := "select i.merchid, m.name, s.id, s.size, mi.imageurl, i.quantity from orderItems i join merchsizes s on s.id = i.sizeid join merch m on m.id = i.merchid join merchimages mi on mi.merchid = i.merchid AND mi.isprimary WHERE i.orderId = $1;"
+	statement := "select i.merchid, m.name, s.id, s.size, mi.imageurl, i.quantity, i.unitPrice, i.subtotal, i.discount, i.total from orderItems i join merchsizes s on s.id = i.sizeid join merch m on m.id = i.merchid join merchimages mi on mi.merchid = i.merchid AND mi.isprimary WHERE i.orderId = $1;"
 	rows, err := Connection.Query(statement, orderID)
 	if err != nil {
 		return nil, err
@@ -34,7 +44,7 @@ func GetOrderItems(orderID int) ([]OrderItemDto, error) {
 	var items = []OrderItemDto{}
 	for rows.Next() {
 		var item OrderItemDto
-		if err = rows.Scan(&item.MerchID, &item.ItemName, &item.SizeID, &item.SizeName, &item.ImageUrl, &item.Quantity); err != nil {
+		if err = rows.Scan(&item.MerchID, &item.ItemName, &item.SizeID, &item.SizeName, &item.ImageUrl, &item.Quantity, &item.UnitPrice, &item.Subtotal, &item.Discount, &item.Total); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -2,19 +2,32 @@ package repo
 
 import (
 	"database/sql"
+	"strings"
 	"time"
+
+	"github.com/geobuff/api/utils"
 )
 
+// ORDER_RESERVATION_DURATION is how long stock is held for a pending order. The
+// Stripe session expires at the same time, which must be at least 30 minutes out.
+const ORDER_RESERVATION_DURATION = time.Hour
+
+// ORDER_RESERVATION_GRACE_PERIOD gives Stripe time to deliver the expiry (or a
+// late payment) before stale pending orders are cleaned up.
+const ORDER_RESERVATION_GRACE_PERIOD = 15 * time.Minute
+
 type Order struct {
-	ID         int           `json:"id"`
-	StatusID   int           `json:"statusId"`
-	ShippingId int           `json:"shippingId"`
-	DiscountId sql.NullInt64 `json:"discountId"`
-	Email      string        `json:"email"`
-	FirstName  string        `json:"firstName"`
-	LastName   string        `json:"lastName"`
-	Address    string        `json:"address"`
-	Added      time.Time     `json:"added"`
+	ID                int            `json:"id"`
+	StatusID          int            `json:"statusId"`
+	ShippingId        int            `json:"shippingId"`
+	DiscountId        sql.NullInt64  `json:"discountId"`
+	Email             string         `json:"email"`
+	FirstName         string         `json:"firstName"`
+	LastName          string         `json:"lastName"`
+	Address           utils.Address  `json:"address"`
+	Added             time.Time      `json:"added"`
+	CheckoutSessionID sql.NullString `json:"checkoutSessionId"`
+	ReservedUntil     sql.NullTime   `json:"reservedUntil"`
 }
 
 type OrderStatus struct {
@@ -30,10 +43,10 @@ type CheckoutItemDto struct {
 }
 
 type Customer struct {
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Address   string `json:"address"`
+	Email     string        `json:"email"`
+	FirstName string        `json:"firstName"`
+	LastName  string        `json:"lastName"`
+	Address   utils.Address `json:"address"`
 }
 
 type CreateCheckoutDto struct {
@@ -41,21 +54,39 @@ type CreateCheckoutDto struct {
 	Customer   Customer          `json:"customer"`
 	ShippingId int               `json:"shippingId"`
 	DiscountId sql.NullInt64     `json:"discountId"`
+	Currency   string            `json:"currency"`
 }
 
 type OrderDto struct {
-	ID             int            `json:"id"`
-	StatusID       int            `json:"statusId"`
-	Status         string         `json:"status"`
-	ShippingOption string         `json:"shippingOption"`
-	Discount       sql.NullString `json:"discount"`
-	FirstName      string         `json:"firstName"`
-	LastName       string         `json:"lastName"`
-	Address        string         `json:"address"`
-	Added          time.Time      `json:"added"`
-	Items          []OrderItemDto `json:"items"`
+	ID             int             `json:"id"`
+	StatusID       int             `json:"statusId"`
+	Status         string          `json:"status"`
+	ShippingOption string          `json:"shippingOption"`
+	Discount       sql.NullString  `json:"discount"`
+	Subtotal       sql.NullFloat64 `json:"subtotal"`
+	DiscountTotal  sql.NullFloat64 `json:"discountTotal"`
+	ShippingTotal  sql.NullFloat64 `json:"shippingTotal"`
+	Total          sql.NullFloat64 `json:"total"`
+	Currency       string          `json:"currency"`
+	Carrier        sql.NullString  `json:"carrier"`
+	TrackingNumber sql.NullString  `json:"trackingNumber"`
+	PaidAt         sql.NullTime    `json:"paidAt"`
+	PackedAt       sql.NullTime    `json:"packedAt"`
+	ShippedAt      sql.NullTime    `json:"shippedAt"`
+	DeliveredAt    sql.NullTime    `json:"deliveredAt"`
+	CancelledAt    sql.NullTime    `json:"cancelledAt"`
+	RefundedAt     sql.NullTime    `json:"refundedAt"`
+	FirstName      string          `json:"firstName"`
+	LastName       string          `json:"lastName"`
+	Address        utils.Address   `json:"address"`
+	Added          time.Time       `json:"added"`
+	Items          []OrderItemDto  `json:"items"`
 }
 
+// ORDER_ADDRESS_COLUMNS selects the structured address, falling back to the
+// legacy free text address for orders that couldn't be backfilled.
+const ORDER_ADDRESS_COLUMNS = "COALESCE(o.addressLine1, o.address, ''), COALESCE(o.addressLine2, ''), COALESCE(o.city, ''), COALESCE(o.region, ''), COALESCE(o.postcode, ''), COALESCE(o.countryCode, '')"
+
 type OrdersFilterDto struct {
 	StatusID int `json:"statusId"`
 	Page     int `json:"page"`
@@ -63,7 +94,7 @@ type OrdersFilterDto struct {
 }
 
 func GetOrders(filter OrdersFilterDto) ([]OrderDto, error) {
-	statement := "SELECT o.id, o.statusid, s.name, d.code, o.firstname, o.lastname, o.address, o.added FROM orders o JOIN shippingoptions s ON s.id = o.shippingid LEFT JOIN discounts d ON d.id = o.discountid WHERE o.statusid = $1 LIMIT $2 OFFSET $3;"
+	statement := "SELECT o.id, o.statusid, s.name, d.code, o.subtotal, o.discountTotal, o.shippingTotal, o.total, o.currency, o.carrier, o.trackingNumber, o.paidAt, o.packedAt, o.shippedAt, o.deliveredAt, o.cancelledAt, o.refundedAt, o.firstname, o.lastname, " + ORDER_ADDRESS_COLUMNS + ", o.added FROM orders o JOIN shippingoptions s ON s.id = o.shippingid LEFT JOIN discounts d ON d.id = o.discountid WHERE o.statusid = $1 LIMIT $2 OFFSET $3;"
 	rows, err := Connection.Query(statement, filter.StatusID, filter.Limit, filter.Limit*filter.Page)
 	if err != nil {
 		return nil, err
@@ -73,7 +104,7 @@ func GetOrders(filter OrdersFilterDto) ([]OrderDto, error) {
 	var orders = []OrderDto{}
 	for rows.Next() {
 		var order OrderDto
-		if err = rows.Scan(&order.ID, &order.StatusID, &order.ShippingOption, &order.Discount, &order.FirstName, &order.LastName, &order.Address, &order.Added); err != nil {
+		if err = rows.Scan(&order.ID, &order.StatusID, &order.ShippingOption, &order.Discount, &order.Subtotal, &order.DiscountTotal, &order.ShippingTotal, &order.Total, &order.Currency, &order.Carrier, &order.TrackingNumber, &order.PaidAt, &order.PackedAt, &order.ShippedAt, &order.DeliveredAt, &order.CancelledAt, &order.RefundedAt, &order.FirstName, &order.LastName, &order.Address.Line1, &order.Address.Line2, &order.Address.City, &order.Address.Region, &order.Address.Postcode, &order.Address.CountryCode, &order.Added); err != nil {
 			return nil, err
 		}
 
@@ -101,9 +132,9 @@ var GetFirstOrderID = func(statusID, offset int) (int, error) {
 	return id, err
 }
 
-func GetNonPendingOrders(email string) ([]OrderDto, error) {
-	statement := "SELECT o.id, o.statusid, s.name, d.code, o.firstname, o.lastname, o.address, o.added FROM orders o JOIN shippingoptions s ON s.id = o.shippingid LEFT JOIN discounts d ON d.id = o.discountid WHERE o.email = $1 AND o.statusid != $2;"
-	rows, err := Connection.Query(statement, email, ORDER_STATUS_PENDING)
+var GetNonPendingOrders = func(email string) ([]OrderDto, error) {
+	statement := "SELECT o.id, o.statusid, s.name, d.code, o.subtotal, o.discountTotal, o.shippingTotal, o.total, o.currency, o.carrier, o.trackingNumber, o.paidAt, o.packedAt, o.shippedAt, o.deliveredAt, o.cancelledAt, o.refundedAt, o.firstname, o.lastname, " + ORDER_ADDRESS_COLUMNS + ", o.added FROM orders o JOIN shippingoptions s ON s.id = o.shippingid LEFT JOIN discounts d ON d.id = o.discountid WHERE lower(o.email) = lower($1) AND o.statusid != $2 AND o.statusid != $3;"
+	rows, err := Connection.Query(statement, email, ORDER_STATUS_PENDING, ORDER_STATUS_CANCELLED)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +143,7 @@ func GetNonPendingOrders(email string) ([]OrderDto, error) {
 	var orders = []OrderDto{}
 	for rows.Next() {
 		var order OrderDto
-		if err = rows.Scan(&order.ID, &order.StatusID, &order.ShippingOption, &order.Discount, &order.FirstName, &order.LastName, &order.Address, &order.Added); err != nil {
+		if err = rows.Scan(&order.ID, &order.StatusID, &order.ShippingOption, &order.Discount, &order.Subtotal, &order.DiscountTotal, &order.ShippingTotal, &order.Total, &order.Currency, &order.Carrier, &order.TrackingNumber, &order.PaidAt, &order.PackedAt, &order.ShippedAt, &order.DeliveredAt, &order.CancelledAt, &order.RefundedAt, &order.FirstName, &order.LastName, &order.Address.Line1, &order.Address.Line2, &order.Address.City, &order.Address.Region, &order.Address.Postcode, &order.Address.CountryCode, &order.Added); err != nil {
 			return nil, err
 		}
 
@@ -133,52 +164,198 @@ func GetNonPendingOrders(email string) ([]OrderDto, error) {
 	return orders, rows.Err()
 }
 
-func InsertOrder(order CreateCheckoutDto) (int, error) {
-	statement := "INSERT INTO orders (statusid, shippingid, discountid, email, firstname, lastname, address, added) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;"
+// InsertOrder creates a pending order with the quoted prices and reserves its
+// items until reservedUntil. The cancel token lets the customer abandon the
+// order from the checkout cancel page without exposing their email. An
+// InsufficientStockError is returned if any size can't cover the request.
+var InsertOrder = func(order CreateCheckoutDto, quote utils.PriceQuote, reservedUntil time.Time, cancelToken string) (int, error) {
 	var id int
-	err := Connection.QueryRow(statement, ORDER_STATUS_PENDING, order.ShippingId, order.DiscountId, order.Customer.Email, order.Customer.FirstName, order.Customer.LastName, order.Customer.Address, time.Now()).Scan(&id)
-	if err != nil {
-		return 0, err
-	}
+	err := withTransaction(func(tx *sql.Tx) error {
+		if order.DiscountId.Valid {
+			if err := lockDiscount(tx, int(order.DiscountId.Int64), order.Customer.Email); err != nil {
+				return err
+			}
+		}
+
+		if err := reserveMerchSizes(tx, order.Items); err != nil {
+			return err
+		}
 
-	for _, item := range order.Items {
-		err := insertOrderItem(item, id)
+		address := order.Customer.Address
+		statement := "INSERT INTO orders (statusid, shippingid, discountid, email, firstname, lastname, addressLine1, addressLine2, city, region, postcode, countryCode, added, reservedUntil, subtotal, discountTotal, shippingTotal, total, cancelToken, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20) RETURNING id;"
+		err := tx.QueryRow(statement, ORDER_STATUS_PENDING, order.ShippingId, order.DiscountId, order.Customer.Email, order.Customer.FirstName, order.Customer.LastName, address.Line1, address.Line2, address.City, address.Region, address.Postcode, strings.ToLower(address.CountryCode), time.Now(), reservedUntil, quote.Subtotal, quote.Discount, quote.Shipping, quote.Total, cancelToken, quote.Currency).Scan(&id)
 		if err != nil {
-			return 0, err
+			return err
 		}
-	}
 
+		for _, line := range quote.Lines {
+			if err := insertOrderItem(tx, line, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	return id, err
 }
 
-func UpdateStatusLatestOrder(email string) (int, error) {
-	statement := "UPDATE orders set statusId = $1 where id = (select id from orders where email = $2 order by added desc LIMIT 1) returning id;"
+var UpdateOrderCheckoutSession = func(orderID int, sessionID string) error {
+	statement := "UPDATE orders SET checkoutSessionId = $2 WHERE id = $1 RETURNING id;"
 	var id int
-	err := Connection.QueryRow(statement, ORDER_STATUS_PAYMENT_RECEIVED, email).Scan(&id)
-	return id, err
+	return Connection.QueryRow(statement, orderID, sessionID).Scan(&id)
 }
 
 func DeleteOrder(orderId int) error {
-	Connection.QueryRow("DELETE FROM orderitems WHERE orderid = $1", orderId)
-	var id int
-	return Connection.QueryRow("DELETE FROM orders WHERE id = $1 returning id;", orderId).Scan(&id)
+	return withTransaction(func(tx *sql.Tx) error {
+		released, err := releaseOrder(tx, orderId)
+		if err != nil || released {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM orderitems WHERE orderid = $1;", orderId); err != nil {
+			return err
+		}
+
+		var id int
+		return tx.QueryRow("DELETE FROM orders WHERE id = $1 returning id;", orderId).Scan(&id)
+	})
 }
 
-func RemoveLatestPendingOrder(email string) error {
-	statement := "SELECT id from orders where email = $1 AND statusid = $2 order by added desc LIMIT 1;"
-	var orderId int
-	err := Connection.QueryRow(statement, email, ORDER_STATUS_PENDING).Scan(&orderId)
-	if err != nil {
+// CancelPendingOrder releases the pending order matching the cancel token. It
+// returns sql.ErrNoRows if there is no such order or it is no longer pending.
+var CancelPendingOrder = func(cancelToken string) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		statement := "SELECT id FROM orders WHERE cancelToken = $1 AND statusId = $2;"
+		var orderId int
+		err := tx.QueryRow(statement, cancelToken, ORDER_STATUS_PENDING).Scan(&orderId)
+		if err != nil {
+			return err
+		}
+
+		_, err = releaseOrder(tx, orderId)
 		return err
+	})
+}
+
+var HasNonPendingOrders = func(email string) (bool, error) {
+	statement := "SELECT COUNT(id) FROM orders WHERE lower(email) = lower($1) AND statusId != $2 AND statusId != $3;"
+	var count int
+	err := Connection.QueryRow(statement, email, ORDER_STATUS_PENDING, ORDER_STATUS_CANCELLED).Scan(&count)
+	return count > 0, err
+}
+
+var ReleaseOrder = func(orderID int) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		_, err := releaseOrder(tx, orderID)
+		return err
+	})
+}
+
+// ReleaseExpiredOrders removes pending orders whose reservation ended before the
+// cutoff, returning their stock. Orders from before reservations existed fall
+// back to the date they were added.
+var ReleaseExpiredOrders = func(cutoff time.Time) (int, error) {
+	statement := "SELECT id FROM orders WHERE statusId = $1 AND COALESCE(reservedUntil, added) < $2;"
+	rows, err := Connection.Query(statement, ORDER_STATUS_PENDING, cutoff)
+	if err != nil {
+		return 0, err
 	}
+	defer rows.Close()
 
-	Connection.QueryRow("DELETE from orderItems where orderid = $1;", orderId)
-	var id int
-	return Connection.QueryRow("DELETE from orders where id = $1 RETURNING id;", orderId).Scan(&id)
+	var orderIDs = []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		orderIDs = append(orderIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var released int
+	for _, orderID := range orderIDs {
+		err := withTransaction(func(tx *sql.Tx) error {
+			ok, err := releaseOrder(tx, orderID)
+			if ok {
+				released = released + 1
+			}
+			return err
+		})
+
+		if err != nil {
+			return released, err
+		}
+	}
+	return released, nil
 }
 
-func UpdateOrderStatus(orderID, statusID int) error {
-	statement := "UPDATE orders set statusId = $1 where id = $2 returning id;"
+// releaseOrder deletes a pending order and puts any reserved stock back. It
+// returns false if the order is no longer pending, e.g. because it was paid.
+func releaseOrder(tx *sql.Tx, orderID int) (bool, error) {
+	var reservedUntil sql.NullTime
+	err := tx.QueryRow("SELECT reservedUntil FROM orders WHERE id = $1 AND statusId = $2 FOR UPDATE;", orderID, ORDER_STATUS_PENDING).Scan(&reservedUntil)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if reservedUntil.Valid {
+		if err = restockOrderItems(tx, orderID); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err = tx.Exec("DELETE FROM orderItems WHERE orderId = $1;", orderID); err != nil {
+		return false, err
+	}
+
 	var id int
-	return Connection.QueryRow(statement, statusID, orderID).Scan(&id)
+	err = tx.QueryRow("DELETE FROM orders WHERE id = $1 RETURNING id;", orderID).Scan(&id)
+	return err == nil, err
+}
+
+// BackfillOrderAddresses parses the free text address of orders placed before
+// addresses were structured. Orders that can't be parsed are left as they are
+// and counted so they can be fixed by hand.
+func BackfillOrderAddresses() (int, int, error) {
+	rows, err := Connection.Query("SELECT id, address, COALESCE(countryCode, '') FROM orders WHERE addressLine1 IS NULL AND address IS NOT NULL ORDER BY id;")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	addresses := make(map[int]utils.Address)
+	var orderIDs = []int{}
+	var unparsed int
+	for rows.Next() {
+		var id int
+		var text, countryCode string
+		if err = rows.Scan(&id, &text, &countryCode); err != nil {
+			return 0, 0, err
+		}
+
+		address, ok := utils.ParseAddress(text, countryCode)
+		if !ok {
+			unparsed = unparsed + 1
+			continue
+		}
+		addresses[id] = address
+		orderIDs = append(orderIDs, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	statement := "UPDATE orders SET addressLine1 = $2, addressLine2 = $3, city = $4, region = $5, postcode = $6, countryCode = $7 WHERE id = $1;"
+	for index, id := range orderIDs {
+		address := addresses[id]
+		if _, err := Connection.Exec(statement, id, address.Line1, address.Line2, address.City, address.Region, address.Postcode, address.CountryCode); err != nil {
+			return index, unparsed, err
+		}
+	}
+	return len(orderIDs), unparsed, nil
 }
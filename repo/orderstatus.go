@@ -1,14 +1,226 @@
 package repo
 
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status IDs match the seeded orderStatus rows, so new statuses are appended
+// rather than kept in lifecycle order.
 const (
-	ORDER_STATUS_PENDING int = iota + 1
-	ORDER_STATUS_PAYMENT_RECEIVED
-	ORDER_STATUS_SHIPPED
+	ORDER_STATUS_PENDING          int = 1
+	ORDER_STATUS_PAYMENT_RECEIVED int = 2
+	ORDER_STATUS_SHIPPED          int = 3
+	ORDER_STATUS_PACKED           int = 4
+	ORDER_STATUS_DELIVERED        int = 5
+	ORDER_STATUS_CANCELLED        int = 6
+	ORDER_STATUS_REFUNDED         int = 7
 )
 
+// Paid orders can't simply be cancelled, they have to be refunded so the
+// customer gets their money back.
+var orderStatusTransitions = map[int][]int{
+	ORDER_STATUS_PENDING:          {ORDER_STATUS_PAYMENT_RECEIVED, ORDER_STATUS_CANCELLED},
+	ORDER_STATUS_PAYMENT_RECEIVED: {ORDER_STATUS_PACKED, ORDER_STATUS_REFUNDED},
+	ORDER_STATUS_PACKED:           {ORDER_STATUS_SHIPPED, ORDER_STATUS_REFUNDED},
+	ORDER_STATUS_SHIPPED:          {ORDER_STATUS_DELIVERED, ORDER_STATUS_REFUNDED},
+	ORDER_STATUS_DELIVERED:        {ORDER_STATUS_REFUNDED},
+}
+
+var orderStatusTimestamps = map[int]string{
+	ORDER_STATUS_PAYMENT_RECEIVED: "paidAt",
+	ORDER_STATUS_PACKED:           "packedAt",
+	ORDER_STATUS_SHIPPED:          "shippedAt",
+	ORDER_STATUS_DELIVERED:        "deliveredAt",
+	ORDER_STATUS_CANCELLED:        "cancelledAt",
+	ORDER_STATUS_REFUNDED:         "refundedAt",
+}
+
+var orderStatusKeys = map[int]string{
+	ORDER_STATUS_PENDING:          "pending",
+	ORDER_STATUS_PAYMENT_RECEIVED: "paid",
+	ORDER_STATUS_PACKED:           "packed",
+	ORDER_STATUS_SHIPPED:          "shipped",
+	ORDER_STATUS_DELIVERED:        "delivered",
+	ORDER_STATUS_CANCELLED:        "cancelled",
+	ORDER_STATUS_REFUNDED:         "refunded",
+}
+
+var ErrMissingTracking = errors.New("carrier and tracking number are required to ship an order")
+
+type InvalidOrderTransitionError struct {
+	From int
+	To   int
+}
+
+func (e InvalidOrderTransitionError) Error() string {
+	return fmt.Sprintf("order can't move from %s to %s", OrderStatusKey(e.From), OrderStatusKey(e.To))
+}
+
+type OrderSummaryDto struct {
+	ID             int             `json:"id"`
+	StatusID       int             `json:"statusId"`
+	Status         string          `json:"status"`
+	Email          string          `json:"email"`
+	FirstName      string          `json:"firstName"`
+	Carrier        sql.NullString  `json:"carrier"`
+	TrackingNumber sql.NullString  `json:"trackingNumber"`
+	Total          sql.NullFloat64 `json:"total"`
+	Currency       string          `json:"currency"`
+}
+
+type OrderPaymentDto struct {
+	ID                int             `json:"id"`
+	StatusID          int             `json:"statusId"`
+	PaymentIntentID   sql.NullString  `json:"paymentIntentId"`
+	CheckoutSessionID sql.NullString  `json:"checkoutSessionId"`
+	Total             sql.NullFloat64 `json:"total"`
+}
+
+func CanTransitionOrder(from, to int) bool {
+	for _, val := range orderStatusTransitions[from] {
+		if val == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusKey is a stable name for the status, used to pick email templates.
+func OrderStatusKey(statusID int) string {
+	if key, found := orderStatusKeys[statusID]; found {
+		return key
+	}
+	return fmt.Sprintf("status %d", statusID)
+}
+
 func getOrderStatus(id int) (string, error) {
 	statement := "SELECT status from orderStatus WHERE id = $1;"
 	var result string
 	err := Connection.QueryRow(statement, id).Scan(&result)
 	return result, err
 }
+
+// TransitionOrderStatus moves an order to the given status if the lifecycle
+// allows it and stamps the time it happened. Cancelling a pending order puts
+// its reserved stock back.
+var TransitionOrderStatus = func(orderID, statusID int, carrier, trackingNumber string) (OrderSummaryDto, error) {
+	var summary OrderSummaryDto
+	err := withTransaction(func(tx *sql.Tx) error {
+		from, reserved, err := lockOrderStatus(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if !CanTransitionOrder(from, statusID) {
+			return InvalidOrderTransitionError{From: from, To: statusID}
+		}
+
+		if statusID == ORDER_STATUS_SHIPPED && (carrier == "" || trackingNumber == "") {
+			return ErrMissingTracking
+		}
+
+		if statusID == ORDER_STATUS_CANCELLED && reserved {
+			if err = restockOrderItems(tx, orderID); err != nil {
+				return err
+			}
+		}
+
+		if err = setOrderStatus(tx, orderID, statusID); err != nil {
+			return err
+		}
+
+		if statusID == ORDER_STATUS_SHIPPED {
+			if _, err = tx.Exec("UPDATE orders SET carrier = $2, trackingNumber = $3 WHERE id = $1;", orderID, carrier, trackingNumber); err != nil {
+				return err
+			}
+		}
+
+		summary, err = getOrderSummary(tx, orderID)
+		return err
+	})
+	return summary, err
+}
+
+// RefundOrder refunds an order while holding its row, so two refunds of the
+// same order can't both reach the payment provider. refund is called with the
+// order's payment once the order is known to be refundable and returns the
+// provider's refund id. Stock is returned when requested, e.g. when the items
+// never left.
+var RefundOrder = func(orderID int, restock bool, refund func(payment OrderPaymentDto) (string, error)) (OrderSummaryDto, error) {
+	var summary OrderSummaryDto
+	err := withTransaction(func(tx *sql.Tx) error {
+		payment, err := getOrderPayment(tx, orderID, true)
+		if err != nil {
+			return err
+		}
+
+		if !CanTransitionOrder(payment.StatusID, ORDER_STATUS_REFUNDED) {
+			return InvalidOrderTransitionError{From: payment.StatusID, To: ORDER_STATUS_REFUNDED}
+		}
+
+		refundID, err := refund(payment)
+		if err != nil {
+			return err
+		}
+
+		if restock {
+			if err = restockOrderItems(tx, orderID); err != nil {
+				return err
+			}
+		}
+
+		if err = setOrderStatus(tx, orderID, ORDER_STATUS_REFUNDED); err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec("UPDATE orders SET refundId = $2 WHERE id = $1;", orderID, refundID); err != nil {
+			return err
+		}
+
+		summary, err = getOrderSummary(tx, orderID)
+		return err
+	})
+	return summary, err
+}
+
+var GetOrderSummary = func(orderID int) (OrderSummaryDto, error) {
+	return getOrderSummary(Connection, orderID)
+}
+
+var GetOrderPayment = func(orderID int) (OrderPaymentDto, error) {
+	return getOrderPayment(Connection, orderID, false)
+}
+
+func getOrderPayment(db executor, orderID int, lock bool) (OrderPaymentDto, error) {
+	statement := "SELECT id, statusId, paymentIntentId, checkoutSessionId, total FROM orders WHERE id = $1"
+	if lock {
+		statement += " FOR UPDATE"
+	}
+
+	var payment OrderPaymentDto
+	err := db.QueryRow(statement+";", orderID).Scan(&payment.ID, &payment.StatusID, &payment.PaymentIntentID, &payment.CheckoutSessionID, &payment.Total)
+	return payment, err
+}
+
+func lockOrderStatus(tx *sql.Tx, orderID int) (int, bool, error) {
+	var statusID int
+	var reservedUntil sql.NullTime
+	err := tx.QueryRow("SELECT statusId, reservedUntil FROM orders WHERE id = $1 FOR UPDATE;", orderID).Scan(&statusID, &reservedUntil)
+	return statusID, reservedUntil.Valid, err
+}
+
+func setOrderStatus(tx *sql.Tx, orderID, statusID int) error {
+	statement := fmt.Sprintf("UPDATE orders SET statusId = $2, %s = $3 WHERE id = $1;", orderStatusTimestamps[statusID])
+	_, err := tx.Exec(statement, orderID, statusID, time.Now())
+	return err
+}
+
+func getOrderSummary(db executor, orderID int) (OrderSummaryDto, error) {
+	statement := "SELECT o.id, o.statusId, s.status, o.email, o.firstName, o.carrier, o.trackingNumber, o.total, o.currency FROM orders o JOIN orderStatus s ON s.id = o.statusId WHERE o.id = $1;"
+	var summary OrderSummaryDto
+	err := db.QueryRow(statement, orderID).Scan(&summary.ID, &summary.StatusID, &summary.Status, &summary.Email, &summary.FirstName, &summary.Carrier, &summary.TrackingNumber, &summary.Total, &summary.Currency)
+	return summary, err
+}
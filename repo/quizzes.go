@@ -143,13 +143,13 @@ var GetQuiz = func(id int) (Quiz, error) {
 	return quiz, err
 }
 
-var GetQuizByRoute = func(route string) (QuizDto, error) {
+var GetQuizByRoute = func(route, detail string) (QuizDto, error) {
 	statement := "SELECT * FROM quizzes WHERE route = $1;"
 	var quiz QuizDto
 	err := Connection.QueryRow(statement, route).Scan(&quiz.ID, &quiz.TypeID, &quiz.BadgeID, &quiz.ContinentID, &quiz.Country, &quiz.Singular, &quiz.Name, &quiz.MaxScore, &quiz.Time, &quiz.MapName, &quiz.ImageURL, &quiz.Plural, &quiz.APIPath, &quiz.Route, &quiz.HasLeaderboard, &quiz.HasGrouping, &quiz.HasFlags, &quiz.Enabled)
 
 	if quiz.MapName != "" {
-		svgMap, err := GetMap(quiz.MapName)
+		svgMap, err := GetMapWithDetail(quiz.MapName, detail)
 		if err != nil {
 			return QuizDto{}, err
 		}
@@ -175,9 +175,13 @@ var ScoreExceedsMax = func(quizID, score int) (bool, error) {
 }
 
 func CreateQuiz(newQuiz CreateQuizDto) (Quiz, error) {
+	return createQuiz(Connection, newQuiz)
+}
+
+func createQuiz(db executor, newQuiz CreateQuizDto) (Quiz, error) {
 	statement := "INSERT INTO quizzes (typeId, badgeId, continentId, country, singular, name, maxScore, time, mapSVG, imageUrl, plural, apiPath, route, hasLeaderboard, hasGrouping, hasFlags, enabled) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING *;"
 	var quiz Quiz
-	err := Connection.QueryRow(statement, newQuiz.TypeID, newQuiz.BadgeID, newQuiz.ContinentID, newQuiz.Country, newQuiz.Singular, newQuiz.Name, newQuiz.MaxScore, newQuiz.Time, newQuiz.MapSVG, newQuiz.ImageURL, newQuiz.Plural, newQuiz.APIPath, newQuiz.Route, newQuiz.HasLeaderboard, newQuiz.HasGrouping, newQuiz.HasFlags, newQuiz.Enabled).Scan(&quiz.ID, &quiz.TypeID, &quiz.BadgeID, &quiz.ContinentID, &quiz.Country, &quiz.Singular, &quiz.Name, &quiz.MaxScore, &quiz.Time, &quiz.MapSVG, &quiz.ImageURL, &quiz.Plural, &quiz.APIPath, &quiz.Route, &quiz.HasLeaderboard, &quiz.HasGrouping, &quiz.HasFlags, &quiz.Enabled)
+	err := db.QueryRow(statement, newQuiz.TypeID, newQuiz.BadgeID, newQuiz.ContinentID, newQuiz.Country, newQuiz.Singular, newQuiz.Name, newQuiz.MaxScore, newQuiz.Time, newQuiz.MapSVG, newQuiz.ImageURL, newQuiz.Plural, newQuiz.APIPath, newQuiz.Route, newQuiz.HasLeaderboard, newQuiz.HasGrouping, newQuiz.HasFlags, newQuiz.Enabled).Scan(&quiz.ID, &quiz.TypeID, &quiz.BadgeID, &quiz.ContinentID, &quiz.Country, &quiz.Singular, &quiz.Name, &quiz.MaxScore, &quiz.Time, &quiz.MapSVG, &quiz.ImageURL, &quiz.Plural, &quiz.APIPath, &quiz.Route, &quiz.HasLeaderboard, &quiz.HasGrouping, &quiz.HasFlags, &quiz.Enabled)
 	return quiz, err
 }
 
@@ -228,20 +232,6 @@ func DeleteQuiz(quizID int) error {
 	return err
 }
 
-func getWorldQuizCount(badgeID int) (int, error) {
-	statement := "SELECT COUNT(id) FROM quizzes WHERE badgeid = $1;"
-	var count int
-	err := Connection.QueryRow(statement, badgeID).Scan(&count)
-	return count, err
-}
-
-func getContinentQuizCount(continentID int) (int, error) {
-	statement := "SELECT COUNT(id) FROM quizzes WHERE continentid = $1;"
-	var count int
-	err := Connection.QueryRow(statement, continentID).Scan(&count)
-	return count, err
-}
-
 func GetQuizRoutes() ([]string, error) {
 	rows, err := Connection.Query("SELECT route FROM quizzes;")
 	if err != nil {
@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"log"
+	"time"
+)
+
+// COUNTRY_LEADERBOARD_TOP_N is the number of best scores per country that are
+// averaged when comparing countries on a quiz. Migration 000041 seeds
+// quizCountryScores using the same value.
+const COUNTRY_LEADERBOARD_TOP_N = 10
+
+type UserRankingDto struct {
+	UserID      int    `json:"userId"`
+	Username    string `json:"username"`
+	CountryCode string `json:"countryCode"`
+	XP          int    `json:"xp"`
+	Rank        int    `json:"rank"`
+}
+
+type UserRankDto struct {
+	UserID       int    `json:"userId"`
+	CountryCode  string `json:"countryCode"`
+	XP           int    `json:"xp"`
+	GlobalRank   int    `json:"globalRank"`
+	GlobalTotal  int    `json:"globalTotal"`
+	CountryRank  int    `json:"countryRank"`
+	CountryTotal int    `json:"countryTotal"`
+}
+
+type CountryRankingDto struct {
+	QuizID       int       `json:"quizId"`
+	CountryCode  string    `json:"countryCode"`
+	Entries      int       `json:"entries"`
+	AverageScore float64   `json:"averageScore"`
+	AverageTime  float64   `json:"averageTime"`
+	Updated      time.Time `json:"updated"`
+	Rank         int       `json:"rank"`
+}
+
+type GetUserRankingsFilterParams struct {
+	Page        int    `json:"page"`
+	Limit       int    `json:"limit"`
+	CountryCode string `json:"countryCode"`
+}
+
+type GetCountryRankingsFilterParams struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	MinEntries int `json:"minEntries"`
+}
+
+var GetUserRankings = func(filterParams GetUserRankingsFilterParams) ([]UserRankingDto, error) {
+	statement := "SELECT u.id, u.username, u.countryCode, u.xp, (SELECT COUNT(x.id) FROM users x WHERE x.xp > u.xp AND ($1 = '' OR x.countryCode = $1)) + 1 FROM users u WHERE $1 = '' OR u.countryCode = $1 ORDER BY u.xp DESC, u.id LIMIT $2 OFFSET $3;"
+	rows, err := Connection.Query(statement, filterParams.CountryCode, filterParams.Limit, filterParams.Page*filterParams.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings = []UserRankingDto{}
+	for rows.Next() {
+		var ranking UserRankingDto
+		if err = rows.Scan(&ranking.UserID, &ranking.Username, &ranking.CountryCode, &ranking.XP, &ranking.Rank); err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+	return rankings, rows.Err()
+}
+
+var GetFirstUserRankingID = func(filterParams GetUserRankingsFilterParams) (int, error) {
+	statement := "SELECT id FROM users WHERE $1 = '' OR countryCode = $1 ORDER BY xp DESC, id LIMIT 1 OFFSET $2;"
+	var id int
+	err := Connection.QueryRow(statement, filterParams.CountryCode, (filterParams.Page+1)*filterParams.Limit).Scan(&id)
+	return id, err
+}
+
+var GetUserRank = func(userID int) (UserRankDto, error) {
+	statement := "SELECT u.id, u.countryCode, u.xp, (SELECT COUNT(x.id) FROM users x WHERE x.xp > u.xp) + 1, (SELECT COUNT(x.id) FROM users x), (SELECT COUNT(x.id) FROM users x WHERE x.countryCode = u.countryCode AND x.xp > u.xp) + 1, (SELECT COUNT(x.id) FROM users x WHERE x.countryCode = u.countryCode) FROM users u WHERE u.id = $1;"
+	var rank UserRankDto
+	err := Connection.QueryRow(statement, userID).Scan(&rank.UserID, &rank.CountryCode, &rank.XP, &rank.GlobalRank, &rank.GlobalTotal, &rank.CountryRank, &rank.CountryTotal)
+	return rank, err
+}
+
+var GetCountryRankings = func(quizID int, filterParams GetCountryRankingsFilterParams) ([]CountryRankingDto, error) {
+	statement := "SELECT quizId, countryCode, entries, averageScore, averageTime, updated, RANK () OVER (ORDER BY averageScore DESC, averageTime) rank FROM quizCountryScores WHERE quizId = $1 AND entries >= $2 ORDER BY averageScore DESC, averageTime LIMIT $3 OFFSET $4;"
+	rows, err := Connection.Query(statement, quizID, filterParams.MinEntries, filterParams.Limit, filterParams.Page*filterParams.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rankings = []CountryRankingDto{}
+	for rows.Next() {
+		var ranking CountryRankingDto
+		if err = rows.Scan(&ranking.QuizID, &ranking.CountryCode, &ranking.Entries, &ranking.AverageScore, &ranking.AverageTime, &ranking.Updated, &ranking.Rank); err != nil {
+			return nil, err
+		}
+		rankings = append(rankings, ranking)
+	}
+	return rankings, rows.Err()
+}
+
+var GetFirstCountryRankingID = func(quizID int, filterParams GetCountryRankingsFilterParams) (int, error) {
+	statement := "SELECT id FROM quizCountryScores WHERE quizId = $1 AND entries >= $2 ORDER BY averageScore DESC, averageTime LIMIT 1 OFFSET $3;"
+	var id int
+	err := Connection.QueryRow(statement, quizID, filterParams.MinEntries, (filterParams.Page+1)*filterParams.Limit).Scan(&id)
+	return id, err
+}
+
+// refreshQuizCountryScore recalculates the summary row for a single quiz and
+// country so rankings never need to aggregate the leaderboard on read. It's a
+// single upsert, so concurrent refreshes can't leave duplicate or missing rows,
+// and the row is removed once the country has no entries left.
+func refreshQuizCountryScore(quizID int, countryCode string) error {
+	statement := "WITH summary AS (SELECT (SELECT COUNT(l.id) FROM leaderboard l JOIN users u ON u.id = l.userId WHERE l.quizId = $1 AND u.countryCode = $2) entries, AVG(t.score) averageScore, AVG(t.time) averageTime FROM (SELECT l.score, l.time FROM leaderboard l JOIN users u ON u.id = l.userId WHERE l.quizId = $1 AND u.countryCode = $2 ORDER BY l.score DESC, l.time LIMIT $4) t HAVING COUNT(*) > 0), upserted AS (INSERT INTO quizCountryScores (quizId, countryCode, entries, averageScore, averageTime, updated) SELECT $1, $2, entries, averageScore, averageTime, $3 FROM summary ON CONFLICT (quizId, countryCode) DO UPDATE SET entries = EXCLUDED.entries, averageScore = EXCLUDED.averageScore, averageTime = EXCLUDED.averageTime, updated = EXCLUDED.updated RETURNING id) DELETE FROM quizCountryScores WHERE quizId = $1 AND countryCode = $2 AND NOT EXISTS (SELECT 1 FROM summary);"
+	_, err := Connection.Exec(statement, quizID, countryCode, time.Now(), COUNTRY_LEADERBOARD_TOP_N)
+	return err
+}
+
+// refreshUserQuizCountryScore runs after the leaderboard or user has been
+// saved. A failure only leaves the country rankings stale until the next
+// refresh, so it's logged rather than failing a write that has already happened.
+func refreshUserQuizCountryScore(quizID, userID int) {
+	var countryCode string
+	if err := Connection.QueryRow("SELECT countryCode FROM users WHERE id = $1;", userID).Scan(&countryCode); err != nil {
+		log.Printf("failed to refresh country score for quiz %d and user %d: %v", quizID, userID, err)
+		return
+	}
+	refreshQuizCountryScores([]int{quizID}, countryCode)
+}
+
+func refreshQuizCountryScores(quizIDs []int, countryCodes ...string) {
+	for _, quizID := range quizIDs {
+		for _, countryCode := range countryCodes {
+			if err := refreshQuizCountryScore(quizID, countryCode); err != nil {
+				log.Printf("failed to refresh country score for quiz %d and country %s: %v", quizID, countryCode, err)
+			}
+		}
+	}
+}
+
+func refreshUserQuizCountryScores(userID int, countryCodes ...string) {
+	quizIDs, err := getUserLeaderboardQuizIDs(userID)
+	if err != nil {
+		log.Printf("failed to refresh country scores for user %d: %v", userID, err)
+		return
+	}
+	refreshQuizCountryScores(quizIDs, countryCodes...)
+}
+
+func getUserLeaderboardQuizIDs(userID int) ([]int, error) {
+	rows, err := Connection.Query("SELECT DISTINCT quizId FROM leaderboard WHERE userId = $1;", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quizIDs = []int{}
+	for rows.Next() {
+		var quizID int
+		if err = rows.Scan(&quizID); err != nil {
+			return nil, err
+		}
+		quizIDs = append(quizIDs, quizID)
+	}
+	return quizIDs, rows.Err()
+}
@@ -0,0 +1,171 @@
+package repo
+
+import "time"
+
+// Report intervals map to Postgres date_trunc fields.
+const (
+	REPORT_INTERVAL_DAY   = "day"
+	REPORT_INTERVAL_WEEK  = "week"
+	REPORT_INTERVAL_MONTH = "month"
+)
+
+// ReportFilter limits reports to orders paid from From up to, but not
+// including, To.
+type ReportFilter struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+// Revenue is split by currency as amounts in different currencies can't be
+// added together.
+type RevenueReportDto struct {
+	Period        time.Time `json:"period"`
+	Currency      string    `json:"currency"`
+	Orders        int       `json:"orders"`
+	Subtotal      float64   `json:"subtotal"`
+	DiscountTotal float64   `json:"discountTotal"`
+	ShippingTotal float64   `json:"shippingTotal"`
+	Total         float64   `json:"total"`
+	Refunded      float64   `json:"refunded"`
+}
+
+type MerchSalesReportDto struct {
+	MerchID   int    `json:"merchId"`
+	MerchName string `json:"merchName"`
+	SizeID    int    `json:"sizeId"`
+	Size      string `json:"size"`
+	Orders    int    `json:"orders"`
+	Units     int    `json:"units"`
+}
+
+type DiscountUsageReportDto struct {
+	DiscountID int     `json:"discountId"`
+	Code       string  `json:"code"`
+	Currency   string  `json:"currency"`
+	Orders     int     `json:"orders"`
+	Value      float64 `json:"value"`
+}
+
+type ShippingMixReportDto struct {
+	ShippingOptionID int     `json:"shippingOptionId"`
+	ShippingOption   string  `json:"shippingOption"`
+	Currency         string  `json:"currency"`
+	Orders           int     `json:"orders"`
+	Share            float64 `json:"share"`
+	ShippingTotal    float64 `json:"shippingTotal"`
+}
+
+type LowStockReportDto struct {
+	MerchID   int    `json:"merchId"`
+	MerchName string `json:"merchName"`
+	SizeID    int    `json:"sizeId"`
+	Size      string `json:"size"`
+	Quantity  int    `json:"quantity"`
+}
+
+var GetRevenueReport = func(filter ReportFilter) ([]RevenueReportDto, error) {
+	statement := "SELECT date_trunc($3, paidAt) AS period, currency, COUNT(id), COALESCE(SUM(subtotal), 0), COALESCE(SUM(discountTotal), 0), COALESCE(SUM(shippingTotal), 0), COALESCE(SUM(total), 0), COALESCE(SUM(total) FILTER (WHERE statusId = $4), 0) FROM orders WHERE paidAt >= $1 AND paidAt < $2 GROUP BY period, currency ORDER BY period, currency;"
+	rows, err := Connection.Query(statement, filter.From, filter.To, filter.Interval, ORDER_STATUS_REFUNDED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report = []RevenueReportDto{}
+	for rows.Next() {
+		var entry RevenueReportDto
+		if err = rows.Scan(&entry.Period, &entry.Currency, &entry.Orders, &entry.Subtotal, &entry.DiscountTotal, &entry.ShippingTotal, &entry.Total, &entry.Refunded); err != nil {
+			return nil, err
+		}
+		report = append(report, entry)
+	}
+	return report, rows.Err()
+}
+
+// GetMerchSalesReport returns units sold per size, best sellers first. Refunded
+// orders aren't counted as sales.
+var GetMerchSalesReport = func(filter ReportFilter) ([]MerchSalesReportDto, error) {
+	statement := "SELECT m.id, m.name, s.id, s.size, COUNT(DISTINCT o.id), SUM(i.quantity) FROM orderItems i JOIN orders o ON o.id = i.orderId JOIN merch m ON m.id = i.merchId JOIN merchSizes s ON s.id = i.sizeId WHERE o.paidAt >= $1 AND o.paidAt < $2 AND o.statusId != $3 GROUP BY m.id, m.name, s.id, s.size ORDER BY SUM(i.quantity) DESC, m.name, s.id;"
+	rows, err := Connection.Query(statement, filter.From, filter.To, ORDER_STATUS_REFUNDED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report = []MerchSalesReportDto{}
+	for rows.Next() {
+		var entry MerchSalesReportDto
+		if err = rows.Scan(&entry.MerchID, &entry.MerchName, &entry.SizeID, &entry.Size, &entry.Orders, &entry.Units); err != nil {
+			return nil, err
+		}
+		report = append(report, entry)
+	}
+	return report, rows.Err()
+}
+
+var GetDiscountUsageReport = func(filter ReportFilter) ([]DiscountUsageReportDto, error) {
+	statement := "SELECT d.id, d.code, o.currency, COUNT(o.id), COALESCE(SUM(o.discountTotal), 0) FROM orders o JOIN discounts d ON d.id = o.discountId WHERE o.paidAt >= $1 AND o.paidAt < $2 AND o.statusId != $3 GROUP BY d.id, d.code, o.currency ORDER BY COUNT(o.id) DESC, d.code, o.currency;"
+	rows, err := Connection.Query(statement, filter.From, filter.To, ORDER_STATUS_REFUNDED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report = []DiscountUsageReportDto{}
+	for rows.Next() {
+		var entry DiscountUsageReportDto
+		if err = rows.Scan(&entry.DiscountID, &entry.Code, &entry.Currency, &entry.Orders, &entry.Value); err != nil {
+			return nil, err
+		}
+		report = append(report, entry)
+	}
+	return report, rows.Err()
+}
+
+// GetShippingMixReport returns how many orders used each shipping option, with
+// their share of all orders in the range.
+var GetShippingMixReport = func(filter ReportFilter) ([]ShippingMixReportDto, error) {
+	statement := "SELECT s.id, s.name, o.currency, COUNT(o.id), COALESCE(SUM(o.shippingTotal), 0) FROM orders o JOIN shippingOptions s ON s.id = o.shippingId WHERE o.paidAt >= $1 AND o.paidAt < $2 AND o.statusId != $3 GROUP BY s.id, s.name, o.currency ORDER BY COUNT(o.id) DESC, s.name, o.currency;"
+	rows, err := Connection.Query(statement, filter.From, filter.To, ORDER_STATUS_REFUNDED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report = []ShippingMixReportDto{}
+	var total int
+	for rows.Next() {
+		var entry ShippingMixReportDto
+		if err = rows.Scan(&entry.ShippingOptionID, &entry.ShippingOption, &entry.Currency, &entry.Orders, &entry.ShippingTotal); err != nil {
+			return nil, err
+		}
+		total = total + entry.Orders
+		report = append(report, entry)
+	}
+
+	for index, entry := range report {
+		report[index].Share = float64(entry.Orders) / float64(total)
+	}
+	return report, rows.Err()
+}
+
+// GetLowStock returns sizes with threshold or fewer left, emptiest first.
+var GetLowStock = func(threshold int) ([]LowStockReportDto, error) {
+	statement := "SELECT m.id, m.name, s.id, s.size, s.quantity FROM merchSizes s JOIN merch m ON m.id = s.merchId WHERE s.quantity <= $1 ORDER BY s.quantity, m.name, s.id;"
+	rows, err := Connection.Query(statement, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report = []LowStockReportDto{}
+	for rows.Next() {
+		var entry LowStockReportDto
+		if err = rows.Scan(&entry.MerchID, &entry.MerchName, &entry.SizeID, &entry.Size, &entry.Quantity); err != nil {
+			return nil, err
+		}
+		report = append(report, entry)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"database/sql"
+
+	"github.com/geobuff/api/utils"
+)
+
+type ShippingLabelDto struct {
+	OrderID        int             `json:"orderId"`
+	FirstName      string          `json:"firstName"`
+	LastName       string          `json:"lastName"`
+	Email          string          `json:"email"`
+	Address        utils.Address   `json:"address"`
+	ShippingOption string          `json:"shippingOption"`
+	Quantity       int             `json:"quantity"`
+	Weight         int             `json:"weight"`
+	Total          sql.NullFloat64 `json:"total"`
+	Currency       string          `json:"currency"`
+}
+
+// GetUnshippedOrders returns paid orders that haven't been sent yet, oldest
+// first, with the item count and weight in grams needed for a label.
+var GetUnshippedOrders = func() ([]ShippingLabelDto, error) {
+	statement := "SELECT o.id, o.firstName, o.lastName, o.email, " + ORDER_ADDRESS_COLUMNS + ", s.name, COALESCE(SUM(i.quantity), 0), COALESCE(SUM(i.quantity * COALESCE(m.weight, 0)), 0), o.total, o.currency FROM orders o JOIN shippingOptions s ON s.id = o.shippingId LEFT JOIN orderItems i ON i.orderId = o.id LEFT JOIN merch m ON m.id = i.merchId WHERE o.statusId IN ($1, $2) GROUP BY o.id, s.name ORDER BY o.paidAt, o.id;"
+	rows, err := Connection.Query(statement, ORDER_STATUS_PAYMENT_RECEIVED, ORDER_STATUS_PACKED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels = []ShippingLabelDto{}
+	for rows.Next() {
+		var label ShippingLabelDto
+		if err = rows.Scan(&label.OrderID, &label.FirstName, &label.LastName, &label.Email, &label.Address.Line1, &label.Address.Line2, &label.Address.City, &label.Address.Region, &label.Address.Postcode, &label.Address.CountryCode, &label.ShippingOption, &label.Quantity, &label.Weight, &label.Total, &label.Currency); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
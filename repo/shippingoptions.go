@@ -1,5 +1,12 @@
 package repo
 
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/geobuff/api/utils"
+)
+
 type ShippingOption struct {
 	ID          int     `json:"id"`
 	Name        string  `json:"name"`
@@ -8,8 +15,28 @@ type ShippingOption struct {
 	ImageURL    string  `json:"imageUrl"`
 }
 
-func GetShippingOptions() ([]ShippingOption, error) {
-	rows, err := Connection.Query("SELECT * from shippingoptions;")
+type ShippingRate struct {
+	ID               int           `json:"id"`
+	ShippingOptionID int           `json:"shippingOptionId"`
+	CurrencyCode     string        `json:"currencyCode"`
+	MaxQuantity      sql.NullInt64 `json:"maxQuantity"`
+	MaxWeight        sql.NullInt64 `json:"maxWeight"`
+	Price            float64       `json:"price"`
+}
+
+func (r ShippingRate) Tier() utils.ShippingTier {
+	return utils.ShippingTier{
+		MaxQuantity: r.MaxQuantity,
+		MaxWeight:   r.MaxWeight,
+		Price:       r.Price,
+	}
+}
+
+// GetShippingOptions returns the options that deliver to the country. Options
+// without any countries deliver everywhere, and an empty country returns all.
+var GetShippingOptions = func(countryCode string) ([]ShippingOption, error) {
+	statement := "SELECT o.id, o.name, o.description, o.price, o.imageUrl FROM shippingOptions o WHERE $1 = '' OR NOT EXISTS (SELECT 1 FROM shippingOptionCountries c WHERE c.shippingOptionId = o.id) OR EXISTS (SELECT 1 FROM shippingOptionCountries c WHERE c.shippingOptionId = o.id AND c.countryCode = $1) ORDER BY o.id;"
+	rows, err := Connection.Query(statement, strings.ToLower(countryCode))
 	if err != nil {
 		return nil, err
 	}
@@ -26,9 +53,37 @@ func GetShippingOptions() ([]ShippingOption, error) {
 	return options, rows.Err()
 }
 
-func GetShippingOption(id int) (ShippingOption, error) {
-	statement := "SELECT * from shippingoptions WHERE id = $1;"
+var GetShippingOption = func(id int) (ShippingOption, error) {
+	statement := "SELECT id, name, description, price, imageUrl from shippingoptions WHERE id = $1;"
 	var option ShippingOption
 	err := Connection.QueryRow(statement, id).Scan(&option.ID, &option.Name, &option.Description, &option.Price, &option.ImageURL)
 	return option, err
 }
+
+var ShippingOptionDeliversTo = func(id int, countryCode string) (bool, error) {
+	statement := "SELECT COUNT(id) = 0 OR COUNT(id) FILTER (WHERE countryCode = $2) > 0 FROM shippingOptionCountries WHERE shippingOptionId = $1;"
+	var delivers bool
+	err := Connection.QueryRow(statement, id, strings.ToLower(countryCode)).Scan(&delivers)
+	return delivers, err
+}
+
+// GetShippingRates returns the option's tiers in the currency from smallest to
+// largest, so the first that fits the cart is the cheapest.
+var GetShippingRates = func(shippingOptionID int, currencyCode string) ([]ShippingRate, error) {
+	statement := "SELECT id, shippingOptionId, currencyCode, maxQuantity, maxWeight, price FROM shippingRates WHERE shippingOptionId = $1 AND currencyCode = $2 ORDER BY maxWeight NULLS LAST, maxQuantity NULLS LAST, price;"
+	rows, err := Connection.Query(statement, shippingOptionID, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates = []ShippingRate{}
+	for rows.Next() {
+		var rate ShippingRate
+		if err = rows.Scan(&rate.ID, &rate.ShippingOptionID, &rate.CurrencyCode, &rate.MaxQuantity, &rate.MaxWeight, &rate.Price); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
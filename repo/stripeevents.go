@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type StripeEvent struct {
+	ID        int           `json:"id"`
+	EventID   string        `json:"eventId"`
+	Type      string        `json:"type"`
+	OrderID   sql.NullInt64 `json:"orderId"`
+	Processed time.Time     `json:"processed"`
+}
+
+var ErrOrderNotPending = errors.New("order is no longer waiting for payment")
+
+// CompleteOrderPayment marks the order as paid. Stock was reserved when the
+// order was created, so it is only taken here for orders that predate
+// reservations. The event is recorded in the same transaction, so a redelivered
+// event returns false without touching the order again. A payment for an order
+// that was cancelled or released in the meantime returns ErrOrderNotPending and
+// isn't recorded, so it can be refunded.
+var CompleteOrderPayment = func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+	var processed bool
+	err := withTransaction(func(tx *sql.Tx) error {
+		recorded, err := recordStripeEvent(tx, eventID, eventType, orderID)
+		if err != nil || !recorded {
+			return err
+		}
+
+		var reserved bool
+		statement := "UPDATE orders SET statusId = $2, paidAt = $4, paymentIntentId = $5 WHERE id = $1 AND statusId = $3 RETURNING reservedUntil IS NOT NULL;"
+		err = tx.QueryRow(statement, orderID, ORDER_STATUS_PAYMENT_RECEIVED, ORDER_STATUS_PENDING, time.Now(), paymentIntentID).Scan(&reserved)
+		if err == sql.ErrNoRows {
+			return unmatchedOrderPayment(tx, orderID, paymentIntentID)
+		} else if err != nil {
+			return err
+		}
+
+		if !reserved {
+			if err = reduceOrderItemQuantities(tx, orderID); err != nil {
+				return err
+			}
+		}
+
+		processed = true
+		return nil
+	})
+	return processed, err
+}
+
+// ReleaseOrderReservation cancels a pending order once its checkout session has
+// expired. Like CompleteOrderPayment it is a no-op for redelivered events.
+var ReleaseOrderReservation = func(eventID, eventType string, orderID int) (bool, error) {
+	var released bool
+	err := withTransaction(func(tx *sql.Tx) error {
+		recorded, err := recordStripeEvent(tx, eventID, eventType, orderID)
+		if err != nil || !recorded {
+			return err
+		}
+
+		released, err = releaseOrder(tx, orderID)
+		return err
+	})
+	return released, err
+}
+
+// unmatchedOrderPayment allows the same payment being reported by another
+// event, e.g. a delayed payment method, but nothing else.
+func unmatchedOrderPayment(tx *sql.Tx, orderID int, paymentIntentID string) error {
+	var recorded sql.NullString
+	err := tx.QueryRow("SELECT paymentIntentId FROM orders WHERE id = $1;", orderID).Scan(&recorded)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if paymentIntentID != "" && recorded.String == paymentIntentID {
+		return nil
+	}
+	return ErrOrderNotPending
+}
+
+func recordStripeEvent(tx *sql.Tx, eventID, eventType string, orderID int) (bool, error) {
+	statement := "INSERT INTO stripeEvents (eventId, type, orderId, processed) SELECT $1, $2, (SELECT id FROM orders WHERE id = $3), $4 ON CONFLICT (eventId) DO NOTHING RETURNING id;"
+	var id int
+	err := tx.QueryRow(statement, eventID, eventType, orderID, time.Now()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func reduceOrderItemQuantities(tx *sql.Tx, orderID int) error {
+	rows, err := tx.Query("SELECT sizeId, quantity FROM orderItems WHERE orderId = $1;", orderID)
+	if err != nil {
+		return err
+	}
+
+	var items = []OrderItem{}
+	for rows.Next() {
+		var item OrderItem
+		if err = rows.Scan(&item.SizeID, &item.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := reduceMerchItemQuantity(tx, item.SizeID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
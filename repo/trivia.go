@@ -12,11 +12,14 @@ type Trivia struct {
 	MaxScore int       `json:"maxScore"`
 }
 
+// TriviaDto holds each map its questions use once, keyed by name, when the
+// client asks for deduped maps. Otherwise the maps are on the questions.
 type TriviaDto struct {
-	ID        int           `json:"id"`
-	Name      string        `json:"name"`
-	MaxScore  int           `json:"maxScore"`
-	Questions []QuestionDto `json:"questions"`
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	MaxScore  int               `json:"maxScore"`
+	Questions []QuestionDto     `json:"questions"`
+	Maps      map[string]MapDto `json:"maps,omitempty"`
 }
 
 type GetTriviaFilter struct {
@@ -51,6 +54,12 @@ func GetFirstTriviaID(filter GetTriviaFilter) (int, error) {
 	return id, err
 }
 
+var GetTriviaById = func(id int) (Trivia, error) {
+	var trivia Trivia
+	err := Connection.QueryRow("SELECT * FROM trivia WHERE id = $1;", id).Scan(&trivia.ID, &trivia.Name, &trivia.Date, &trivia.MaxScore)
+	return trivia, err
+}
+
 func GetTrivia(date string) (*TriviaDto, error) {
 	var result TriviaDto
 	err := Connection.QueryRow("SELECT id, name, maxscore from trivia WHERE date = $1;", date).Scan(&result.ID, &result.Name, &result.MaxScore)
@@ -67,6 +76,41 @@ func GetTrivia(date string) (*TriviaDto, error) {
 	return &result, nil
 }
 
+// GetTriviaWithMaps loads each map the questions use once. With dedupe the
+// maps are returned in Maps keyed by name, otherwise they're set on every
+// question that uses them so older clients keep working.
+var GetTriviaWithMaps = func(date, detail string, dedupe bool) (*TriviaDto, error) {
+	result, err := GetTrivia(date)
+	if err != nil {
+		return nil, err
+	}
+
+	maps := make(map[string]MapDto)
+	for index, question := range result.Questions {
+		if question.MapName == "" {
+			continue
+		}
+
+		svgMap, found := maps[question.MapName]
+		if !found {
+			svgMap, err = GetMapWithDetail(question.MapName, detail)
+			if err != nil {
+				return nil, err
+			}
+			maps[question.MapName] = svgMap
+		}
+
+		if !dedupe {
+			result.Questions[index].Map = svgMap
+		}
+	}
+
+	if dedupe {
+		result.Maps = maps
+	}
+	return result, nil
+}
+
 func DeleteTriviaByDate(dateString string) error {
 	trivia, err := GetTrivia(dateString)
 	if err != nil {
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestGetTriviaWithMaps(t *testing.T) {
+	savedGetMapWithDetail := GetMapWithDetail
+	defer func() {
+		GetMapWithDetail = savedGetMapWithDetail
+	}()
+
+	tt := []struct {
+		name   string
+		dedupe bool
+	}{
+		{name: "maps on questions by default", dedupe: false},
+		{name: "maps deduped", dedupe: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := useRecordingConnection(t)
+			recorder.queueRows([]string{"id", "name", "maxscore"}, []driver.Value{int64(1), "Trivia", int64(3)})
+
+			questionColumns := []string{"id", "name", "question", "map", "highlighted", "flagCode", "url", "imageUrl", "imageAttributeName", "imageAttributeUrl", "imageWidth", "imageHeight", "imageAlt", "explainer"}
+			recorder.queueRows(questionColumns,
+				[]driver.Value{int64(1), "Map", "Which country?", "WorldCountries", "nz", "", nil, "", "", "", int64(0), int64(0), "", ""},
+				[]driver.Value{int64(2), "Map", "Which country?", "WorldCountries", "au", "", nil, "", "", "", int64(0), int64(0), "", ""},
+				[]driver.Value{int64(3), "Text", "True or false?", "", "", "", nil, "", "", "", int64(0), int64(0), "", ""},
+			)
+			for i := 0; i < 3; i++ {
+				recorder.queueRows([]string{"id", "text", "isCorrect", "flagCode", "url"})
+			}
+
+			var loaded int
+			GetMapWithDetail = func(className, detail string) (MapDto, error) {
+				loaded++
+				return MapDto{ClassName: className}, nil
+			}
+
+			trivia, err := GetTriviaWithMaps("2022-01-01", "low", tc.dedupe)
+			if err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			if loaded != 1 {
+				t.Errorf("expected the map to be loaded once; got %v", loaded)
+			}
+
+			for _, question := range trivia.Questions {
+				hasMap := question.Map.ClassName != ""
+				if expected := !tc.dedupe && question.MapName != ""; hasMap != expected {
+					t.Errorf("expected map on question %d %v; got %v", question.ID, expected, hasMap)
+				}
+			}
+
+			if _, found := trivia.Maps["WorldCountries"]; found != tc.dedupe || (!tc.dedupe && trivia.Maps != nil) {
+				t.Errorf("expected deduped maps %v; got %v", tc.dedupe, trivia.Maps)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ type TriviaAnswer struct {
 }
 
 type AnswerDto struct {
+	ID        int            `json:"id"`
 	Text      string         `json:"text"`
 	IsCorrect bool           `json:"isCorrect"`
 	FlagCode  string         `json:"flagCode"`
@@ -21,7 +22,7 @@ type AnswerDto struct {
 }
 
 func GetTriviaAnswers(triviaQuestionId int) ([]AnswerDto, error) {
-	rows, err := Connection.Query("SELECT a.text, a.isCorrect, a.flagCode, f.url FROM triviaAnswers a LEFT JOIN flagentries f ON f.code = a.flagcode WHERE triviaQuestionId = $1;", triviaQuestionId)
+	rows, err := Connection.Query("SELECT a.id, a.text, a.isCorrect, a.flagCode, f.url FROM triviaAnswers a LEFT JOIN flagentries f ON f.code = a.flagcode WHERE triviaQuestionId = $1;", triviaQuestionId)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +31,7 @@ func GetTriviaAnswers(triviaQuestionId int) ([]AnswerDto, error) {
 	var answers = []AnswerDto{}
 	for rows.Next() {
 		var answer AnswerDto
-		if err = rows.Scan(&answer.Text, &answer.IsCorrect, &answer.FlagCode, &answer.FlagUrl); err != nil {
+		if err = rows.Scan(&answer.ID, &answer.Text, &answer.IsCorrect, &answer.FlagCode, &answer.FlagUrl); err != nil {
 			return nil, err
 		}
 		answers = append(answers, answer)
@@ -46,9 +47,13 @@ func GetTriviaAnswers(triviaQuestionId int) ([]AnswerDto, error) {
 }
 
 func CreateTriviaAnswer(answer TriviaAnswer) error {
+	return insertTriviaAnswer(Connection, answer)
+}
+
+func insertTriviaAnswer(db executor, answer TriviaAnswer) error {
 	statement := "INSERT INTO triviaAnswers (triviaQuestionId, text, isCorrect, flagCode) VALUES ($1, $2, $3, $4) RETURNING id;"
 	var id int
-	return Connection.QueryRow(statement, answer.TriviaQuestionID, answer.Text, answer.IsCorrect, answer.FlagCode).Scan(&id)
+	return db.QueryRow(statement, answer.TriviaQuestionID, answer.Text, answer.IsCorrect, answer.FlagCode).Scan(&id)
 }
 
 func DeleteTriviaAnswers(triviaQuestionId int) error {
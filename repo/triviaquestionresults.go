@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type TriviaQuestionResult struct {
+	ID                     int           `json:"id"`
+	TriviaQuestionID       sql.NullInt64 `json:"triviaQuestionId"`
+	ManualTriviaQuestionID sql.NullInt64 `json:"manualTriviaQuestionId"`
+	AnswerText             string        `json:"answerText"`
+	IsCorrect              bool          `json:"isCorrect"`
+	Skipped                bool          `json:"skipped"`
+	Added                  time.Time     `json:"added"`
+}
+
+type CreateTriviaQuestionResultDto struct {
+	QuestionID int  `json:"questionId"`
+	AnswerID   int  `json:"answerId"`
+	Skipped    bool `json:"skipped"`
+}
+
+type TriviaAnswerOption struct {
+	QuestionID             int
+	ManualTriviaQuestionID sql.NullInt64
+	AnswerID               int
+	Text                   string
+	IsCorrect              bool
+}
+
+type TriviaQuestionStatsDto struct {
+	ID                 int            `json:"id"`
+	Question           string         `json:"question"`
+	Type               string         `json:"type"`
+	Attempts           int            `json:"attempts"`
+	Correct            int            `json:"correct"`
+	Skipped            int            `json:"skipped"`
+	CorrectRate        float64        `json:"correctRate"`
+	SkipRate           float64        `json:"skipRate"`
+	TopWrongAnswer     sql.NullString `json:"topWrongAnswer"`
+	TopWrongAnswerRate float64        `json:"topWrongAnswerRate"`
+}
+
+type GetProblematicQuestionsFilter struct {
+	MinAttempts int `json:"minAttempts"`
+	Limit       int `json:"limit"`
+}
+
+var GetTriviaAnswerOptions = func(triviaID int) ([]TriviaAnswerOption, error) {
+	rows, err := Connection.Query("SELECT q.id, q.manualTriviaQuestionId, a.id, a.text, a.isCorrect FROM triviaQuestions q JOIN triviaAnswers a ON a.triviaQuestionId = q.id WHERE q.triviaId = $1;", triviaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options = []TriviaAnswerOption{}
+	for rows.Next() {
+		var option TriviaAnswerOption
+		if err = rows.Scan(&option.QuestionID, &option.ManualTriviaQuestionID, &option.AnswerID, &option.Text, &option.IsCorrect); err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+	}
+	return options, rows.Err()
+}
+
+func BuildTriviaQuestionResults(options []TriviaAnswerOption, submitted []CreateTriviaQuestionResultDto) ([]TriviaQuestionResult, error) {
+	questions := make(map[int]sql.NullInt64)
+	answers := make(map[int]TriviaAnswerOption)
+	for _, option := range options {
+		questions[option.QuestionID] = option.ManualTriviaQuestionID
+		answers[option.AnswerID] = option
+	}
+
+	now := time.Now()
+	visited := make(map[int]bool)
+	var results = []TriviaQuestionResult{}
+	for _, value := range submitted {
+		manualID, found := questions[value.QuestionID]
+		if !found {
+			return nil, fmt.Errorf("question %d is not part of this trivia", value.QuestionID)
+		}
+
+		if visited[value.QuestionID] {
+			return nil, fmt.Errorf("duplicate results for question %d", value.QuestionID)
+		}
+		visited[value.QuestionID] = true
+
+		result := TriviaQuestionResult{
+			TriviaQuestionID:       sql.NullInt64{Int64: int64(value.QuestionID), Valid: true},
+			ManualTriviaQuestionID: manualID,
+			Skipped:                value.Skipped,
+			Added:                  now,
+		}
+
+		if !value.Skipped {
+			answer, found := answers[value.AnswerID]
+			if !found || answer.QuestionID != value.QuestionID {
+				return nil, fmt.Errorf("answer %d is not an option for question %d", value.AnswerID, value.QuestionID)
+			}
+			result.AnswerText = answer.Text
+			result.IsCorrect = answer.IsCorrect
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// CreateTriviaQuestionResults records the results against the user's trivia
+// play. Each question can only be reported once per play, so submitting the
+// same results again fails with a unique violation.
+var CreateTriviaQuestionResults = func(userTriviaPlayID int, results []TriviaQuestionResult) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		statement := "INSERT INTO triviaQuestionResults (userTriviaPlayId, triviaQuestionId, manualTriviaQuestionId, answerText, isCorrect, skipped, added) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
+		for _, result := range results {
+			var id int
+			if err := tx.QueryRow(statement, userTriviaPlayID, result.TriviaQuestionID, result.ManualTriviaQuestionID, result.AnswerText, result.IsCorrect, result.Skipped, result.Added).Scan(&id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var GetTriviaQuestionStats = func(triviaID int) ([]TriviaQuestionStatsDto, error) {
+	statement := "SELECT q.id, q.question, t.name, COUNT(r.id), COUNT(r.id) FILTER (WHERE r.isCorrect), COUNT(r.id) FILTER (WHERE r.skipped), (SELECT w.answerText FROM triviaQuestionResults w WHERE w.triviaQuestionId = q.id AND NOT w.isCorrect AND NOT w.skipped GROUP BY w.answerText ORDER BY COUNT(*) DESC, w.answerText LIMIT 1), COALESCE((SELECT COUNT(*) FROM triviaQuestionResults w WHERE w.triviaQuestionId = q.id AND NOT w.isCorrect AND NOT w.skipped GROUP BY w.answerText ORDER BY COUNT(*) DESC LIMIT 1), 0) FROM triviaQuestions q JOIN triviaQuestionType t ON t.id = q.typeId LEFT JOIN triviaQuestionResults r ON r.triviaQuestionId = q.id WHERE q.triviaId = $1 GROUP BY q.id, t.name ORDER BY q.id;"
+	return getTriviaQuestionStats(statement, triviaID)
+}
+
+var GetProblematicManualTriviaQuestions = func(filter GetProblematicQuestionsFilter) ([]TriviaQuestionStatsDto, error) {
+	statement := "SELECT q.id, q.question, t.name, COUNT(r.id), COUNT(r.id) FILTER (WHERE r.isCorrect), COUNT(r.id) FILTER (WHERE r.skipped), (SELECT w.answerText FROM triviaQuestionResults w WHERE w.manualTriviaQuestionId = q.id AND NOT w.isCorrect AND NOT w.skipped GROUP BY w.answerText ORDER BY COUNT(*) DESC, w.answerText LIMIT 1), COALESCE((SELECT COUNT(*) FROM triviaQuestionResults w WHERE w.manualTriviaQuestionId = q.id AND NOT w.isCorrect AND NOT w.skipped GROUP BY w.answerText ORDER BY COUNT(*) DESC LIMIT 1), 0) FROM manualTriviaQuestions q JOIN triviaQuestionType t ON t.id = q.typeId JOIN triviaQuestionResults r ON r.manualTriviaQuestionId = q.id GROUP BY q.id, t.name HAVING COUNT(r.id) >= $1 ORDER BY (COUNT(r.id) FILTER (WHERE r.isCorrect))::float / COUNT(r.id), (COUNT(r.id) FILTER (WHERE r.skipped))::float / COUNT(r.id) DESC, q.id LIMIT $2;"
+	return getTriviaQuestionStats(statement, filter.MinAttempts, filter.Limit)
+}
+
+func getTriviaQuestionStats(statement string, args ...interface{}) ([]TriviaQuestionStatsDto, error) {
+	rows, err := Connection.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats = []TriviaQuestionStatsDto{}
+	for rows.Next() {
+		var stat TriviaQuestionStatsDto
+		var topWrongAnswerCount int
+		if err = rows.Scan(&stat.ID, &stat.Question, &stat.Type, &stat.Attempts, &stat.Correct, &stat.Skipped, &stat.TopWrongAnswer, &topWrongAnswerCount); err != nil {
+			return nil, err
+		}
+
+		if stat.Attempts > 0 {
+			stat.CorrectRate = float64(stat.Correct) / float64(stat.Attempts)
+			stat.SkipRate = float64(stat.Skipped) / float64(stat.Attempts)
+			stat.TopWrongAnswerRate = float64(topWrongAnswerCount) / float64(stat.Attempts)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
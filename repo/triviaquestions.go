@@ -3,23 +3,25 @@ package repo
 import (
 	"database/sql"
 	"math/rand"
+	"time"
 )
 
 type TriviaQuestion struct {
-	ID                 int    `json:"id"`
-	TriviaId           int    `json:"triviaId"`
-	TypeID             int    `json:"typeId"`
-	Question           string `json:"question"`
-	Map                string `json:"map"`
-	Highlighted        string `json:"highlighted"`
-	FlagCode           string `json:"flagCode"`
-	ImageURL           string `json:"imageUrl"`
-	ImageAttributeName string `json:"imageAttributeName"`
-	ImageAttributeURL  string `json:"ImageAttributeUrl"`
-	ImageWidth         int    `json:"imageWidth"`
-	ImageHeight        int    `json:"imageHeight"`
-	ImageAlt           string `json:"imageAlt"`
-	Explainer          string `json:"explainer"`
+	ID                     int           `json:"id"`
+	TriviaId               int           `json:"triviaId"`
+	ManualTriviaQuestionID sql.NullInt64 `json:"manualTriviaQuestionId"`
+	TypeID                 int           `json:"typeId"`
+	Question               string        `json:"question"`
+	Map                    string        `json:"map"`
+	Highlighted            string        `json:"highlighted"`
+	FlagCode               string        `json:"flagCode"`
+	ImageURL               string        `json:"imageUrl"`
+	ImageAttributeName     string        `json:"imageAttributeName"`
+	ImageAttributeURL      string        `json:"ImageAttributeUrl"`
+	ImageWidth             int           `json:"imageWidth"`
+	ImageHeight            int           `json:"imageHeight"`
+	ImageAlt               string        `json:"imageAlt"`
+	Explainer              string        `json:"explainer"`
 }
 
 type QuestionDto struct {
@@ -55,14 +57,6 @@ func GetTriviaQuestions(triviaId int) ([]QuestionDto, error) {
 			return nil, err
 		}
 
-		if question.MapName != "" {
-			svgMap, err := GetMap(question.MapName)
-			if err != nil {
-				return nil, err
-			}
-			question.Map = svgMap
-		}
-
 		answers, err := GetTriviaAnswers(question.ID)
 		if err != nil {
 			return nil, err
@@ -79,10 +73,61 @@ func GetTriviaQuestions(triviaId int) ([]QuestionDto, error) {
 	return questions, nil
 }
 
+// CreateTriviaQuestion adds a question generated from map or flag data.
+// Questions taken from the manual bank must go through
+// CreateTriviaQuestionFromManual instead so their results roll up to it.
 func CreateTriviaQuestion(question TriviaQuestion) (int, error) {
-	statement := "INSERT INTO triviaQuestions (triviaId, typeId, question, map, highlighted, flagCode, imageUrl, imageAttributeName, imageAttributeUrl, imageWidth, imageHeight, imageAlt, explainer) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id;"
+	return insertTriviaQuestion(Connection, question)
+}
+
+// CreateTriviaQuestionFromManual is how the trivia generator adds a manual
+// question to a trivia. It copies the question and its answers, links the copy
+// back to the manual question so results are reported against it, and marks
+// the manual question as used.
+func CreateTriviaQuestionFromManual(triviaID int, question ManualTriviaQuestion) (int, error) {
+	var id int
+	err := withTransaction(func(tx *sql.Tx) error {
+		answers, err := getManualTriviaAnswers(tx, question.ID)
+		if err != nil {
+			return err
+		}
+
+		id, err = insertTriviaQuestion(tx, TriviaQuestion{
+			TriviaId:               triviaID,
+			ManualTriviaQuestionID: sql.NullInt64{Int64: int64(question.ID), Valid: true},
+			TypeID:                 question.TypeID,
+			Question:               question.Question,
+			Map:                    question.Map,
+			Highlighted:            question.Highlighted,
+			FlagCode:               question.FlagCode,
+			ImageURL:               question.ImageURL,
+			ImageAttributeName:     question.ImageAttributeName,
+			ImageAttributeURL:      question.ImageAttributeURL,
+			ImageWidth:             question.ImageWidth,
+			ImageHeight:            question.ImageHeight,
+			ImageAlt:               question.ImageAlt,
+			Explainer:              question.Explainer,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, answer := range answers {
+			if err = insertTriviaAnswer(tx, TriviaAnswer{TriviaQuestionID: id, Text: answer.Text, IsCorrect: answer.IsCorrect, FlagCode: answer.FlagCode}); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE manualtriviaquestions SET lastUsed = $2 WHERE id = $1;", question.ID, time.Now().Format("2006-01-02"))
+		return err
+	})
+	return id, err
+}
+
+func insertTriviaQuestion(db executor, question TriviaQuestion) (int, error) {
+	statement := "INSERT INTO triviaQuestions (triviaId, typeId, question, map, highlighted, flagCode, imageUrl, imageAttributeName, imageAttributeUrl, imageWidth, imageHeight, imageAlt, explainer, manualTriviaQuestionId) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id;"
 	var id int
-	err := Connection.QueryRow(statement, question.TriviaId, question.TypeID, question.Question, question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.Explainer).Scan(&id)
+	err := db.QueryRow(statement, question.TriviaId, question.TypeID, question.Question, question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, question.ImageWidth, question.ImageHeight, question.ImageAlt, question.Explainer, question.ManualTriviaQuestionID).Scan(&id)
 	return id, err
 }
 
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestCreateTriviaQuestionFromManual(t *testing.T) {
+	recorder := useRecordingConnection(t)
+
+	question := ManualTriviaQuestion{ID: 42, TypeID: 2, Question: "Which country is highlighted?", Map: "WorldCountries", Highlighted: "nz"}
+	answers := []ManualTriviaAnswer{
+		{ID: 1, ManualTriviaQuestionID: 42, Text: "New Zealand", IsCorrect: true},
+		{ID: 2, ManualTriviaQuestionID: 42, Text: "Australia"},
+	}
+
+	answerRows := [][]driver.Value{}
+	for _, answer := range answers {
+		answerRows = append(answerRows, []driver.Value{int64(answer.ID), int64(answer.ManualTriviaQuestionID), answer.Text, answer.IsCorrect, answer.FlagCode})
+	}
+	recorder.queueRows([]string{"id", "manualtriviaquestionid", "text", "iscorrect", "flagcode"}, answerRows...)
+
+	id, err := CreateTriviaQuestionFromManual(7, question)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if !recorder.committed || len(recorder.statements) != 5 {
+		t.Fatalf("expected the question, 2 answers and last used to be committed; got %v", recorder.statements)
+	}
+
+	if recorder.statements[0].args[0] != int64(42) {
+		t.Errorf("expected answers for manual question 42; got %v", recorder.statements[0].args)
+	}
+
+	inserted := recorder.statements[1]
+	if !strings.HasPrefix(inserted.query, "INSERT INTO triviaQuestions") {
+		t.Fatalf("expected question insert; got %s", inserted.query)
+	}
+
+	if inserted.args[0] != int64(7) || inserted.args[2] != question.Question || inserted.args[13] != int64(42) {
+		t.Errorf("expected question for trivia 7 linked to manual question 42; got %v", inserted.args)
+	}
+
+	options := []TriviaAnswerOption{}
+	for i, statement := range recorder.statements[2:4] {
+		if !strings.HasPrefix(statement.query, "INSERT INTO triviaAnswers") || statement.args[0] != int64(id) || statement.args[1] != answers[i].Text || statement.args[2] != answers[i].IsCorrect {
+			t.Errorf("expected answer %s for question %d; got %s %v", answers[i].Text, id, statement.query, statement.args)
+		}
+		options = append(options, TriviaAnswerOption{QuestionID: id, AnswerID: i + 1, Text: answers[i].Text, IsCorrect: answers[i].IsCorrect})
+	}
+
+	if used := recorder.statements[4]; !strings.HasPrefix(used.query, "UPDATE manualtriviaquestions SET lastUsed") || used.args[0] != int64(42) {
+		t.Errorf("expected manual question 42 to be marked as used; got %s %v", used.query, used.args)
+	}
+
+	optionRows := [][]driver.Value{}
+	for _, option := range options {
+		optionRows = append(optionRows, []driver.Value{int64(option.QuestionID), inserted.args[13], int64(option.AnswerID), option.Text, option.IsCorrect})
+	}
+	recorder.queueRows([]string{"id", "manualtriviaquestionid", "id", "text", "iscorrect"}, optionRows...)
+
+	loaded, err := GetTriviaAnswerOptions(7)
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	results, err := BuildTriviaQuestionResults(loaded, []CreateTriviaQuestionResultDto{{QuestionID: id, AnswerID: 2}})
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if err = CreateTriviaQuestionResults(1, results); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	result := recorder.statements[len(recorder.statements)-1]
+	if !strings.HasPrefix(result.query, "INSERT INTO triviaQuestionResults") || result.args[2] != int64(42) || result.args[3] != "Australia" {
+		t.Errorf("expected wrong answer result rolled up to manual question 42; got %s %v", result.query, result.args)
+	}
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type TriviaQuestionTemplate struct {
+	ID          int       `json:"id"`
+	TypeID      int       `json:"typeId"`
+	Type        string    `json:"type"`
+	CategoryID  int       `json:"categoryId"`
+	Category    string    `json:"category"`
+	MappingKey  string    `json:"mappingKey"`
+	Map         string    `json:"map"`
+	Question    string    `json:"question"`
+	Answer      string    `json:"answer"`
+	Explainer   string    `json:"explainer"`
+	Distractors int       `json:"distractors"`
+	IsActive    bool      `json:"isActive"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+type TriviaQuestionTemplateDto struct {
+	TypeID      int    `json:"typeId" validate:"required"`
+	CategoryID  int    `json:"categoryId" validate:"required"`
+	MappingKey  string `json:"mappingKey" validate:"required"`
+	Map         string `json:"map"`
+	Question    string `json:"question" validate:"required"`
+	Answer      string `json:"answer" validate:"required"`
+	Explainer   string `json:"explainer"`
+	Distractors int    `json:"distractors" validate:"omitempty,min=1,max=5"`
+	IsActive    bool   `json:"isActive"`
+}
+
+var GetTriviaQuestionTemplates = func() ([]TriviaQuestionTemplate, error) {
+	rows, err := Connection.Query("SELECT q.id, q.typeId, t.name, q.categoryId, c.name, q.mappingKey, q.map, q.question, q.answer, q.explainer, q.distractors, q.isActive, q.lastUpdated FROM triviaQuestionTemplates q JOIN triviaQuestionType t ON t.id = q.typeId JOIN triviaQuestionCategory c ON c.id = q.categoryId ORDER BY q.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates = []TriviaQuestionTemplate{}
+	for rows.Next() {
+		var template TriviaQuestionTemplate
+		if err = rows.Scan(&template.ID, &template.TypeID, &template.Type, &template.CategoryID, &template.Category, &template.MappingKey, &template.Map, &template.Question, &template.Answer, &template.Explainer, &template.Distractors, &template.IsActive, &template.LastUpdated); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+var GetTriviaQuestionTemplate = func(id int) (TriviaQuestionTemplate, error) {
+	statement := "SELECT q.id, q.typeId, t.name, q.categoryId, c.name, q.mappingKey, q.map, q.question, q.answer, q.explainer, q.distractors, q.isActive, q.lastUpdated FROM triviaQuestionTemplates q JOIN triviaQuestionType t ON t.id = q.typeId JOIN triviaQuestionCategory c ON c.id = q.categoryId WHERE q.id = $1;"
+	var template TriviaQuestionTemplate
+	err := Connection.QueryRow(statement, id).Scan(&template.ID, &template.TypeID, &template.Type, &template.CategoryID, &template.Category, &template.MappingKey, &template.Map, &template.Question, &template.Answer, &template.Explainer, &template.Distractors, &template.IsActive, &template.LastUpdated)
+	return template, err
+}
+
+var CreateTriviaQuestionTemplate = func(template TriviaQuestionTemplateDto) (int, error) {
+	statement := "INSERT INTO triviaQuestionTemplates (typeId, categoryId, mappingKey, map, question, answer, explainer, distractors, isActive, lastUpdated) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;"
+	var id int
+	err := Connection.QueryRow(statement, template.TypeID, template.CategoryID, template.MappingKey, template.Map, strings.TrimSpace(template.Question), strings.TrimSpace(template.Answer), strings.TrimSpace(template.Explainer), template.Distractors, template.IsActive, time.Now()).Scan(&id)
+	return id, err
+}
+
+var UpdateTriviaQuestionTemplate = func(id int, template TriviaQuestionTemplateDto) error {
+	statement := "UPDATE triviaQuestionTemplates SET typeId = $2, categoryId = $3, mappingKey = $4, map = $5, question = $6, answer = $7, explainer = $8, distractors = $9, isActive = $10, lastUpdated = $11 WHERE id = $1 RETURNING id;"
+	return Connection.QueryRow(statement, id, template.TypeID, template.CategoryID, template.MappingKey, template.Map, strings.TrimSpace(template.Question), strings.TrimSpace(template.Answer), strings.TrimSpace(template.Explainer), template.Distractors, template.IsActive, time.Now()).Scan(&id)
+}
+
+var DeleteTriviaQuestionTemplate = func(id int) error {
+	return Connection.QueryRow("DELETE FROM triviaQuestionTemplates WHERE id = $1 RETURNING id;", id).Scan(&id)
+}
+
+// SaveGeneratedTriviaQuestions adds generated questions to the manual question
+// bank, skipping any that are already there, and returns how many were added.
+var SaveGeneratedTriviaQuestions = func(questions []CreateManualTriviaQuestionDto) (int, error) {
+	var saved int
+	err := withTransaction(func(tx *sql.Tx) error {
+		for _, question := range questions {
+			var exists bool
+			statement := "SELECT EXISTS (SELECT 1 FROM manualtriviaquestions WHERE typeid = $1 AND question = $2 AND COALESCE(map, '') = $3 AND COALESCE(highlighted, '') = $4 AND COALESCE(flagcode, '') = $5);"
+			if err := tx.QueryRow(statement, question.TypeID, strings.TrimSpace(question.Question), question.Map, question.Highlighted, question.FlagCode).Scan(&exists); err != nil {
+				return err
+			}
+
+			if exists {
+				continue
+			}
+
+			if err := insertManualTriviaQuestion(tx, question); err != nil {
+				return err
+			}
+			saved++
+		}
+		return nil
+	})
+	return saved, err
+}
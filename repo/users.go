@@ -24,19 +24,20 @@ type User struct {
 }
 
 type UserDto struct {
-	ID                      int       `json:"id"`
-	AvatarId                int       `json:"avatarId"`
-	AvatarName              string    `json:"avatarName"`
-	AvatarDescription       string    `json:"avatarDescription"`
-	AvatarPrimaryImageUrl   string    `json:"avatarPrimaryImageUrl"`
-	AvatarSecondaryImageUrl string    `json:"avatarSecondaryImageUrl"`
-	Username                string    `json:"username"`
-	Email                   string    `json:"email"`
-	CountryCode             string    `json:"countryCode"`
-	FlagUrl                 string    `json:"flagUrl"`
-	Joined                  time.Time `json:"joined"`
-	IsAdmin                 bool      `json:"isAdmin"`
-	XP                      int       `json:"xp"`
+	ID                      int             `json:"id"`
+	AvatarId                int             `json:"avatarId"`
+	AvatarName              string          `json:"avatarName"`
+	AvatarDescription       string          `json:"avatarDescription"`
+	AvatarPrimaryImageUrl   string          `json:"avatarPrimaryImageUrl"`
+	AvatarSecondaryImageUrl string          `json:"avatarSecondaryImageUrl"`
+	Username                string          `json:"username"`
+	Email                   string          `json:"email"`
+	CountryCode             string          `json:"countryCode"`
+	FlagUrl                 string          `json:"flagUrl"`
+	Joined                  time.Time       `json:"joined"`
+	IsAdmin                 bool            `json:"isAdmin"`
+	XP                      int             `json:"xp"`
+	TriviaStreak            TriviaStreakDto `json:"triviaStreak"`
 }
 
 type AuthUserDto struct {
@@ -149,9 +150,21 @@ var InsertUser = func(user User) (int, error) {
 }
 
 var UpdateUser = func(userID int, user UpdateUserDto) error {
+	var previousCountryCode string
+	if err := Connection.QueryRow("SELECT countryCode FROM users WHERE id = $1;", userID).Scan(&previousCountryCode); err != nil {
+		return err
+	}
+
 	statement := "UPDATE users set avatarid = $2, username = $3, email = $4, countryCode = $5, xp = $6 WHERE id = $1 RETURNING id;"
 	var id int
-	return Connection.QueryRow(statement, userID, user.AvatarId, user.Username, user.Email, user.CountryCode, user.XP).Scan(&id)
+	if err := Connection.QueryRow(statement, userID, user.AvatarId, user.Username, user.Email, user.CountryCode, user.XP).Scan(&id); err != nil {
+		return err
+	}
+
+	if previousCountryCode != user.CountryCode {
+		refreshUserQuizCountryScores(userID, previousCountryCode, user.CountryCode)
+	}
+	return nil
 }
 
 func UpdateUserXP(userID, score, maxScore int) (int, error) {
@@ -174,11 +187,21 @@ var DeleteUser = func(userID int) error {
 		}
 	}
 
+	quizIDs, err := getUserLeaderboardQuizIDs(userID)
+	if err != nil {
+		return err
+	}
+
 	leaderboardStatement := "DELETE FROM leaderboard WHERE userId = $1;"
 	Connection.QueryRow(leaderboardStatement, userID)
-	usersStatement := "DELETE FROM users WHERE id = $1 RETURNING id;"
-	var id int
-	return Connection.QueryRow(usersStatement, userID).Scan(&id)
+	usersStatement := "DELETE FROM users WHERE id = $1 RETURNING countryCode;"
+	var countryCode string
+	if err := Connection.QueryRow(usersStatement, userID).Scan(&countryCode); err != nil {
+		return err
+	}
+
+	refreshQuizCountryScores(quizIDs, countryCode)
+	return nil
 }
 
 var UsernameExists = func(username string) (bool, error) {
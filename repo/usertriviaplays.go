@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/geobuff/api/utils"
+)
+
+type UserTriviaPlay struct {
+	ID         int           `json:"id"`
+	UserID     int           `json:"userId"`
+	TriviaID   sql.NullInt64 `json:"triviaId"`
+	TriviaDate time.Time     `json:"triviaDate"`
+	Score      int           `json:"score"`
+	MaxScore   int           `json:"maxScore"`
+	PlayedOn   time.Time     `json:"playedOn"`
+	Added      time.Time     `json:"added"`
+}
+
+type CreateUserTriviaPlayDto struct {
+	UserID   int    `json:"userId"`
+	TriviaID int    `json:"triviaId"`
+	Score    int    `json:"score"`
+	Timezone string `json:"timezone"`
+}
+
+type TriviaStreakDto struct {
+	Current       int          `json:"current"`
+	Longest       int          `json:"longest"`
+	StreakFreezes int          `json:"streakFreezes"`
+	LastPlayed    sql.NullTime `json:"lastPlayed"`
+}
+
+type TriviaLeaderboardEntryDto struct {
+	UserID      int       `json:"userId"`
+	Username    string    `json:"username"`
+	CountryCode string    `json:"countryCode"`
+	Score       int       `json:"score"`
+	MaxScore    int       `json:"maxScore"`
+	Added       time.Time `json:"added"`
+	Rank        int       `json:"rank"`
+}
+
+type GetTriviaLeaderboardFilterParams struct {
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+}
+
+type UpdateStreakFreezesDto struct {
+	Count int `json:"count"`
+}
+
+var GetUserTriviaPlayID = func(userID, triviaID int) (int, error) {
+	var id int
+	err := Connection.QueryRow("SELECT id FROM userTriviaPlays WHERE userId = $1 AND triviaId = $2;", userID, triviaID).Scan(&id)
+	return id, err
+}
+
+// InsertUserTriviaPlay records the play against the user's local date. Any days
+// missed since the last play are covered by streak freezes when the user has
+// enough of them to bridge the whole gap. Playing the same trivia twice fails
+// with a unique violation.
+var InsertUserTriviaPlay = func(play CreateUserTriviaPlayDto, trivia Trivia, location *time.Location) error {
+	return withTransaction(func(tx *sql.Tx) error {
+		var freezes int
+		err := tx.QueryRow("UPDATE users SET timezone = $2 WHERE id = $1 RETURNING streakFreezes;", play.UserID, location.String()).Scan(&freezes)
+		if err != nil {
+			return err
+		}
+
+		played, frozen, err := getUserStreakDays(tx, play.UserID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		today := utils.Day(now.In(location))
+		missed := utils.MissedDays(played, frozen, today)
+		if len(missed) > 0 && len(missed) <= freezes {
+			for _, day := range missed {
+				if _, err := tx.Exec("INSERT INTO userStreakFreezes (userId, frozenOn) VALUES ($1, $2);", play.UserID, day); err != nil {
+					return err
+				}
+			}
+
+			if _, err := tx.Exec("UPDATE users SET streakFreezes = streakFreezes - $2 WHERE id = $1;", play.UserID, len(missed)); err != nil {
+				return err
+			}
+		}
+
+		statement := "INSERT INTO userTriviaPlays (userId, triviaId, triviaDate, score, maxScore, playedOn, added) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
+		var id int
+		return tx.QueryRow(statement, play.UserID, trivia.ID, trivia.Date, play.Score, trivia.MaxScore, today, now).Scan(&id)
+	})
+}
+
+var GetUserTriviaStreak = func(userID int) (TriviaStreakDto, error) {
+	var result TriviaStreakDto
+	var timezone string
+	err := Connection.QueryRow("SELECT timezone, streakFreezes FROM users WHERE id = $1;", userID).Scan(&timezone, &result.StreakFreezes)
+	if err != nil {
+		return result, err
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	played, frozen, err := getUserStreakDays(Connection, userID)
+	if err != nil {
+		return result, err
+	}
+
+	if len(played) > 0 {
+		result.LastPlayed = sql.NullTime{Time: played[len(played)-1], Valid: true}
+	}
+
+	result.Current, result.Longest = utils.CalculateStreaks(played, frozen, time.Now().In(location))
+	return result, nil
+}
+
+func getUserStreakDays(db executor, userID int) ([]time.Time, []time.Time, error) {
+	played, err := getDates(db, "SELECT DISTINCT playedOn FROM userTriviaPlays WHERE userId = $1 ORDER BY playedOn;", userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	frozen, err := getDates(db, "SELECT frozenOn FROM userStreakFreezes WHERE userId = $1 ORDER BY frozenOn;", userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return played, frozen, nil
+}
+
+func getDates(db executor, statement string, args ...interface{}) ([]time.Time, error) {
+	rows, err := db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dates = []time.Time{}
+	for rows.Next() {
+		var date time.Time
+		if err = rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, rows.Err()
+}
+
+var AddUserStreakFreezes = func(userID, count int) error {
+	statement := "UPDATE users SET streakFreezes = streakFreezes + $2 WHERE id = $1 RETURNING id;"
+	var id int
+	return Connection.QueryRow(statement, userID, count).Scan(&id)
+}
+
+func getUserTriviaPlayCounts(userID int) (int, int, error) {
+	var plays, perfect int
+	err := Connection.QueryRow("SELECT COUNT(id), COUNT(id) FILTER (WHERE maxScore > 0 AND score = maxScore) FROM userTriviaPlays WHERE userId = $1;", userID).Scan(&plays, &perfect)
+	return plays, perfect, err
+}
+
+var GetTriviaLeaderboard = func(date string, filterParams GetTriviaLeaderboardFilterParams) ([]TriviaLeaderboardEntryDto, error) {
+	statement := "SELECT p.userId, u.username, u.countryCode, p.score, p.maxScore, p.added, RANK () OVER (ORDER BY p.score DESC, p.added) rank FROM userTriviaPlays p JOIN users u ON u.id = p.userId WHERE p.triviaDate = $1 ORDER BY p.score DESC, p.added LIMIT $2 OFFSET $3;"
+	rows, err := Connection.Query(statement, date, filterParams.Limit, filterParams.Page*filterParams.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries = []TriviaLeaderboardEntryDto{}
+	for rows.Next() {
+		var entry TriviaLeaderboardEntryDto
+		if err = rows.Scan(&entry.UserID, &entry.Username, &entry.CountryCode, &entry.Score, &entry.MaxScore, &entry.Added, &entry.Rank); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+var GetFirstTriviaLeaderboardID = func(date string, filterParams GetTriviaLeaderboardFilterParams) (int, error) {
+	statement := "SELECT id FROM userTriviaPlays WHERE triviaDate = $1 ORDER BY score DESC, added LIMIT 1 OFFSET $2;"
+	var id int
+	err := Connection.QueryRow(statement, date, (filterParams.Page+1)*filterParams.Limit).Scan(&id)
+	return id, err
+}
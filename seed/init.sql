@@ -23,6 +23,17 @@ INSERT INTO badges (typeid, continentId, name, description, imageUrl, background
 (3, 6, 'PacificBuff', 'Complete all Oceania quizzes.', 'https://twemoji.maxcdn.com/v/13.0.1/svg/1f3dd.svg', '#D3ECFF', '#F4900C'),
 (4, null, 'Sharing is Caring', 'Create a community quiz.', 'https://twemoji.maxcdn.com/v/13.0.1/svg/1f91d.svg', '#276f86', '#000000');
 
+INSERT INTO badgeRules (badgeId, metric, threshold) values
+(1, 'leaderboard_entries', 1),
+(2, 'badge_quizzes_completed', null),
+(3, 'badge_quizzes_completed', null),
+(4, 'badge_quizzes_completed', null),
+(5, 'badge_quizzes_completed', null),
+(6, 'badge_quizzes_completed', null),
+(7, 'badge_quizzes_completed', null),
+(8, 'badge_quizzes_completed', null),
+(9, 'community_quizzes_created', 1);
+
 INSERT INTO avatarTypes (name) values
 ('Commando'),
 ('Traveller'),
@@ -106,11 +117,11 @@ INSERT INTO triviaQuestionType (name) values
 ('Flag'),
 ('Map');
 
-INSERT INTO merch (name, description, sizeGuideImageUrl, price, externalLink, route) values
-('Tee', 'With summer just around the corner, we teamed up with the only carbon neutral clothing company in New Zealand (Koa Goods) to bring you guys the freshest eco-friendly tee to let the squad know you''re ready to drop those countries of the world at a moments notice. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', 'https://geobuff.sgp1.digitaloceanspaces.com/merch/tee/size-guide.png', 49.99, null, 'tee'),
-('Socks', 'With summer just around the corner, we teamed up with the only carbon neutral clothing company in New Zealand (Koa Goods) to bring you guys the freshest eco-friendly hoof covers to let the squad know you don''t mess around when it comes to capital cities. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 11.99, null, 'socks'),
-('Poster Combo', 'Just moved flats and the bedroom walls are looking bare, boring and barren? The folks at GeoBuff HQ have got you covered. We''ve teamed up with the goodfella''s at The Big Picture to spruce up that decor and let the homies know that when the flags come out, you mean business. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 29.99, null, 'poster-combo'),
-('Sticker Pack', 'Rear window on the wagon covered in dust and Raglan Roast have run out of stickers? The folks at GeoBuff HQ have got you covered. We''ve teamed up with the goodfella''s at The Big Picture to spice up that rear window and let the geezers in the slow lane know that you get your geoflex on. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 24.99, null, 'sticker-pack');
+INSERT INTO merch (name, description, sizeGuideImageUrl, price, externalLink, route, weight) values
+('Tee', 'With summer just around the corner, we teamed up with the only carbon neutral clothing company in New Zealand (Koa Goods) to bring you guys the freshest eco-friendly tee to let the squad know you''re ready to drop those countries of the world at a moments notice. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', 'https://geobuff.sgp1.digitaloceanspaces.com/merch/tee/size-guide.png', 49.99, null, 'tee', 220),
+('Socks', 'With summer just around the corner, we teamed up with the only carbon neutral clothing company in New Zealand (Koa Goods) to bring you guys the freshest eco-friendly hoof covers to let the squad know you don''t mess around when it comes to capital cities. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 11.99, null, 'socks', 80),
+('Poster Combo', 'Just moved flats and the bedroom walls are looking bare, boring and barren? The folks at GeoBuff HQ have got you covered. We''ve teamed up with the goodfella''s at The Big Picture to spruce up that decor and let the homies know that when the flags come out, you mean business. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 29.99, null, 'poster-combo', 350),
+('Sticker Pack', 'Rear window on the wagon covered in dust and Raglan Roast have run out of stickers? The folks at GeoBuff HQ have got you covered. We''ve teamed up with the goodfella''s at The Big Picture to spice up that rear window and let the geezers in the slow lane know that you get your geoflex on. By copping one of these OG pieces you''re directly contributing to hosting costs and helping us keep this thing afloat. Kia Kaha!', null, 24.99, null, 'sticker-pack', 40);
 
 INSERT INTO merchSizes (merchId, size, quantity) values
 (1, 'S', 0),
@@ -145,10 +156,42 @@ INSERT INTO discounts (merchId, code, amount) values
 INSERT INTO orderStatus (status) values
 ('Pending'),
 ('Payment Received'),
-('Shipped');
+('Shipped'),
+('Packed'),
+('Delivered'),
+('Cancelled'),
+('Refunded');
 
 INSERT INTO shippingOptions (name, description, price, imageUrl) values
-('NZ-Wide Standard Shipping', 'Expect delivery in 5-7 days', 5.99, 'https://upload.wikimedia.org/wikipedia/commons/1/1f/NZ_Post_logo.png');
+('NZ-Wide Standard Shipping', 'Expect delivery in 5-7 days', 5.99, 'https://upload.wikimedia.org/wikipedia/commons/1/1f/NZ_Post_logo.png'),
+('International Tracked Shipping', 'Expect delivery in 10-15 days', 24.99, 'https://upload.wikimedia.org/wikipedia/commons/1/1f/NZ_Post_logo.png');
+
+INSERT INTO currencies (code, rate, roundingIncrement, priceEnding) values
+('AUD', 0.92, 0.01, 0.99),
+('USD', 0.61, 0.01, 0.99),
+('GBP', 0.48, 0.01, 0.99),
+('EUR', 0.56, 0.01, 0.99);
+
+INSERT INTO currencyCountries (countryCode, currencyCode) values
+('nz', 'NZD'),
+('au', 'AUD'),
+('us', 'USD'),
+('gb', 'GBP'),
+('ie', 'EUR'),
+('de', 'EUR'),
+('fr', 'EUR'),
+('es', 'EUR'),
+('it', 'EUR'),
+('nl', 'EUR');
+
+INSERT INTO shippingOptionCountries (shippingOptionId, countryCode) values
+(1, 'nz');
+
+INSERT INTO shippingRates (shippingOptionId, currencyCode, maxQuantity, maxWeight, price) values
+(2, 'NZD', null, 500, 24.99),
+(2, 'NZD', null, 2000, 39.99),
+(2, 'AUD', null, 500, 19.99),
+(2, 'AUD', null, 2000, 34.99);
 
 INSERT INTO communityQuizStatus (name) values
 ('Pending'),
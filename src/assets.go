@@ -0,0 +1,36 @@
+package src
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+// ASSET_CONTENT_SECURITY_POLICY stops anything an uploaded svg still refers
+// to from loading when it's opened directly.
+const ASSET_CONTENT_SECURITY_POLICY = "default-src 'none'; style-src 'unsafe-inline'; img-src data:"
+
+// getAsset serves files from the blob store. Asset keys change with their
+// content, so they can be cached for good.
+func (s *Server) getAsset(writer http.ResponseWriter, request *http.Request) {
+	data, contentType, err := s.ms.Get(mux.Vars(request)["key"])
+	if err == utils.ErrBlobNotFound || err == utils.ErrInvalidBlobKey {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Security-Policy", ASSET_CONTENT_SECURITY_POLICY)
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	writer.Write(data)
+}
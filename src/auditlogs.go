@@ -0,0 +1,66 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/geobuff/api/repo"
+)
+
+type AuditLogPageDto struct {
+	Logs    []repo.AuditLog `json:"logs"`
+	HasMore bool            `json:"hasMore"`
+}
+
+func GetAuditLogs(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filterParams repo.GetAuditLogsFilterParams
+	err = json.Unmarshal(requestBody, &filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	logs, err := repo.GetAuditLogs(filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch _, err := repo.GetFirstAuditLogID(filterParams); err {
+	case sql.ErrNoRows:
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(AuditLogPageDto{logs, false})
+	case nil:
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(AuditLogPageDto{logs, true})
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
+
+// writeAdminChangeError maps database errors from catalogue changes to a status
+// code. conflict is shown when a unique or foreign key constraint blocks it.
+func writeAdminChangeError(writer http.ResponseWriter, err error, conflict string) {
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNotFound)
+	case repo.IsUniqueViolation(err), repo.IsForeignKeyViolation(err):
+		http.Error(writer, conflict, http.StatusConflict)
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
@@ -379,6 +379,19 @@ var IsAdmin = func(request *http.Request) (int, error) {
 	return http.StatusOK, nil
 }
 
+var getRequestUserID = func(request *http.Request) (int, error) {
+	token, err := getToken(request)
+	if err != nil {
+		return 0, err
+	}
+
+	claims, err := getClaims(token)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
 var getToken = func(request *http.Request) (string, error) {
 	header := request.Header.Get("Authorization")
 	if len(header) < 8 {
@@ -3,6 +3,7 @@ package src
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -37,3 +38,12 @@ func GetUserBadges(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(badges)
 }
+
+// evaluateUserBadges runs after whatever earned the badge has been saved, so a
+// failure is logged and picked up by the next evaluation instead of failing
+// the request.
+func evaluateUserBadges(userID int, event string) {
+	if err := repo.EvaluateUserBadges(userID, event); err != nil {
+		log.Printf("failed to evaluate badges for user %d on %s: %v", userID, event, err)
+	}
+}
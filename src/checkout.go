@@ -2,6 +2,7 @@ package src
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,12 +11,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/geobuff/api/repo"
-	"github.com/stripe/stripe-go/client"
-	"github.com/stripe/stripe-go/v72"
-	"github.com/stripe/stripe-go/v72/checkout/session"
-	"github.com/stripe/stripe-go/webhook"
+	"github.com/geobuff/api/utils"
+	"github.com/google/uuid"
 )
 
 type ErrResp struct {
@@ -32,7 +33,7 @@ type WebhookDto struct {
 	Customer string `json:"customer"`
 }
 
-func HandleCreateCheckoutSession(writer http.ResponseWriter, request *http.Request) {
+func (s *Server) getCheckoutQuote(writer http.ResponseWriter, request *http.Request) {
 	requestBody, err := ioutil.ReadAll(request.Body)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
@@ -46,100 +47,290 @@ func HandleCreateCheckoutSession(writer http.ResponseWriter, request *http.Reque
 		return
 	}
 
-	_, err = repo.InsertOrder(createCheckoutDto)
+	quote, _, _, code, err := s.quoteCheckout(&createCheckoutDto)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
 		return
 	}
 
-	var lineItems []*stripe.CheckoutSessionLineItemParams
-	for _, checkoutItem := range createCheckoutDto.Items {
-		merchItem, err := repo.GetMerchItem(checkoutItem.ID)
-		if err != nil {
-			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
-			return
-		}
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(quote)
+}
 
-		amount := int64(merchItem.Price.Float64 * 100)
-		image := os.Getenv("SITE_URL") + merchItem.Images[0].ImageUrl
-		newItem := stripe.CheckoutSessionLineItemParams{
-			Amount:   &amount,
-			Name:     stripe.String(fmt.Sprintf("%s - %s", merchItem.Name, checkoutItem.SizeName)),
-			Images:   []*string{stripe.String(image)},
-			Currency: stripe.String("NZD"),
-			Quantity: stripe.Int64(int64(checkoutItem.Quantity)),
-		}
+func (s *Server) createCheckoutSession(writer http.ResponseWriter, request *http.Request) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
 
-		lineItems = append(lineItems, &newItem)
+	var createCheckoutDto repo.CreateCheckoutDto
+	err = json.Unmarshal(requestBody, &createCheckoutDto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
 	}
 
-	shippingOption, err := repo.GetShippingOption(createCheckoutDto.ShippingId)
+	createCheckoutDto.Customer.Address, err = utils.ValidateAddress(createCheckoutDto.Customer.Address)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	amount := int64(shippingOption.Price * 100)
-	shippingItem := stripe.CheckoutSessionLineItemParams{
-		Amount:   &amount,
-		Name:     stripe.String(shippingOption.Name),
-		Images:   []*string{stripe.String(shippingOption.ImageURL)},
-		Currency: stripe.String("NZD"),
-		Quantity: stripe.Int64(1),
+	quote, merch, shippingOption, code, err := s.quoteCheckout(&createCheckoutDto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
 	}
-	lineItems = append(lineItems, &shippingItem)
 
-	var discounts []*stripe.CheckoutSessionDiscountParams
-	if createCheckoutDto.DiscountId.Valid {
-		discount, err := repo.GetDiscount(int(createCheckoutDto.DiscountId.Int64))
-		if err != nil {
-			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
-			return
+	var lineItems []utils.PaymentLineItem
+	for index, line := range quote.Lines {
+		merchItem := merch[line.MerchID]
+		newItem := utils.PaymentLineItem{
+			Name:      fmt.Sprintf("%s - %s", merchItem.Name, createCheckoutDto.Items[index].SizeName),
+			UnitPrice: line.UnitPrice,
+			Quantity:  line.Quantity,
 		}
 
-		discounts = []*stripe.CheckoutSessionDiscountParams{
-			{
-				Coupon: stripe.String(discount.Code),
-			},
+		if len(merchItem.Images) > 0 {
+			newItem.ImageURL = os.Getenv("SITE_URL") + merchItem.Images[0].ImageUrl
 		}
+		lineItems = append(lineItems, newItem)
 	}
 
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-	params := &stripe.CheckoutSessionParams{
-		SuccessURL: stripe.String(os.Getenv("SITE_URL") + "/checkout/success?session_id={CHECKOUT_SESSION_ID}"),
-		CancelURL:  stripe.String(fmt.Sprintf("%s/checkout/canceled?email=%s", os.Getenv("SITE_URL"), createCheckoutDto.Customer.Email)),
-		PaymentMethodTypes: stripe.StringSlice([]string{
-			"card",
-		}),
-		Mode:          stripe.String(string(stripe.CheckoutSessionModePayment)),
-		LineItems:     lineItems,
-		CustomerEmail: &createCheckoutDto.Customer.Email,
-		Discounts:     discounts,
+	lineItems = append(lineItems, utils.PaymentLineItem{
+		Name:      shippingOption.Name,
+		UnitPrice: quote.Shipping,
+		Quantity:  1,
+		ImageURL:  shippingOption.ImageURL,
+	})
+
+	reservedUntil := time.Now().Add(repo.ORDER_RESERVATION_DURATION)
+	cancelToken := uuid.New().String()
+	orderID, err := repo.InsertOrder(createCheckoutDto, quote, reservedUntil, cancelToken)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), checkoutErrorStatus(err))
+		return
 	}
 
-	s, err := session.New(params)
+	checkoutSession, err := s.pp.CreateCheckout(utils.CheckoutRequest{
+		OrderID:        orderID,
+		Email:          createCheckoutDto.Customer.Email,
+		Currency:       quote.Currency,
+		LineItems:      lineItems,
+		DiscountName:   quote.DiscountCode,
+		DiscountAmount: quote.Discount,
+		SuccessURL:     os.Getenv("SITE_URL") + "/checkout/success?session_id={CHECKOUT_SESSION_ID}",
+		CancelURL:      fmt.Sprintf("%s/checkout/canceled?token=%s", os.Getenv("SITE_URL"), cancelToken),
+		ExpiresAt:      reservedUntil,
+	})
 	if err != nil {
+		repo.ReleaseOrder(orderID)
 		writeJSON(writer, nil, err)
 		return
 	}
 
+	err = repo.UpdateOrderCheckoutSession(orderID, checkoutSession.ID)
+	if err != nil {
+		repo.ReleaseOrder(orderID)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
 	result := CreateCheckoutResult{
-		SessionID: s.ID,
+		SessionID: checkoutSession.ID,
 	}
 	writeJSON(writer, result, nil)
 }
 
+// DEFAULT_COUNTRY_CODE is the destination assumed for clients that don't send
+// one, since shipping used to be NZ only.
+const DEFAULT_COUNTRY_CODE = "nz"
+
+// quoteCheckout prices the cart from the database rather than trusting the
+// client, returning the merch and shipping option used so callers can describe
+// the line items. The destination country and resolved currency are filled in
+// on the checkout so they can be saved with the order.
+func (s *Server) quoteCheckout(checkout *repo.CreateCheckoutDto) (utils.PriceQuote, map[int]*repo.MerchDto, repo.ShippingOption, int, error) {
+	var quote utils.PriceQuote
+	var shippingOption repo.ShippingOption
+	if len(checkout.Items) == 0 {
+		return quote, nil, shippingOption, http.StatusBadRequest, errors.New("cart is empty")
+	}
+
+	checkout.Customer.Address = utils.NormaliseAddress(checkout.Customer.Address)
+	if checkout.Customer.Address.CountryCode == "" {
+		checkout.Customer.Address.CountryCode = DEFAULT_COUNTRY_CODE
+	}
+
+	currency, code, err := getCheckoutCurrency(*checkout)
+	if err != nil {
+		return quote, nil, shippingOption, code, err
+	}
+	checkout.Currency = currency.Code
+
+	prices, err := repo.GetMerchPrices(currency.Code)
+	if err != nil {
+		return quote, nil, shippingOption, http.StatusInternalServerError, err
+	}
+
+	merch := make(map[int]*repo.MerchDto)
+	input := utils.PricingInput{Currency: currency.Code}
+	var quantity, weight int
+	for _, item := range checkout.Items {
+		merchItem, found := merch[item.ID]
+		if !found {
+			var err error
+			merchItem, err = repo.GetMerchItem(item.ID)
+			if err != nil {
+				return quote, nil, shippingOption, lookupErrorStatus(err), err
+			}
+			merch[item.ID] = merchItem
+		}
+
+		if !merchItem.Price.Valid {
+			return quote, nil, shippingOption, http.StatusBadRequest, fmt.Errorf("%s is not available for purchase", merchItem.Name)
+		}
+
+		unitPrice, found := prices[item.ID]
+		if !found {
+			unitPrice = utils.ConvertPrice(merchItem.Price.Float64, currency)
+		}
+
+		quantity = quantity + item.Quantity
+		weight = weight + int(merchItem.Weight.Int64)*item.Quantity
+		input.Lines = append(input.Lines, utils.PricingLineInput{
+			MerchID:   item.ID,
+			SizeID:    item.SizeID,
+			Quantity:  item.Quantity,
+			UnitPrice: unitPrice,
+		})
+	}
+
+	shippingOption, err = repo.GetShippingOption(checkout.ShippingId)
+	if err != nil {
+		return quote, nil, shippingOption, lookupErrorStatus(err), err
+	}
+
+	input.Shipping, code, err = getShippingPrice(shippingOption, checkout.Customer.Address.CountryCode, currency, quantity, weight)
+	if err != nil {
+		return quote, nil, shippingOption, code, err
+	}
+
+	if checkout.DiscountId.Valid {
+		discount, err := repo.GetDiscount(int(checkout.DiscountId.Int64))
+		if err != nil {
+			return quote, nil, shippingOption, lookupErrorStatus(err), err
+		}
+
+		uses, customerUses, err := repo.GetDiscountUsage(discount.ID, checkout.Customer.Email)
+		if err != nil {
+			return quote, nil, shippingOption, http.StatusInternalServerError, err
+		}
+
+		pricingDiscount := discount.PricingInput(uses, customerUses)
+		pricingDiscount.Amount = utils.ConvertAmount(pricingDiscount.Amount, currency)
+		input.Discount = &pricingDiscount
+	}
+
+	quote, err = s.ps.Quote(input, time.Now())
+	if err != nil {
+		return quote, nil, shippingOption, http.StatusBadRequest, err
+	}
+	return quote, merch, shippingOption, http.StatusOK, nil
+}
+
+// getCheckoutCurrency uses the currency the customer picked, otherwise the one
+// for their country.
+func getCheckoutCurrency(checkout repo.CreateCheckoutDto) (utils.Currency, int, error) {
+	if checkout.Currency == "" {
+		currency, err := repo.GetCountryCurrency(checkout.Customer.Address.CountryCode)
+		if err != nil {
+			return currency, http.StatusInternalServerError, err
+		}
+		return currency, http.StatusOK, nil
+	}
+
+	currency, err := repo.GetCurrency(checkout.Currency)
+	if err == sql.ErrNoRows {
+		return currency, http.StatusBadRequest, fmt.Errorf("currency %s is not supported", strings.ToUpper(checkout.Currency))
+	} else if err != nil {
+		return currency, http.StatusInternalServerError, err
+	}
+	return currency, http.StatusOK, nil
+}
+
+// getShippingPrice uses the smallest rate tier that fits the cart. Rates set in
+// the currency win over converted base currency rates, and options without any
+// tiers charge their flat price.
+func getShippingPrice(option repo.ShippingOption, countryCode string, currency utils.Currency, quantity, weight int) (float64, int, error) {
+	delivers, err := repo.ShippingOptionDeliversTo(option.ID, countryCode)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	if !delivers {
+		return 0, http.StatusBadRequest, fmt.Errorf("%s does not deliver to %s", option.Name, strings.ToUpper(countryCode))
+	}
+
+	rates, err := repo.GetShippingRates(option.ID, currency.Code)
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+
+	converted := false
+	if len(rates) == 0 && currency.Code != utils.BASE_CURRENCY {
+		if rates, err = repo.GetShippingRates(option.ID, utils.BASE_CURRENCY); err != nil {
+			return 0, http.StatusInternalServerError, err
+		}
+		converted = true
+	}
+
+	if len(rates) == 0 {
+		return utils.ConvertPrice(option.Price, currency), http.StatusOK, nil
+	}
+
+	var tiers []utils.ShippingTier
+	for _, rate := range rates {
+		tiers = append(tiers, rate.Tier())
+	}
+
+	tier, found := utils.SelectShippingTier(tiers, quantity, weight)
+	if !found {
+		return 0, http.StatusBadRequest, fmt.Errorf("cart is too large to send with %s", option.Name)
+	}
+
+	if converted {
+		return utils.ConvertPrice(tier.Price, currency), http.StatusOK, nil
+	}
+	return tier.Price, http.StatusOK, nil
+}
+
+func lookupErrorStatus(err error) int {
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func checkoutErrorStatus(err error) int {
+	var stockErr repo.InsufficientStockError
+	switch {
+	case errors.As(err, &stockErr):
+		return http.StatusConflict
+	case err == utils.ErrDiscountExpired, err == utils.ErrDiscountUsageLimit, err == utils.ErrDiscountCustomerLimit:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}, err error) {
 	var respVal interface{}
 	if err != nil {
-		msg := err.Error()
-		var serr *stripe.Error
-		if errors.As(err, &serr) {
-			msg = serr.Msg
-		}
 		w.WriteHeader(http.StatusBadRequest)
 		var e ErrResp
-		e.Error.Message = msg
+		e.Error.Message = err.Error()
 		respVal = e
 	} else {
 		respVal = v
@@ -159,57 +350,45 @@ func writeJSON(w http.ResponseWriter, v interface{}, err error) {
 	}
 }
 
-func HandleWebhook(writer http.ResponseWriter, request *http.Request) {
+func (s *Server) handleWebhook(writer http.ResponseWriter, request *http.Request) {
 	requestBody, err := ioutil.ReadAll(request.Body)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	event, err := webhook.ConstructEvent(requestBody, request.Header.Get("Stripe-Signature"), os.Getenv("STRIPE_WEBHOOK_SECRET"))
+	event, err := s.pp.VerifyWebhook(requestBody, request.Header)
 	if err != nil {
 		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	switch event.Type {
-	case "payment_intent.succeeded":
-		var req struct {
-			Customer string `json:"customer"`
-		}
-
-		err := json.Unmarshal(event.Data.Raw, &req)
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusInternalServerError)
+	switch event.Outcome {
+	case utils.PAYMENT_EVENT_CHECKOUT_PAID:
+		processed, err := repo.CompleteOrderPayment(event.ID, event.Type, event.OrderID, event.PaymentID)
+		if err == repo.ErrOrderNotPending {
+			// The order was cancelled or released while the customer was
+			// paying, so there's nothing to fulfil and the money goes back.
+			if _, err = s.pp.Refund(event.PaymentID); err != nil && err != utils.ErrPaymentAlreadyRefunded {
+				log.Printf("failed to refund payment %s for order %d: %v", event.PaymentID, event.OrderID, err)
+				http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("refunded payment %s for order %d which is no longer pending", event.PaymentID, event.OrderID)
 			return
-		}
-
-		sc := &client.API{}
-		sc.Init(os.Getenv("STRIPE_SECRET_KEY"), nil)
-		c, err := sc.Customers.Get(req.Customer, nil)
-		if err != nil {
+		} else if err != nil {
 			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 			return
 		}
 
-		id, err := repo.UpdateStatusLatestOrder(c.Email)
-		if err != nil {
-			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
-			return
+		if processed {
+			s.notifyOrderStatus(event.OrderID)
 		}
-
-		items, err := repo.GetOrderItems(id)
+	case utils.PAYMENT_EVENT_CHECKOUT_FAILED:
+		_, err = repo.ReleaseOrderReservation(event.ID, event.Type, event.OrderID)
 		if err != nil {
 			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 			return
 		}
-
-		for _, val := range items {
-			err = repo.ReduceMerchItemQuantity(val.SizeID, val.Quantity)
-			if err != nil {
-				http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
-				return
-			}
-		}
 	}
 }
@@ -0,0 +1,617 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestHandleWebhook(t *testing.T) {
+	savedCompleteOrderPayment := repo.CompleteOrderPayment
+	savedReleaseOrderReservation := repo.ReleaseOrderReservation
+	savedGetOrderSummary := repo.GetOrderSummary
+
+	defer func() {
+		repo.CompleteOrderPayment = savedCompleteOrderPayment
+		repo.ReleaseOrderReservation = savedReleaseOrderReservation
+		repo.GetOrderSummary = savedGetOrderSummary
+	}()
+
+	repo.GetOrderSummary = func(orderID int) (repo.OrderSummaryDto, error) {
+		return repo.OrderSummaryDto{ID: orderID, StatusID: repo.ORDER_STATUS_PAYMENT_RECEIVED}, nil
+	}
+
+	tt := []struct {
+		name                 string
+		completeOrderPayment func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error)
+		releaseOrder         func(eventID, eventType string, orderID int) (bool, error)
+		body                 string
+		signed               bool
+		status               int
+		orderID              int
+		releasedOrderID      int
+		emails               int
+		refunded             bool
+	}{
+		{
+			name:                 "invalid signature",
+			completeOrderPayment: repo.CompleteOrderPayment,
+			body:                 `{"id": "evt_1", "type": "checkout_paid", "outcome": "checkout_paid", "orderId": 5}`,
+			signed:               false,
+			status:               http.StatusBadRequest,
+		},
+		{
+			name:                 "unhandled event type",
+			completeOrderPayment: repo.CompleteOrderPayment,
+			body:                 `{"id": "evt_1", "type": "payment_intent.succeeded", "outcome": ""}`,
+			signed:               true,
+			status:               http.StatusOK,
+		},
+		{
+			name: "error on CompleteOrderPayment",
+			completeOrderPayment: func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+				return false, errors.New("test")
+			},
+			body:    `{"id": "evt_1", "type": "checkout_paid", "outcome": "checkout_paid", "orderId": 5, "paymentId": "pi_1"}`,
+			signed:  true,
+			status:  http.StatusInternalServerError,
+			orderID: 5,
+		},
+		{
+			name: "happy path",
+			completeOrderPayment: func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+				return true, nil
+			},
+			body:    `{"id": "evt_1", "type": "checkout_paid", "outcome": "checkout_paid", "orderId": 5, "paymentId": "pi_1"}`,
+			signed:  true,
+			status:  http.StatusOK,
+			orderID: 5,
+			emails:  1,
+		},
+		{
+			name: "happy path, order no longer pending",
+			completeOrderPayment: func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+				return false, repo.ErrOrderNotPending
+			},
+			body:     `{"id": "evt_1", "type": "checkout_paid", "outcome": "checkout_paid", "orderId": 5, "paymentId": "%s"}`,
+			signed:   true,
+			status:   http.StatusOK,
+			orderID:  5,
+			refunded: true,
+		},
+		{
+			name: "happy path, redelivered event",
+			completeOrderPayment: func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+				return false, nil
+			},
+			body:    `{"id": "evt_1", "type": "checkout_paid", "outcome": "checkout_paid", "orderId": 5, "paymentId": "pi_1"}`,
+			signed:  true,
+			status:  http.StatusOK,
+			orderID: 5,
+		},
+		{
+			name: "error on ReleaseOrderReservation",
+			releaseOrder: func(eventID, eventType string, orderID int) (bool, error) {
+				return false, errors.New("test")
+			},
+			body:            `{"id": "evt_2", "type": "checkout_failed", "outcome": "checkout_failed", "orderId": 5}`,
+			signed:          true,
+			status:          http.StatusInternalServerError,
+			releasedOrderID: 5,
+		},
+		{
+			name: "happy path, expired session",
+			releaseOrder: func(eventID, eventType string, orderID int) (bool, error) {
+				return true, nil
+			},
+			body:            `{"id": "evt_2", "type": "checkout_failed", "outcome": "checkout_failed", "orderId": 5}`,
+			signed:          true,
+			status:          http.StatusOK,
+			releasedOrderID: 5,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var completedOrderID int
+			repo.CompleteOrderPayment = func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+				completedOrderID = orderID
+				return tc.completeOrderPayment(eventID, eventType, orderID, paymentIntentID)
+			}
+
+			var releasedOrderID int
+			repo.ReleaseOrderReservation = func(eventID, eventType string, orderID int) (bool, error) {
+				releasedOrderID = orderID
+				return tc.releaseOrder(eventID, eventType, orderID)
+			}
+
+			pp := utils.NewFakePaymentProvider("whsec_test")
+			paymentID := newFakePayment(t, pp)
+			body := tc.body
+			if strings.Contains(body, "%s") {
+				body = fmt.Sprintf(body, paymentID)
+			}
+
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			if tc.signed {
+				request.Header = pp.SignWebhook([]byte(body), time.Now())
+			} else {
+				request.Header = utils.NewFakePaymentProvider("whsec_other").SignWebhook([]byte(body), time.Now())
+			}
+
+			es := &mockEmailService{}
+			writer := httptest.NewRecorder()
+			getMockServerWithServices(es, pp).handleWebhook(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if completedOrderID != tc.orderID {
+				t.Errorf("expected order id %v; got %v", tc.orderID, completedOrderID)
+			}
+
+			if releasedOrderID != tc.releasedOrderID {
+				t.Errorf("expected released order id %v; got %v", tc.releasedOrderID, releasedOrderID)
+			}
+
+			if len(es.updates) != tc.emails {
+				t.Errorf("expected %v emails; got %v", tc.emails, len(es.updates))
+			}
+
+			if payment, _ := pp.GetPayment(paymentID); payment.Refunded != tc.refunded {
+				t.Errorf("expected refunded %v; got %v", tc.refunded, payment.Refunded)
+			}
+		})
+	}
+}
+
+func TestGetCheckoutQuote(t *testing.T) {
+	savedGetMerchItem := repo.GetMerchItem
+	savedGetShippingOption := repo.GetShippingOption
+	savedGetDiscount := repo.GetDiscount
+	savedGetDiscountUsage := repo.GetDiscountUsage
+	savedGetCurrency := repo.GetCurrency
+	savedGetCountryCurrency := repo.GetCountryCurrency
+	savedGetMerchPrices := repo.GetMerchPrices
+	savedShippingOptionDeliversTo := repo.ShippingOptionDeliversTo
+	savedGetShippingRates := repo.GetShippingRates
+
+	defer func() {
+		repo.GetMerchItem = savedGetMerchItem
+		repo.GetShippingOption = savedGetShippingOption
+		repo.GetDiscount = savedGetDiscount
+		repo.GetDiscountUsage = savedGetDiscountUsage
+		repo.GetCurrency = savedGetCurrency
+		repo.GetCountryCurrency = savedGetCountryCurrency
+		repo.GetMerchPrices = savedGetMerchPrices
+		repo.ShippingOptionDeliversTo = savedShippingOptionDeliversTo
+		repo.GetShippingRates = savedGetShippingRates
+	}()
+
+	currencies := map[string]utils.Currency{
+		"NZD": {Code: "NZD", Rate: 1, RoundingIncrement: 0.01},
+		"AUD": {Code: "AUD", Rate: 0.9, RoundingIncrement: 0.01},
+		"USD": {Code: "USD", Rate: 0.6, RoundingIncrement: 0.01, PriceEnding: sql.NullFloat64{Float64: 0.99, Valid: true}},
+	}
+	repo.GetCurrency = func(code string) (utils.Currency, error) {
+		currency, found := currencies[strings.ToUpper(code)]
+		if !found {
+			return currency, sql.ErrNoRows
+		}
+		return currency, nil
+	}
+	repo.GetCountryCurrency = func(countryCode string) (utils.Currency, error) {
+		if countryCode == "au" {
+			return currencies["AUD"], nil
+		}
+		return currencies["NZD"], nil
+	}
+	repo.GetMerchPrices = func(currencyCode string) (map[int]float64, error) {
+		if currencyCode == "AUD" {
+			return map[int]float64{1: 19}, nil
+		}
+		return map[int]float64{}, nil
+	}
+	repo.ShippingOptionDeliversTo = func(id int, countryCode string) (bool, error) {
+		return id != 2 || countryCode == "nz", nil
+	}
+
+	// Option 3 has tiered rates in NZD only.
+	repo.GetShippingRates = func(shippingOptionID int, currencyCode string) ([]repo.ShippingRate, error) {
+		if shippingOptionID != 3 || currencyCode != "NZD" {
+			return nil, nil
+		}
+		return []repo.ShippingRate{
+			{ShippingOptionID: 3, CurrencyCode: "NZD", MaxQuantity: sql.NullInt64{Int64: 2, Valid: true}, Price: 10},
+			{ShippingOptionID: 3, CurrencyCode: "NZD", MaxQuantity: sql.NullInt64{Int64: 4, Valid: true}, Price: 15},
+		}, nil
+	}
+
+	merchItem := func(id int) (*repo.MerchDto, error) {
+		return &repo.MerchDto{ID: id, Name: "Tee", Price: sql.NullFloat64{Float64: 20, Valid: true}}, nil
+	}
+
+	shippingOption := func(id int) (repo.ShippingOption, error) {
+		return repo.ShippingOption{ID: id, Price: 5.99}, nil
+	}
+
+	discountUsage := func(discountID int, email string) (int, int, error) {
+		return 0, 0, nil
+	}
+
+	tt := []struct {
+		name              string
+		getMerchItem      func(id int) (*repo.MerchDto, error)
+		getShippingOption func(id int) (repo.ShippingOption, error)
+		getDiscount       func(id int) (repo.Discount, error)
+		getDiscountUsage  func(discountID int, email string) (int, int, error)
+		body              string
+		status            int
+		total             float64
+	}{
+		{
+			name:              "invalid body",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              "testing",
+			status:            http.StatusBadRequest,
+		},
+		{
+			name:              "empty cart",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [], "shippingId": 1}`,
+			status:            http.StatusBadRequest,
+		},
+		{
+			name: "merch not found",
+			getMerchItem: func(id int) (*repo.MerchDto, error) {
+				return nil, sql.ErrNoRows
+			},
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "shippingId": 1}`,
+			status:            http.StatusBadRequest,
+		},
+		{
+			name:         "error on GetShippingOption",
+			getMerchItem: merchItem,
+			getShippingOption: func(id int) (repo.ShippingOption, error) {
+				return repo.ShippingOption{}, errors.New("test")
+			},
+			getDiscount:      repo.GetDiscount,
+			getDiscountUsage: discountUsage,
+			body:             `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "shippingId": 1}`,
+			status:           http.StatusInternalServerError,
+		},
+		{
+			name:              "expired discount",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount: func(id int) (repo.Discount, error) {
+				return repo.Discount{ID: id, Code: "OLD", Amount: 5, Expires: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}}, nil
+			},
+			getDiscountUsage: discountUsage,
+			body:             `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "shippingId": 1, "discountId": {"Int64": 1, "Valid": true}}`,
+			status:           http.StatusBadRequest,
+		},
+		{
+			name:              "happy path",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 2}], "shippingId": 1}`,
+			status:            http.StatusOK,
+			total:             45.99,
+		},
+		{
+			name:              "happy path, with discount",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount: func(id int) (repo.Discount, error) {
+				return repo.Discount{ID: id, Code: "NOSHIP", Amount: 5.99}, nil
+			},
+			getDiscountUsage: discountUsage,
+			body:             `{"items": [{"id": 1, "sizeId": 1, "quantity": 2}], "shippingId": 1, "discountId": {"Int64": 1, "Valid": true}}`,
+			status:           http.StatusOK,
+			total:            40,
+		},
+		{
+			name:              "unsupported currency",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "shippingId": 1, "currency": "JPY"}`,
+			status:            http.StatusBadRequest,
+		},
+		{
+			name:              "option does not deliver to country",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "customer": {"address": {"countryCode": "au"}}, "shippingId": 2}`,
+			status:            http.StatusBadRequest,
+		},
+		{
+			name:              "happy path, country currency with set price",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 2}], "customer": {"address": {"countryCode": "au"}}, "shippingId": 1}`,
+			status:            http.StatusOK,
+			total:             43.4,
+		},
+		{
+			name:              "happy path, picked currency with price ending",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 1}], "customer": {"address": {"countryCode": "au"}}, "shippingId": 1, "currency": "usd"}`,
+			status:            http.StatusOK,
+			total:             16.98,
+		},
+		{
+			name:              "happy path, shipping tier",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 3}], "shippingId": 3}`,
+			status:            http.StatusOK,
+			total:             75,
+		},
+		{
+			name:              "cart too large for shipping tiers",
+			getMerchItem:      merchItem,
+			getShippingOption: shippingOption,
+			getDiscount:       repo.GetDiscount,
+			getDiscountUsage:  discountUsage,
+			body:              `{"items": [{"id": 1, "sizeId": 1, "quantity": 5}], "shippingId": 3}`,
+			status:            http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetMerchItem = tc.getMerchItem
+			repo.GetShippingOption = tc.getShippingOption
+			repo.GetDiscount = tc.getDiscount
+			repo.GetDiscountUsage = tc.getDiscountUsage
+
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			getMockServer().getCheckoutQuote(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusOK {
+				body, err := ioutil.ReadAll(result.Body)
+				if err != nil {
+					t.Fatalf("could not read response: %v", err)
+				}
+
+				var parsed utils.PriceQuote
+				err = json.Unmarshal(body, &parsed)
+				if err != nil {
+					t.Errorf("could not unmarshal response body: %v", err)
+				}
+
+				if parsed.Total != tc.total {
+					t.Errorf("expected total %v; got %v", tc.total, parsed.Total)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckoutOrderFlow takes an order from checkout through payment and
+// refund using the fake payment provider in place of Stripe.
+func TestCheckoutOrderFlow(t *testing.T) {
+	savedGetMerchItem := repo.GetMerchItem
+	savedGetShippingOption := repo.GetShippingOption
+	savedInsertOrder := repo.InsertOrder
+	savedUpdateOrderCheckoutSession := repo.UpdateOrderCheckoutSession
+	savedCompleteOrderPayment := repo.CompleteOrderPayment
+	savedGetOrderSummary := repo.GetOrderSummary
+	savedGetOrderPayment := repo.GetOrderPayment
+	savedRefundOrder := repo.RefundOrder
+	savedIsAdmin := IsAdmin
+	savedGetCountryCurrency := repo.GetCountryCurrency
+	savedGetMerchPrices := repo.GetMerchPrices
+	savedShippingOptionDeliversTo := repo.ShippingOptionDeliversTo
+	savedGetShippingRates := repo.GetShippingRates
+
+	defer func() {
+		repo.GetMerchItem = savedGetMerchItem
+		repo.GetShippingOption = savedGetShippingOption
+		repo.GetCountryCurrency = savedGetCountryCurrency
+		repo.GetMerchPrices = savedGetMerchPrices
+		repo.ShippingOptionDeliversTo = savedShippingOptionDeliversTo
+		repo.GetShippingRates = savedGetShippingRates
+		repo.InsertOrder = savedInsertOrder
+		repo.UpdateOrderCheckoutSession = savedUpdateOrderCheckoutSession
+		repo.CompleteOrderPayment = savedCompleteOrderPayment
+		repo.GetOrderSummary = savedGetOrderSummary
+		repo.GetOrderPayment = savedGetOrderPayment
+		repo.RefundOrder = savedRefundOrder
+		IsAdmin = savedIsAdmin
+	}()
+
+	order := repo.OrderPaymentDto{ID: 9, StatusID: repo.ORDER_STATUS_PENDING}
+	var sessionID, cancelToken string
+	repo.GetMerchItem = func(id int) (*repo.MerchDto, error) {
+		return &repo.MerchDto{ID: id, Name: "Tee", Price: sql.NullFloat64{Float64: 20, Valid: true}}, nil
+	}
+	repo.GetShippingOption = func(id int) (repo.ShippingOption, error) {
+		return repo.ShippingOption{ID: id, Name: "Standard", Price: 5.99}, nil
+	}
+	repo.GetCountryCurrency = func(countryCode string) (utils.Currency, error) {
+		return utils.Currency{Code: utils.BASE_CURRENCY, Rate: 1}, nil
+	}
+	repo.GetMerchPrices = func(currencyCode string) (map[int]float64, error) {
+		return map[int]float64{}, nil
+	}
+	repo.ShippingOptionDeliversTo = func(id int, countryCode string) (bool, error) {
+		return true, nil
+	}
+	repo.GetShippingRates = func(shippingOptionID int, currencyCode string) ([]repo.ShippingRate, error) {
+		return nil, nil
+	}
+	repo.InsertOrder = func(checkout repo.CreateCheckoutDto, quote utils.PriceQuote, reservedUntil time.Time, token string) (int, error) {
+		cancelToken = token
+		return order.ID, nil
+	}
+	repo.UpdateOrderCheckoutSession = func(orderID int, id string) error {
+		sessionID = id
+		return nil
+	}
+	repo.CompleteOrderPayment = func(eventID, eventType string, orderID int, paymentIntentID string) (bool, error) {
+		if orderID != order.ID {
+			return false, sql.ErrNoRows
+		}
+		order.StatusID = repo.ORDER_STATUS_PAYMENT_RECEIVED
+		order.PaymentIntentID = sql.NullString{String: paymentIntentID, Valid: true}
+		return true, nil
+	}
+	repo.GetOrderSummary = func(orderID int) (repo.OrderSummaryDto, error) {
+		return repo.OrderSummaryDto{ID: orderID, StatusID: order.StatusID, Email: "test@gmail.com"}, nil
+	}
+	repo.GetOrderPayment = func(orderID int) (repo.OrderPaymentDto, error) {
+		return order, nil
+	}
+	repo.RefundOrder = func(orderID int, restock bool, refund func(payment repo.OrderPaymentDto) (string, error)) (repo.OrderSummaryDto, error) {
+		if !repo.CanTransitionOrder(order.StatusID, repo.ORDER_STATUS_REFUNDED) {
+			return repo.OrderSummaryDto{}, repo.InvalidOrderTransitionError{From: order.StatusID, To: repo.ORDER_STATUS_REFUNDED}
+		}
+
+		if _, err := refund(order); err != nil {
+			return repo.OrderSummaryDto{}, err
+		}
+		order.StatusID = repo.ORDER_STATUS_REFUNDED
+		return repo.OrderSummaryDto{ID: orderID, StatusID: order.StatusID}, nil
+	}
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+
+	pp := utils.NewFakePaymentProvider("whsec_test")
+	es := &mockEmailService{}
+	server := getMockServerWithServices(es, pp)
+
+	body := `{"items": [{"id": 1, "sizeId": 1, "sizeName": "M", "quantity": 2}], "customer": {"email": "test@gmail.com", "address": {"line1": "1 Queen St", "city": "Auckland", "postcode": "1010", "countryCode": "NZ"}}, "shippingId": 1}`
+	request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		t.Fatalf("could not create POST request: %v", err)
+	}
+
+	writer := httptest.NewRecorder()
+	server.createCheckoutSession(writer, request)
+	if writer.Code != http.StatusOK {
+		t.Fatalf("expected checkout status %v; got %v: %s", http.StatusOK, writer.Code, writer.Body.String())
+	}
+
+	checkout, found := pp.Checkout(sessionID)
+	if !found {
+		t.Fatalf("expected checkout session %v to exist", sessionID)
+	}
+
+	if checkout.OrderID != order.ID || len(checkout.LineItems) != 2 {
+		t.Errorf("expected order %v with 2 line items; got order %v with %v", order.ID, checkout.OrderID, len(checkout.LineItems))
+	}
+
+	if cancelToken == "" || !strings.Contains(checkout.CancelURL, cancelToken) || strings.Contains(checkout.CancelURL, "test@gmail.com") {
+		t.Errorf("expected cancel url to carry the cancel token; got %v", checkout.CancelURL)
+	}
+
+	payload, header, err := pp.CompleteCheckout(sessionID)
+	if err != nil {
+		t.Fatalf("could not complete checkout: %v", err)
+	}
+
+	request, err = http.NewRequest("POST", "", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("could not create POST request: %v", err)
+	}
+	request.Header = header
+
+	writer = httptest.NewRecorder()
+	server.handleWebhook(writer, request)
+	if writer.Code != http.StatusOK {
+		t.Fatalf("expected webhook status %v; got %v", http.StatusOK, writer.Code)
+	}
+
+	if order.StatusID != repo.ORDER_STATUS_PAYMENT_RECEIVED || len(es.updates) != 1 {
+		t.Fatalf("expected order to be paid with 1 email; got status %v with %v emails", order.StatusID, len(es.updates))
+	}
+
+	payment, err := pp.GetPayment(order.PaymentIntentID.String)
+	if err != nil {
+		t.Fatalf("could not get payment: %v", err)
+	}
+
+	if payment.Amount != 45.99 {
+		t.Errorf("expected payment of 45.99; got %v", payment.Amount)
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest} {
+		request, err = http.NewRequest("POST", "", bytes.NewBuffer([]byte(`{"restock": true}`)))
+		if err != nil {
+			t.Fatalf("could not create POST request: %v", err)
+		}
+
+		request = mux.SetURLVars(request, map[string]string{
+			"id": strconv.Itoa(order.ID),
+		})
+
+		writer = httptest.NewRecorder()
+		server.refundOrder(writer, request)
+		if writer.Code != status {
+			t.Errorf("expected refund status %v; got %v", status, writer.Code)
+		}
+	}
+
+	payment, err = pp.GetPayment(order.PaymentIntentID.String)
+	if err != nil {
+		t.Fatalf("could not get payment: %v", err)
+	}
+
+	if !payment.Refunded || order.StatusID != repo.ORDER_STATUS_REFUNDED {
+		t.Errorf("expected payment and order to be refunded; got %v and status %v", payment.Refunded, order.StatusID)
+	}
+}
@@ -2,6 +2,7 @@ package src
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -21,4 +22,11 @@ func IncrementCommunityQuizPlays(writer http.ResponseWriter, request *http.Reque
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
+
+	userID, err := repo.GetCommunityQuizUserID(id)
+	if err != nil {
+		log.Printf("failed to get owner of community quiz %d: %v", id, err)
+		return
+	}
+	evaluateUserBadges(userID, repo.BADGE_EVENT_COMMUNITY_QUIZ_PLAY)
 }
@@ -108,6 +108,8 @@ func CreateCommunityQuiz(writer http.ResponseWriter, request *http.Request) {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
+
+	evaluateUserBadges(quiz.UserID, repo.BADGE_EVENT_QUIZ_PUBLISH)
 }
 
 func UpdateCommunityQuiz(writer http.ResponseWriter, request *http.Request) {
@@ -0,0 +1,59 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func GetCurrencies(writer http.ResponseWriter, request *http.Request) {
+	currencies, err := repo.GetCurrencies()
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(currencies)
+}
+
+func GetCountryCurrency(writer http.ResponseWriter, request *http.Request) {
+	currency, err := repo.GetCountryCurrency(mux.Vars(request)["code"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(currency)
+}
+
+// getRequestCurrency resolves the ?currency= query param, or the currency for
+// ?country= if none was picked. Prices default to the base currency.
+func getRequestCurrency(request *http.Request) (utils.Currency, int, error) {
+	query := request.URL.Query()
+	if code := query.Get("currency"); code != "" {
+		currency, err := repo.GetCurrency(code)
+		if err == sql.ErrNoRows {
+			return currency, http.StatusBadRequest, fmt.Errorf("currency %s is not supported", strings.ToUpper(code))
+		} else if err != nil {
+			return currency, http.StatusInternalServerError, err
+		}
+		return currency, http.StatusOK, nil
+	}
+
+	if country := query.Get("country"); country != "" {
+		currency, err := repo.GetCountryCurrency(country)
+		if err != nil {
+			return currency, http.StatusInternalServerError, err
+		}
+		return currency, http.StatusOK, nil
+	}
+	return utils.Currency{Code: utils.BASE_CURRENCY, Rate: 1}, http.StatusOK, nil
+}
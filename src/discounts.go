@@ -3,8 +3,11 @@ package src
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/geobuff/api/repo"
 	"github.com/gorilla/mux"
@@ -37,3 +40,125 @@ func GetDiscount(writer http.ResponseWriter, request *http.Request) {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 	}
 }
+
+func (s *Server) createDiscount(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.DiscountDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateDiscount(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	discount, err := repo.CreateDiscount(userID, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Discount code already in use or merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(discount)
+}
+
+func (s *Server) updateDiscount(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.DiscountDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateDiscount(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	discount, err := repo.UpdateDiscount(userID, id, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Discount code already in use or merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(discount)
+}
+
+func DeleteDiscount(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err = repo.DeleteDiscount(userID, id); err != nil {
+		writeAdminChangeError(writer, err, "Discount has been used on existing orders and can't be deleted. Set it to expire instead.")
+		return
+	}
+}
+
+func (s *Server) validateDiscount(dto repo.DiscountDto) error {
+	if err := s.vs.GetValidator().Struct(dto); err != nil {
+		return err
+	}
+
+	if (dto.UsageLimit.Valid && dto.UsageLimit.Int64 <= 0) || (dto.CustomerLimit.Valid && dto.CustomerLimit.Int64 <= 0) {
+		return errors.New("usage limits must be greater than zero")
+	}
+	return nil
+}
@@ -1,15 +1,45 @@
 package src
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
 	"github.com/gorilla/mux"
 )
 
+const MAX_FLAG_SIZE = 512 * 1024
+
+const (
+	FLAG_URL_CHECK_TIMEOUT     = 10 * time.Second
+	FLAG_URL_CHECK_CONCURRENCY = 8
+)
+
+type FlagUrlCheckDto struct {
+	Code   string `json:"code"`
+	Url    string `json:"url"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	Ok     bool   `json:"ok"`
+}
+
+type FlagUrlReportDto struct {
+	Total  int               `json:"total"`
+	Broken []FlagUrlCheckDto `json:"broken"`
+}
+
 func GetFlagGroups(writer http.ResponseWriter, request *http.Request) {
 	groups, err := repo.GetFlagGroups()
 	if err != nil {
@@ -62,8 +92,257 @@ func CreateFlags(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	if err = validateFlagEntries(newFlags.Entries); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
 	if err := repo.CreateFlags(newFlags); err != nil {
+		writeAdminChangeError(writer, err, "flag group already exists\n")
+		return
+	}
+}
+
+func (s *Server) updateFlags(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.UpdateFlagsDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.vs.GetValidator().Struct(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = validateFlagEntries(dto.Entries); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	replaced, err := repo.UpdateFlags(mux.Vars(request)["key"], dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "flag group can't be updated\n")
+		return
+	}
+	s.deleteFlagAssets(replaced)
+}
+
+func (s *Server) deleteFlags(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	assetKeys, err := repo.DeleteFlags(mux.Vars(request)["key"])
+	if err == repo.ErrFlagInUse {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusConflict)
+		return
+	} else if err != nil {
+		writeAdminChangeError(writer, err, "flag group is in use\n")
+		return
+	}
+	s.deleteFlagAssets(assetKeys)
+}
+
+func (s *Server) deleteFlagEntry(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	vars := mux.Vars(request)
+	assetKey, err := repo.DeleteFlagEntry(vars["key"], vars["code"])
+	if err == repo.ErrFlagInUse {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusConflict)
+		return
+	} else if err != nil {
+		writeAdminChangeError(writer, err, "flag is in use\n")
+		return
+	}
+	s.deleteFlagAssets([]string{assetKey})
+}
+
+// uploadFlag stores an svg or png as the flag for the code, adding it to the
+// group if it isn't there yet. Svgs are sanitised before they're stored and
+// assets are named by their content, so a new upload never serves a cached
+// copy of the old flag.
+func (s *Server) uploadFlag(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	vars := mux.Vars(request)
+	code := strings.ToLower(vars["code"])
+	if !utils.IsISO3166Code(code) {
+		http.Error(writer, fmt.Sprintf("invalid flag code %s\n", vars["code"]), http.StatusBadRequest)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, MAX_FLAG_SIZE))
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, extension, contentType, err := getFlagAsset(data)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	group, err := repo.GetFlagGroup(vars["key"])
+	if err == sql.ErrNoRows {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	assetKey := fmt.Sprintf("flags/%s/%s-%x.%s", group.Key, code, hash[:4], extension)
+	url, err := s.ms.Put(assetKey, contentType, data)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	replaced, err := repo.SetFlagEntryAsset(group.ID, code, url, assetKey)
+	if err != nil {
+		s.deleteFlagAssets([]string{assetKey})
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+	s.deleteFlagAssets([]string{replaced})
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(repo.FlagEntry{
+		GroupID:  group.ID,
+		Code:     code,
+		Url:      url,
+		AssetKey: assetKey,
+	})
+}
+
+// getFlagAsset works out whether the upload is a png or an svg from its
+// content rather than the content type it was sent with.
+func getFlagAsset(data []byte) ([]byte, string, string, error) {
+	if http.DetectContentType(data) == "image/png" {
+		if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, "", "", fmt.Errorf("invalid png: %v", err)
+		}
+		return data, "png", "image/png", nil
+	}
+
+	sanitised, err := utils.SanitiseSVG(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", "", errors.New("flags must be an svg or png")
+	}
+	return sanitised, "svg", "image/svg+xml", nil
+}
+
+func (s *Server) deleteFlagAssets(assetKeys []string) {
+	for _, assetKey := range assetKeys {
+		if assetKey == "" {
+			continue
+		}
+
+		if err := s.ms.Delete(assetKey); err != nil && err != utils.ErrBlobNotFound {
+			log.Printf("failed to delete flag asset %s: %v", assetKey, err)
+		}
+	}
+}
+
+func validateFlagEntries(entries []repo.CreateFlagEntryDto) error {
+	var invalid []string
+	for _, entry := range entries {
+		if !utils.IsISO3166Code(entry.Code) {
+			invalid = append(invalid, entry.Code)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid flag codes %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// CheckFlagUrls requests every flag in the group and reports the ones that
+// can't be loaded.
+func CheckFlagUrls(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	entries, err := repo.GetFlagEntries(mux.Vars(request)["key"])
+	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
+
+	results := make([]FlagUrlCheckDto, len(entries))
+	var wg sync.WaitGroup
+	limit := make(chan bool, FLAG_URL_CHECK_CONCURRENCY)
+	for index, entry := range entries {
+		wg.Add(1)
+		go func(index int, entry repo.FlagEntry) {
+			defer wg.Done()
+			limit <- true
+			defer func() { <-limit }()
+
+			status, err := checkFlagUrl(entry.Url)
+			result := FlagUrlCheckDto{Code: entry.Code, Url: entry.Url, Status: status}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			result.Ok = err == nil && status < http.StatusBadRequest
+			results[index] = result
+		}(index, entry)
+	}
+	wg.Wait()
+
+	var broken = []FlagUrlCheckDto{}
+	for _, result := range results {
+		if !result.Ok {
+			broken = append(broken, result)
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(FlagUrlReportDto{Total: len(results), Broken: broken})
+}
+
+var checkFlagUrl = func(url string) (int, error) {
+	client := http.Client{Timeout: FLAG_URL_CHECK_TIMEOUT}
+	response, err := client.Head(url)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		return response.StatusCode, nil
+	}
+
+	response, err = client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
 }
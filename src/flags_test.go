@@ -0,0 +1,364 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestCreateFlags(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedCreateFlags := repo.CreateFlags
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.CreateFlags = savedCreateFlags
+	}()
+
+	tt := []struct {
+		name        string
+		createFlags func(flags repo.CreateFlagsDto) error
+		body        string
+		status      int
+	}{
+		{
+			name:   "invalid body",
+			body:   "testing",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid code",
+			body:   `{"key": "world-countries", "label": "World", "entries": [{"code": "nz", "url": "nz.svg"}, {"code": "zz", "url": "zz.svg"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "error on CreateFlags",
+			createFlags: func(flags repo.CreateFlagsDto) error {
+				return errors.New("test")
+			},
+			body:   `{"key": "us-states", "label": "US States", "entries": [{"code": "us-ca", "url": "ca.svg"}]}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "happy path",
+			createFlags: func(flags repo.CreateFlagsDto) error {
+				return nil
+			},
+			body:   `{"key": "us-states", "label": "US States", "entries": [{"code": "us-ca", "url": "ca.svg"}]}`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+			repo.CreateFlags = tc.createFlags
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			CreateFlags(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUploadFlag(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetFlagGroup := repo.GetFlagGroup
+	savedSetFlagEntryAsset := repo.SetFlagEntryAsset
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetFlagGroup = savedGetFlagGroup
+		repo.SetFlagEntryAsset = savedSetFlagEntryAsset
+	}()
+
+	var pngFlag bytes.Buffer
+	png.Encode(&pngFlag, image.NewRGBA(image.Rect(0, 0, 3, 2)))
+
+	getFlagGroup := func(key string) (repo.FlagGroup, error) {
+		return repo.FlagGroup{ID: 1, Key: key}, nil
+	}
+
+	tt := []struct {
+		name         string
+		getFlagGroup func(key string) (repo.FlagGroup, error)
+		setAsset     func(groupId int, code, url, assetKey string) (string, error)
+		code         string
+		body         []byte
+		status       int
+		contentType  string
+		stored       []string
+	}{
+		{
+			name:   "invalid code",
+			code:   "zz",
+			body:   []byte("<svg></svg>"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too large",
+			code:   "nz",
+			body:   bytes.Repeat([]byte(" "), MAX_FLAG_SIZE+1),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "not an image",
+			code:   "nz",
+			body:   []byte("<html></html>"),
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "group not found",
+			getFlagGroup: func(key string) (repo.FlagGroup, error) {
+				return repo.FlagGroup{}, sql.ErrNoRows
+			},
+			code:   "nz",
+			body:   []byte("<svg></svg>"),
+			status: http.StatusNotFound,
+		},
+		{
+			name:         "error on SetFlagEntryAsset",
+			getFlagGroup: getFlagGroup,
+			setAsset: func(groupId int, code, url, assetKey string) (string, error) {
+				return "", errors.New("test")
+			},
+			code:   "nz",
+			body:   []byte("<svg></svg>"),
+			status: http.StatusInternalServerError,
+			stored: []string{"flags/world-countries/old.svg"},
+		},
+		{
+			name:         "happy path, svg",
+			getFlagGroup: getFlagGroup,
+			setAsset: func(groupId int, code, url, assetKey string) (string, error) {
+				return "flags/world-countries/old.svg", nil
+			},
+			code:        "NZ",
+			body:        []byte(`<svg onload="alert(1)"><rect width="3" height="2"/></svg>`),
+			status:      http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:         "happy path, png",
+			getFlagGroup: getFlagGroup,
+			setAsset: func(groupId int, code, url, assetKey string) (string, error) {
+				return "", nil
+			},
+			code:        "gb-eng",
+			body:        pngFlag.Bytes(),
+			status:      http.StatusOK,
+			contentType: "image/png",
+			stored:      []string{"flags/world-countries/old.svg"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+			repo.GetFlagGroup = tc.getFlagGroup
+			repo.SetFlagEntryAsset = tc.setAsset
+
+			store := utils.NewFakeBlobStore("/api/assets")
+			store.Put("flags/world-countries/old.svg", "image/svg+xml", []byte("<svg></svg>"))
+			s := getMockServer()
+			s.ms = store
+
+			request, err := http.NewRequest("PUT", "", bytes.NewBuffer(tc.body))
+			if err != nil {
+				t.Fatalf("could not create PUT request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"key":  "world-countries",
+				"code": tc.code,
+			})
+
+			writer := httptest.NewRecorder()
+			s.uploadFlag(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				if tc.stored != nil && len(store.Keys()) != len(tc.stored) {
+					t.Errorf("expected failed upload to be removed; got %v", store.Keys())
+				}
+				return
+			}
+
+			var entry repo.FlagEntry
+			if err = json.NewDecoder(result.Body).Decode(&entry); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if !strings.HasPrefix(entry.AssetKey, "flags/world-countries/"+strings.ToLower(tc.code)+"-") || entry.Url != "/api/assets/"+entry.AssetKey {
+				t.Errorf("unexpected entry %+v", entry)
+			}
+
+			data, contentType, err := store.Get(entry.AssetKey)
+			if err != nil || contentType != tc.contentType {
+				t.Fatalf("expected %v to be stored; got %v %v", tc.contentType, contentType, err)
+			}
+
+			if bytes.Contains(data, []byte("onload")) {
+				t.Errorf("expected svg to be sanitised; got %s", data)
+			}
+
+			if tc.stored != nil && len(store.Keys()) != 2 || tc.stored == nil && len(store.Keys()) != 1 {
+				t.Errorf("expected replaced flag to be removed; got %v", store.Keys())
+			}
+		})
+	}
+}
+
+func TestDeleteFlags(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedDeleteFlags := repo.DeleteFlags
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.DeleteFlags = savedDeleteFlags
+	}()
+
+	tt := []struct {
+		name        string
+		deleteFlags func(key string) ([]string, error)
+		status      int
+		remaining   int
+	}{
+		{
+			name: "not found",
+			deleteFlags: func(key string) ([]string, error) {
+				return nil, sql.ErrNoRows
+			},
+			status:    http.StatusNotFound,
+			remaining: 1,
+		},
+		{
+			name: "in use",
+			deleteFlags: func(key string) ([]string, error) {
+				return nil, repo.ErrFlagInUse
+			},
+			status:    http.StatusConflict,
+			remaining: 1,
+		},
+		{
+			name: "happy path",
+			deleteFlags: func(key string) ([]string, error) {
+				return []string{"flags/us-states/us-ca.svg", "flags/us-states/missing.svg"}, nil
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+			repo.DeleteFlags = tc.deleteFlags
+
+			store := utils.NewFakeBlobStore("/api/assets")
+			store.Put("flags/us-states/us-ca.svg", "image/svg+xml", []byte("<svg></svg>"))
+			s := getMockServer()
+			s.ms = store
+
+			request, err := http.NewRequest("DELETE", "", nil)
+			if err != nil {
+				t.Fatalf("could not create DELETE request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"key": "us-states",
+			})
+
+			writer := httptest.NewRecorder()
+			s.deleteFlags(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if len(store.Keys()) != tc.remaining {
+				t.Errorf("expected %v assets left; got %v", tc.remaining, store.Keys())
+			}
+		})
+	}
+}
+
+func TestCheckFlagUrls(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetFlagEntries := repo.GetFlagEntries
+	savedCheckFlagUrl := checkFlagUrl
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetFlagEntries = savedGetFlagEntries
+		checkFlagUrl = savedCheckFlagUrl
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	repo.GetFlagEntries = func(key string) ([]repo.FlagEntry, error) {
+		return []repo.FlagEntry{
+			{Code: "nz", Url: "https://example.com/nz.svg"},
+			{Code: "au", Url: "https://example.com/au.svg"},
+			{Code: "fj", Url: "https://offline.example.com/fj.svg"},
+		}, nil
+	}
+	checkFlagUrl = func(url string) (int, error) {
+		switch url {
+		case "https://example.com/au.svg":
+			return http.StatusNotFound, nil
+		case "https://offline.example.com/fj.svg":
+			return 0, errors.New("no such host")
+		}
+		return http.StatusOK, nil
+	}
+
+	request, err := http.NewRequest("GET", "", nil)
+	if err != nil {
+		t.Fatalf("could not create GET request: %v", err)
+	}
+
+	request = mux.SetURLVars(request, map[string]string{
+		"key": "world-countries",
+	})
+
+	writer := httptest.NewRecorder()
+	CheckFlagUrls(writer, request)
+	result := writer.Result()
+	defer result.Body.Close()
+
+	var report FlagUrlReportDto
+	if err = json.NewDecoder(result.Body).Decode(&report); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	if report.Total != 3 || len(report.Broken) != 2 || report.Broken[0].Code != "au" || report.Broken[1].Error == "" {
+		t.Errorf("expected au and fj to be broken; got %+v", report)
+	}
+}
@@ -0,0 +1,28 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/geobuff/api/repo"
+)
+
+// GetIntegrityReport lists maps, mappings, quizzes and trivia questions that
+// refer to rows that don't exist, so broken quizzes are found before players
+// find them.
+func GetIntegrityReport(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.CheckIntegrity()
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(report)
+}
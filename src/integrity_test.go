@@ -0,0 +1,69 @@
+package src
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+)
+
+func TestGetIntegrityReport(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedCheckIntegrity := repo.CheckIntegrity
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.CheckIntegrity = savedCheckIntegrity
+	}()
+
+	tt := []struct {
+		name           string
+		isAdmin        func(request *http.Request) (int, error)
+		checkIntegrity func() (utils.IntegrityReport, error)
+		status         int
+	}{
+		{
+			name:    "not admin",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:           "error on CheckIntegrity",
+			isAdmin:        func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			checkIntegrity: func() (utils.IntegrityReport, error) { return utils.IntegrityReport{}, errors.New("test") },
+			status:         http.StatusInternalServerError,
+		},
+		{
+			name:    "happy path",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			checkIntegrity: func() (utils.IntegrityReport, error) {
+				return utils.IntegrityReport{Counts: map[string]int{}, Issues: []utils.IntegrityIssue{}}, nil
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.CheckIntegrity = tc.checkIntegrity
+
+			request, err := http.NewRequest("GET", "", nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			GetIntegrityReport(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
@@ -137,6 +137,8 @@ func CreateEntry(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	evaluateUserBadges(newEntry.UserID, repo.BADGE_EVENT_LEADERBOARD_SUBMIT)
+
 	writer.Header().Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusCreated)
 	newEntry.ID = id
@@ -196,6 +198,8 @@ func UpdateEntry(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	evaluateUserBadges(updatedEntry.UserID, repo.BADGE_EVENT_LEADERBOARD_SUBMIT)
+
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(updatedEntry)
 }
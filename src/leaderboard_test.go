@@ -327,12 +327,14 @@ func TestCreateEntry(t *testing.T) {
 	savedValidUser := ValidUser
 	savedScoreExceedsMax := repo.ScoreExceedsMax
 	savedInsertLeaderboardEntry := repo.InsertLeaderboardEntry
+	savedEvaluateUserBadges := repo.EvaluateUserBadges
 
 	defer func() {
 		repo.GetUser = savedGetUser
 		ValidUser = savedValidUser
 		repo.ScoreExceedsMax = savedScoreExceedsMax
 		repo.InsertLeaderboardEntry = savedInsertLeaderboardEntry
+		repo.EvaluateUserBadges = savedEvaluateUserBadges
 	}()
 
 	user := repo.UserDto{
@@ -345,6 +347,7 @@ func TestCreateEntry(t *testing.T) {
 		validUser              func(request *http.Request, id int) (int, error)
 		scoreExceedsMax        func(quizID, score int) (bool, error)
 		insertLeaderboardEntry func(entry repo.LeaderboardEntry) (int, error)
+		evaluateUserBadges     func(userID int, event string) error
 		body                   string
 		status                 int
 	}{
@@ -410,6 +413,18 @@ func TestCreateEntry(t *testing.T) {
 			body:                   `{"userId": 1, "country": "New Zealand", "capitals": 100, "time": 200}`,
 			status:                 http.StatusInternalServerError,
 		},
+		{
+			name:    "valid body, valid user, error on EvaluateUserBadges is logged",
+			getUser: func(id int) (repo.UserDto, error) { return user, nil },
+			validUser: func(request *http.Request, id int) (int, error) {
+				return http.StatusOK, nil
+			},
+			scoreExceedsMax:        func(quizID, score int) (bool, error) { return false, nil },
+			insertLeaderboardEntry: func(entry repo.LeaderboardEntry) (int, error) { return 1, nil },
+			evaluateUserBadges:     func(userID int, event string) error { return errors.New("test") },
+			body:                   `{"userId": 1, "country": "New Zealand", "capitals": 100, "time": 200}`,
+			status:                 http.StatusCreated,
+		},
 		{
 			name:    "happy path",
 			getUser: func(id int) (repo.UserDto, error) { return user, nil },
@@ -418,6 +433,7 @@ func TestCreateEntry(t *testing.T) {
 			},
 			scoreExceedsMax:        func(quizID, score int) (bool, error) { return false, nil },
 			insertLeaderboardEntry: func(entry repo.LeaderboardEntry) (int, error) { return 1, nil },
+			evaluateUserBadges:     func(userID int, event string) error { return nil },
 			body:                   `{"userId": 1, "country": "New Zealand", "capitals": 100, "time": 200}`,
 			status:                 http.StatusCreated,
 		},
@@ -429,6 +445,7 @@ func TestCreateEntry(t *testing.T) {
 			ValidUser = tc.validUser
 			repo.ScoreExceedsMax = tc.scoreExceedsMax
 			repo.InsertLeaderboardEntry = tc.insertLeaderboardEntry
+			repo.EvaluateUserBadges = tc.evaluateUserBadges
 
 			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
 			if err != nil {
@@ -466,6 +483,7 @@ func TestUpdateEntry(t *testing.T) {
 	savedScoreExceedsMax := repo.ScoreExceedsMax
 	savedGetLeaderboardEntry := repo.GetLeaderboardEntry
 	savedUpdateLeaderboardEntry := repo.UpdateLeaderboardEntry
+	savedEvaluateUserBadges := repo.EvaluateUserBadges
 
 	defer func() {
 		repo.GetUser = savedGetUser
@@ -473,8 +491,11 @@ func TestUpdateEntry(t *testing.T) {
 		repo.ScoreExceedsMax = savedScoreExceedsMax
 		repo.GetLeaderboardEntry = savedGetLeaderboardEntry
 		repo.UpdateLeaderboardEntry = savedUpdateLeaderboardEntry
+		repo.EvaluateUserBadges = savedEvaluateUserBadges
 	}()
 
+	repo.EvaluateUserBadges = func(userID int, event string) error { return nil }
+
 	user := repo.UserDto{
 		Username: "testing",
 	}
@@ -1,12 +1,17 @@
 package src
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/geobuff/api/repo"
 	"github.com/gorilla/mux"
@@ -142,3 +147,219 @@ func DeleteManualTriviaQuestion(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 }
+
+var manualTriviaQuestionCSVHeader = []string{"type", "category", "question", "map", "highlighted", "flagCode", "imageUrl", "imageAttributeName", "imageAttributeUrl", "imageWidth", "imageHeight", "imageAlt", "explainer", "quizDate"}
+
+func ImportManualTriviaQuestions(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var questions []repo.ManualTriviaQuestionBankDto
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "text/csv") {
+		questions, err = parseManualTriviaQuestionsCSV(bytes.NewReader(requestBody))
+	} else {
+		err = json.Unmarshal(requestBody, &questions)
+	}
+
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	rows, valid, err := repo.ValidateManualTriviaQuestionImport(questions)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := repo.ImportManualTriviaQuestionsResultDto{
+		DryRun: request.URL.Query().Get("dryRun") == "true",
+		Total:  len(questions),
+		Valid:  len(valid),
+		Rows:   rows,
+	}
+
+	if result.DryRun || len(valid) != len(questions) {
+		writer.Header().Set("Content-Type", "application/json")
+		if !result.DryRun {
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(writer).Encode(result)
+		return
+	}
+
+	err = repo.ImportManualTriviaQuestions(valid)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	result.Imported = len(valid)
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+func ExportManualTriviaQuestions(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filterParams repo.GetManualTriviaQuestionEntriesFilterParams
+	err = json.Unmarshal(requestBody, &filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	questions, err := repo.GetManualTriviaQuestionBank(filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch format := request.URL.Query().Get("format"); format {
+	case "csv":
+		writer.Header().Set("Content-Type", "text/csv")
+		writer.Header().Set("Content-Disposition", "attachment; filename=manual-trivia-questions.csv")
+		if err := writeManualTriviaQuestionsCSV(writer, questions); err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		}
+	case "", "json":
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(questions)
+	default:
+		http.Error(writer, fmt.Sprintf("invalid format %s\n", format), http.StatusBadRequest)
+	}
+}
+
+func parseManualTriviaQuestionsCSV(reader io.Reader) ([]repo.ManualTriviaQuestionBankDto, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("missing csv header")
+	}
+
+	columns := make(map[string]int)
+	for index, name := range records[0] {
+		columns[strings.TrimSpace(name)] = index
+	}
+
+	var questions = []repo.ManualTriviaQuestionBankDto{}
+	for line, record := range records[1:] {
+		value := func(name string) string {
+			if index, found := columns[name]; found && index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		question := repo.ManualTriviaQuestionBankDto{
+			Type:               value("type"),
+			Category:           value("category"),
+			Question:           value("question"),
+			Map:                value("map"),
+			Highlighted:        value("highlighted"),
+			FlagCode:           value("flagCode"),
+			ImageURL:           value("imageUrl"),
+			ImageAttributeName: value("imageAttributeName"),
+			ImageAttributeURL:  value("imageAttributeUrl"),
+			ImageAlt:           value("imageAlt"),
+			Explainer:          value("explainer"),
+			QuizDate:           value("quizDate"),
+			Answers:            []repo.CreateManualTriviaAnswerDto{},
+		}
+
+		for _, field := range []struct {
+			name   string
+			target *int
+		}{{"imageWidth", &question.ImageWidth}, {"imageHeight", &question.ImageHeight}} {
+			if raw := value(field.name); raw != "" {
+				if *field.target, err = strconv.Atoi(raw); err != nil {
+					return nil, fmt.Errorf("row %d: invalid %s %s", line+1, field.name, raw)
+				}
+			}
+		}
+
+		for i := 1; ; i++ {
+			prefix := fmt.Sprintf("answer%d", i)
+			if _, found := columns[prefix]; !found {
+				break
+			}
+
+			text := value(prefix)
+			if text == "" {
+				continue
+			}
+
+			isCorrect, _ := strconv.ParseBool(value(prefix + "Correct"))
+			question.Answers = append(question.Answers, repo.CreateManualTriviaAnswerDto{
+				Text:      text,
+				IsCorrect: isCorrect,
+				FlagCode:  value(prefix + "FlagCode"),
+			})
+		}
+
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+func writeManualTriviaQuestionsCSV(writer io.Writer, questions []repo.ManualTriviaQuestionBankDto) error {
+	var answerCount int
+	for _, question := range questions {
+		if len(question.Answers) > answerCount {
+			answerCount = len(question.Answers)
+		}
+	}
+
+	header := append([]string{}, manualTriviaQuestionCSVHeader...)
+	for i := 1; i <= answerCount; i++ {
+		header = append(header, fmt.Sprintf("answer%d", i), fmt.Sprintf("answer%dCorrect", i), fmt.Sprintf("answer%dFlagCode", i))
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, question := range questions {
+		record := []string{question.Type, question.Category, question.Question, question.Map, question.Highlighted, question.FlagCode, question.ImageURL, question.ImageAttributeName, question.ImageAttributeURL, strconv.Itoa(question.ImageWidth), strconv.Itoa(question.ImageHeight), question.ImageAlt, question.Explainer, question.QuizDate}
+		for i := 0; i < answerCount; i++ {
+			if i < len(question.Answers) {
+				answer := question.Answers[i]
+				record = append(record, answer.Text, strconv.FormatBool(answer.IsCorrect), answer.FlagCode)
+			} else {
+				record = append(record, "", "", "")
+			}
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,202 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+)
+
+func TestImportManualTriviaQuestions(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedValidateManualTriviaQuestionImport := repo.ValidateManualTriviaQuestionImport
+	savedImportManualTriviaQuestions := repo.ImportManualTriviaQuestions
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.ValidateManualTriviaQuestionImport = savedValidateManualTriviaQuestionImport
+		repo.ImportManualTriviaQuestions = savedImportManualTriviaQuestions
+	}()
+
+	validRow := func(questions []repo.ManualTriviaQuestionBankDto) ([]repo.ImportManualTriviaQuestionRowDto, []repo.CreateManualTriviaQuestionDto, error) {
+		return []repo.ImportManualTriviaQuestionRowDto{{Row: 1, Errors: []string{}}}, []repo.CreateManualTriviaQuestionDto{{}}, nil
+	}
+
+	invalidRow := func(questions []repo.ManualTriviaQuestionBankDto) ([]repo.ImportManualTriviaQuestionRowDto, []repo.CreateManualTriviaQuestionDto, error) {
+		return []repo.ImportManualTriviaQuestionRowDto{{Row: 1, Errors: []string{"invalid type"}}}, []repo.CreateManualTriviaQuestionDto{}, nil
+	}
+
+	csvBody := "type,category,question,answer1,answer1Correct,answer2,answer2Correct\nText,General,What is the capital of France?,Paris,true,Lyon,false\n"
+
+	tt := []struct {
+		name        string
+		isAdmin     func(request *http.Request) (int, error)
+		validate    func(questions []repo.ManualTriviaQuestionBankDto) ([]repo.ImportManualTriviaQuestionRowDto, []repo.CreateManualTriviaQuestionDto, error)
+		importer    func(questions []repo.CreateManualTriviaQuestionDto) error
+		contentType string
+		body        string
+		query       string
+		status      int
+		imported    int
+	}{
+		{
+			name:        "not admin",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			validate:    validRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return nil },
+			contentType: "application/json",
+			body:        "[{}]",
+			status:      http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid json body",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    validRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return nil },
+			contentType: "application/json",
+			body:        "testing",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:    "error on ValidateManualTriviaQuestionImport",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate: func(questions []repo.ManualTriviaQuestionBankDto) ([]repo.ImportManualTriviaQuestionRowDto, []repo.CreateManualTriviaQuestionDto, error) {
+				return nil, nil, errors.New("test")
+			},
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return nil },
+			contentType: "application/json",
+			body:        "[{}]",
+			status:      http.StatusInternalServerError,
+		},
+		{
+			name:        "invalid rows",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    invalidRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return errors.New("should not be called") },
+			contentType: "application/json",
+			body:        "[{}]",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "dry run",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    validRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return errors.New("should not be called") },
+			contentType: "text/csv",
+			body:        csvBody,
+			query:       "?dryRun=true",
+			status:      http.StatusOK,
+		},
+		{
+			name:        "error on ImportManualTriviaQuestions",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    validRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return errors.New("test") },
+			contentType: "application/json",
+			body:        "[{}]",
+			status:      http.StatusInternalServerError,
+		},
+		{
+			name:        "happy path",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    validRow,
+			importer:    func(questions []repo.CreateManualTriviaQuestionDto) error { return nil },
+			contentType: "text/csv",
+			body:        csvBody,
+			status:      http.StatusOK,
+			imported:    1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.ValidateManualTriviaQuestionImport = tc.validate
+			repo.ImportManualTriviaQuestions = tc.importer
+
+			request, err := http.NewRequest("POST", tc.query, bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+			request.Header.Set("Content-Type", tc.contentType)
+
+			writer := httptest.NewRecorder()
+			ImportManualTriviaQuestions(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusOK {
+				body, err := ioutil.ReadAll(result.Body)
+				if err != nil {
+					t.Fatalf("could not read response: %v", err)
+				}
+
+				var parsed repo.ImportManualTriviaQuestionsResultDto
+				err = json.Unmarshal(body, &parsed)
+				if err != nil {
+					t.Errorf("could not unmarshal response body: %v", err)
+				}
+
+				if parsed.Imported != tc.imported {
+					t.Errorf("expected imported %v; got %v", tc.imported, parsed.Imported)
+				}
+			}
+		})
+	}
+}
+
+func TestManualTriviaQuestionsCSVRoundTrip(t *testing.T) {
+	questions := []repo.ManualTriviaQuestionBankDto{
+		{
+			Type:        "Map",
+			Category:    "Geography",
+			Question:    "Which country is highlighted, \"roughly\"?",
+			Map:         "WorldCountries",
+			Highlighted: "France",
+			ImageWidth:  0,
+			ImageHeight: 0,
+			QuizDate:    "2022-01-02",
+			Answers: []repo.CreateManualTriviaAnswerDto{
+				{Text: "France", IsCorrect: true, FlagCode: "fr"},
+				{Text: "Spain", IsCorrect: false, FlagCode: "es"},
+			},
+		},
+		{
+			Type:        "Image",
+			Category:    "General",
+			Question:    "What is this?",
+			ImageURL:    "https://example.com/image.png",
+			ImageWidth:  400,
+			ImageHeight: 300,
+			Answers: []repo.CreateManualTriviaAnswerDto{
+				{Text: "Mountain", IsCorrect: true},
+				{Text: "Lake"},
+				{Text: "River"},
+			},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeManualTriviaQuestionsCSV(&buffer, questions); err != nil {
+		t.Fatalf("could not write csv: %v", err)
+	}
+
+	parsed, err := parseManualTriviaQuestionsCSV(&buffer)
+	if err != nil {
+		t.Fatalf("could not parse csv: %v", err)
+	}
+
+	if !reflect.DeepEqual(parsed, questions) {
+		t.Errorf("expected %v; got %v", questions, parsed)
+	}
+}
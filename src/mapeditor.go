@@ -0,0 +1,446 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+// MapImportDiffDto describes how an imported SVG differs from the stored map.
+// Elements are compared on their id, which for regions matched to a mapping
+// entry is the entry's code. Elements without an id are replaced but left out
+// of the report.
+type MapImportDiffDto struct {
+	Revision   int                `json:"revision"`
+	Applied    bool               `json:"applied"`
+	Added      []string           `json:"added"`
+	Removed    []string           `json:"removed"`
+	Changed    []string           `json:"changed"`
+	Unchanged  int                `json:"unchanged"`
+	Unmatched  []string           `json:"unmatched"`
+	Unmapped   []string           `json:"unmapped"`
+	Duplicates []string           `json:"duplicates"`
+	Warnings   []utils.SVGWarning `json:"warnings"`
+}
+
+func (s *Server) updateMap(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.UpdateMapDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.vs.GetValidator().Struct(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err = utils.MapViewBox(dto.ViewBox, nil, utils.MapRenderOptions{}); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	svgMap, err := repo.UpdateMap(userID, mux.Vars(request)["className"], dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Map couldn't be updated.")
+		return
+	}
+
+	mapImageCache.Flush()
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(svgMap)
+}
+
+func AddMapElement(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	element, err := getMapElementBody(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	svgMap, err := repo.AddMapElement(userID, mux.Vars(request)["className"], element)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Element couldn't be added.")
+		return
+	}
+
+	mapImageCache.Flush()
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(svgMap)
+}
+
+func ReplaceMapElement(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	element, err := getMapElementBody(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	svgMap, err := repo.ReplaceMapElement(userID, mux.Vars(request)["className"], id, element)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Element couldn't be replaced.")
+		return
+	}
+
+	mapImageCache.Flush()
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(svgMap)
+}
+
+func DeleteMapElement(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	svgMap, err := repo.DeleteMapElement(userID, mux.Vars(request)["className"], id)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Element couldn't be deleted.")
+		return
+	}
+
+	mapImageCache.Flush()
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(svgMap)
+}
+
+// getMapElementBody reads a single element, checking it's a shape we can draw
+// and that its geometry parses.
+func getMapElementBody(request *http.Request) (repo.MapElementDto, error) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return repo.MapElementDto{}, err
+	}
+
+	var element repo.MapElementDto
+	if err = json.Unmarshal(requestBody, &element); err != nil {
+		return repo.MapElementDto{}, err
+	}
+
+	if !utils.IsSVGShape(element.Type) {
+		return repo.MapElementDto{}, fmt.Errorf("invalid element type %s", element.Type)
+	}
+
+	if _, found, err := utils.ElementBounds(toSVGElements([]repo.MapElementDto{element})); err != nil {
+		return repo.MapElementDto{}, err
+	} else if !found {
+		return repo.MapElementDto{}, fmt.Errorf("element %s has no geometry", element.ID)
+	}
+	return element, nil
+}
+
+// ImportMap replaces the map with a new revision of its SVG. Elements are
+// matched to the map's mapping entries on code, then svg name, and the
+// response reports what changed. With dryRun=true nothing is saved.
+func ImportMap(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	dryRun := false
+	if value := request.URL.Query().Get("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("invalid dryRun %s\n", value), http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var svgDto SvgDto
+	err = json.Unmarshal(requestBody, &svgDto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	preview, err := getMapPreview(svgDto.SVG)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	className := mux.Vars(request)["className"]
+	svgMap, err := repo.GetMap(className)
+	if err == sql.ErrNoRows {
+		http.Error(writer, fmt.Sprintf("map %s not found\n", className), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := repo.GetMappingEntries(svgMap.Key)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	elements, diff := getMapImportDiff(svgMap.Elements, preview.Elements, entries)
+	diff.Revision = svgMap.Revision + 1
+	diff.Warnings = preview.Warnings
+
+	if !dryRun {
+		updated, err := repo.ImportMap(userID, className, repo.ImportMapDto{ViewBox: preview.ViewBox, Elements: elements})
+		if err != nil {
+			writeAdminChangeError(writer, err, "Map couldn't be imported.")
+			return
+		}
+
+		mapImageCache.Flush()
+		diff.Revision = updated.Revision
+		diff.Applied = true
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(diff)
+}
+
+// getMapImportDiff gives imported elements the code and svg name of the
+// mapping entry they match, then compares them with the current elements.
+func getMapImportDiff(current, imported []repo.MapElementDto, entries []repo.MappingEntryDto) ([]repo.MapElementDto, MapImportDiffDto) {
+	diff := MapImportDiffDto{
+		Added:      []string{},
+		Removed:    []string{},
+		Changed:    []string{},
+		Unmatched:  []string{},
+		Unmapped:   []string{},
+		Duplicates: []string{},
+	}
+
+	byCode := make(map[string]repo.MappingEntryDto)
+	byName := make(map[string]repo.MappingEntryDto)
+	for _, entry := range entries {
+		byCode[strings.ToLower(entry.Code)] = entry
+		byName[strings.ToLower(entry.SVGName)] = entry
+	}
+
+	matched := make(map[string]bool)
+	elements := make([]repo.MapElementDto, 0, len(imported))
+	for _, element := range imported {
+		if element.ClipPathId != "" || (element.ID == "" && element.Name == "") {
+			elements = append(elements, element)
+			continue
+		}
+
+		entry, found := byCode[strings.ToLower(element.ID)]
+		if !found {
+			entry, found = byName[strings.ToLower(element.Name)]
+		}
+
+		if !found {
+			if element.ID != "" {
+				diff.Unmatched = append(diff.Unmatched, element.ID)
+			} else {
+				diff.Unmatched = append(diff.Unmatched, element.Name)
+			}
+			elements = append(elements, element)
+			continue
+		}
+
+		if matched[entry.Code] {
+			diff.Duplicates = append(diff.Duplicates, entry.Code)
+		}
+		matched[entry.Code] = true
+		element.ID, element.Name = entry.Code, entry.SVGName
+		elements = append(elements, element)
+	}
+
+	for _, entry := range entries {
+		if !matched[entry.Code] {
+			diff.Unmapped = append(diff.Unmapped, entry.Code)
+		}
+	}
+
+	existing := make(map[string]repo.MapElementDto)
+	for _, element := range current {
+		if element.ID != "" {
+			existing[strings.ToLower(element.ID)] = element
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, element := range elements {
+		key := strings.ToLower(element.ID)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		previous, found := existing[key]
+		if !found {
+			diff.Added = append(diff.Added, element.ID)
+			continue
+		}
+
+		previous.EntryID, previous.MapID = 0, 0
+		element.EntryID, element.MapID = 0, 0
+		if previous == element {
+			diff.Unchanged++
+		} else {
+			diff.Changed = append(diff.Changed, element.ID)
+		}
+	}
+
+	for key, element := range existing {
+		if !seen[key] {
+			diff.Removed = append(diff.Removed, element.ID)
+		}
+	}
+
+	for _, list := range [][]string{diff.Added, diff.Removed, diff.Changed, diff.Unmatched, diff.Unmapped, diff.Duplicates} {
+		sort.Strings(list)
+	}
+	return elements, diff
+}
+
+func GetMapRevisions(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	revisions, err := repo.GetMapRevisions(mux.Vars(request)["className"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(revisions)
+}
+
+func GetMapRevision(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	revision, err := strconv.Atoi(mux.Vars(request)["revision"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := repo.GetMapRevision(mux.Vars(request)["className"], revision)
+	if err == sql.ErrNoRows {
+		http.Error(writer, fmt.Sprintf("revision %d not found\n", revision), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+func RollbackMap(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	revision, err := strconv.Atoi(mux.Vars(request)["revision"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	svgMap, err := repo.RollbackMap(userID, mux.Vars(request)["className"], revision)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Map couldn't be rolled back.")
+		return
+	}
+
+	mapImageCache.Flush()
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(svgMap)
+}
@@ -0,0 +1,297 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestGetMapImportDiff(t *testing.T) {
+	current := []repo.MapElementDto{
+		{EntryID: 1, MapID: 1, Type: "path", ID: "NZ", Name: "New Zealand", D: "M0 0h10v10z"},
+		{EntryID: 2, MapID: 1, Type: "path", ID: "AU", Name: "Australia", D: "M20 0h10v10z"},
+		{EntryID: 3, MapID: 1, Type: "path", ID: "FJ", Name: "Fiji", D: "M40 0h10v10z"},
+		{EntryID: 4, MapID: 1, Type: "rect", Width: "50", Height: "10"},
+	}
+
+	imported := []repo.MapElementDto{
+		{Type: "path", ID: "nz", Name: "NZ", D: "M0 0h10v10z"},
+		{Type: "path", Name: "Australia", D: "M20 0h12v10z"},
+		{Type: "path", ID: "to", Name: "Kingdom of Tonga", D: "M60 0h10v10z"},
+		{Type: "path", ID: "ocean", D: "M0 0h100v100z"},
+		{Type: "path", ID: "dup", Name: "Australia", D: "M20 0h12v10z"},
+		{Type: "path", ClipPathId: "clip", D: "M0 0h1v1z"},
+	}
+
+	entries := []repo.MappingEntryDto{
+		{Code: "NZ", SVGName: "New Zealand"},
+		{Code: "AU", SVGName: "Australia"},
+		{Code: "TO", SVGName: "Tonga"},
+		{Code: "WS", SVGName: "Samoa"},
+	}
+
+	elements, diff := getMapImportDiff(current, imported, entries)
+
+	if len(elements) != len(imported) {
+		t.Fatalf("expected %v elements; got %v", len(imported), len(elements))
+	}
+
+	if elements[0].ID != "NZ" || elements[0].Name != "New Zealand" {
+		t.Errorf("expected matched element to take entry code and svg name; got %v %v", elements[0].ID, elements[0].Name)
+	}
+
+	if elements[1].ID != "AU" {
+		t.Errorf("expected element matched on svg name to take code AU; got %v", elements[1].ID)
+	}
+
+	expected := MapImportDiffDto{
+		Added:      []string{"TO", "ocean"},
+		Removed:    []string{"FJ"},
+		Changed:    []string{"AU"},
+		Unchanged:  1,
+		Unmatched:  []string{"ocean"},
+		Unmapped:   []string{"WS"},
+		Duplicates: []string{"AU"},
+	}
+
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected %+v; got %+v", expected, diff)
+	}
+}
+
+func TestImportMap(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetRequestUserID := getRequestUserID
+	savedGetMap := repo.GetMap
+	savedGetMappingEntries := repo.GetMappingEntries
+	savedImportMap := repo.ImportMap
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		getRequestUserID = savedGetRequestUserID
+		repo.GetMap = savedGetMap
+		repo.GetMappingEntries = savedGetMappingEntries
+		repo.ImportMap = savedImportMap
+	}()
+
+	getMap := func(className string) (repo.MapDto, error) {
+		return repo.MapDto{Key: "world-countries", ClassName: className, ViewBox: "0 0 10 10", Revision: 3, Elements: []repo.MapElementDto{{EntryID: 1, Type: "path", ID: "NZ", Name: "New Zealand", D: "M0 0h1v1z"}}}, nil
+	}
+
+	getMappingEntries := func(key string) ([]repo.MappingEntryDto, error) {
+		return []repo.MappingEntryDto{{Code: "NZ", SVGName: "New Zealand"}}, nil
+	}
+
+	svg, _ := json.Marshal(SvgDto{SVG: `<svg viewBox="0 0 10 10"><path id="nz" d="M0 0h2v2z"/></svg>`})
+
+	tt := []struct {
+		name              string
+		isAdmin           func(request *http.Request) (int, error)
+		getMap            func(className string) (repo.MapDto, error)
+		getMappingEntries func(key string) ([]repo.MappingEntryDto, error)
+		importMap         func(userID int, className string, dto repo.ImportMapDto) (repo.MapDto, error)
+		query             string
+		body              []byte
+		status            int
+		revision          int
+		applied           bool
+	}{
+		{
+			name:    "not admin",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			body:    svg,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "invalid dryRun",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:   "?dryRun=maybe",
+			body:    svg,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid svg",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			body:    []byte(`{"svg": "<svg"}`),
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "map not found",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getMap: func(className string) (repo.MapDto, error) {
+				return repo.MapDto{}, sql.ErrNoRows
+			},
+			body:   svg,
+			status: http.StatusNotFound,
+		},
+		{
+			name:              "error on ImportMap",
+			isAdmin:           func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getMap:            getMap,
+			getMappingEntries: getMappingEntries,
+			importMap: func(userID int, className string, dto repo.ImportMapDto) (repo.MapDto, error) {
+				return repo.MapDto{}, errors.New("test")
+			},
+			body:   svg,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:              "happy path, dry run",
+			isAdmin:           func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getMap:            getMap,
+			getMappingEntries: getMappingEntries,
+			importMap: func(userID int, className string, dto repo.ImportMapDto) (repo.MapDto, error) {
+				return repo.MapDto{}, errors.New("dry run shouldn't save")
+			},
+			query:    "?dryRun=true",
+			body:     svg,
+			status:   http.StatusOK,
+			revision: 4,
+		},
+		{
+			name:              "happy path",
+			isAdmin:           func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getMap:            getMap,
+			getMappingEntries: getMappingEntries,
+			importMap: func(userID int, className string, dto repo.ImportMapDto) (repo.MapDto, error) {
+				if len(dto.Elements) != 1 || dto.Elements[0].ID != "NZ" {
+					return repo.MapDto{}, errors.New("expected matched element")
+				}
+				return repo.MapDto{Revision: 4}, nil
+			},
+			body:     svg,
+			status:   http.StatusOK,
+			revision: 4,
+			applied:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+			repo.GetMap = tc.getMap
+			repo.GetMappingEntries = tc.getMappingEntries
+			repo.ImportMap = tc.importMap
+
+			request, err := http.NewRequest("POST", tc.query, bytes.NewBuffer(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"className": "WorldCountries",
+			})
+
+			writer := httptest.NewRecorder()
+			ImportMap(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var diff MapImportDiffDto
+			if err = json.NewDecoder(result.Body).Decode(&diff); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if diff.Revision != tc.revision || diff.Applied != tc.applied {
+				t.Errorf("expected revision %v applied %v; got %v %v", tc.revision, tc.applied, diff.Revision, diff.Applied)
+			}
+
+			if len(diff.Changed) != 1 || diff.Changed[0] != "NZ" {
+				t.Errorf("expected NZ to be changed; got %v", diff.Changed)
+			}
+		})
+	}
+}
+
+func TestRollbackMap(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetRequestUserID := getRequestUserID
+	savedRollbackMap := repo.RollbackMap
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		getRequestUserID = savedGetRequestUserID
+		repo.RollbackMap = savedRollbackMap
+	}()
+
+	tt := []struct {
+		name        string
+		revision    string
+		rollbackMap func(userID int, className string, revision int) (repo.MapDto, error)
+		status      int
+	}{
+		{
+			name:     "invalid revision",
+			revision: "first",
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "revision not found",
+			revision: "2",
+			rollbackMap: func(userID int, className string, revision int) (repo.MapDto, error) {
+				return repo.MapDto{}, sql.ErrNoRows
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "error on RollbackMap",
+			revision: "2",
+			rollbackMap: func(userID int, className string, revision int) (repo.MapDto, error) {
+				return repo.MapDto{}, errors.New("test")
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:     "happy path",
+			revision: "2",
+			rollbackMap: func(userID int, className string, revision int) (repo.MapDto, error) {
+				return repo.MapDto{ClassName: className, Revision: 5}, nil
+			},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+			getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+			repo.RollbackMap = tc.rollbackMap
+
+			request, err := http.NewRequest("POST", "", nil)
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"className": "WorldCountries",
+				"revision":  tc.revision,
+			})
+
+			writer := httptest.NewRecorder()
+			RollbackMap(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package src
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+// MAP_IMAGE_CACHE_EXPIRY is how long rendered maps are kept for each set of
+// query params.
+const MAP_IMAGE_CACHE_EXPIRY = time.Hour
+
+// MAP_IMAGE_CACHE_SIZE bounds the number of rendered maps kept, as anyone can
+// request them.
+const MAP_IMAGE_CACHE_SIZE = 500
+
+const DEFAULT_MAP_IMAGE_WIDTH = 1200
+
+// mapImageSizes are the PNG widths and heights that can be requested, so a
+// request can't tie up the server rasterising or fill the cache with sizes.
+// 630 is the height of a 1200 wide share image.
+var mapImageSizes = []int{300, 600, 630, 1200, 2400}
+
+// groupColours are given to groupings in alphabetical order. The default fill
+// is left out so grouped regions stand out from ones without a grouping.
+var groupColours = []string{"#f4a261", "#4e8fd6", "#e9c46a", "#9b72cf", "#2a9d8f", "#f28482", "#8d99ae", "#a7c957", "#d4a373"}
+
+var mapImageCache = utils.NewLRUCache(MAP_IMAGE_CACHE_SIZE, MAP_IMAGE_CACHE_EXPIRY)
+
+var mapImageContentTypes = map[string]string{
+	"svg": "image/svg+xml",
+	"png": "image/png",
+}
+
+type mapImageRequest struct {
+	className string
+	format    string
+	groups    bool
+	width     int
+	height    int
+	options   utils.MapRenderOptions
+}
+
+// GetMapImage draws a stored map as an SVG or PNG, e.g. for trivia share
+// images. Highlight and crop take comma separated element ids or names,
+// groups=true colours regions by their mapping's grouping.
+func GetMapImage(writer http.ResponseWriter, request *http.Request) {
+	params, err := getMapImageRequest(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	svgMap, err := repo.GetMap(params.className)
+	if err == sql.ErrNoRows {
+		http.Error(writer, fmt.Sprintf("map %s not found\n", params.className), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Only keys on the map are kept, so made up ones can't fill the cache.
+	cropped := len(params.options.Crop) > 0
+	params.options.Highlight = filterMapElementKeys(params.options.Highlight, svgMap.Elements)
+	params.options.Crop = filterMapElementKeys(params.options.Crop, svgMap.Elements)
+	if cropped && len(params.options.Crop) == 0 {
+		http.Error(writer, fmt.Sprintf("%v\n", utils.ErrNoCropElements), http.StatusBadRequest)
+		return
+	}
+
+	key := params.cacheKey()
+	if image, found := mapImageCache.Get(key); found {
+		writeMapImage(writer, params.format, image.([]byte))
+		return
+	}
+
+	params.options.Title = svgMap.Label
+	if params.groups {
+		entries, err := repo.GetMappingEntries(svgMap.Key)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+			return
+		}
+		params.options.Fills = getGroupFills(svgMap.Elements, entries)
+	}
+
+	elements := toSVGElements(svgMap.Elements)
+	var image []byte
+	if params.format == "png" {
+		image, err = utils.RenderMapPNG(svgMap.ViewBox, elements, params.options, params.width, params.height)
+	} else {
+		image, err = utils.RenderMapSVG(svgMap.ViewBox, elements, params.options)
+	}
+
+	if err == utils.ErrNoCropElements {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	mapImageCache.Set(key, image)
+	writeMapImage(writer, params.format, image)
+}
+
+func writeMapImage(writer http.ResponseWriter, format string, image []byte) {
+	writer.Header().Set("Content-Type", mapImageContentTypes[format])
+	writer.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(MAP_IMAGE_CACHE_EXPIRY.Seconds())))
+	writer.Write(image)
+}
+
+func getMapImageRequest(request *http.Request) (mapImageRequest, error) {
+	vars := mux.Vars(request)
+	query := request.URL.Query()
+	params := mapImageRequest{
+		className: vars["className"],
+		format:    vars["format"],
+		options: utils.MapRenderOptions{
+			Highlight: getMapElementKeys(query["highlight"]),
+			Crop:      getMapElementKeys(query["crop"]),
+		},
+	}
+
+	if _, found := mapImageContentTypes[params.format]; !found {
+		return params, fmt.Errorf("invalid format %s", params.format)
+	}
+
+	if groups := query.Get("groups"); groups != "" {
+		value, err := strconv.ParseBool(groups)
+		if err != nil {
+			return params, fmt.Errorf("invalid groups %s", groups)
+		}
+		params.groups = value
+	}
+
+	if background := query.Get("background"); background != "" {
+		colour, err := utils.ParseHexColour(background)
+		if err != nil {
+			return params, err
+		}
+		params.options.Background = fmt.Sprintf("#%02x%02x%02x", colour.R, colour.G, colour.B)
+	}
+
+	if params.format != "png" {
+		return params, nil
+	}
+
+	params.width = DEFAULT_MAP_IMAGE_WIDTH
+	for name, size := range map[string]*int{"width": &params.width, "height": &params.height} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+
+		value, err := strconv.Atoi(raw)
+		if err != nil || !isMapImageSize(value) {
+			return params, fmt.Errorf("%s must be one of %s", name, strings.Trim(fmt.Sprint(mapImageSizes), "[]"))
+		}
+		*size = value
+	}
+	return params, nil
+}
+
+func isMapImageSize(size int) bool {
+	for _, val := range mapImageSizes {
+		if val == size {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey only includes the params that change the image, in a fixed order,
+// so unrelated query params don't fill the cache.
+func (p mapImageRequest) cacheKey() string {
+	values := url.Values{}
+	values.Set("highlight", joinMapElementKeys(p.options.Highlight))
+	values.Set("crop", joinMapElementKeys(p.options.Crop))
+	values.Set("groups", strconv.FormatBool(p.groups))
+	values.Set("background", p.options.Background)
+	values.Set("width", strconv.Itoa(p.width))
+	values.Set("height", strconv.Itoa(p.height))
+	return fmt.Sprintf("%s.%s?%s", p.className, p.format, values.Encode())
+}
+
+func getMapElementKeys(values []string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, value := range values {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+				keys[key] = true
+			}
+		}
+	}
+	return keys
+}
+
+// filterMapElementKeys keeps the keys matching an element's id or name.
+func filterMapElementKeys(keys map[string]bool, elements []repo.MapElementDto) map[string]bool {
+	filtered := make(map[string]bool)
+	for _, element := range elements {
+		for _, key := range []string{strings.ToLower(element.ID), strings.ToLower(element.Name)} {
+			if key != "" && keys[key] {
+				filtered[key] = true
+			}
+		}
+	}
+	return filtered
+}
+
+func joinMapElementKeys(keys map[string]bool) string {
+	var result []string
+	for key := range keys {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return strings.Join(result, ",")
+}
+
+// getGroupFills colours each element by the grouping of the mapping entry
+// drawn with it, matched on code or svg name.
+func getGroupFills(elements []repo.MapElementDto, entries []repo.MappingEntryDto) map[string]string {
+	var groupings []string
+	colours := make(map[string]string)
+	byCode := make(map[string]string)
+	byName := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Grouping == "" {
+			continue
+		}
+
+		if _, found := colours[entry.Grouping]; !found {
+			colours[entry.Grouping] = ""
+			groupings = append(groupings, entry.Grouping)
+		}
+		byCode[strings.ToLower(entry.Code)] = entry.Grouping
+		byName[entry.SVGName] = entry.Grouping
+	}
+
+	sort.Strings(groupings)
+	for index, grouping := range groupings {
+		colours[grouping] = groupColours[index%len(groupColours)]
+	}
+
+	fills := make(map[string]string)
+	for _, element := range elements {
+		grouping, found := byCode[strings.ToLower(element.ID)]
+		if !found {
+			grouping, found = byName[element.Name]
+		}
+
+		if found && element.ID != "" {
+			fills[strings.ToLower(element.ID)] = colours[grouping]
+		}
+	}
+	return fills
+}
+
+func toSVGElements(elements []repo.MapElementDto) []utils.SVGElement {
+	result := make([]utils.SVGElement, 0, len(elements))
+	for _, element := range elements {
+		result = append(result, utils.SVGElement{
+			Type:       element.Type,
+			ID:         element.ID,
+			Name:       element.Name,
+			D:          element.D,
+			Points:     element.Points,
+			X:          element.X,
+			Y:          element.Y,
+			Width:      element.Width,
+			Height:     element.Height,
+			Cx:         element.Cx,
+			Cy:         element.Cy,
+			R:          element.R,
+			Transform:  element.Transform,
+			XlinkHref:  element.XlinkHref,
+			ClipPath:   element.ClipPath,
+			ClipPathId: element.ClipPathId,
+			X1:         element.X1,
+			Y1:         element.Y1,
+			X2:         element.X2,
+			Y2:         element.Y2,
+		})
+	}
+	return result
+}
@@ -0,0 +1,224 @@
+package src
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGetMapImage(t *testing.T) {
+	savedGetMap := repo.GetMap
+	savedGetMappingEntries := repo.GetMappingEntries
+	savedCache := mapImageCache
+
+	defer func() {
+		repo.GetMap = savedGetMap
+		repo.GetMappingEntries = savedGetMappingEntries
+		mapImageCache = savedCache
+	}()
+
+	getMap := func(className string) (repo.MapDto, error) {
+		return repo.MapDto{
+			Key:       "world-countries",
+			ClassName: className,
+			Label:     "World Map",
+			ViewBox:   "0 0 30 10",
+			Elements: []repo.MapElementDto{
+				{Type: "rect", ID: "nz", Name: "New Zealand", X: "0", Y: "0", Width: "10", Height: "10"},
+				{Type: "path", ID: "au", Name: "Australia", D: "M20 0h10v10h-10z"},
+			},
+		}, nil
+	}
+
+	getMappingEntries := func(key string) ([]repo.MappingEntryDto, error) {
+		return []repo.MappingEntryDto{
+			{Code: "NZ", SVGName: "New Zealand", Grouping: "Oceania"},
+			{Code: "XX", SVGName: "Australia", Grouping: "Asia"},
+		}, nil
+	}
+
+	tt := []struct {
+		name              string
+		getMap            func(className string) (repo.MapDto, error)
+		getMappingEntries func(key string) ([]repo.MappingEntryDto, error)
+		format            string
+		query             string
+		status            int
+		contentType       string
+		contains          []string
+	}{
+		{
+			name:   "invalid format",
+			getMap: getMap,
+			format: "gif",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid width",
+			getMap: getMap,
+			format: "png",
+			query:  "?width=5000",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "size not allowed",
+			getMap: getMap,
+			format: "png",
+			query:  "?width=1201",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid background",
+			getMap: getMap,
+			format: "svg",
+			query:  "?background=red",
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "map not found",
+			getMap: func(className string) (repo.MapDto, error) {
+				return repo.MapDto{}, sql.ErrNoRows
+			},
+			format: "svg",
+			status: http.StatusNotFound,
+		},
+		{
+			name: "error on GetMap",
+			getMap: func(className string) (repo.MapDto, error) {
+				return repo.MapDto{}, errors.New("test")
+			},
+			format: "svg",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "crop to unknown element",
+			getMap: getMap,
+			format: "svg",
+			query:  "?crop=xx",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:              "error on GetMappingEntries",
+			getMap:            getMap,
+			getMappingEntries: func(key string) ([]repo.MappingEntryDto, error) { return nil, errors.New("test") },
+			format:            "svg",
+			query:             "?groups=true",
+			status:            http.StatusInternalServerError,
+		},
+		{
+			name:        "happy path, svg",
+			getMap:      getMap,
+			format:      "svg",
+			query:       "?highlight=NZ&crop=au,nz",
+			status:      http.StatusOK,
+			contentType: "image/svg+xml",
+			contains:    []string{"<title>World Map</title>", `viewBox="-1.5 -1.5 33 13"`, `height="10" fill="#e24f4f"`},
+		},
+		{
+			name:              "happy path, groups",
+			getMap:            getMap,
+			getMappingEntries: getMappingEntries,
+			format:            "svg",
+			query:             "?groups=true",
+			status:            http.StatusOK,
+			contentType:       "image/svg+xml",
+			contains:          []string{`height="10" fill="#4e8fd6"`, `h-10z" fill="#f4a261"`},
+		},
+		{
+			name:        "happy path, png",
+			getMap:      getMap,
+			format:      "png",
+			query:       "?width=1200&height=630",
+			status:      http.StatusOK,
+			contentType: "image/png",
+			contains:    []string{"\x89PNG"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetMap = tc.getMap
+			repo.GetMappingEntries = tc.getMappingEntries
+			mapImageCache = utils.NewLRUCache(MAP_IMAGE_CACHE_SIZE, MAP_IMAGE_CACHE_EXPIRY)
+
+			request, err := http.NewRequest("GET", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"className": "WorldCountries",
+				"format":    tc.format,
+			})
+
+			writer := httptest.NewRecorder()
+			GetMapImage(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			if contentType := result.Header.Get("Content-Type"); contentType != tc.contentType {
+				t.Errorf("expected content type %v; got %v", tc.contentType, contentType)
+			}
+
+			body, err := ioutil.ReadAll(result.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			for _, expected := range tc.contains {
+				if !strings.Contains(string(body), expected) {
+					t.Errorf("expected response to contain %s; got %s", expected, body)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMapImageCached(t *testing.T) {
+	savedGetMap := repo.GetMap
+	savedCache := mapImageCache
+
+	defer func() {
+		repo.GetMap = savedGetMap
+		mapImageCache = savedCache
+	}()
+
+	repo.GetMap = func(className string) (repo.MapDto, error) {
+		return repo.MapDto{ViewBox: "0 0 10 10", Elements: []repo.MapElementDto{{Type: "rect", ID: "nz", Width: "10", Height: "10"}, {Type: "rect", ID: "au", Name: "Australia", Width: "10", Height: "10"}}}, nil
+	}
+	mapImageCache = utils.NewLRUCache(MAP_IMAGE_CACHE_SIZE, MAP_IMAGE_CACHE_EXPIRY)
+
+	for _, query := range []string{"?highlight=nz,au", "?highlight=au,nz&utm_source=test", "?highlight=nz,xx", "?highlight=nz,yy", "?highlight=australia"} {
+		request, err := http.NewRequest("GET", query, nil)
+		if err != nil {
+			t.Fatalf("could not create GET request: %v", err)
+		}
+
+		request = mux.SetURLVars(request, map[string]string{"className": "WorldCountries", "format": "svg"})
+		writer := httptest.NewRecorder()
+		GetMapImage(writer, request)
+		if writer.Code != http.StatusOK {
+			t.Fatalf("expected status %v; got %v", http.StatusOK, writer.Code)
+		}
+	}
+
+	if mapImageCache.Len() != 3 {
+		t.Errorf("expected 3 cached images; got %v", mapImageCache.Len())
+	}
+}
@@ -0,0 +1,273 @@
+package src
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+// mappingEntryFilter narrows a group's entries. Attributes are filtered on
+// their value, e.g. capital=Paris, or for numbers by range, e.g.
+// population.min=1000000.
+type mappingEntryFilter struct {
+	grouping  string
+	neighbour string
+	equals    map[string]string
+	min       map[string]float64
+	max       map[string]float64
+}
+
+func GetMappingAttributes(writer http.ResponseWriter, request *http.Request) {
+	attributes, err := repo.GetMappingAttributes()
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(attributes)
+}
+
+// getMappingEntryFilter reads the grouping, neighbour and attribute query
+// params. Params that aren't any of these are ignored.
+func getMappingEntryFilter(request *http.Request) (mappingEntryFilter, int, error) {
+	filter := mappingEntryFilter{
+		equals: make(map[string]string),
+		min:    make(map[string]float64),
+		max:    make(map[string]float64),
+	}
+
+	query := request.URL.Query()
+	filter.grouping = query.Get("grouping")
+	filter.neighbour = query.Get("neighbour")
+
+	attributes, err := repo.GetMappingAttributes()
+	if err != nil {
+		return filter, http.StatusInternalServerError, err
+	}
+
+	types := make(map[string]string)
+	for _, attribute := range attributes {
+		types[attribute.Key] = attribute.ValueType
+	}
+
+	for name := range query {
+		key, bound := name, ""
+		if index := strings.LastIndex(name, "."); index >= 0 {
+			key, bound = name[:index], name[index+1:]
+		}
+
+		valueType, found := types[key]
+		if !found {
+			continue
+		}
+
+		value := query.Get(name)
+		if bound == "" {
+			filter.equals[key] = value
+			continue
+		}
+
+		if valueType != repo.MAPPING_ATTRIBUTE_NUMBER || (bound != "min" && bound != "max") {
+			return filter, http.StatusBadRequest, fmt.Errorf("invalid filter %s", name)
+		}
+
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return filter, http.StatusBadRequest, fmt.Errorf("%s must be a number", name)
+		}
+
+		if bound == "min" {
+			filter.min[key] = number
+		} else {
+			filter.max[key] = number
+		}
+	}
+	return filter, http.StatusOK, nil
+}
+
+func filterMappingEntries(entries []repo.MappingEntryDto, filter mappingEntryFilter) []repo.MappingEntryDto {
+	var result = []repo.MappingEntryDto{}
+	for _, entry := range entries {
+		if matchesMappingEntryFilter(entry, filter) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func matchesMappingEntryFilter(entry repo.MappingEntryDto, filter mappingEntryFilter) bool {
+	if filter.grouping != "" && !strings.EqualFold(entry.Grouping, filter.grouping) {
+		return false
+	}
+
+	if filter.neighbour != "" {
+		found := false
+		for _, code := range entry.Neighbours {
+			found = found || strings.EqualFold(code, filter.neighbour)
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for key, expected := range filter.equals {
+		switch value := entry.Attributes[key].(type) {
+		case string:
+			if !strings.EqualFold(value, expected) {
+				return false
+			}
+		case float64:
+			if number, err := strconv.ParseFloat(expected, 64); err != nil || number != value {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for key, min := range filter.min {
+		if value, ok := entry.Attributes[key].(float64); !ok || value < min {
+			return false
+		}
+	}
+
+	for key, max := range filter.max {
+		if value, ok := entry.Attributes[key].(float64); !ok || value > max {
+			return false
+		}
+	}
+	return true
+}
+
+// ImportMappingAttributes sets attributes and neighbours on a group's entries
+// from a JSON or CSV dataset. Nothing is saved unless every row is valid, and
+// with dryRun=true the rows are only checked.
+func ImportMappingAttributes(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var rows []repo.MappingEntryAttributesDto
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "text/csv") {
+		rows, err = parseMappingAttributesCSV(bytes.NewReader(requestBody))
+	} else {
+		err = json.Unmarshal(requestBody, &rows)
+	}
+
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	results, valid, err := repo.ValidateMappingAttributeImport(mux.Vars(request)["key"], rows)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := repo.ImportMappingAttributesResultDto{
+		DryRun: request.URL.Query().Get("dryRun") == "true",
+		Total:  len(rows),
+		Valid:  len(valid),
+		Rows:   results,
+	}
+
+	if result.DryRun || len(valid) != len(rows) {
+		writer.Header().Set("Content-Type", "application/json")
+		if !result.DryRun {
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(writer).Encode(result)
+		return
+	}
+
+	err = repo.ImportMappingAttributes(valid)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+	mappingIndexCache.Delete(mux.Vars(request)["key"])
+
+	result.Imported = len(valid)
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+// parseMappingAttributesCSV reads a code column, an optional neighbours
+// column of codes separated by semicolons and a column per attribute.
+func parseMappingAttributesCSV(reader io.Reader) ([]repo.MappingEntryAttributesDto, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("missing csv header")
+	}
+
+	header := records[0]
+	code, neighbours := -1, -1
+	for index, name := range header {
+		header[index] = strings.TrimSpace(name)
+		switch header[index] {
+		case "code":
+			code = index
+		case "neighbours":
+			neighbours = index
+		}
+	}
+
+	if code < 0 {
+		return nil, errors.New("missing code column")
+	}
+
+	var rows = []repo.MappingEntryAttributesDto{}
+	for _, record := range records[1:] {
+		row := repo.MappingEntryAttributesDto{Attributes: make(map[string]interface{})}
+		for index, value := range record {
+			if index >= len(header) {
+				break
+			}
+
+			value = strings.TrimSpace(value)
+			switch index {
+			case code:
+				row.Code = value
+			case neighbours:
+				row.Neighbours = []string{}
+				for _, neighbour := range strings.Split(value, ";") {
+					if neighbour = strings.TrimSpace(neighbour); neighbour != "" {
+						row.Neighbours = append(row.Neighbours, neighbour)
+					}
+				}
+			default:
+				row.Attributes[header[index]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
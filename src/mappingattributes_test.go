@@ -0,0 +1,241 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestFilterMappingEntries(t *testing.T) {
+	savedGetMappingAttributes := repo.GetMappingAttributes
+	defer func() {
+		repo.GetMappingAttributes = savedGetMappingAttributes
+	}()
+
+	repo.GetMappingAttributes = func() ([]repo.MappingAttribute, error) {
+		return []repo.MappingAttribute{
+			{ID: 1, Key: "capital", ValueType: repo.MAPPING_ATTRIBUTE_TEXT},
+			{ID: 2, Key: "population", ValueType: repo.MAPPING_ATTRIBUTE_NUMBER},
+		}, nil
+	}
+
+	entries := []repo.MappingEntryDto{
+		{Code: "FR", Grouping: "Europe", Attributes: map[string]interface{}{"capital": "Paris", "population": float64(67000000)}, Neighbours: []string{"BE", "DE"}},
+		{Code: "BE", Grouping: "Europe", Attributes: map[string]interface{}{"capital": "Brussels", "population": float64(11500000)}, Neighbours: []string{"FR"}},
+		{Code: "NZ", Grouping: "Oceania", Attributes: map[string]interface{}{"capital": "Wellington"}, Neighbours: []string{}},
+	}
+
+	tt := []struct {
+		name     string
+		query    string
+		status   int
+		expected []string
+	}{
+		{name: "min on text attribute", query: "?capital.min=1", status: http.StatusBadRequest},
+		{name: "invalid number", query: "?population.max=lots", status: http.StatusBadRequest},
+		{name: "unknown params ignored", query: "?page=1", status: http.StatusOK, expected: []string{"FR", "BE", "NZ"}},
+		{name: "grouping", query: "?grouping=europe", status: http.StatusOK, expected: []string{"FR", "BE"}},
+		{name: "neighbour", query: "?neighbour=fr", status: http.StatusOK, expected: []string{"BE"}},
+		{name: "text attribute", query: "?capital=paris", status: http.StatusOK, expected: []string{"FR"}},
+		{name: "number range", query: "?population.min=1000000&population.max=20000000", status: http.StatusOK, expected: []string{"BE"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest("GET", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			filter, status, err := getMappingEntryFilter(request)
+			if status != tc.status {
+				t.Fatalf("expected status %v; got %v (%v)", tc.status, status, err)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			result := filterMappingEntries(entries, filter)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("expected %v entries; got %v", len(tc.expected), len(result))
+			}
+
+			for index, entry := range result {
+				if entry.Code != tc.expected[index] {
+					t.Errorf("expected %v; got %v", tc.expected[index], entry.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMappingAttributesCSV(t *testing.T) {
+	rows, err := parseMappingAttributesCSV(bytes.NewBufferString("code,capital,neighbours\nFR,Paris,BE; DE\nNZ,Wellington,\n"))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows; got %v", len(rows))
+	}
+
+	if rows[0].Code != "FR" || rows[0].Attributes["capital"] != "Paris" || len(rows[0].Neighbours) != 2 || rows[0].Neighbours[1] != "DE" {
+		t.Errorf("unexpected first row %+v", rows[0])
+	}
+
+	if rows[1].Neighbours == nil || len(rows[1].Neighbours) != 0 {
+		t.Errorf("expected empty neighbours to clear them; got %v", rows[1].Neighbours)
+	}
+
+	if _, err = parseMappingAttributesCSV(bytes.NewBufferString("name,capital\nFrance,Paris\n")); err == nil {
+		t.Error("expected error for missing code column")
+	}
+}
+
+func TestImportMappingAttributes(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedValidate := repo.ValidateMappingAttributeImport
+	savedImport := repo.ImportMappingAttributes
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.ValidateMappingAttributeImport = savedValidate
+		repo.ImportMappingAttributes = savedImport
+	}()
+
+	validate := func(key string, rows []repo.MappingEntryAttributesDto) ([]repo.ImportMappingAttributesRowDto, []repo.UpdateMappingEntryAttributesDto, error) {
+		results := []repo.ImportMappingAttributesRowDto{}
+		valid := []repo.UpdateMappingEntryAttributesDto{}
+		for index, row := range rows {
+			result := repo.ImportMappingAttributesRowDto{Row: index + 1, Code: row.Code, Errors: []string{}}
+			if row.Code == "XX" {
+				result.Errors = append(result.Errors, "invalid code")
+			} else {
+				valid = append(valid, repo.UpdateMappingEntryAttributesDto{EntryID: index + 1})
+			}
+			results = append(results, result)
+		}
+		return results, valid, nil
+	}
+
+	body := []byte(`[{"code": "FR", "attributes": {"capital": "Paris"}}]`)
+
+	tt := []struct {
+		name        string
+		isAdmin     func(request *http.Request) (int, error)
+		validate    func(key string, rows []repo.MappingEntryAttributesDto) ([]repo.ImportMappingAttributesRowDto, []repo.UpdateMappingEntryAttributesDto, error)
+		importRows  func(updates []repo.UpdateMappingEntryAttributesDto) error
+		query       string
+		contentType string
+		body        []byte
+		status      int
+		imported    int
+	}{
+		{
+			name:    "not admin",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			body:    body,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "invalid body",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			body:    []byte("testing"),
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "error on ValidateMappingAttributeImport",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate: func(key string, rows []repo.MappingEntryAttributesDto) ([]repo.ImportMappingAttributesRowDto, []repo.UpdateMappingEntryAttributesDto, error) {
+				return nil, nil, errors.New("test")
+			},
+			body:   body,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:     "invalid row",
+			isAdmin:  func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate: validate,
+			body:     []byte(`[{"code": "FR"}, {"code": "XX"}]`),
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "error on ImportMappingAttributes",
+			isAdmin:  func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate: validate,
+			importRows: func(updates []repo.UpdateMappingEntryAttributesDto) error {
+				return errors.New("test")
+			},
+			body:   body,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:     "happy path, dry run",
+			isAdmin:  func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate: validate,
+			importRows: func(updates []repo.UpdateMappingEntryAttributesDto) error {
+				return errors.New("dry run shouldn't save")
+			},
+			query:  "?dryRun=true",
+			body:   body,
+			status: http.StatusOK,
+		},
+		{
+			name:        "happy path, csv",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			validate:    validate,
+			importRows:  func(updates []repo.UpdateMappingEntryAttributesDto) error { return nil },
+			contentType: "text/csv",
+			body:        []byte("code,capital\nFR,Paris\nBE,Brussels\n"),
+			status:      http.StatusOK,
+			imported:    2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.ValidateMappingAttributeImport = tc.validate
+			repo.ImportMappingAttributes = tc.importRows
+
+			request, err := http.NewRequest("POST", tc.query, bytes.NewBuffer(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+			request.Header.Set("Content-Type", tc.contentType)
+
+			request = mux.SetURLVars(request, map[string]string{
+				"key": "world-countries",
+			})
+
+			writer := httptest.NewRecorder()
+			ImportMappingAttributes(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var parsed repo.ImportMappingAttributesResultDto
+			if err = json.NewDecoder(result.Body).Decode(&parsed); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if parsed.Imported != tc.imported {
+				t.Errorf("expected %v imported; got %v", tc.imported, parsed.Imported)
+			}
+		})
+	}
+}
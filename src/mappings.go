@@ -5,11 +5,42 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
 	"github.com/gorilla/mux"
+	"github.com/patrickmn/go-cache"
 )
 
+// MAPPING_INDEX_CACHE_EXPIRY is how long a mapping group's answer index is
+// kept. Indexes are dropped straight away when the mapping is edited.
+const MAPPING_INDEX_CACHE_EXPIRY = time.Hour
+
+var mappingIndexCache = cache.New(MAPPING_INDEX_CACHE_EXPIRY, 2*MAPPING_INDEX_CACHE_EXPIRY)
+
+// MatchMappingDto is an answer to check against a mapping group. MaxDistance
+// and CharsPerEdit override the default edit distance rules.
+type MatchMappingDto struct {
+	Answer       string `json:"answer" validate:"required"`
+	MaxDistance  *int   `json:"maxDistance" validate:"omitempty,min=0,max=5"`
+	CharsPerEdit *int   `json:"charsPerEdit" validate:"omitempty,min=1,max=20"`
+}
+
+type MappingMatchDto struct {
+	Matched    bool                  `json:"matched"`
+	Entry      *repo.MappingEntryDto `json:"entry"`
+	Name       string                `json:"name"`
+	Distance   int                   `json:"distance"`
+	Confidence float64               `json:"confidence"`
+}
+
+type mappingIndex struct {
+	index   *utils.MatchIndex
+	entries map[string]repo.MappingEntryDto
+}
+
 func GetMappingGroups(writer http.ResponseWriter, request *http.Request) {
 	mapping, err := repo.GetMappingGroups()
 	if err != nil {
@@ -27,6 +58,15 @@ func (s *Server) getMappingEntries(writer http.ResponseWriter, request *http.Req
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
+
+	if len(request.URL.Query()) > 0 {
+		filter, code, err := getMappingEntryFilter(request)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), code)
+			return
+		}
+		entries = filterMappingEntries(entries, filter)
+	}
 	language := request.Header.Get("Content-Language")
 	if language != "" && language != "en" {
 		translatedEntries := make([]repo.MappingEntryDto, len(entries))
@@ -70,6 +110,8 @@ func (s *Server) translateEntry(entry repo.MappingEntryDto, language string) (re
 		AlternativeNames: entry.AlternativeNames,
 		Prefixes:         entry.Prefixes,
 		Grouping:         entry.Grouping,
+		Attributes:       entry.Attributes,
+		Neighbours:       entry.Neighbours,
 	}, nil
 }
 
@@ -107,6 +149,7 @@ func EditMapping(writer http.ResponseWriter, request *http.Request) {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
+	mappingIndexCache.Delete(mux.Vars(request)["key"])
 }
 
 func DeleteMapping(writer http.ResponseWriter, request *http.Request) {
@@ -117,5 +160,103 @@ func DeleteMapping(writer http.ResponseWriter, request *http.Request) {
 
 	if err := repo.DeleteMapping(mux.Vars(request)["key"]); err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+	mappingIndexCache.Delete(mux.Vars(request)["key"])
+}
+
+// matchMappingEntry finds the entry in the mapping group an answer refers to,
+// allowing for typos, missing accents and punctuation, and optional prefixes.
+// An answer that doesn't match is not an error, matched is false instead.
+func (s *Server) matchMappingEntry(writer http.ResponseWriter, request *http.Request) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto MatchMappingDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.vs.GetValidator().Struct(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := mux.Vars(request)["key"]
+	index, err := getMappingIndex(key)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(index.entries) == 0 {
+		http.Error(writer, fmt.Sprintf("mapping %s not found\n", key), http.StatusNotFound)
+		return
+	}
+
+	options := utils.DefaultMatchOptions()
+	if dto.MaxDistance != nil {
+		options.MaxDistance = *dto.MaxDistance
+	}
+
+	if dto.CharsPerEdit != nil {
+		options.CharsPerEdit = *dto.CharsPerEdit
+	}
+
+	var result MappingMatchDto
+	if match, found := index.index.Match(dto.Answer, options); found {
+		entry := index.entries[match.Key]
+		result = MappingMatchDto{
+			Matched:    true,
+			Entry:      &entry,
+			Name:       match.Name,
+			Distance:   match.Distance,
+			Confidence: match.Confidence,
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+// getMappingIndex builds the answer index for a mapping group from each
+// entry's name, svg name and alternative names, or returns the cached one.
+// Empty indexes aren't cached, so unknown keys can't fill the cache.
+func getMappingIndex(key string) (mappingIndex, error) {
+	if cached, found := mappingIndexCache.Get(key); found {
+		return cached.(mappingIndex), nil
+	}
+
+	entries, err := repo.GetMappingEntries(key)
+	if err != nil {
+		return mappingIndex{}, err
+	}
+
+	result := mappingIndex{entries: make(map[string]repo.MappingEntryDto)}
+	candidates := make([]utils.MatchCandidate, 0, len(entries))
+	for _, entry := range entries {
+		id := strconv.Itoa(entry.ID)
+		result.entries[id] = entry
+
+		candidate := utils.MatchCandidate{Key: id, Names: []string{entry.Name, entry.SVGName}}
+		if entry.AlternativeNames != nil {
+			candidate.Names = append(candidate.Names, *entry.AlternativeNames...)
+		}
+
+		if entry.Prefixes != nil {
+			candidate.Prefixes = *entry.Prefixes
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	result.index = utils.NewMatchIndex(candidates)
+	if len(entries) > 0 {
+		mappingIndexCache.Set(key, result, cache.DefaultExpiration)
 	}
+	return result, nil
 }
@@ -0,0 +1,137 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/patrickmn/go-cache"
+)
+
+func TestMatchMappingEntry(t *testing.T) {
+	savedGetMappingEntries := repo.GetMappingEntries
+	savedCache := mappingIndexCache
+
+	defer func() {
+		repo.GetMappingEntries = savedGetMappingEntries
+		mappingIndexCache = savedCache
+	}()
+
+	getMappingEntries := func(key string) ([]repo.MappingEntryDto, error) {
+		return []repo.MappingEntryDto{
+			{ID: 1, Code: "CI", Name: "côte d'ivoire", SVGName: "Côte d'Ivoire", AlternativeNames: &pq.StringArray{"ivory coast"}, Prefixes: &pq.StringArray{}},
+			{ID: 2, Code: "BS", Name: "bahamas", SVGName: "Bahamas", AlternativeNames: &pq.StringArray{}, Prefixes: &pq.StringArray{"the"}},
+		}, nil
+	}
+
+	tt := []struct {
+		name              string
+		getMappingEntries func(key string) ([]repo.MappingEntryDto, error)
+		body              string
+		status            int
+		matched           bool
+		code              string
+	}{
+		{
+			name:   "invalid body",
+			body:   "testing",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing answer",
+			body:   `{"answer": ""}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid max distance",
+			body:   `{"answer": "bahamas", "maxDistance": 10}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:              "error on GetMappingEntries",
+			getMappingEntries: func(key string) ([]repo.MappingEntryDto, error) { return nil, errors.New("test") },
+			body:              `{"answer": "bahamas"}`,
+			status:            http.StatusInternalServerError,
+		},
+		{
+			name:              "mapping not found",
+			getMappingEntries: func(key string) ([]repo.MappingEntryDto, error) { return []repo.MappingEntryDto{}, nil },
+			body:              `{"answer": "bahamas"}`,
+			status:            http.StatusNotFound,
+		},
+		{
+			name:              "no match",
+			getMappingEntries: getMappingEntries,
+			body:              `{"answer": "barbados"}`,
+			status:            http.StatusOK,
+		},
+		{
+			name:              "happy path, diacritics",
+			getMappingEntries: getMappingEntries,
+			body:              `{"answer": "Cote dIvoire"}`,
+			status:            http.StatusOK,
+			matched:           true,
+			code:              "CI",
+		},
+		{
+			name:              "happy path, prefix and typo",
+			getMappingEntries: getMappingEntries,
+			body:              `{"answer": "the bahamsa"}`,
+			status:            http.StatusOK,
+			matched:           true,
+			code:              "BS",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetMappingEntries = tc.getMappingEntries
+			mappingIndexCache = cache.New(MAPPING_INDEX_CACHE_EXPIRY, MAPPING_INDEX_CACHE_EXPIRY)
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"key": "world-countries",
+			})
+
+			writer := httptest.NewRecorder()
+			getMockServer().matchMappingEntry(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if _, cached := mappingIndexCache.Get("world-countries"); cached != (tc.status == http.StatusOK) {
+				t.Errorf("expected index cached %v; got %v", tc.status == http.StatusOK, cached)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var match MappingMatchDto
+			if err = json.NewDecoder(result.Body).Decode(&match); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if match.Matched != tc.matched {
+				t.Fatalf("expected matched %v; got %v", tc.matched, match.Matched)
+			}
+
+			if tc.matched && match.Entry.Code != tc.code {
+				t.Errorf("expected %v; got %v", tc.code, match.Entry.Code)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package src
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
 	"github.com/gorilla/mux"
 )
 
@@ -35,7 +35,13 @@ func GetMaps(writer http.ResponseWriter, request *http.Request) {
 }
 
 func GetMap(writer http.ResponseWriter, request *http.Request) {
-	svgMap, err := repo.GetMap(mux.Vars(request)["className"])
+	detail, err := getMapDetail(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	svgMap, err := repo.GetMapWithDetail(mux.Vars(request)["className"], detail)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
@@ -45,6 +51,20 @@ func GetMap(writer http.ResponseWriter, request *http.Request) {
 	json.NewEncoder(writer).Encode(svgMap)
 }
 
+// getMapDetail reads the detail query param, which picks how simplified the
+// map geometry is. Maps are returned in full detail by default.
+func getMapDetail(request *http.Request) (string, error) {
+	detail := request.URL.Query().Get("detail")
+	if detail == "" {
+		return utils.MAP_DETAIL_FULL, nil
+	}
+
+	if !utils.IsMapDetail(detail) {
+		return "", fmt.Errorf("invalid detail %s", detail)
+	}
+	return detail, nil
+}
+
 func GetMapHighlightedRegions(writer http.ResponseWriter, request *http.Request) {
 	regions, err := repo.GetMapHighlightedRegions(mux.Vars(request)["className"])
 	if err != nil {
@@ -80,53 +100,57 @@ func GetMapPreview(writer http.ResponseWriter, request *http.Request) {
 	json.NewEncoder(writer).Encode(preview)
 }
 
-func getMapPreview(svg string) (repo.MapDto, error) {
-	result := repo.MapDto{
-		ID:        0,
-		Key:       "preview",
-		ClassName: "Preview",
-		Label:     "Map Preview",
-		ViewBox:   "",
-		Elements:  []repo.MapElementDto{},
-	}
-
-	var width string
-	var height string
-	var currentElement repo.MapElementDto
-	scanner := bufio.NewScanner(strings.NewReader(svg))
-	for scanner.Scan() {
-		text := scanner.Text()
-
-		if strings.Contains(text, "width=") {
-			widthSub := text[(strings.Index(text, `width="`) + 7):]
-			width = widthSub[:strings.Index(widthSub, `"`)]
-		}
-
-		if strings.Contains(text, "height=") {
-			heightSub := text[(strings.Index(text, `height="`) + 8):]
-			height = heightSub[:strings.Index(heightSub, `"`)]
-		}
-
-		if strings.Contains(text, "id=") {
-			idSub := text[(strings.Index(text, `id="`) + 4):]
-			currentElement.ID = idSub[:strings.Index(idSub, `"`)]
-			currentElement.Type = "path"
-			result.Elements = append(result.Elements, currentElement)
-		} else if strings.Contains(text, "d=") {
-			sub := text[(strings.Index(text, `d="`) + 3):]
-			currentElement.D = sub[:strings.Index(sub, `"`)]
-		} else if strings.Contains(text, "title=") {
-			titleSub := text[(strings.Index(text, `title="`) + 7):]
-			currentElement.Name = titleSub[:strings.Index(titleSub, `"`)]
-		}
-	}
-	result.ViewBox = fmt.Sprintf("0 0 %s %s", width, height)
-
-	if err := scanner.Err(); err != nil {
-		return repo.MapDto{}, err
-	}
-
-	sort.Slice(result.Elements, func(i, j int) bool {
+// MapPreviewDto includes anything in the SVG that couldn't be imported so it
+// can be fixed before the map is created.
+type MapPreviewDto struct {
+	repo.MapDto
+	Warnings []utils.SVGWarning `json:"warnings"`
+}
+
+func getMapPreview(svg string) (MapPreviewDto, error) {
+	document, err := utils.ParseSVG(strings.NewReader(svg))
+	if err != nil {
+		return MapPreviewDto{}, err
+	}
+
+	result := MapPreviewDto{
+		MapDto: repo.MapDto{
+			ID:        0,
+			Key:       "preview",
+			ClassName: "Preview",
+			Label:     "Map Preview",
+			ViewBox:   document.ViewBox,
+			Elements:  []repo.MapElementDto{},
+		},
+		Warnings: document.Warnings,
+	}
+
+	for _, element := range document.Elements {
+		result.Elements = append(result.Elements, repo.MapElementDto{
+			Type:       element.Type,
+			ID:         element.ID,
+			Name:       element.Name,
+			D:          element.D,
+			Points:     element.Points,
+			X:          element.X,
+			Y:          element.Y,
+			Width:      element.Width,
+			Height:     element.Height,
+			Cx:         element.Cx,
+			Cy:         element.Cy,
+			R:          element.R,
+			Transform:  element.Transform,
+			XlinkHref:  element.XlinkHref,
+			ClipPath:   element.ClipPath,
+			ClipPathId: element.ClipPathId,
+			X1:         element.X1,
+			Y1:         element.Y1,
+			X2:         element.X2,
+			Y2:         element.Y2,
+		})
+	}
+
+	sort.SliceStable(result.Elements, func(i, j int) bool {
 		return result.Elements[i].Name < result.Elements[j].Name
 	})
 
@@ -134,34 +158,38 @@ func getMapPreview(svg string) (repo.MapDto, error) {
 }
 
 func CreateMap(writer http.ResponseWriter, request *http.Request) {
-	requestBody, err := ioutil.ReadAll(request.Body)
-	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
 		return
 	}
 
-	var payload CreateMapDto
-	err = json.Unmarshal(requestBody, &payload)
+	userID, err := getRequestUserID(request)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
 
-	err = repo.CreateMap(payload.SVGMap)
+	requestBody, err := ioutil.ReadAll(request.Body)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	err = repo.CreateMappings(payload.Mappings)
+	var payload CreateMapDto
+	err = json.Unmarshal(requestBody, &payload)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	_, err = repo.CreateQuiz(payload.Quiz)
+	svgMap, err := repo.CreateMap(userID, payload.SVGMap, payload.Mappings, payload.Quiz)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		writeAdminChangeError(writer, err, "Map key, class name or quiz route already in use. Please choose another and try again.")
 		return
 	}
+	mappingIndexCache.Delete(payload.Mappings.Key)
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(svgMap)
 }
@@ -0,0 +1,172 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGetMap(t *testing.T) {
+	savedGetMapWithDetail := repo.GetMapWithDetail
+
+	defer func() {
+		repo.GetMapWithDetail = savedGetMapWithDetail
+	}()
+
+	var requested string
+	getMapWithDetail := func(className, detail string) (repo.MapDto, error) {
+		requested = detail
+		return repo.MapDto{ClassName: className}, nil
+	}
+
+	tt := []struct {
+		name             string
+		getMapWithDetail func(className, detail string) (repo.MapDto, error)
+		query            string
+		status           int
+		detail           string
+	}{
+		{
+			name:             "invalid detail",
+			getMapWithDetail: getMapWithDetail,
+			query:            "?detail=extreme",
+			status:           http.StatusBadRequest,
+		},
+		{
+			name: "error on GetMapWithDetail",
+			getMapWithDetail: func(className, detail string) (repo.MapDto, error) {
+				return repo.MapDto{}, errors.New("test")
+			},
+			query:  "",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:             "happy path, full detail by default",
+			getMapWithDetail: getMapWithDetail,
+			query:            "",
+			status:           http.StatusOK,
+			detail:           utils.MAP_DETAIL_FULL,
+		},
+		{
+			name:             "happy path, low detail",
+			getMapWithDetail: getMapWithDetail,
+			query:            "?detail=low",
+			status:           http.StatusOK,
+			detail:           utils.MAP_DETAIL_LOW,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetMapWithDetail = tc.getMapWithDetail
+			requested = ""
+
+			request, err := http.NewRequest("GET", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"className": "WorldCountries",
+			})
+
+			writer := httptest.NewRecorder()
+			GetMap(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if requested != tc.detail {
+				t.Errorf("expected detail %v; got %v", tc.detail, requested)
+			}
+		})
+	}
+}
+
+func TestGetMapPreview(t *testing.T) {
+	tt := []struct {
+		name     string
+		body     string
+		status   int
+		elements []string
+		warnings int
+	}{
+		{
+			name:   "invalid body",
+			body:   "testing",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid svg",
+			body:   `{"svg": "<svg><path></svg>"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "happy path",
+			body:     `{"svg": "<svg viewBox=\"0 0 10 10\"><path id=\"b\" title=\"B\" d=\"M0 0\"/><circle id=\"a\" title=\"A\" r=\"1\"/><text>x</text></svg>"}`,
+			status:   http.StatusOK,
+			elements: []string{"a", "b"},
+			warnings: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			GetMapPreview(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusOK {
+				body, err := ioutil.ReadAll(result.Body)
+				if err != nil {
+					t.Fatalf("could not read response: %v", err)
+				}
+
+				var parsed MapPreviewDto
+				err = json.Unmarshal(body, &parsed)
+				if err != nil {
+					t.Fatalf("could not unmarshal response body: %v", err)
+				}
+
+				if parsed.ViewBox != "0 0 10 10" {
+					t.Errorf("expected viewBox 0 0 10 10; got %v", parsed.ViewBox)
+				}
+
+				if len(parsed.Elements) != len(tc.elements) {
+					t.Fatalf("expected %v elements; got %v", len(tc.elements), len(parsed.Elements))
+				}
+
+				for index, id := range tc.elements {
+					if parsed.Elements[index].ID != id {
+						t.Errorf("expected element %v; got %v", id, parsed.Elements[index].ID)
+					}
+				}
+
+				if len(parsed.Warnings) != tc.warnings {
+					t.Errorf("expected %v warnings; got %v", tc.warnings, len(parsed.Warnings))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package src
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+)
+
+// MAX_MEDIA_SIZE is the largest image that can be uploaded.
+const MAX_MEDIA_SIZE = 5 << 20
+
+// MAX_MEDIA_FORM_OVERHEAD leaves room for the rest of the multipart form
+// around the image.
+const MAX_MEDIA_FORM_OVERHEAD = 64 << 10
+
+const MAX_MEDIA_ATTRIBUTION_LENGTH = 500
+
+// Uploads are throttled per user so the store can't be filled faster than
+// unused media is cleaned up.
+const (
+	MEDIA_UPLOAD_LIMIT    = 30
+	MEDIA_UPLOAD_WINDOW   = time.Hour
+	MEDIA_UPLOAD_MAX_KEYS = 10000
+)
+
+// MEDIA_KEY_PREFIX keeps uploads apart from the other files in the store, so
+// cleaning up media never touches flags.
+const MEDIA_KEY_PREFIX = "media/"
+
+var mediaUploadLimiter = utils.NewRateLimiter(MEDIA_UPLOAD_LIMIT, MEDIA_UPLOAD_WINDOW, MEDIA_UPLOAD_MAX_KEYS)
+
+type MediaGCResultDto struct {
+	DryRun   bool         `json:"dryRun"`
+	Unused   []repo.Media `json:"unused"`
+	Orphaned []string     `json:"orphaned"`
+}
+
+// uploadMedia stores an image for a quiz, question, avatar or anything else
+// with an image url. Images are checked by their content and named by it, so
+// uploading the same image twice returns the first upload, unless it's given a
+// different attribution. The url, size and attribution are returned, and are
+// filled in on questions that use the url.
+func (s *Server) uploadMedia(writer http.ResponseWriter, request *http.Request) {
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusUnauthorized)
+		return
+	}
+
+	if !mediaUploadLimiter.Allow(strconv.Itoa(userID)) {
+		http.Error(writer, "Too many uploads. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	limit := int64(MAX_MEDIA_SIZE + MAX_MEDIA_FORM_OVERHEAD)
+	request.Body = http.MaxBytesReader(writer, request.Body, limit)
+	if err = request.ParseMultipartForm(MAX_MEDIA_SIZE); err != nil {
+		code := http.StatusBadRequest
+		if request.ContentLength > limit {
+			code = http.StatusRequestEntityTooLarge
+		}
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > MAX_MEDIA_SIZE {
+		http.Error(writer, fmt.Sprintf("images must be under %dMB\n", MAX_MEDIA_SIZE>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	attributionName := strings.TrimSpace(request.FormValue("attributionName"))
+	attributionURL := strings.TrimSpace(request.FormValue("attributionUrl"))
+	if err = validateMediaAttribution(attributionName, attributionURL); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	info, err := utils.InspectMedia(data)
+	if err == utils.ErrUnsupportedMedia {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	key := fmt.Sprintf("%s%x.%s", MEDIA_KEY_PREFIX, hash[:16], info.Extension)
+
+	// The row is saved before the file so garbage collection never sees a
+	// file without one while the upload is in progress.
+	media, created, err := repo.CreateMedia(repo.CreateMediaDto{
+		Key:             key,
+		URL:             s.ms.URL(key),
+		ContentType:     info.ContentType,
+		Size:            len(data),
+		Width:           info.Width,
+		Height:          info.Height,
+		AttributionName: attributionName,
+		AttributionURL:  attributionURL,
+		UserID:          userID,
+	})
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if !created {
+		// The first upload's attribution is kept, so a different one would
+		// otherwise be silently dropped.
+		attributed := attributionName != "" || attributionURL != ""
+		if attributed && (attributionName != media.AttributionName || attributionURL != media.AttributionURL) {
+			http.Error(writer, "This image has already been uploaded with a different attribution.", http.StatusConflict)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(media)
+		return
+	}
+
+	if _, err = s.ms.Put(key, info.ContentType, data); err != nil {
+		if deleteErr := repo.DeleteMedia(media.ID); deleteErr != nil {
+			log.Printf("failed to delete media %d: %v", media.ID, deleteErr)
+		}
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(media)
+}
+
+// validateMediaAttribution makes sure the attribution link can only ever be
+// a web page, as it's shown as a link next to the image.
+func validateMediaAttribution(name, link string) error {
+	if len(name) > MAX_MEDIA_ATTRIBUTION_LENGTH || len(link) > MAX_MEDIA_ATTRIBUTION_LENGTH {
+		return fmt.Errorf("attribution must be under %d characters", MAX_MEDIA_ATTRIBUTION_LENGTH)
+	}
+
+	if link == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return errors.New("attribution url must be an http or https url")
+	}
+	return nil
+}
+
+// collectMedia deletes uploads nothing has used within the grace period,
+// along with any files in the store left without a record of the upload.
+// With dryRun=true they're only listed.
+func (s *Server) collectMedia(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	result, err := s.deleteUnusedMedia(request.URL.Query().Get("dryRun") == "true")
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+// collectUnusedMedia periodically cleans up media nothing uses.
+func (s *Server) collectUnusedMedia(interval time.Duration) {
+	for range time.Tick(interval) {
+		result, err := s.deleteUnusedMedia(false)
+		if err != nil {
+			log.Printf("failed to collect unused media: %v", err)
+			continue
+		}
+
+		if len(result.Unused) > 0 || len(result.Orphaned) > 0 {
+			log.Printf("deleted %d unused and %d orphaned media", len(result.Unused), len(result.Orphaned))
+		}
+	}
+}
+
+func (s *Server) deleteUnusedMedia(dryRun bool) (MediaGCResultDto, error) {
+	result := MediaGCResultDto{
+		DryRun:   dryRun,
+		Unused:   []repo.Media{},
+		Orphaned: []string{},
+	}
+
+	unused, err := repo.GetUnusedMedia(time.Now().Add(-repo.MEDIA_GC_GRACE_PERIOD))
+	if err != nil {
+		return result, err
+	}
+
+	for _, media := range unused {
+		if !dryRun {
+			deleted, err := repo.DeleteUnusedMedia(media.ID)
+			if err != nil {
+				return result, err
+			}
+
+			if !deleted {
+				continue
+			}
+			s.deleteMediaFile(media.Key)
+		}
+		result.Unused = append(result.Unused, media)
+	}
+
+	// Files are listed before the records are loaded. Uploads save their
+	// record first, so a file still being uploaded always has one.
+	stored, err := s.ms.List(MEDIA_KEY_PREFIX)
+	if err != nil {
+		return result, err
+	}
+
+	keys, err := repo.GetMediaKeys()
+	if err != nil {
+		return result, err
+	}
+
+	for _, key := range stored {
+		if keys[key] {
+			continue
+		}
+
+		if !dryRun {
+			s.deleteMediaFile(key)
+		}
+		result.Orphaned = append(result.Orphaned, key)
+	}
+	return result, nil
+}
+
+func (s *Server) deleteMediaFile(key string) {
+	if err := s.ms.Delete(key); err != nil && err != utils.ErrBlobNotFound {
+		log.Printf("failed to delete media file %s: %v", key, err)
+	}
+}
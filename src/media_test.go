@@ -0,0 +1,323 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+)
+
+func TestUploadMedia(t *testing.T) {
+	savedGetRequestUserID := getRequestUserID
+	savedCreateMedia := repo.CreateMedia
+	savedMediaUploadLimiter := mediaUploadLimiter
+
+	defer func() {
+		getRequestUserID = savedGetRequestUserID
+		repo.CreateMedia = savedCreateMedia
+		mediaUploadLimiter = savedMediaUploadLimiter
+	}()
+
+	var image120x80 bytes.Buffer
+	png.Encode(&image120x80, image.NewRGBA(image.Rect(0, 0, 120, 80)))
+
+	createMedia := func(created bool) func(dto repo.CreateMediaDto) (repo.Media, bool, error) {
+		return func(dto repo.CreateMediaDto) (repo.Media, bool, error) {
+			return repo.Media{ID: 1, Key: dto.Key, URL: dto.URL, ContentType: dto.ContentType, Size: dto.Size, Width: dto.Width, Height: dto.Height, AttributionName: dto.AttributionName, AttributionURL: dto.AttributionURL}, created, nil
+		}
+	}
+
+	tt := []struct {
+		name           string
+		getUserID      func(request *http.Request) (int, error)
+		createMedia    func(dto repo.CreateMediaDto) (repo.Media, bool, error)
+		limited        bool
+		file           []byte
+		attributionURL string
+		status         int
+		stored         bool
+	}{
+		{
+			name: "unauthorized",
+			getUserID: func(request *http.Request) (int, error) {
+				return 0, errors.New("test")
+			},
+			file:   image120x80.Bytes(),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "too many uploads",
+			limited: true,
+			file:    image120x80.Bytes(),
+			status:  http.StatusTooManyRequests,
+		},
+		{
+			name:   "missing file",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too large",
+			file:   make([]byte, MAX_MEDIA_SIZE+MAX_MEDIA_FORM_OVERHEAD),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "svg",
+			file:   []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "invalid png",
+			file:   image120x80.Bytes()[:30],
+			status: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid attribution url",
+			file:           image120x80.Bytes(),
+			attributionURL: "javascript:alert(1)",
+			status:         http.StatusBadRequest,
+		},
+		{
+			name: "error on CreateMedia",
+			createMedia: func(dto repo.CreateMediaDto) (repo.Media, bool, error) {
+				return repo.Media{}, false, errors.New("test")
+			},
+			file:   image120x80.Bytes(),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:           "already uploaded",
+			createMedia:    createMedia(false),
+			file:           image120x80.Bytes(),
+			attributionURL: "https://commons.wikimedia.org",
+			status:         http.StatusOK,
+		},
+		{
+			name: "already uploaded with a different attribution",
+			createMedia: func(dto repo.CreateMediaDto) (repo.Media, bool, error) {
+				return repo.Media{ID: 1, Key: dto.Key, AttributionName: "Someone else"}, false, nil
+			},
+			file:           image120x80.Bytes(),
+			attributionURL: "https://commons.wikimedia.org",
+			status:         http.StatusConflict,
+		},
+		{
+			name:           "happy path",
+			createMedia:    createMedia(true),
+			file:           image120x80.Bytes(),
+			attributionURL: "https://commons.wikimedia.org",
+			status:         http.StatusCreated,
+			stored:         true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+			if tc.getUserID != nil {
+				getRequestUserID = tc.getUserID
+			}
+			repo.CreateMedia = tc.createMedia
+			mediaUploadLimiter = utils.NewRateLimiter(MEDIA_UPLOAD_LIMIT, MEDIA_UPLOAD_WINDOW, MEDIA_UPLOAD_MAX_KEYS)
+			if tc.limited {
+				for i := 0; i < MEDIA_UPLOAD_LIMIT; i++ {
+					mediaUploadLimiter.Allow("1")
+				}
+			}
+
+			store := utils.NewFakeBlobStore("https://cdn.geobuff.com")
+			s := getMockServer()
+			s.ms = store
+
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			form.WriteField("attributionName", "Wikimedia Commons")
+			form.WriteField("attributionUrl", tc.attributionURL)
+			if tc.file != nil {
+				part, _ := form.CreateFormFile("file", "upload.png")
+				part.Write(tc.file)
+			}
+			form.Close()
+
+			request, err := http.NewRequest("POST", "", &body)
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+			request.Header.Set("Content-Type", form.FormDataContentType())
+
+			writer := httptest.NewRecorder()
+			s.uploadMedia(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if len(store.Keys()) > 0 != tc.stored {
+				t.Errorf("expected stored to be %v; got %v", tc.stored, store.Keys())
+			}
+
+			if tc.status != http.StatusOK && tc.status != http.StatusCreated {
+				return
+			}
+
+			var media repo.Media
+			if err = json.NewDecoder(result.Body).Decode(&media); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if !strings.HasPrefix(media.Key, MEDIA_KEY_PREFIX) || !strings.HasSuffix(media.Key, ".png") || media.URL != "https://cdn.geobuff.com/"+media.Key {
+				t.Errorf("unexpected key or url %s %s", media.Key, media.URL)
+			}
+
+			if media.ContentType != "image/png" || media.Width != 120 || media.Height != 80 || media.AttributionName != "Wikimedia Commons" || media.AttributionURL != tc.attributionURL {
+				t.Errorf("unexpected media %+v", media)
+			}
+		})
+	}
+}
+
+func TestCollectMedia(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetUnusedMedia := repo.GetUnusedMedia
+	savedDeleteUnusedMedia := repo.DeleteUnusedMedia
+	savedGetMediaKeys := repo.GetMediaKeys
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetUnusedMedia = savedGetUnusedMedia
+		repo.DeleteUnusedMedia = savedDeleteUnusedMedia
+		repo.GetMediaKeys = savedGetMediaKeys
+	}()
+
+	getUnusedMedia := func(cutoff time.Time) ([]repo.Media, error) {
+		if time.Since(cutoff) < repo.MEDIA_GC_GRACE_PERIOD {
+			return nil, errors.New("expected grace period for new uploads")
+		}
+		return []repo.Media{{ID: 1, Key: "media/unused.png"}, {ID: 2, Key: "media/now-used.png"}}, nil
+	}
+
+	deleteUnusedMedia := func(id int) (bool, error) {
+		return id == 1, nil
+	}
+
+	getMediaKeys := func() (map[string]bool, error) {
+		return map[string]bool{"media/used.png": true, "media/now-used.png": true}, nil
+	}
+
+	tt := []struct {
+		name              string
+		getUnusedMedia    func(cutoff time.Time) ([]repo.Media, error)
+		deleteUnusedMedia func(id int) (bool, error)
+		getMediaKeys      func() (map[string]bool, error)
+		dryRun            bool
+		status            int
+		unused            int
+		orphaned          int
+		remaining         []string
+	}{
+		{
+			name: "error on GetUnusedMedia",
+			getUnusedMedia: func(cutoff time.Time) ([]repo.Media, error) {
+				return nil, errors.New("test")
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:           "error on DeleteUnusedMedia",
+			getUnusedMedia: getUnusedMedia,
+			deleteUnusedMedia: func(id int) (bool, error) {
+				return false, errors.New("test")
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:              "dry run",
+			getUnusedMedia:    getUnusedMedia,
+			deleteUnusedMedia: deleteUnusedMedia,
+			getMediaKeys: func() (map[string]bool, error) {
+				return map[string]bool{"media/unused.png": true, "media/used.png": true, "media/now-used.png": true}, nil
+			},
+			dryRun:    true,
+			status:    http.StatusOK,
+			unused:    2,
+			orphaned:  1,
+			remaining: []string{"flags/world-countries/nz.svg", "media/now-used.png", "media/orphan.png", "media/unused.png", "media/used.png"},
+		},
+		{
+			name:              "happy path",
+			getUnusedMedia:    getUnusedMedia,
+			deleteUnusedMedia: deleteUnusedMedia,
+			getMediaKeys:      getMediaKeys,
+			status:            http.StatusOK,
+			unused:            1,
+			orphaned:          1,
+			remaining:         []string{"flags/world-countries/nz.svg", "media/now-used.png", "media/used.png"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+			repo.GetUnusedMedia = tc.getUnusedMedia
+			repo.DeleteUnusedMedia = tc.deleteUnusedMedia
+			repo.GetMediaKeys = tc.getMediaKeys
+
+			store := utils.NewFakeBlobStore("/api/assets")
+			for _, key := range []string{"flags/world-countries/nz.svg", "media/unused.png", "media/used.png", "media/now-used.png", "media/orphan.png"} {
+				store.Put(key, "image/png", []byte(key))
+			}
+			s := getMockServer()
+			s.ms = store
+
+			url := "/api/admin/media/gc"
+			if tc.dryRun {
+				url += "?dryRun=true"
+			}
+
+			request, err := http.NewRequest("POST", url, nil)
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			s.collectMedia(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var parsed MediaGCResultDto
+			if err = json.NewDecoder(result.Body).Decode(&parsed); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if parsed.DryRun != tc.dryRun || len(parsed.Unused) != tc.unused || len(parsed.Orphaned) != tc.orphaned {
+				t.Errorf("unexpected result %+v", parsed)
+			}
+
+			remaining := store.Keys()
+			sort.Strings(remaining)
+			if strings.Join(remaining, ",") != strings.Join(tc.remaining, ",") {
+				t.Errorf("expected %v to remain; got %v", tc.remaining, remaining)
+			}
+		})
+	}
+}
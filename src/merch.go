@@ -2,20 +2,50 @@ package src
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
 )
 
+// GetMerch shows prices in the ?currency= or ?country= currency, using prices
+// set for the currency where there are any.
 func GetMerch(writer http.ResponseWriter, request *http.Request) {
+	currency, code, err := getRequestCurrency(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
 	merch, err := repo.GetMerch()
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
 
+	prices := map[int]float64{}
+	if currency.Code != utils.BASE_CURRENCY {
+		prices, err = repo.GetMerchPrices(currency.Code)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for index, entry := range merch {
+		merch[index].Currency = currency.Code
+		if price, found := prices[entry.ID]; found && entry.Price.Valid {
+			merch[index].Price.Float64 = price
+		} else if entry.Price.Valid {
+			merch[index].Price.Float64 = utils.ConvertPrice(entry.Price.Float64, currency)
+		}
+	}
+
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(merch)
 }
@@ -43,3 +73,355 @@ func MerchExists(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(exists)
 }
+
+func (s *Server) createMerch(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchDetailsDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerch(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merch, err := repo.CreateMerch(userID, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Route already in use. Please choose another and try again.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(merch)
+}
+
+func (s *Server) updateMerch(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchDetailsDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerch(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merch, err := repo.UpdateMerch(userID, id, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Route already in use. Please choose another and try again.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(merch)
+}
+
+func DeleteMerch(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err = repo.DeleteMerch(userID, id); err != nil {
+		writeAdminChangeError(writer, err, "Merch has existing orders and can't be deleted. Set its stock to zero instead.")
+		return
+	}
+}
+
+func (s *Server) validateMerch(dto repo.MerchDetailsDto) error {
+	if err := s.vs.GetValidator().Struct(dto); err != nil {
+		return err
+	}
+
+	if dto.Price.Valid && dto.Price.Float64 < 0 {
+		return errors.New("price can't be negative")
+	}
+	return nil
+}
+
+func (s *Server) createMerchSize(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchSizeDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerchSize(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merchSize, err := repo.CreateMerchSize(userID, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(merchSize)
+}
+
+func (s *Server) updateMerchSize(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchSizeDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerchSize(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merchSize, err := repo.UpdateMerchSize(userID, id, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(merchSize)
+}
+
+func DeleteMerchSize(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err = repo.DeleteMerchSize(userID, id); err != nil {
+		writeAdminChangeError(writer, err, "Size has existing orders and can't be deleted. Set its stock to zero instead.")
+		return
+	}
+}
+
+func (s *Server) validateMerchSize(dto repo.MerchSizeDto) error {
+	return s.vs.GetValidator().Struct(dto)
+}
+
+func (s *Server) createMerchImage(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchImageDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerchImage(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merchImage, err := repo.CreateMerchImage(userID, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(merchImage)
+}
+
+func (s *Server) updateMerchImage(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.MerchImageDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.validateMerchImage(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	merchImage, err := repo.UpdateMerchImage(userID, id, dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "Merch item does not exist.")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(merchImage)
+}
+
+func DeleteMerchImage(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err = repo.DeleteMerchImage(userID, id); err != nil {
+		writeAdminChangeError(writer, err, "Image is still in use and can't be deleted.")
+		return
+	}
+}
+
+func (s *Server) validateMerchImage(dto repo.MerchImageDto) error {
+	return s.vs.GetValidator().Struct(dto)
+}
@@ -1,6 +1,8 @@
 package src
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -9,29 +11,69 @@ import (
 	"testing"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 func TestGetMerch(t *testing.T) {
 	savedGetMerch := repo.GetMerch
+	savedGetCurrency := repo.GetCurrency
+	savedGetMerchPrices := repo.GetMerchPrices
 
 	defer func() {
 		repo.GetMerch = savedGetMerch
+		repo.GetCurrency = savedGetCurrency
+		repo.GetMerchPrices = savedGetMerchPrices
 	}()
 
+	repo.GetCurrency = func(code string) (utils.Currency, error) {
+		if code != "aud" {
+			return utils.Currency{}, sql.ErrNoRows
+		}
+		return utils.Currency{Code: "AUD", Rate: 0.9, RoundingIncrement: 0.01}, nil
+	}
+	repo.GetMerchPrices = func(currencyCode string) (map[int]float64, error) {
+		return map[int]float64{2: 19}, nil
+	}
+
+	merch := func() ([]repo.MerchDto, error) {
+		return []repo.MerchDto{
+			{ID: 1, Price: sql.NullFloat64{Float64: 10, Valid: true}},
+			{ID: 2, Price: sql.NullFloat64{Float64: 20, Valid: true}},
+		}, nil
+	}
+
 	tt := []struct {
 		name     string
 		getMerch func() ([]repo.MerchDto, error)
+		query    string
 		status   int
+		prices   []float64
 	}{
 		{
 			name:     "error on GetMerch",
 			getMerch: func() ([]repo.MerchDto, error) { return nil, errors.New("test") },
 			status:   http.StatusInternalServerError,
 		},
+		{
+			name:     "unsupported currency",
+			getMerch: merch,
+			query:    "?currency=jpy",
+			status:   http.StatusBadRequest,
+		},
 		{
 			name:     "happy path",
-			getMerch: func() ([]repo.MerchDto, error) { return []repo.MerchDto{}, nil },
+			getMerch: merch,
 			status:   http.StatusOK,
+			prices:   []float64{10, 20},
+		},
+		{
+			name:     "happy path, other currency",
+			getMerch: merch,
+			query:    "?currency=aud",
+			status:   http.StatusOK,
+			prices:   []float64{9, 19},
 		},
 	}
 
@@ -39,7 +81,7 @@ func TestGetMerch(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			repo.GetMerch = tc.getMerch
 
-			request, err := http.NewRequest("GET", "", nil)
+			request, err := http.NewRequest("GET", tc.query, nil)
 			if err != nil {
 				t.Fatalf("could not create GET request: %v", err)
 			}
@@ -59,7 +101,132 @@ func TestGetMerch(t *testing.T) {
 					t.Fatalf("could not read response: %v", err)
 				}
 
-				var parsed []repo.Merch
+				var parsed []repo.MerchDto
+				err = json.Unmarshal(body, &parsed)
+				if err != nil {
+					t.Errorf("could not unmarshal response body: %v", err)
+				}
+
+				for index, price := range tc.prices {
+					if parsed[index].Price.Float64 != price {
+						t.Errorf("expected price %v; got %v", price, parsed[index].Price.Float64)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMerch(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetRequestUserID := getRequestUserID
+	savedCreateMerch := repo.CreateMerch
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		getRequestUserID = savedGetRequestUserID
+		repo.CreateMerch = savedCreateMerch
+	}()
+
+	getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+
+	tt := []struct {
+		name        string
+		isAdmin     func(request *http.Request) (int, error)
+		createMerch func(userID int, merch repo.MerchDetailsDto) (repo.Merch, error)
+		body        string
+		status      int
+	}{
+		{
+			name:        "invalid permissions",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			createMerch: repo.CreateMerch,
+			body:        "",
+			status:      http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid body",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: repo.CreateMerch,
+			body:        "testing",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "missing name",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: repo.CreateMerch,
+			body:        `{"description": "test", "route": "geobuff-tee"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid route",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: repo.CreateMerch,
+			body:        `{"name": "Tee", "description": "test", "route": "GeoBuff Tee"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "negative price",
+			isAdmin:     func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: repo.CreateMerch,
+			body:        `{"name": "Tee", "description": "test", "route": "geobuff-tee", "price": {"Float64": -1, "Valid": true}}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:    "route already in use",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: func(userID int, merch repo.MerchDetailsDto) (repo.Merch, error) {
+				return repo.Merch{}, &pq.Error{Code: "23505"}
+			},
+			body:   `{"name": "Tee", "description": "test", "route": "geobuff-tee"}`,
+			status: http.StatusConflict,
+		},
+		{
+			name:    "error on CreateMerch",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: func(userID int, merch repo.MerchDetailsDto) (repo.Merch, error) {
+				return repo.Merch{}, errors.New("test")
+			},
+			body:   `{"name": "Tee", "description": "test", "route": "geobuff-tee"}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:    "happy path",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			createMerch: func(userID int, merch repo.MerchDetailsDto) (repo.Merch, error) {
+				return repo.Merch{ID: 1, Name: merch.Name, Description: merch.Description, Route: merch.Route}, nil
+			},
+			body:   `{"name": "Tee", "description": "test", "route": "geobuff-tee", "price": {"Float64": 29.99, "Valid": true}}`,
+			status: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.CreateMerch = tc.createMerch
+
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			getMockServer().createMerch(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusCreated {
+				body, err := ioutil.ReadAll(result.Body)
+				if err != nil {
+					t.Fatalf("could not read response: %v", err)
+				}
+
+				var parsed repo.Merch
 				err = json.Unmarshal(body, &parsed)
 				if err != nil {
 					t.Errorf("could not unmarshal response body: %v", err)
@@ -68,3 +235,74 @@ func TestGetMerch(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteMerchSize(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetRequestUserID := getRequestUserID
+	savedDeleteMerchSize := repo.DeleteMerchSize
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		getRequestUserID = savedGetRequestUserID
+		repo.DeleteMerchSize = savedDeleteMerchSize
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+
+	tt := []struct {
+		name            string
+		deleteMerchSize func(userID, sizeID int) error
+		id              string
+		status          int
+	}{
+		{
+			name:            "invalid id",
+			deleteMerchSize: repo.DeleteMerchSize,
+			id:              "testing",
+			status:          http.StatusBadRequest,
+		},
+		{
+			name:            "size not found",
+			deleteMerchSize: func(userID, sizeID int) error { return sql.ErrNoRows },
+			id:              "1",
+			status:          http.StatusNotFound,
+		},
+		{
+			name:            "size has orders",
+			deleteMerchSize: func(userID, sizeID int) error { return &pq.Error{Code: "23503"} },
+			id:              "1",
+			status:          http.StatusConflict,
+		},
+		{
+			name:            "happy path",
+			deleteMerchSize: func(userID, sizeID int) error { return nil },
+			id:              "1",
+			status:          http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.DeleteMerchSize = tc.deleteMerchSize
+
+			request, err := http.NewRequest("DELETE", "", nil)
+			if err != nil {
+				t.Fatalf("could not create DELETE request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"id": tc.id,
+			})
+
+			writer := httptest.NewRecorder()
+			DeleteMerchSize(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+// ORDER_ACCESS_AUDIENCE marks tokens that only grant read access to a guest's
+// orders. They are signed with a key derived from AUTH_SIGNING_KEY so they
+// can't be used as login tokens, or the other way around.
+const ORDER_ACCESS_AUDIENCE = "orders"
+
+// ORDER_ACCESS_LINK_DURATION is how long a magic link to view orders is valid.
+const ORDER_ACCESS_LINK_DURATION = 7 * 24 * time.Hour
+
+// Requests for order access links are throttled per email so an address can't
+// be spammed, and per client IP so emails can't be enumerated.
+const (
+	ORDER_ACCESS_LINK_EMAIL_LIMIT = 3
+	ORDER_ACCESS_LINK_IP_LIMIT    = 10
+	ORDER_ACCESS_LINK_WINDOW      = time.Hour
+	ORDER_ACCESS_LINK_MAX_KEYS    = 10000
+)
+
+type OrderAccessClaims struct {
+	Email string `json:"email"`
+	jwt.StandardClaims
+}
+
+type OrderAccessLinkDto struct {
+	Email string `json:"email"`
+}
+
+type OrderAccessTokenDto struct {
+	Token string `json:"token"`
+}
+
+var errInvalidOrderAccessToken = errors.New("order access link is invalid or has expired")
+
+var orderAccessLinkEmailLimiter = utils.NewRateLimiter(ORDER_ACCESS_LINK_EMAIL_LIMIT, ORDER_ACCESS_LINK_WINDOW, ORDER_ACCESS_LINK_MAX_KEYS)
+var orderAccessLinkIPLimiter = utils.NewRateLimiter(ORDER_ACCESS_LINK_IP_LIMIT, ORDER_ACCESS_LINK_WINDOW, ORDER_ACCESS_LINK_MAX_KEYS)
+
+// GetGuestOrders returns the orders for the email in a magic link token.
+// Logged in users go through the link too, since account emails aren't
+// verified and could be set to someone else's address.
+func GetGuestOrders(writer http.ResponseWriter, request *http.Request) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto OrderAccessTokenDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	email, err := getOrderAccessEmail(dto.Token, time.Now())
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusUnauthorized)
+		return
+	}
+
+	orders, err := repo.GetNonPendingOrders(email)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(orders)
+}
+
+// sendOrderAccessLink emails a fresh magic link if the address has any orders.
+// Every valid request gets the same empty response, whether or not the address
+// has orders or the email failed to send, so it can't be used to probe for
+// customers.
+func (s *Server) sendOrderAccessLink(writer http.ResponseWriter, request *http.Request) {
+	if !orderAccessLinkIPLimiter.Allow(getRequestIP(request)) {
+		http.Error(writer, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto OrderAccessLinkDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(dto.Email)
+	if err = s.vs.GetValidator().Var(email, "required,email"); err != nil {
+		http.Error(writer, "Please enter a valid email address.", http.StatusBadRequest)
+		return
+	}
+
+	if !orderAccessLinkEmailLimiter.Allow(strings.ToLower(email)) {
+		http.Error(writer, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	hasOrders, err := repo.HasNonPendingOrders(email)
+	if err != nil {
+		log.Printf("failed to check orders for access link: %v", err)
+		return
+	}
+
+	if !hasOrders {
+		return
+	}
+
+	link, err := buildOrderAccessLink(email, time.Now())
+	if err != nil {
+		log.Printf("failed to build order access link: %v", err)
+		return
+	}
+
+	if _, err = s.es.SendOrderAccessLink(email, link); err != nil {
+		log.Printf("failed to send order access link: %v", err)
+	}
+}
+
+// CancelOrder abandons a pending order using the token from the checkout
+// cancel URL.
+func CancelOrder(writer http.ResponseWriter, request *http.Request) {
+	err := repo.CancelPendingOrder(mux.Vars(request)["token"])
+	if err == sql.ErrNoRows {
+		http.Error(writer, "Order does not exist or is no longer pending.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+// getRequestIP returns the client IP. App Engine sets X-Appengine-User-IP and
+// strips it from incoming requests, so it can be trusted when present.
+func getRequestIP(request *http.Request) string {
+	if ip := request.Header.Get("X-Appengine-User-IP"); ip != "" {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+func buildOrderAccessLink(email string, now time.Time) (string, error) {
+	token, err := buildOrderAccessToken(email, now)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/orders?token=%s", os.Getenv("SITE_URL"), url.QueryEscape(token)), nil
+}
+
+var buildOrderAccessToken = func(email string, now time.Time) (string, error) {
+	claims := OrderAccessClaims{
+		Email: email,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  ORDER_ACCESS_AUDIENCE,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ORDER_ACCESS_LINK_DURATION).Unix(),
+			Issuer:    os.Getenv("AUTH_ISSUER"),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(orderAccessSigningKey())
+}
+
+var getOrderAccessEmail = func(tokenString string, now time.Time) (string, error) {
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, &OrderAccessClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return orderAccessSigningKey(), nil
+	})
+	if err != nil || !token.Valid {
+		return "", errInvalidOrderAccessToken
+	}
+
+	claims, ok := token.Claims.(*OrderAccessClaims)
+	if !ok || claims.Email == "" || !claims.VerifyAudience(ORDER_ACCESS_AUDIENCE, true) || !claims.VerifyExpiresAt(now.Unix(), true) {
+		return "", errInvalidOrderAccessToken
+	}
+	return claims.Email, nil
+}
+
+func orderAccessSigningKey() []byte {
+	return []byte(os.Getenv("AUTH_SIGNING_KEY") + ":" + ORDER_ACCESS_AUDIENCE)
+}
+
+// orderAccessLink is best effort so a signing failure never blocks the payment
+// confirmation email.
+func orderAccessLink(email string) string {
+	link, err := buildOrderAccessLink(email, time.Now())
+	if err != nil {
+		log.Printf("failed to build order access link: %v", err)
+		return ""
+	}
+	return link
+}
@@ -0,0 +1,303 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestOrderAccessToken(t *testing.T) {
+	savedKey := os.Getenv("AUTH_SIGNING_KEY")
+	defer os.Setenv("AUTH_SIGNING_KEY", savedKey)
+	os.Setenv("AUTH_SIGNING_KEY", "testing")
+
+	now := time.Now()
+	token, err := buildOrderAccessToken("test@gmail.com", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loginToken, err := buildToken(repo.AuthUserDto{ID: 1, Email: "test@gmail.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tt := []struct {
+		name  string
+		token string
+		now   time.Time
+		email string
+	}{
+		{
+			name:  "valid token",
+			token: token,
+			now:   now,
+			email: "test@gmail.com",
+		},
+		{
+			name:  "expired token",
+			token: token,
+			now:   now.Add(ORDER_ACCESS_LINK_DURATION + time.Minute),
+			email: "",
+		},
+		{
+			name:  "tampered token",
+			token: token + "x",
+			now:   now,
+			email: "",
+		},
+		{
+			name:  "login token",
+			token: loginToken,
+			now:   now,
+			email: "",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			email, err := getOrderAccessEmail(tc.token, tc.now)
+			if email != tc.email {
+				t.Errorf("expected email %v; got %v", tc.email, email)
+			}
+
+			if tc.email == "" && err == nil {
+				t.Error("expected error; got nil")
+			}
+		})
+	}
+}
+
+func TestGetGuestOrders(t *testing.T) {
+	savedGetOrderAccessEmail := getOrderAccessEmail
+	savedGetNonPendingOrders := repo.GetNonPendingOrders
+
+	defer func() {
+		getOrderAccessEmail = savedGetOrderAccessEmail
+		repo.GetNonPendingOrders = savedGetNonPendingOrders
+	}()
+
+	tt := []struct {
+		name                string
+		getOrderAccessEmail func(tokenString string, now time.Time) (string, error)
+		getNonPendingOrders func(email string) ([]repo.OrderDto, error)
+		body                string
+		status              int
+	}{
+		{
+			name:                "invalid body",
+			getOrderAccessEmail: getOrderAccessEmail,
+			getNonPendingOrders: repo.GetNonPendingOrders,
+			body:                "testing",
+			status:              http.StatusBadRequest,
+		},
+		{
+			name: "invalid token",
+			getOrderAccessEmail: func(tokenString string, now time.Time) (string, error) {
+				return "", errInvalidOrderAccessToken
+			},
+			getNonPendingOrders: repo.GetNonPendingOrders,
+			body:                `{"token": "testing"}`,
+			status:              http.StatusUnauthorized,
+		},
+		{
+			name: "error on GetNonPendingOrders",
+			getOrderAccessEmail: func(tokenString string, now time.Time) (string, error) {
+				return "test@gmail.com", nil
+			},
+			getNonPendingOrders: func(email string) ([]repo.OrderDto, error) { return nil, errors.New("test") },
+			body:                `{"token": "testing"}`,
+			status:              http.StatusInternalServerError,
+		},
+		{
+			name: "happy path",
+			getOrderAccessEmail: func(tokenString string, now time.Time) (string, error) {
+				return "test@gmail.com", nil
+			},
+			getNonPendingOrders: func(email string) ([]repo.OrderDto, error) { return []repo.OrderDto{}, nil },
+			body:                `{"token": "testing"}`,
+			status:              http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			getOrderAccessEmail = tc.getOrderAccessEmail
+			repo.GetNonPendingOrders = tc.getNonPendingOrders
+
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			GetGuestOrders(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSendOrderAccessLink(t *testing.T) {
+	savedHasNonPendingOrders := repo.HasNonPendingOrders
+	savedEmailLimiter := orderAccessLinkEmailLimiter
+	savedIPLimiter := orderAccessLinkIPLimiter
+	defer func() {
+		repo.HasNonPendingOrders = savedHasNonPendingOrders
+		orderAccessLinkEmailLimiter = savedEmailLimiter
+		orderAccessLinkIPLimiter = savedIPLimiter
+	}()
+
+	tt := []struct {
+		name                string
+		hasNonPendingOrders func(email string) (bool, error)
+		body                string
+		requests            int
+		status              int
+		emails              int
+	}{
+		{
+			name:                "invalid email",
+			hasNonPendingOrders: repo.HasNonPendingOrders,
+			body:                `{"email": "testing"}`,
+			requests:            1,
+			status:              http.StatusBadRequest,
+		},
+		{
+			name:                "error on HasNonPendingOrders",
+			hasNonPendingOrders: func(email string) (bool, error) { return false, errors.New("test") },
+			body:                `{"email": "test@gmail.com"}`,
+			requests:            1,
+			status:              http.StatusOK,
+		},
+		{
+			name:                "no orders",
+			hasNonPendingOrders: func(email string) (bool, error) { return false, nil },
+			body:                `{"email": "test@gmail.com"}`,
+			requests:            1,
+			status:              http.StatusOK,
+			emails:              0,
+		},
+		{
+			name:                "too many requests for email",
+			hasNonPendingOrders: func(email string) (bool, error) { return true, nil },
+			body:                `{"email": "Test@gmail.com"}`,
+			requests:            ORDER_ACCESS_LINK_EMAIL_LIMIT + 1,
+			status:              http.StatusTooManyRequests,
+			emails:              ORDER_ACCESS_LINK_EMAIL_LIMIT,
+		},
+		{
+			name:                "too many requests for ip",
+			hasNonPendingOrders: func(email string) (bool, error) { return true, nil },
+			body:                `{"email": "testing"}`,
+			requests:            ORDER_ACCESS_LINK_IP_LIMIT + 1,
+			status:              http.StatusTooManyRequests,
+		},
+		{
+			name:                "happy path",
+			hasNonPendingOrders: func(email string) (bool, error) { return true, nil },
+			body:                `{"email": "test@gmail.com"}`,
+			requests:            1,
+			status:              http.StatusOK,
+			emails:              1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.HasNonPendingOrders = tc.hasNonPendingOrders
+			orderAccessLinkEmailLimiter = utils.NewRateLimiter(ORDER_ACCESS_LINK_EMAIL_LIMIT, ORDER_ACCESS_LINK_WINDOW, ORDER_ACCESS_LINK_MAX_KEYS)
+			orderAccessLinkIPLimiter = utils.NewRateLimiter(ORDER_ACCESS_LINK_IP_LIMIT, ORDER_ACCESS_LINK_WINDOW, ORDER_ACCESS_LINK_MAX_KEYS)
+
+			es := &mockEmailService{}
+			server := getMockServerWithServices(es, utils.NewFakePaymentProvider("whsec_test"))
+			var result *http.Response
+			for i := 0; i < tc.requests; i++ {
+				request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+				if err != nil {
+					t.Fatalf("could not create POST request: %v", err)
+				}
+				request.Header.Set("X-Appengine-User-IP", "127.0.0.1")
+
+				writer := httptest.NewRecorder()
+				server.sendOrderAccessLink(writer, request)
+				result = writer.Result()
+				result.Body.Close()
+			}
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if len(es.accessLinks) != tc.emails {
+				t.Errorf("expected %v emails; got %v", tc.emails, len(es.accessLinks))
+			}
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	savedCancelPendingOrder := repo.CancelPendingOrder
+	defer func() {
+		repo.CancelPendingOrder = savedCancelPendingOrder
+	}()
+
+	tt := []struct {
+		name               string
+		cancelPendingOrder func(cancelToken string) error
+		status             int
+	}{
+		{
+			name:               "order not found",
+			cancelPendingOrder: func(cancelToken string) error { return sql.ErrNoRows },
+			status:             http.StatusNotFound,
+		},
+		{
+			name:               "error on CancelPendingOrder",
+			cancelPendingOrder: func(cancelToken string) error { return errors.New("test") },
+			status:             http.StatusInternalServerError,
+		},
+		{
+			name:               "happy path",
+			cancelPendingOrder: func(cancelToken string) error { return nil },
+			status:             http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.CancelPendingOrder = tc.cancelPendingOrder
+
+			request, err := http.NewRequest("DELETE", "", nil)
+			if err != nil {
+				t.Fatalf("could not create DELETE request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"token": "testing",
+			})
+
+			writer := httptest.NewRecorder()
+			CancelOrder(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
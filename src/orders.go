@@ -2,13 +2,20 @@ package src
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
 	"github.com/gorilla/mux"
 )
 
@@ -18,7 +25,13 @@ type OrderPageDto struct {
 }
 
 type UpdateOrderStatusDto struct {
-	StatusID int `json:"statusId"`
+	StatusID       int    `json:"statusId"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"trackingNumber"`
+}
+
+type RefundOrderDto struct {
+	Restock bool `json:"restock"`
 }
 
 func GetOrders(writer http.ResponseWriter, request *http.Request) {
@@ -60,30 +73,79 @@ func GetOrders(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
-func GetUserOrders(writer http.ResponseWriter, request *http.Request) {
-	email := mux.Vars(request)["email"]
-	user, err := repo.GetAuthUserUsingEmail(email)
+func (s *Server) updateOrderStatus(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	if code, err := ValidUser(request, user.ID); err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	orders, err := repo.GetNonPendingOrders(email)
+	var dto UpdateOrderStatusDto
+	err = json.Unmarshal(requestBody, &dto)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
+	if dto.StatusID == repo.ORDER_STATUS_REFUNDED {
+		http.Error(writer, "Use the refund endpoint to refund an order.", http.StatusBadRequest)
+		return
+	}
+
+	if dto.StatusID == repo.ORDER_STATUS_CANCELLED {
+		if code, err := s.expireOrderCheckout(id); err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), code)
+			return
+		}
+	}
+
+	summary, err := repo.TransitionOrderStatus(id, dto.StatusID, strings.TrimSpace(dto.Carrier), strings.TrimSpace(dto.TrackingNumber))
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), orderTransitionErrorStatus(err))
+		return
+	}
+
+	s.sendOrderStatusEmail(summary)
 	writer.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(writer).Encode(orders)
+	json.NewEncoder(writer).Encode(summary)
+}
+
+// expireOrderCheckout closes the checkout session of a pending order before
+// it's cancelled, so the customer can't pay for an order that no longer exists.
+func (s *Server) expireOrderCheckout(orderID int) (int, error) {
+	payment, err := repo.GetOrderPayment(orderID)
+	if err != nil {
+		return orderTransitionErrorStatus(err), err
+	}
+
+	if payment.StatusID != repo.ORDER_STATUS_PENDING || !payment.CheckoutSessionID.Valid || payment.CheckoutSessionID.String == "" {
+		return http.StatusOK, nil
+	}
+
+	err = s.pp.ExpireCheckoutSession(payment.CheckoutSessionID.String)
+	if err == utils.ErrCheckoutCompleted {
+		return http.StatusConflict, err
+	} else if err != nil {
+		return http.StatusBadGateway, err
+	}
+	return http.StatusOK, nil
 }
 
-func UpdateOrderStatus(writer http.ResponseWriter, request *http.Request) {
+// refundOrder refunds the payment with the provider before recording it, so an order
+// is never marked refunded unless the money has actually been returned. The
+// order is locked while the provider is called, so it's only refunded once.
+func (s *Server) refundOrder(writer http.ResponseWriter, request *http.Request) {
 	if code, err := IsAdmin(request); err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), code)
 		return
@@ -101,18 +163,114 @@ func UpdateOrderStatus(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	var dto UpdateOrderStatusDto
+	var dto RefundOrderDto
 	err = json.Unmarshal(requestBody, &dto)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
 		return
 	}
 
-	err = repo.UpdateOrderStatus(id, dto.StatusID)
+	var refundID string
+	summary, err := repo.RefundOrder(id, dto.Restock, func(payment repo.OrderPaymentDto) (string, error) {
+		result, err := s.refundPayment(payment)
+		refundID = result
+		return result, err
+	})
+
+	var refundErr refundError
+	if errors.As(err, &refundErr) {
+		http.Error(writer, fmt.Sprintf("%v\n", refundErr.err), refundErr.status)
+		return
+	} else if err != nil {
+		if refundID != "" {
+			log.Printf("refunded order %d with %s but failed to record it: %v", id, refundID, err)
+		}
+		http.Error(writer, fmt.Sprintf("%v\n", err), orderTransitionErrorStatus(err))
+		return
+	}
+
+	s.sendOrderStatusEmail(summary)
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(summary)
+}
+
+// refundError carries the response status for a payment that can't be
+// refunded.
+type refundError struct {
+	status int
+	err    error
+}
+
+func (e refundError) Error() string {
+	return e.err.Error()
+}
+
+func (s *Server) refundPayment(payment repo.OrderPaymentDto) (string, error) {
+	if !payment.PaymentIntentID.Valid || payment.PaymentIntentID.String == "" {
+		return "", refundError{http.StatusBadRequest, errors.New("Order has no recorded payment to refund.")}
+	}
+
+	providerPayment, err := s.pp.GetPayment(payment.PaymentIntentID.String)
 	if err != nil {
-		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return "", refundError{http.StatusBadGateway, err}
+	}
+
+	if providerPayment.Refunded {
+		return "", refundError{http.StatusConflict, utils.ErrPaymentAlreadyRefunded}
+	}
+
+	if providerPayment.Status != utils.PAYMENT_STATUS_SUCCEEDED {
+		return "", refundError{http.StatusBadRequest, errors.New("Payment has not completed so can't be refunded.")}
+	}
+
+	refundID, err := s.pp.Refund(providerPayment.ID)
+	if err != nil {
+		return "", refundError{http.StatusBadGateway, err}
+	}
+	return refundID, nil
+}
+
+func (s *Server) notifyOrderStatus(orderID int) {
+	summary, err := repo.GetOrderSummary(orderID)
+	if err != nil {
+		log.Printf("failed to load order %d for notification: %v", orderID, err)
 		return
 	}
+	s.sendOrderStatusEmail(summary)
+}
+
+// sendOrderStatusEmail is best effort, the status change has already been
+// saved by the time the customer is emailed.
+func (s *Server) sendOrderStatusEmail(summary repo.OrderSummaryDto) {
+	update := utils.OrderStatusEmail{
+		OrderID:        summary.ID,
+		StatusKey:      repo.OrderStatusKey(summary.StatusID),
+		FirstName:      summary.FirstName,
+		Carrier:        summary.Carrier.String,
+		TrackingNumber: summary.TrackingNumber.String,
+		Total:          summary.Total.Float64,
+		Currency:       summary.Currency,
+	}
+
+	if summary.StatusID == repo.ORDER_STATUS_PAYMENT_RECEIVED {
+		update.AccessLink = orderAccessLink(summary.Email)
+	}
+
+	if _, err := s.es.SendOrderStatusUpdate(summary.Email, update); err != nil {
+		log.Printf("failed to email order %d status update: %v", summary.ID, err)
+	}
+}
+
+func orderTransitionErrorStatus(err error) int {
+	var transitionErr repo.InvalidOrderTransitionError
+	switch {
+	case err == sql.ErrNoRows:
+		return http.StatusNotFound
+	case err == repo.ErrMissingTracking, errors.As(err, &transitionErr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 func DeleteOrder(writer http.ResponseWriter, request *http.Request) {
@@ -133,10 +291,81 @@ func DeleteOrder(writer http.ResponseWriter, request *http.Request) {
 	}
 }
 
-func CancelOrder(writer http.ResponseWriter, request *http.Request) {
-	err := repo.RemoveLatestPendingOrder(mux.Vars(request)["email"])
+// shippingLabelCSVHeader uses the column names most label tools map
+// automatically when importing orders.
+var shippingLabelCSVHeader = []string{"Order Number", "Recipient Name", "Email", "Address Line 1", "Address Line 2", "City", "State", "Postal Code", "Country", "Item Count", "Weight (g)", "Shipping Service", "Order Total", "Currency"}
+
+// ExportUnshippedOrders downloads paid orders that haven't been shipped as a
+// CSV that can be imported into a shipping label tool.
+func ExportUnshippedOrders(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	labels, err := repo.GetUnshippedOrders()
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
+
+	writer.Header().Set("Content-Type", "text/csv")
+	writer.Header().Set("Content-Disposition", "attachment; filename=unshipped-orders.csv")
+	if err := writeShippingLabelsCSV(writer, labels); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
+
+func writeShippingLabelsCSV(writer io.Writer, labels []repo.ShippingLabelDto) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(shippingLabelCSVHeader); err != nil {
+		return err
+	}
+
+	for _, label := range labels {
+		var total string
+		if label.Total.Valid {
+			total = strconv.FormatFloat(label.Total.Float64, 'f', 2, 64)
+		}
+
+		record := []string{
+			strconv.Itoa(label.OrderID),
+			strings.TrimSpace(label.FirstName + " " + label.LastName),
+			label.Email,
+			label.Address.Line1,
+			label.Address.Line2,
+			label.Address.City,
+			label.Address.Region,
+			label.Address.Postcode,
+			strings.ToUpper(label.Address.CountryCode),
+			strconv.Itoa(label.Quantity),
+			strconv.Itoa(label.Weight),
+			label.ShippingOption,
+			total,
+			label.Currency,
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// releaseExpiredOrders periodically removes pending orders whose reservation has
+// lapsed, in case Stripe never delivers the session expiry.
+func releaseExpiredOrders(interval time.Duration) {
+	for range time.Tick(interval) {
+		released, err := repo.ReleaseExpiredOrders(time.Now().Add(-repo.ORDER_RESERVATION_GRACE_PERIOD))
+		if err != nil {
+			log.Printf("failed to release expired orders: %v", err)
+			continue
+		}
+
+		if released > 0 {
+			log.Printf("released %d expired orders", released)
+		}
+	}
 }
@@ -0,0 +1,471 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+	"github.com/sendgrid/rest"
+)
+
+type mockEmailService struct {
+	updates     []utils.OrderStatusEmail
+	accessLinks []string
+	stockAlerts [][]utils.LowStockItem
+}
+
+func (m *mockEmailService) SendResetToken(email, resetLink string) (*rest.Response, error) {
+	return nil, nil
+}
+
+func (m *mockEmailService) SendOrderStatusUpdate(email string, update utils.OrderStatusEmail) (*rest.Response, error) {
+	m.updates = append(m.updates, update)
+	return nil, nil
+}
+
+func (m *mockEmailService) SendOrderAccessLink(email, accessLink string) (*rest.Response, error) {
+	m.accessLinks = append(m.accessLinks, accessLink)
+	return nil, nil
+}
+
+func (m *mockEmailService) SendLowStockAlert(email string, items []utils.LowStockItem, threshold int) (*rest.Response, error) {
+	m.stockAlerts = append(m.stockAlerts, items)
+	return nil, nil
+}
+
+func getMockServerWithServices(es utils.IEmailService, pp utils.IPaymentProvider) *Server {
+	return NewServer(utils.NewTranslationService(), es, utils.NewValidationService(), utils.NewPricingService(), pp, utils.NewFakeBlobStore("/api/assets"))
+}
+
+// newFakePayment runs a checkout through the fake provider so there is a
+// settled payment to refund.
+func newFakePayment(t *testing.T, pp *utils.FakePaymentProvider) string {
+	checkoutSession, err := pp.CreateCheckout(utils.CheckoutRequest{
+		OrderID:   1,
+		Currency:  "NZD",
+		LineItems: []utils.PaymentLineItem{{Name: "Tee", UnitPrice: 20, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("could not create checkout: %v", err)
+	}
+
+	payload, header, err := pp.CompleteCheckout(checkoutSession.ID)
+	if err != nil {
+		t.Fatalf("could not complete checkout: %v", err)
+	}
+
+	event, err := pp.VerifyWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("could not verify webhook: %v", err)
+	}
+	return event.PaymentID
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedTransitionOrderStatus := repo.TransitionOrderStatus
+	savedGetOrderPayment := repo.GetOrderPayment
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.TransitionOrderStatus = savedTransitionOrderStatus
+		repo.GetOrderPayment = savedGetOrderPayment
+	}()
+
+	tt := []struct {
+		name                  string
+		isAdmin               func(request *http.Request) (int, error)
+		transitionOrderStatus func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error)
+		checkout              string
+		id                    string
+		body                  string
+		status                int
+		emails                int
+		expired               bool
+	}{
+		{
+			name:                  "invalid permissions",
+			isAdmin:               func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			transitionOrderStatus: repo.TransitionOrderStatus,
+			id:                    "1",
+			body:                  "",
+			status:                http.StatusUnauthorized,
+		},
+		{
+			name:                  "invalid id",
+			isAdmin:               func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: repo.TransitionOrderStatus,
+			id:                    "testing",
+			body:                  "",
+			status:                http.StatusBadRequest,
+		},
+		{
+			name:                  "refund through status endpoint",
+			isAdmin:               func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: repo.TransitionOrderStatus,
+			id:                    "1",
+			body:                  `{"statusId": 7}`,
+			status:                http.StatusBadRequest,
+		},
+		{
+			name:    "invalid transition",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error) {
+				return repo.OrderSummaryDto{}, repo.InvalidOrderTransitionError{From: repo.ORDER_STATUS_PENDING, To: statusID}
+			},
+			id:     "1",
+			body:   `{"statusId": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "missing tracking",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error) {
+				return repo.OrderSummaryDto{}, repo.ErrMissingTracking
+			},
+			id:     "1",
+			body:   `{"statusId": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "order not found",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error) {
+				return repo.OrderSummaryDto{}, sql.ErrNoRows
+			},
+			id:     "1",
+			body:   `{"statusId": 4}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:                  "cancel paid checkout",
+			isAdmin:               func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: repo.TransitionOrderStatus,
+			checkout:              "paid",
+			id:                    "1",
+			body:                  `{"statusId": 6}`,
+			status:                http.StatusConflict,
+		},
+		{
+			name:    "happy path, cancel open checkout",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error) {
+				return repo.OrderSummaryDto{ID: orderID, StatusID: statusID}, nil
+			},
+			checkout: "open",
+			id:       "1",
+			body:     `{"statusId": 6}`,
+			status:   http.StatusOK,
+			emails:   1,
+			expired:  true,
+		},
+		{
+			name:    "happy path",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			transitionOrderStatus: func(orderID, statusID int, carrier, trackingNumber string) (repo.OrderSummaryDto, error) {
+				return repo.OrderSummaryDto{ID: orderID, StatusID: statusID, Carrier: sql.NullString{String: carrier, Valid: true}, TrackingNumber: sql.NullString{String: trackingNumber, Valid: true}}, nil
+			},
+			id:     "1",
+			body:   `{"statusId": 3, "carrier": "NZ Post", "trackingNumber": "AB123"}`,
+			status: http.StatusOK,
+			emails: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.TransitionOrderStatus = tc.transitionOrderStatus
+
+			pp := utils.NewFakePaymentProvider("whsec_test")
+			checkoutSession, err := pp.CreateCheckout(utils.CheckoutRequest{
+				OrderID:   1,
+				Currency:  "NZD",
+				LineItems: []utils.PaymentLineItem{{Name: "Tee", UnitPrice: 20, Quantity: 1}},
+			})
+			if err != nil {
+				t.Fatalf("could not create checkout: %v", err)
+			}
+
+			if tc.checkout == "paid" {
+				if _, _, err = pp.CompleteCheckout(checkoutSession.ID); err != nil {
+					t.Fatalf("could not complete checkout: %v", err)
+				}
+			}
+
+			repo.GetOrderPayment = func(orderID int) (repo.OrderPaymentDto, error) {
+				if tc.checkout == "" {
+					return repo.OrderPaymentDto{ID: orderID, StatusID: repo.ORDER_STATUS_PAYMENT_RECEIVED}, nil
+				}
+				return repo.OrderPaymentDto{ID: orderID, StatusID: repo.ORDER_STATUS_PENDING, CheckoutSessionID: sql.NullString{String: checkoutSession.ID, Valid: true}}, nil
+			}
+
+			request, err := http.NewRequest("PUT", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create PUT request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"id": tc.id,
+			})
+
+			es := &mockEmailService{}
+			writer := httptest.NewRecorder()
+			getMockServerWithServices(es, pp).updateOrderStatus(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if len(es.updates) != tc.emails {
+				t.Fatalf("expected %v emails; got %v", tc.emails, len(es.updates))
+			}
+
+			if _, _, err = pp.CompleteCheckout(checkoutSession.ID); tc.expired != (err != nil && tc.checkout == "open") {
+				t.Errorf("expected checkout expired %v; got %v", tc.expired, err)
+			}
+
+			if tc.emails > 0 && tc.checkout == "" && es.updates[0].TrackingNumber != "AB123" {
+				t.Errorf("expected tracking number AB123; got %v", es.updates[0].TrackingNumber)
+			}
+		})
+	}
+}
+
+func TestRefundOrder(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedRefundOrder := repo.RefundOrder
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.RefundOrder = savedRefundOrder
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+
+	paidOrder := func(paymentID string) repo.OrderPaymentDto {
+		return repo.OrderPaymentDto{ID: 1, StatusID: repo.ORDER_STATUS_SHIPPED, PaymentIntentID: sql.NullString{String: paymentID, Valid: paymentID != ""}}
+	}
+
+	tt := []struct {
+		name        string
+		payment     func(paymentID string) repo.OrderPaymentDto
+		recordErr   error
+		paymentID   string
+		settle      bool
+		refundFirst bool
+		body        string
+		status      int
+		refunded    bool
+	}{
+		{
+			name:    "invalid body",
+			payment: paidOrder,
+			body:    "testing",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name: "order not paid",
+			payment: func(paymentID string) repo.OrderPaymentDto {
+				return repo.OrderPaymentDto{ID: 1, StatusID: repo.ORDER_STATUS_PENDING}
+			},
+			body:   `{"restock": true}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "missing payment intent",
+			payment: paidOrder,
+			body:    `{"restock": true}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:      "unknown payment",
+			payment:   paidOrder,
+			paymentID: "pi_missing",
+			body:      `{"restock": false}`,
+			status:    http.StatusBadGateway,
+		},
+		{
+			name:        "already refunded with provider",
+			payment:     paidOrder,
+			settle:      true,
+			refundFirst: true,
+			body:        `{"restock": false}`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:      "error recording refund",
+			payment:   paidOrder,
+			recordErr: errors.New("test"),
+			settle:    true,
+			body:      `{"restock": false}`,
+			status:    http.StatusInternalServerError,
+			refunded:  true,
+		},
+		{
+			name:     "happy path",
+			payment:  paidOrder,
+			settle:   true,
+			body:     `{"restock": false}`,
+			status:   http.StatusOK,
+			refunded: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pp := utils.NewFakePaymentProvider("whsec_test")
+			paymentID := tc.paymentID
+			if tc.settle {
+				paymentID = newFakePayment(t, pp)
+			}
+
+			if tc.refundFirst {
+				if _, err := pp.Refund(paymentID); err != nil {
+					t.Fatalf("could not refund payment: %v", err)
+				}
+			}
+
+			var refundID string
+			repo.RefundOrder = func(orderID int, restock bool, refund func(payment repo.OrderPaymentDto) (string, error)) (repo.OrderSummaryDto, error) {
+				payment := tc.payment(paymentID)
+				if !repo.CanTransitionOrder(payment.StatusID, repo.ORDER_STATUS_REFUNDED) {
+					return repo.OrderSummaryDto{}, repo.InvalidOrderTransitionError{From: payment.StatusID, To: repo.ORDER_STATUS_REFUNDED}
+				}
+
+				id, err := refund(payment)
+				if err != nil {
+					return repo.OrderSummaryDto{}, err
+				}
+				refundID = id
+
+				if tc.recordErr != nil {
+					return repo.OrderSummaryDto{}, tc.recordErr
+				}
+				return repo.OrderSummaryDto{ID: orderID, StatusID: repo.ORDER_STATUS_REFUNDED}, nil
+			}
+
+			request, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(tc.body)))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"id": "1",
+			})
+
+			writer := httptest.NewRecorder()
+			getMockServerWithServices(&mockEmailService{}, pp).refundOrder(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if (refundID != "") != tc.refunded {
+				t.Errorf("expected refunded %v; got refund id %q", tc.refunded, refundID)
+			}
+		})
+	}
+}
+
+func TestExportUnshippedOrders(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetUnshippedOrders := repo.GetUnshippedOrders
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetUnshippedOrders = savedGetUnshippedOrders
+	}()
+
+	tt := []struct {
+		name               string
+		isAdmin            func(request *http.Request) (int, error)
+		getUnshippedOrders func() ([]repo.ShippingLabelDto, error)
+		status             int
+		records            [][]string
+	}{
+		{
+			name:               "invalid permissions",
+			isAdmin:            func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			getUnshippedOrders: repo.GetUnshippedOrders,
+			status:             http.StatusUnauthorized,
+		},
+		{
+			name:    "error on GetUnshippedOrders",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getUnshippedOrders: func() ([]repo.ShippingLabelDto, error) {
+				return nil, errors.New("test")
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:    "happy path",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			getUnshippedOrders: func() ([]repo.ShippingLabelDto, error) {
+				return []repo.ShippingLabelDto{
+					{
+						OrderID:        5,
+						FirstName:      "Jane",
+						LastName:       "Doe",
+						Email:          "test@gmail.com",
+						Address:        utils.Address{Line1: "1 Queen St", Line2: "Level 2, Suite 3", City: "Auckland", Postcode: "1010", CountryCode: "nz"},
+						ShippingOption: "NZ-Wide Shipping",
+						Quantity:       2,
+						Weight:         400,
+						Total:          sql.NullFloat64{Float64: 45.9, Valid: true},
+						Currency:       "NZD",
+					},
+				}, nil
+			},
+			status: http.StatusOK,
+			records: [][]string{
+				shippingLabelCSVHeader,
+				{"5", "Jane Doe", "test@gmail.com", "1 Queen St", "Level 2, Suite 3", "Auckland", "", "1010", "NZ", "2", "400", "NZ-Wide Shipping", "45.90", "NZD"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+			repo.GetUnshippedOrders = tc.getUnshippedOrders
+
+			request, err := http.NewRequest("GET", "", nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			ExportUnshippedOrders(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusOK {
+				records, err := csv.NewReader(result.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("could not read csv: %v", err)
+				}
+
+				if !reflect.DeepEqual(records, tc.records) {
+					t.Errorf("expected records %v; got %v", tc.records, records)
+				}
+			}
+		})
+	}
+}
@@ -131,7 +131,13 @@ func GetQuiz(writer http.ResponseWriter, request *http.Request) {
 }
 
 func (s *Server) getQuizByRoute(writer http.ResponseWriter, request *http.Request) {
-	quiz, err := repo.GetQuizByRoute(mux.Vars(request)["route"])
+	detail, err := getMapDetail(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := repo.GetQuizByRoute(mux.Vars(request)["route"], detail)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
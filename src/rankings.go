@@ -0,0 +1,115 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+type UserRankingsDto struct {
+	Rankings []repo.UserRankingDto `json:"rankings"`
+	HasMore  bool                  `json:"hasMore"`
+}
+
+type CountryRankingsDto struct {
+	Rankings []repo.CountryRankingDto `json:"rankings"`
+	HasMore  bool                     `json:"hasMore"`
+}
+
+func GetUserRankings(writer http.ResponseWriter, request *http.Request) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filterParams repo.GetUserRankingsFilterParams
+	err = json.Unmarshal(requestBody, &filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	rankings, err := repo.GetUserRankings(filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch _, err := repo.GetFirstUserRankingID(filterParams); err {
+	case sql.ErrNoRows:
+		rankingsDto := UserRankingsDto{rankings, false}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(rankingsDto)
+	case nil:
+		rankingsDto := UserRankingsDto{rankings, true}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(rankingsDto)
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
+
+func GetUserRank(writer http.ResponseWriter, request *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(request)["userId"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	switch rank, err := repo.GetUserRank(userID); err {
+	case sql.ErrNoRows:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNoContent)
+	case nil:
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(rank)
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
+
+func GetCountryRankings(writer http.ResponseWriter, request *http.Request) {
+	quizID, err := strconv.Atoi(mux.Vars(request)["quizId"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filterParams repo.GetCountryRankingsFilterParams
+	err = json.Unmarshal(requestBody, &filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	rankings, err := repo.GetCountryRankings(quizID, filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch _, err := repo.GetFirstCountryRankingID(quizID, filterParams); err {
+	case sql.ErrNoRows:
+		rankingsDto := CountryRankingsDto{rankings, false}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(rankingsDto)
+	case nil:
+		rankingsDto := CountryRankingsDto{rankings, true}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(rankingsDto)
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,142 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestGetCountryRankings(t *testing.T) {
+	savedGetCountryRankings := repo.GetCountryRankings
+	savedGetFirstCountryRankingID := repo.GetFirstCountryRankingID
+
+	defer func() {
+		repo.GetCountryRankings = savedGetCountryRankings
+		repo.GetFirstCountryRankingID = savedGetFirstCountryRankingID
+	}()
+
+	tt := []struct {
+		name                     string
+		getCountryRankings       func(quizID int, filterParams repo.GetCountryRankingsFilterParams) ([]repo.CountryRankingDto, error)
+		getFirstCountryRankingID func(quizID int, filterParams repo.GetCountryRankingsFilterParams) (int, error)
+		quizID                   string
+		body                     string
+		status                   int
+		hasMore                  bool
+	}{
+		{
+			name:                     "invalid quiz id",
+			getCountryRankings:       repo.GetCountryRankings,
+			getFirstCountryRankingID: repo.GetFirstCountryRankingID,
+			quizID:                   "testing",
+			body:                     "",
+			status:                   http.StatusBadRequest,
+		},
+		{
+			name:                     "invalid body",
+			getCountryRankings:       repo.GetCountryRankings,
+			getFirstCountryRankingID: repo.GetFirstCountryRankingID,
+			quizID:                   "1",
+			body:                     "testing",
+			status:                   http.StatusBadRequest,
+		},
+		{
+			name: "error on GetCountryRankings",
+			getCountryRankings: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) ([]repo.CountryRankingDto, error) {
+				return nil, errors.New("test")
+			},
+			getFirstCountryRankingID: repo.GetFirstCountryRankingID,
+			quizID:                   "1",
+			body:                     `{"page": 0, "limit": 10}`,
+			status:                   http.StatusInternalServerError,
+		},
+		{
+			name: "error on GetFirstCountryRankingID",
+			getCountryRankings: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) ([]repo.CountryRankingDto, error) {
+				return []repo.CountryRankingDto{}, nil
+			},
+			getFirstCountryRankingID: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) (int, error) {
+				return 0, errors.New("test")
+			},
+			quizID: "1",
+			body:   `{"page": 0, "limit": 10}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "happy path, has more is false",
+			getCountryRankings: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) ([]repo.CountryRankingDto, error) {
+				return []repo.CountryRankingDto{{QuizID: quizID, CountryCode: "nz", Rank: 1}}, nil
+			},
+			getFirstCountryRankingID: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) (int, error) {
+				return 0, sql.ErrNoRows
+			},
+			quizID:  "1",
+			body:    `{"page": 0, "limit": 10}`,
+			status:  http.StatusOK,
+			hasMore: false,
+		},
+		{
+			name: "happy path, has more is true",
+			getCountryRankings: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) ([]repo.CountryRankingDto, error) {
+				return []repo.CountryRankingDto{{QuizID: quizID, CountryCode: "nz", Rank: 1}}, nil
+			},
+			getFirstCountryRankingID: func(quizID int, filterParams repo.GetCountryRankingsFilterParams) (int, error) {
+				return 2, nil
+			},
+			quizID:  "1",
+			body:    `{"page": 0, "limit": 1}`,
+			status:  http.StatusOK,
+			hasMore: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetCountryRankings = tc.getCountryRankings
+			repo.GetFirstCountryRankingID = tc.getFirstCountryRankingID
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"quizId": tc.quizID,
+			})
+
+			writer := httptest.NewRecorder()
+			GetCountryRankings(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status == http.StatusOK {
+				body, err := ioutil.ReadAll(result.Body)
+				if err != nil {
+					t.Fatalf("could not read response: %v", err)
+				}
+
+				var parsed CountryRankingsDto
+				err = json.Unmarshal(body, &parsed)
+				if err != nil {
+					t.Errorf("could not unmarshal response body: %v", err)
+				}
+
+				if parsed.HasMore != tc.hasMore {
+					t.Errorf("expected hasMore %v; got %v", tc.hasMore, parsed.HasMore)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package src
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+)
+
+// REPORT_DATE_FORMAT is used for the from and to query params and CSV dates.
+const REPORT_DATE_FORMAT = "2006-01-02"
+
+// DEFAULT_REPORT_DAYS is how far back reports go when no range is given.
+const DEFAULT_REPORT_DAYS = 30
+
+// DEFAULT_LOW_STOCK_THRESHOLD is used when neither the threshold query param
+// nor LOW_STOCK_THRESHOLD is set.
+const DEFAULT_LOW_STOCK_THRESHOLD = 5
+
+var reportIntervals = map[string]bool{
+	repo.REPORT_INTERVAL_DAY:   true,
+	repo.REPORT_INTERVAL_WEEK:  true,
+	repo.REPORT_INTERVAL_MONTH: true,
+}
+
+func GetRevenueReport(writer http.ResponseWriter, request *http.Request) {
+	filter, code, err := getReportFilter(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.GetRevenueReport(filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(writer, request, "revenue", report, func() [][]string {
+		records := [][]string{{"period", "currency", "orders", "subtotal", "discountTotal", "shippingTotal", "total", "refunded"}}
+		for _, entry := range report {
+			records = append(records, []string{entry.Period.Format(REPORT_DATE_FORMAT), entry.Currency, strconv.Itoa(entry.Orders), formatAmount(entry.Subtotal), formatAmount(entry.DiscountTotal), formatAmount(entry.ShippingTotal), formatAmount(entry.Total), formatAmount(entry.Refunded)})
+		}
+		return records
+	})
+}
+
+func GetMerchSalesReport(writer http.ResponseWriter, request *http.Request) {
+	filter, code, err := getReportFilter(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.GetMerchSalesReport(filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(writer, request, "merch-sales", report, func() [][]string {
+		records := [][]string{{"merchId", "merchName", "sizeId", "size", "orders", "units"}}
+		for _, entry := range report {
+			records = append(records, []string{strconv.Itoa(entry.MerchID), entry.MerchName, strconv.Itoa(entry.SizeID), entry.Size, strconv.Itoa(entry.Orders), strconv.Itoa(entry.Units)})
+		}
+		return records
+	})
+}
+
+func GetDiscountUsageReport(writer http.ResponseWriter, request *http.Request) {
+	filter, code, err := getReportFilter(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.GetDiscountUsageReport(filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(writer, request, "discount-usage", report, func() [][]string {
+		records := [][]string{{"discountId", "code", "currency", "orders", "value"}}
+		for _, entry := range report {
+			records = append(records, []string{strconv.Itoa(entry.DiscountID), entry.Code, entry.Currency, strconv.Itoa(entry.Orders), formatAmount(entry.Value)})
+		}
+		return records
+	})
+}
+
+func GetShippingMixReport(writer http.ResponseWriter, request *http.Request) {
+	filter, code, err := getReportFilter(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.GetShippingMixReport(filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(writer, request, "shipping-mix", report, func() [][]string {
+		records := [][]string{{"shippingOptionId", "shippingOption", "currency", "orders", "share", "shippingTotal"}}
+		for _, entry := range report {
+			records = append(records, []string{strconv.Itoa(entry.ShippingOptionID), entry.ShippingOption, entry.Currency, strconv.Itoa(entry.Orders), strconv.FormatFloat(entry.Share, 'f', 4, 64), formatAmount(entry.ShippingTotal)})
+		}
+		return records
+	})
+}
+
+func GetLowStockReport(writer http.ResponseWriter, request *http.Request) {
+	threshold, code, err := getLowStockThreshold(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	report, err := repo.GetLowStock(threshold)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeReport(writer, request, "low-stock", report, func() [][]string {
+		records := [][]string{{"merchId", "merchName", "sizeId", "size", "quantity"}}
+		for _, entry := range report {
+			records = append(records, []string{strconv.Itoa(entry.MerchID), entry.MerchName, strconv.Itoa(entry.SizeID), entry.Size, strconv.Itoa(entry.Quantity)})
+		}
+		return records
+	})
+}
+
+// sendLowStockAlert emails the low stock report to LOW_STOCK_ALERT_EMAIL now
+// rather than waiting for the daily check.
+func (s *Server) sendLowStockAlert(writer http.ResponseWriter, request *http.Request) {
+	threshold, code, err := getLowStockThreshold(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	email := os.Getenv("LOW_STOCK_ALERT_EMAIL")
+	if email == "" {
+		http.Error(writer, "LOW_STOCK_ALERT_EMAIL is not set\n", http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.emailLowStock(email, threshold)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(report)
+}
+
+// alertLowStock periodically emails the low stock report, if there is
+// anything in it. Alerts are only sent when LOW_STOCK_ALERT_EMAIL is set.
+func (s *Server) alertLowStock(interval time.Duration) {
+	for range time.Tick(interval) {
+		email := os.Getenv("LOW_STOCK_ALERT_EMAIL")
+		if email == "" {
+			continue
+		}
+
+		threshold, err := strconv.Atoi(os.Getenv("LOW_STOCK_THRESHOLD"))
+		if err != nil {
+			threshold = DEFAULT_LOW_STOCK_THRESHOLD
+		}
+
+		if _, err := s.emailLowStock(email, threshold); err != nil {
+			log.Printf("failed to send low stock alert: %v", err)
+		}
+	}
+}
+
+func (s *Server) emailLowStock(email string, threshold int) ([]repo.LowStockReportDto, error) {
+	report, err := repo.GetLowStock(threshold)
+	if err != nil || len(report) == 0 {
+		return report, err
+	}
+
+	var items []utils.LowStockItem
+	for _, entry := range report {
+		items = append(items, utils.LowStockItem{MerchName: entry.MerchName, Size: entry.Size, Quantity: entry.Quantity})
+	}
+
+	_, err = s.es.SendLowStockAlert(email, items, threshold)
+	return report, err
+}
+
+// getReportFilter reads the from and to dates, both inclusive, and the
+// interval revenue is grouped by. Reports are admin only.
+func getReportFilter(request *http.Request) (repo.ReportFilter, int, error) {
+	var filter repo.ReportFilter
+	if code, err := IsAdmin(request); err != nil {
+		return filter, code, err
+	}
+
+	query := request.URL.Query()
+	today, _ := time.Parse(REPORT_DATE_FORMAT, time.Now().UTC().Format(REPORT_DATE_FORMAT))
+	filter.To = today.AddDate(0, 0, 1)
+	if to := query.Get("to"); to != "" {
+		date, err := time.Parse(REPORT_DATE_FORMAT, to)
+		if err != nil {
+			return filter, http.StatusBadRequest, fmt.Errorf("invalid to date %s", to)
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+
+	filter.From = filter.To.AddDate(0, 0, -DEFAULT_REPORT_DAYS)
+	if from := query.Get("from"); from != "" {
+		date, err := time.Parse(REPORT_DATE_FORMAT, from)
+		if err != nil {
+			return filter, http.StatusBadRequest, fmt.Errorf("invalid from date %s", from)
+		}
+		filter.From = date
+	}
+
+	if !filter.From.Before(filter.To) {
+		return filter, http.StatusBadRequest, errors.New("from date must not be after to date")
+	}
+
+	filter.Interval = query.Get("interval")
+	if filter.Interval == "" {
+		filter.Interval = repo.REPORT_INTERVAL_DAY
+	}
+
+	if !reportIntervals[filter.Interval] {
+		return filter, http.StatusBadRequest, fmt.Errorf("invalid interval %s", filter.Interval)
+	}
+	return filter, http.StatusOK, nil
+}
+
+func getLowStockThreshold(request *http.Request) (int, int, error) {
+	if code, err := IsAdmin(request); err != nil {
+		return 0, code, err
+	}
+
+	raw := request.URL.Query().Get("threshold")
+	if raw == "" {
+		raw = os.Getenv("LOW_STOCK_THRESHOLD")
+	}
+
+	if raw == "" {
+		return DEFAULT_LOW_STOCK_THRESHOLD, http.StatusOK, nil
+	}
+
+	threshold, err := strconv.Atoi(raw)
+	if err != nil || threshold < 0 {
+		return 0, http.StatusBadRequest, fmt.Errorf("invalid threshold %s", raw)
+	}
+	return threshold, http.StatusOK, nil
+}
+
+// writeReport writes the report as JSON, or as a CSV download if ?format=csv.
+func writeReport(writer http.ResponseWriter, request *http.Request, name string, report interface{}, records func() [][]string) {
+	switch format := request.URL.Query().Get("format"); format {
+	case "csv":
+		writer.Header().Set("Content-Type", "text/csv")
+		writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-report.csv", name))
+		csvWriter := csv.NewWriter(writer)
+		if err := csvWriter.WriteAll(records()); err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		}
+	case "", "json":
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(report)
+	default:
+		http.Error(writer, fmt.Sprintf("invalid format %s\n", format), http.StatusBadRequest)
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
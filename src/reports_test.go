@@ -0,0 +1,300 @@
+package src
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+)
+
+func TestGetReportFilter(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	defer func() {
+		IsAdmin = savedIsAdmin
+	}()
+
+	tt := []struct {
+		name     string
+		isAdmin  func(request *http.Request) (int, error)
+		query    string
+		status   int
+		from     string
+		to       string
+		interval string
+	}{
+		{
+			name:    "invalid permissions",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusUnauthorized, errors.New("test") },
+			query:   "",
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "invalid from date",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:   "?from=01/02/2022",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "from after to",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:   "?from=2022-02-01&to=2022-01-01",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid interval",
+			isAdmin: func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:   "?interval=year",
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:     "to date is inclusive",
+			isAdmin:  func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:    "?from=2022-01-01&to=2022-01-31&interval=week",
+			status:   http.StatusOK,
+			from:     "2022-01-01",
+			to:       "2022-02-01",
+			interval: "week",
+		},
+		{
+			name:     "defaults to the last 30 days",
+			isAdmin:  func(request *http.Request) (int, error) { return http.StatusOK, nil },
+			query:    "?to=2022-03-30",
+			status:   http.StatusOK,
+			from:     "2022-03-01",
+			to:       "2022-03-31",
+			interval: "day",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			IsAdmin = tc.isAdmin
+
+			request, err := http.NewRequest("GET", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			filter, code, err := getReportFilter(request)
+			if code != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, code)
+			}
+
+			if tc.status != http.StatusOK {
+				if err == nil {
+					t.Error("expected error; got nil")
+				}
+				return
+			}
+
+			if filter.From.Format(REPORT_DATE_FORMAT) != tc.from || filter.To.Format(REPORT_DATE_FORMAT) != tc.to {
+				t.Errorf("expected range %v to %v; got %v to %v", tc.from, tc.to, filter.From.Format(REPORT_DATE_FORMAT), filter.To.Format(REPORT_DATE_FORMAT))
+			}
+
+			if filter.Interval != tc.interval {
+				t.Errorf("expected interval %v; got %v", tc.interval, filter.Interval)
+			}
+		})
+	}
+}
+
+func TestGetRevenueReport(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetRevenueReport := repo.GetRevenueReport
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetRevenueReport = savedGetRevenueReport
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+
+	report := func(filter repo.ReportFilter) ([]repo.RevenueReportDto, error) {
+		return []repo.RevenueReportDto{
+			{Period: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), Currency: "NZD", Orders: 2, Subtotal: 60, DiscountTotal: 5, ShippingTotal: 5.99, Total: 60.99, Refunded: 0},
+		}, nil
+	}
+
+	tt := []struct {
+		name             string
+		getRevenueReport func(filter repo.ReportFilter) ([]repo.RevenueReportDto, error)
+		query            string
+		status           int
+		records          [][]string
+	}{
+		{
+			name: "error on GetRevenueReport",
+			getRevenueReport: func(filter repo.ReportFilter) ([]repo.RevenueReportDto, error) {
+				return nil, errors.New("test")
+			},
+			query:  "",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:             "invalid format",
+			getRevenueReport: report,
+			query:            "?format=xml",
+			status:           http.StatusBadRequest,
+		},
+		{
+			name:             "happy path",
+			getRevenueReport: report,
+			query:            "",
+			status:           http.StatusOK,
+		},
+		{
+			name:             "happy path, csv",
+			getRevenueReport: report,
+			query:            "?format=csv&interval=week",
+			status:           http.StatusOK,
+			records: [][]string{
+				{"period", "currency", "orders", "subtotal", "discountTotal", "shippingTotal", "total", "refunded"},
+				{"2022-01-03", "NZD", "2", "60.00", "5.00", "5.99", "60.99", "0.00"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetRevenueReport = tc.getRevenueReport
+
+			request, err := http.NewRequest("GET", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create GET request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			GetRevenueReport(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.records != nil {
+				records, err := csv.NewReader(result.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("could not read csv: %v", err)
+				}
+
+				if !reflect.DeepEqual(records, tc.records) {
+					t.Errorf("expected records %v; got %v", tc.records, records)
+				}
+			}
+		})
+	}
+}
+
+func TestSendLowStockAlert(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetLowStock := repo.GetLowStock
+	savedAlertEmail := os.Getenv("LOW_STOCK_ALERT_EMAIL")
+	savedThreshold := os.Getenv("LOW_STOCK_THRESHOLD")
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetLowStock = savedGetLowStock
+		os.Setenv("LOW_STOCK_ALERT_EMAIL", savedAlertEmail)
+		os.Setenv("LOW_STOCK_THRESHOLD", savedThreshold)
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	os.Setenv("LOW_STOCK_THRESHOLD", "3")
+
+	tt := []struct {
+		name        string
+		alertEmail  string
+		getLowStock func(threshold int) ([]repo.LowStockReportDto, error)
+		query       string
+		status      int
+		threshold   int
+		emails      int
+	}{
+		{
+			name:        "alert email not set",
+			alertEmail:  "",
+			getLowStock: repo.GetLowStock,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid threshold",
+			alertEmail:  "admin@geobuff.com",
+			getLowStock: repo.GetLowStock,
+			query:       "?threshold=-1",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:       "error on GetLowStock",
+			alertEmail: "admin@geobuff.com",
+			getLowStock: func(threshold int) ([]repo.LowStockReportDto, error) {
+				return nil, errors.New("test")
+			},
+			status:    http.StatusInternalServerError,
+			threshold: 3,
+		},
+		{
+			name:       "nothing low",
+			alertEmail: "admin@geobuff.com",
+			getLowStock: func(threshold int) ([]repo.LowStockReportDto, error) {
+				return []repo.LowStockReportDto{}, nil
+			},
+			status:    http.StatusOK,
+			threshold: 3,
+			emails:    0,
+		},
+		{
+			name:       "happy path",
+			alertEmail: "admin@geobuff.com",
+			getLowStock: func(threshold int) ([]repo.LowStockReportDto, error) {
+				return []repo.LowStockReportDto{{MerchID: 1, MerchName: "Tee", SizeID: 2, Size: "M", Quantity: 1}}, nil
+			},
+			query:     "?threshold=10",
+			status:    http.StatusOK,
+			threshold: 10,
+			emails:    1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("LOW_STOCK_ALERT_EMAIL", tc.alertEmail)
+
+			var threshold int
+			repo.GetLowStock = func(value int) ([]repo.LowStockReportDto, error) {
+				threshold = value
+				return tc.getLowStock(value)
+			}
+
+			request, err := http.NewRequest("POST", tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			es := &mockEmailService{}
+			writer := httptest.NewRecorder()
+			getMockServerWithServices(es, utils.NewFakePaymentProvider("whsec_test")).sendLowStockAlert(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if threshold != tc.threshold {
+				t.Errorf("expected threshold %v; got %v", tc.threshold, threshold)
+			}
+
+			if len(es.stockAlerts) != tc.emails {
+				t.Errorf("expected %v emails; got %v", tc.emails, len(es.stockAlerts))
+			}
+		})
+	}
+}
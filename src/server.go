@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/didip/tollbooth"
 	"github.com/geobuff/api/utils"
@@ -16,21 +17,40 @@ type Server struct {
 	ts utils.ITranslationService
 	es utils.IEmailService
 	vs utils.IValidationService
+	ps utils.IPricingService
+	pp utils.IPaymentProvider
+	ms utils.IMediaStore
 }
 
-func NewServer(ts utils.ITranslationService, es utils.IEmailService, vs utils.IValidationService) *Server {
+func NewServer(ts utils.ITranslationService, es utils.IEmailService, vs utils.IValidationService, ps utils.IPricingService, pp utils.IPaymentProvider, ms utils.IMediaStore) *Server {
 	return &Server{
 		ts,
 		es,
 		vs,
+		ps,
+		pp,
+		ms,
 	}
 }
 
 func getMockServer() *Server {
-	return NewServer(utils.NewTranslationService(), utils.NewEmailService(), utils.NewValidationService())
+	return NewServer(utils.NewTranslationService(), utils.NewEmailService(), utils.NewValidationService(), utils.NewPricingService(), utils.NewFakePaymentProvider("whsec_test"), utils.NewFakeBlobStore("/api/assets"))
 }
 
+// ORDER_CLEANUP_INTERVAL is how often stale pending orders are released.
+const ORDER_CLEANUP_INTERVAL = 5 * time.Minute
+
+// LOW_STOCK_ALERT_INTERVAL is how often low stock is checked and emailed.
+const LOW_STOCK_ALERT_INTERVAL = 24 * time.Hour
+
+// MEDIA_GC_INTERVAL is how often unused uploads are cleaned up.
+const MEDIA_GC_INTERVAL = 6 * time.Hour
+
 func (s *Server) Start() error {
+	go releaseExpiredOrders(ORDER_CLEANUP_INTERVAL)
+	go s.alertLowStock(LOW_STOCK_ALERT_INTERVAL)
+	go s.collectUnusedMedia(MEDIA_GC_INTERVAL)
+
 	max, _ := strconv.ParseFloat(os.Getenv("RATE_LIMITER_MAX"), 64)
 	return http.ListenAndServe(":8080", tollbooth.LimitHandler(tollbooth.NewLimiter(max, nil), (handler(s.router()))))
 }
@@ -80,6 +100,16 @@ func (s *Server) router() http.Handler {
 	router.HandleFunc("/api/trivia-plays/week", GetLastWeekTriviaPlays).Methods("GET")
 	router.HandleFunc("/api/trivia-plays/{id}", IncrementTriviaPlays).Methods("PUT")
 
+	// User Trivia Plays endpoints.
+	router.HandleFunc("/api/user-trivia-plays", CreateUserTriviaPlay).Methods("POST")
+	router.HandleFunc("/api/user-trivia-plays/streak/{userId}", GetUserTriviaStreak).Methods("GET")
+	router.HandleFunc("/api/user-trivia-plays/leaderboard/{date}", GetTriviaLeaderboard).Methods("POST")
+
+	// Trivia Question Result endpoints.
+	router.HandleFunc("/api/trivia-question-results/problematic", GetProblematicManualTriviaQuestions).Methods("POST")
+	router.HandleFunc("/api/trivia-question-results/{triviaId}", GetTriviaQuestionStats).Methods("GET")
+	router.HandleFunc("/api/trivia-question-results/{triviaId}", CreateTriviaQuestionResults).Methods("POST")
+
 	// Trivia Question Type endpoints.
 	router.HandleFunc("/api/trivia-question-types", GetTriviaQuestionTypes).Methods("GET")
 
@@ -89,28 +119,60 @@ func (s *Server) router() http.Handler {
 	// Manual Trivia Question endpoints.
 	router.HandleFunc("/api/manual-trivia-questions/all", GetManualTriviaQuestions).Methods("POST")
 	router.HandleFunc("/api/manual-trivia-questions", CreateManualTriviaQuestion).Methods("POST")
+	router.HandleFunc("/api/manual-trivia-questions/import", ImportManualTriviaQuestions).Methods("POST")
+	router.HandleFunc("/api/manual-trivia-questions/export", ExportManualTriviaQuestions).Methods("POST")
 	router.HandleFunc("/api/manual-trivia-questions/{id}", UpdateManualTriviaQuestion).Methods("PUT")
 	router.HandleFunc("/api/manual-trivia-questions/{id}", DeleteManualTriviaQuestion).Methods("DELETE")
 
+	// Trivia Question Template endpoints.
+	router.HandleFunc("/api/trivia-question-templates", GetTriviaQuestionTemplates).Methods("GET")
+	router.HandleFunc("/api/trivia-question-templates", s.createTriviaQuestionTemplate).Methods("POST")
+	router.HandleFunc("/api/trivia-question-templates/{id}", s.updateTriviaQuestionTemplate).Methods("PUT")
+	router.HandleFunc("/api/trivia-question-templates/{id}", DeleteTriviaQuestionTemplate).Methods("DELETE")
+	router.HandleFunc("/api/trivia-question-templates/{id}/generate", s.generateTriviaQuestions).Methods("POST")
+
 	// Mapping endpoints.
 	router.HandleFunc("/api/mappings", GetMappingGroups).Methods("GET")
 	router.HandleFunc("/api/mappings/{key}", s.getMappingEntries).Methods("GET")
 	router.HandleFunc("/api/mappings-no-flags", GetMappingsWithoutFlags).Methods("GET")
+	router.HandleFunc("/api/mapping-attributes", GetMappingAttributes).Methods("GET")
 	router.HandleFunc("/api/mappings/{key}", EditMapping).Methods("PUT")
 	router.HandleFunc("/api/mappings/{key}", DeleteMapping).Methods("DELETE")
+	router.HandleFunc("/api/mappings/{key}/match", s.matchMappingEntry).Methods("POST")
+	router.HandleFunc("/api/mappings/{key}/attributes", ImportMappingAttributes).Methods("POST")
 
 	// Map endpoints.
 	router.HandleFunc("/api/maps", GetMaps).Methods("GET")
 	router.HandleFunc("/api/maps/highlighted/{className}", GetMapHighlightedRegions).Methods("GET")
+	router.HandleFunc("/api/maps/{className}.{format:svg|png}", GetMapImage).Methods("GET")
 	router.HandleFunc("/api/maps/{className}", GetMap).Methods("GET")
 	router.HandleFunc("/api/maps/preview", GetMapPreview).Methods("POST")
 	router.HandleFunc("/api/maps", CreateMap).Methods("POST")
+	router.HandleFunc("/api/maps/{className}", s.updateMap).Methods("PUT")
+	router.HandleFunc("/api/maps/{className}/elements", AddMapElement).Methods("POST")
+	router.HandleFunc("/api/maps/{className}/elements/{id}", ReplaceMapElement).Methods("PUT")
+	router.HandleFunc("/api/maps/{className}/elements/{id}", DeleteMapElement).Methods("DELETE")
+	router.HandleFunc("/api/maps/{className}/import", ImportMap).Methods("POST")
+	router.HandleFunc("/api/maps/{className}/revisions", GetMapRevisions).Methods("GET")
+	router.HandleFunc("/api/maps/{className}/revisions/{revision}", GetMapRevision).Methods("GET")
+	router.HandleFunc("/api/maps/{className}/revisions/{revision}/rollback", RollbackMap).Methods("POST")
 
 	// Flag endpoints.
 	router.HandleFunc("/api/flags", GetFlagGroups).Methods("GET")
 	router.HandleFunc("/api/flags/{key}", GetFlagEntries).Methods("GET")
 	router.HandleFunc("/api/flags/url/{code}", GetFlagUrl).Methods("GET")
 	router.HandleFunc("/api/flags", CreateFlags).Methods("POST")
+	router.HandleFunc("/api/flags/{key}", s.updateFlags).Methods("PUT")
+	router.HandleFunc("/api/flags/{key}", s.deleteFlags).Methods("DELETE")
+	router.HandleFunc("/api/flags/{key}/check", CheckFlagUrls).Methods("GET")
+	router.HandleFunc("/api/flags/{key}/entries/{code}", s.uploadFlag).Methods("PUT")
+	router.HandleFunc("/api/flags/{key}/entries/{code}", s.deleteFlagEntry).Methods("DELETE")
+
+	// Asset endpoints.
+	router.HandleFunc("/api/assets/{key:.+}", s.getAsset).Methods("GET")
+
+	// Media endpoints.
+	router.HandleFunc("/api/media", s.uploadMedia).Methods("POST")
 
 	// Continent endpoints.
 	router.HandleFunc("/api/continents", GetContinents).Methods("GET")
@@ -132,6 +194,7 @@ func (s *Server) router() http.Handler {
 	router.HandleFunc("/api/users/total/week", GetLastWeekTotalUsers).Methods("GET")
 	router.HandleFunc("/api/users/{id}", s.updateUser).Methods("PUT")
 	router.HandleFunc("/api/users/xp/{id}", s.updateUserXP).Methods("PUT")
+	router.HandleFunc("/api/users/streak-freezes/{id}", AddUserStreakFreezes).Methods("PUT")
 	router.HandleFunc("/api/users/{id}", DeleteUser).Methods("DELETE")
 
 	// Badge endpoints.
@@ -150,19 +213,44 @@ func (s *Server) router() http.Handler {
 	router.HandleFunc("/api/leaderboard/{id}", UpdateEntry).Methods("PUT")
 	router.HandleFunc("/api/leaderboard/{id}", DeleteEntry).Methods("DELETE")
 
+	// Ranking endpoints.
+	router.HandleFunc("/api/rankings/users", GetUserRankings).Methods("POST")
+	router.HandleFunc("/api/rankings/users/{userId}", GetUserRank).Methods("GET")
+	router.HandleFunc("/api/rankings/countries/{quizId}", GetCountryRankings).Methods("POST")
+
 	// Shipping option endpoints.
 	router.HandleFunc("/api/shipping-options", GetShippingOptions).Methods("GET")
 
+	// Report endpoints.
+	router.HandleFunc("/api/reports/revenue", GetRevenueReport).Methods("GET")
+	router.HandleFunc("/api/reports/merch-sales", GetMerchSalesReport).Methods("GET")
+	router.HandleFunc("/api/reports/discounts", GetDiscountUsageReport).Methods("GET")
+	router.HandleFunc("/api/reports/shipping", GetShippingMixReport).Methods("GET")
+	router.HandleFunc("/api/reports/low-stock", GetLowStockReport).Methods("GET")
+	router.HandleFunc("/api/reports/low-stock/alert", s.sendLowStockAlert).Methods("POST")
+
+	// Admin endpoints.
+	router.HandleFunc("/api/admin/integrity", GetIntegrityReport).Methods("GET")
+	router.HandleFunc("/api/admin/media/gc", s.collectMedia).Methods("POST")
+
+	// Currency endpoints.
+	router.HandleFunc("/api/currencies", GetCurrencies).Methods("GET")
+	router.HandleFunc("/api/currencies/country/{code}", GetCountryCurrency).Methods("GET")
+
 	// Checkout endpoints.
-	router.HandleFunc("/api/checkout/create-checkout-session", HandleCreateCheckoutSession).Methods("POST")
-	router.HandleFunc("/api/checkout/webhook", HandleWebhook).Methods("POST")
+	router.HandleFunc("/api/checkout/quote", s.getCheckoutQuote).Methods("POST")
+	router.HandleFunc("/api/checkout/create-checkout-session", s.createCheckoutSession).Methods("POST")
+	router.HandleFunc("/api/checkout/webhook", s.handleWebhook).Methods("POST")
 
 	// Order endpoints.
 	router.HandleFunc("/api/orders", GetOrders).Methods("POST")
-	router.HandleFunc("/api/orders/user/{email}", GetUserOrders).Methods("GET")
-	router.HandleFunc("/api/orders/status/{id}", UpdateOrderStatus).Methods("PUT")
+	router.HandleFunc("/api/orders/guest", GetGuestOrders).Methods("POST")
+	router.HandleFunc("/api/orders/access-link", s.sendOrderAccessLink).Methods("POST")
+	router.HandleFunc("/api/orders/unshipped/export", ExportUnshippedOrders).Methods("GET")
+	router.HandleFunc("/api/orders/status/{id}", s.updateOrderStatus).Methods("PUT")
+	router.HandleFunc("/api/orders/refund/{id}", s.refundOrder).Methods("POST")
 	router.HandleFunc("/api/orders/{id}", DeleteOrder).Methods("DELETE")
-	router.HandleFunc("/api/orders/email/{email}", CancelOrder).Methods("DELETE")
+	router.HandleFunc("/api/orders/cancel/{token}", CancelOrder).Methods("DELETE")
 
 	// Avatar endpoints.
 	router.HandleFunc("/api/avatars", s.getAvatars).Methods("GET")
@@ -170,10 +258,25 @@ func (s *Server) router() http.Handler {
 	// Merch endpoints.
 	router.HandleFunc("/api/merch", GetMerch).Methods("GET")
 	router.HandleFunc("/api/merch/exists", MerchExists).Methods("POST")
+	router.HandleFunc("/api/merch", s.createMerch).Methods("POST")
+	router.HandleFunc("/api/merch/{id}", s.updateMerch).Methods("PUT")
+	router.HandleFunc("/api/merch/{id}", DeleteMerch).Methods("DELETE")
+	router.HandleFunc("/api/merch/sizes", s.createMerchSize).Methods("POST")
+	router.HandleFunc("/api/merch/sizes/{id}", s.updateMerchSize).Methods("PUT")
+	router.HandleFunc("/api/merch/sizes/{id}", DeleteMerchSize).Methods("DELETE")
+	router.HandleFunc("/api/merch/images", s.createMerchImage).Methods("POST")
+	router.HandleFunc("/api/merch/images/{id}", s.updateMerchImage).Methods("PUT")
+	router.HandleFunc("/api/merch/images/{id}", DeleteMerchImage).Methods("DELETE")
 
 	// Discount endpoints.
 	router.HandleFunc("/api/discounts", GetDiscounts).Methods("GET")
 	router.HandleFunc("/api/discounts/{code}", GetDiscount).Methods("GET")
+	router.HandleFunc("/api/discounts", s.createDiscount).Methods("POST")
+	router.HandleFunc("/api/discounts/{id}", s.updateDiscount).Methods("PUT")
+	router.HandleFunc("/api/discounts/{id}", DeleteDiscount).Methods("DELETE")
+
+	// Audit log endpoints.
+	router.HandleFunc("/api/audit-logs", GetAuditLogs).Methods("POST")
 
 	// Community Quiz endpoints.
 	router.HandleFunc("/api/community-quizzes/all", GetCommunityQuizzes).Methods("POST")
@@ -6,15 +6,29 @@ import (
 	"net/http"
 
 	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
 )
 
+// GetShippingOptions lists the options that deliver to the ?country= given.
+// Flat prices are shown in the requested currency, tiered prices depend on the
+// cart so come from the checkout quote.
 func GetShippingOptions(writer http.ResponseWriter, request *http.Request) {
-	options, err := repo.GetShippingOptions()
+	currency, code, err := getRequestCurrency(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	options, err := repo.GetShippingOptions(request.URL.Query().Get("country"))
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
 	}
 
+	for index, option := range options {
+		options[index].Price = utils.ConvertPrice(option.Price, currency)
+	}
+
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(options)
 }
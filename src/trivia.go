@@ -117,7 +117,14 @@ func (s *Server) getAllTrivia(writer http.ResponseWriter, request *http.Request)
 }
 
 func (s *Server) getTriviaByDate(writer http.ResponseWriter, request *http.Request) {
-	trivia, err := repo.GetTrivia(mux.Vars(request)["date"])
+	detail, err := getMapDetail(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	dedupe := request.URL.Query().Get("dedupe") == "true"
+	trivia, err := repo.GetTriviaWithMaps(mux.Vars(request)["date"], detail, dedupe)
 	if err != nil {
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
 		return
@@ -136,6 +143,7 @@ func (s *Server) getTriviaByDate(writer http.ResponseWriter, request *http.Reque
 			Name:      name,
 			MaxScore:  trivia.MaxScore,
 			Questions: make([]repo.QuestionDto, len(trivia.Questions)),
+			Maps:      trivia.Maps,
 		}
 
 		for index, question := range trivia.Questions {
@@ -166,6 +174,7 @@ func (s *Server) getTriviaByDate(writer http.ResponseWriter, request *http.Reque
 				}
 
 				answers[index] = repo.AnswerDto{
+					ID:        answer.ID,
 					Text:      text,
 					IsCorrect: answer.IsCorrect,
 					FlagCode:  answer.FlagCode,
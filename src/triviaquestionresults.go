@@ -0,0 +1,129 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+const DEFAULT_PROBLEMATIC_QUESTIONS_LIMIT = 10
+
+// CreateTriviaQuestionResults records the logged in user's answers for a
+// trivia they've completed, so each play can only be reported once.
+func CreateTriviaQuestionResults(writer http.ResponseWriter, request *http.Request) {
+	userID, err := getRequestUserID(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusUnauthorized)
+		return
+	}
+
+	triviaID, err := strconv.Atoi(mux.Vars(request)["triviaId"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	playID, err := repo.GetUserTriviaPlayID(userID, triviaID)
+	if err == sql.ErrNoRows {
+		http.Error(writer, "trivia must be completed before submitting results", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var submitted []repo.CreateTriviaQuestionResultDto
+	err = json.Unmarshal(requestBody, &submitted)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	options, err := repo.GetTriviaAnswerOptions(triviaID)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	results, err := repo.BuildTriviaQuestionResults(options, submitted)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	err = repo.CreateTriviaQuestionResults(playID, results)
+	if repo.IsUniqueViolation(err) {
+		http.Error(writer, "results already submitted for this trivia", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetTriviaQuestionStats(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	triviaID, err := strconv.Atoi(mux.Vars(request)["triviaId"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := repo.GetTriviaQuestionStats(triviaID)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(stats)
+}
+
+func GetProblematicManualTriviaQuestions(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filter repo.GetProblematicQuestionsFilter
+	err = json.Unmarshal(requestBody, &filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DEFAULT_PROBLEMATIC_QUESTIONS_LIMIT
+	}
+
+	stats, err := repo.GetProblematicManualTriviaQuestions(filter)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(stats)
+}
@@ -0,0 +1,196 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestCreateTriviaQuestionResults(t *testing.T) {
+	savedGetRequestUserID := getRequestUserID
+	savedGetUserTriviaPlayID := repo.GetUserTriviaPlayID
+	savedGetTriviaAnswerOptions := repo.GetTriviaAnswerOptions
+	savedCreateTriviaQuestionResults := repo.CreateTriviaQuestionResults
+
+	defer func() {
+		getRequestUserID = savedGetRequestUserID
+		repo.GetUserTriviaPlayID = savedGetUserTriviaPlayID
+		repo.GetTriviaAnswerOptions = savedGetTriviaAnswerOptions
+		repo.CreateTriviaQuestionResults = savedCreateTriviaQuestionResults
+	}()
+
+	options := []repo.TriviaAnswerOption{
+		{QuestionID: 1, ManualTriviaQuestionID: sql.NullInt64{Int64: 5, Valid: true}, AnswerID: 10, Text: "Paris", IsCorrect: true},
+		{QuestionID: 1, ManualTriviaQuestionID: sql.NullInt64{Int64: 5, Valid: true}, AnswerID: 11, Text: "Lyon"},
+		{QuestionID: 2, AnswerID: 20, Text: "Yes", IsCorrect: true},
+		{QuestionID: 2, AnswerID: 21, Text: "No"},
+	}
+
+	tt := []struct {
+		name                        string
+		getUserID                   func(request *http.Request) (int, error)
+		getUserTriviaPlayID         func(userID, triviaID int) (int, error)
+		triviaID                    string
+		getTriviaAnswerOptions      func(triviaID int) ([]repo.TriviaAnswerOption, error)
+		createTriviaQuestionResults func(playID int, results []repo.TriviaQuestionResult) error
+		body                        string
+		status                      int
+	}{
+		{
+			name:                        "not logged in",
+			getUserID:                   func(request *http.Request) (int, error) { return 0, errors.New("test") },
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "[]",
+			status:                      http.StatusUnauthorized,
+		},
+		{
+			name:                        "invalid trivia id",
+			triviaID:                    "testing",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "[]",
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "trivia not played",
+			getUserTriviaPlayID:         func(userID, triviaID int) (int, error) { return 0, sql.ErrNoRows },
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "[]",
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "error on GetUserTriviaPlayID",
+			getUserTriviaPlayID:         func(userID, triviaID int) (int, error) { return 0, errors.New("test") },
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "[]",
+			status:                      http.StatusInternalServerError,
+		},
+		{
+			name:                        "invalid body",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "testing",
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "error on GetTriviaAnswerOptions",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return nil, errors.New("test") },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        "[]",
+			status:                      http.StatusInternalServerError,
+		},
+		{
+			name:                        "question not in trivia",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        `[{"questionId":3,"answerId":10}]`,
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "answer belongs to another question",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        `[{"questionId":1,"answerId":20}]`,
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "duplicate question",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return nil },
+			body:                        `[{"questionId":1,"answerId":10},{"questionId":1,"answerId":11}]`,
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "results already submitted",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return &pq.Error{Code: "23505"} },
+			body:                        `[{"questionId":1,"answerId":10}]`,
+			status:                      http.StatusBadRequest,
+		},
+		{
+			name:                        "error on CreateTriviaQuestionResults",
+			triviaID:                    "1",
+			getTriviaAnswerOptions:      func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error { return errors.New("test") },
+			body:                        `[{"questionId":1,"answerId":10}]`,
+			status:                      http.StatusInternalServerError,
+		},
+		{
+			name:                   "happy path",
+			triviaID:               "1",
+			getTriviaAnswerOptions: func(triviaID int) ([]repo.TriviaAnswerOption, error) { return options, nil },
+			createTriviaQuestionResults: func(playID int, results []repo.TriviaQuestionResult) error {
+				if playID != 3 {
+					return errors.New("expected results for play 3")
+				}
+
+				if len(results) != 2 {
+					return errors.New("expected two results")
+				}
+
+				if !results[0].ManualTriviaQuestionID.Valid || results[0].AnswerText != "Lyon" || results[0].IsCorrect {
+					return errors.New("unexpected first result")
+				}
+
+				if !results[1].Skipped || results[1].ManualTriviaQuestionID.Valid {
+					return errors.New("unexpected second result")
+				}
+				return nil
+			},
+			body:   `[{"questionId":1,"answerId":11},{"questionId":2,"skipped":true}]`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			getRequestUserID = func(request *http.Request) (int, error) { return 1, nil }
+			if tc.getUserID != nil {
+				getRequestUserID = tc.getUserID
+			}
+			repo.GetUserTriviaPlayID = func(userID, triviaID int) (int, error) { return 3, nil }
+			if tc.getUserTriviaPlayID != nil {
+				repo.GetUserTriviaPlayID = tc.getUserTriviaPlayID
+			}
+			repo.GetTriviaAnswerOptions = tc.getTriviaAnswerOptions
+			repo.CreateTriviaQuestionResults = tc.createTriviaQuestionResults
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"triviaId": tc.triviaID,
+			})
+
+			writer := httptest.NewRecorder()
+			CreateTriviaQuestionResults(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+// MAX_TEMPLATE_SEED keeps generated seeds within the integers a browser can
+// hold exactly, so a seed read from a response can be sent back as is.
+const MAX_TEMPLATE_SEED = 1 << 53
+
+type GenerateTriviaQuestionsDto struct {
+	Count int    `json:"count" validate:"min=0,max=100"`
+	Seed  *int64 `json:"seed"`
+}
+
+type GeneratedTriviaQuestionsDto struct {
+	Seed      int64                              `json:"seed"`
+	DryRun    bool                               `json:"dryRun"`
+	Generated int                                `json:"generated"`
+	Saved     int                                `json:"saved"`
+	Questions []repo.ManualTriviaQuestionBankDto `json:"questions"`
+}
+
+func GetTriviaQuestionTemplates(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	templates, err := repo.GetTriviaQuestionTemplates()
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(templates)
+}
+
+func (s *Server) createTriviaQuestionTemplate(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	dto, code, err := s.getTriviaQuestionTemplateBody(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := repo.CreateTriviaQuestionTemplate(dto)
+	if err != nil {
+		writeAdminChangeError(writer, err, "invalid question type or category\n")
+		return
+	}
+
+	template, err := repo.GetTriviaQuestionTemplate(id)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(template)
+}
+
+func (s *Server) updateTriviaQuestionTemplate(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	dto, code, err := s.getTriviaQuestionTemplateBody(request)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	if err = repo.UpdateTriviaQuestionTemplate(id, dto); err != nil {
+		writeAdminChangeError(writer, err, "invalid question type or category\n")
+		return
+	}
+
+	template, err := repo.GetTriviaQuestionTemplate(id)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(template)
+}
+
+func DeleteTriviaQuestionTemplate(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = repo.DeleteTriviaQuestionTemplate(id); err != nil {
+		writeAdminChangeError(writer, err, "template is in use\n")
+		return
+	}
+}
+
+// getTriviaQuestionTemplateBody reads a template and checks it can generate
+// questions: only text, flag and map questions can be generated, every
+// placeholder has to be a field or attribute of the mapping's entries and map
+// questions need a map to highlight the entries on.
+func (s *Server) getTriviaQuestionTemplateBody(request *http.Request) (repo.TriviaQuestionTemplateDto, int, error) {
+	var dto repo.TriviaQuestionTemplateDto
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return dto, http.StatusBadRequest, err
+	}
+
+	if err = json.Unmarshal(requestBody, &dto); err != nil {
+		return dto, http.StatusBadRequest, err
+	}
+
+	if err = s.vs.GetValidator().Struct(dto); err != nil {
+		return dto, http.StatusBadRequest, err
+	}
+
+	if dto.Distractors == 0 {
+		dto.Distractors = utils.DEFAULT_TEMPLATE_DISTRACTORS
+	}
+
+	switch dto.TypeID {
+	case repo.QUESTION_TYPE_TEXT, repo.QUESTION_TYPE_FLAG:
+		dto.Map = ""
+	case repo.QUESTION_TYPE_MAP:
+		if dto.Map == "" {
+			return dto, http.StatusBadRequest, errors.New("map questions require a map")
+		}
+
+		if _, err = repo.GetMap(dto.Map); err == sql.ErrNoRows {
+			return dto, http.StatusBadRequest, fmt.Errorf("invalid map %s", dto.Map)
+		} else if err != nil {
+			return dto, http.StatusInternalServerError, err
+		}
+	default:
+		return dto, http.StatusBadRequest, fmt.Errorf("questions of type %d can't be generated", dto.TypeID)
+	}
+
+	entries, err := repo.GetMappingEntries(dto.MappingKey)
+	if err != nil {
+		return dto, http.StatusInternalServerError, err
+	}
+
+	if len(entries) == 0 {
+		return dto, http.StatusBadRequest, fmt.Errorf("invalid mapping %s", dto.MappingKey)
+	}
+
+	attributes, err := repo.GetMappingAttributes()
+	if err != nil {
+		return dto, http.StatusInternalServerError, err
+	}
+
+	known := map[string]bool{
+		utils.TEMPLATE_PLACEHOLDER_NAME:     true,
+		utils.TEMPLATE_PLACEHOLDER_CODE:     true,
+		utils.TEMPLATE_PLACEHOLDER_GROUPING: true,
+	}
+
+	for _, attribute := range attributes {
+		known[attribute.Key] = true
+	}
+
+	for _, text := range []string{dto.Question, dto.Answer, dto.Explainer} {
+		for _, placeholder := range utils.TemplatePlaceholders(text) {
+			if !known[placeholder] {
+				return dto, http.StatusBadRequest, fmt.Errorf("invalid placeholder {%s}", placeholder)
+			}
+		}
+	}
+	return dto, http.StatusOK, nil
+}
+
+// generateTriviaQuestions asks a template about the entries of its mapping
+// and adds the questions to the manual question bank. With dryRun=true the
+// questions are only returned. Sending the seed from a previous response back
+// generates the same questions again.
+func (s *Server) generateTriviaQuestions(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto GenerateTriviaQuestionsDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if err = s.vs.GetValidator().Struct(dto); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template, err := repo.GetTriviaQuestionTemplate(id)
+	if err == sql.ErrNoRows {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := getTemplateEntries(template)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := GeneratedTriviaQuestionsDto{
+		DryRun:    request.URL.Query().Get("dryRun") == "true",
+		Questions: []repo.ManualTriviaQuestionBankDto{},
+	}
+
+	if dto.Seed != nil {
+		result.Seed = *dto.Seed
+	} else {
+		result.Seed = rand.Int63n(MAX_TEMPLATE_SEED)
+	}
+
+	generated := utils.GenerateTemplateQuestions(utils.QuestionTemplate{
+		Question:       template.Question,
+		Answer:         template.Answer,
+		Explainer:      template.Explainer,
+		Distractors:    template.Distractors,
+		RequireFlag:    template.TypeID == repo.QUESTION_TYPE_FLAG,
+		RequireElement: template.TypeID == repo.QUESTION_TYPE_MAP,
+	}, entries, dto.Count, result.Seed)
+
+	var questions = []repo.CreateManualTriviaQuestionDto{}
+	for _, val := range generated {
+		question := repo.CreateManualTriviaQuestionDto{
+			TypeID:     template.TypeID,
+			CategoryID: template.CategoryID,
+			Question:   val.Question,
+			Explainer:  val.Explainer,
+			Answers:    []repo.CreateManualTriviaAnswerDto{},
+		}
+
+		switch template.TypeID {
+		case repo.QUESTION_TYPE_FLAG:
+			question.FlagCode = val.Entry.Code
+		case repo.QUESTION_TYPE_MAP:
+			question.Map = template.Map
+			question.Highlighted = val.Entry.SVGName
+		}
+
+		for _, answer := range val.Answers {
+			question.Answers = append(question.Answers, repo.CreateManualTriviaAnswerDto{Text: answer.Text, IsCorrect: answer.IsCorrect})
+		}
+
+		questions = append(questions, question)
+		result.Questions = append(result.Questions, repo.ManualTriviaQuestionBankDto{
+			Type:        template.Type,
+			Category:    template.Category,
+			Question:    question.Question,
+			Map:         question.Map,
+			Highlighted: question.Highlighted,
+			FlagCode:    question.FlagCode,
+			Explainer:   question.Explainer,
+			Answers:     question.Answers,
+		})
+	}
+	result.Generated = len(questions)
+
+	if !result.DryRun && len(questions) > 0 {
+		result.Saved, err = repo.SaveGeneratedTriviaQuestions(questions)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+// getTemplateEntries loads the template's mapping entries, noting which have
+// a flag and, for map questions, which are drawn on the map.
+func getTemplateEntries(template repo.TriviaQuestionTemplate) ([]utils.TemplateEntry, error) {
+	entries, err := repo.GetMappingEntries(template.MappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := make(map[string]bool)
+	if template.TypeID == repo.QUESTION_TYPE_MAP {
+		svgMap, err := repo.GetMap(template.Map)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		for _, element := range svgMap.Elements {
+			elements[element.Name] = true
+		}
+	}
+
+	var result = []utils.TemplateEntry{}
+	for _, entry := range entries {
+		result = append(result, utils.TemplateEntry{
+			Code:       entry.Code,
+			Name:       entry.Name,
+			SVGName:    entry.SVGName,
+			Grouping:   entry.Grouping,
+			HasFlag:    entry.FlagUrl != "",
+			HasElement: elements[entry.SVGName],
+			Attributes: entry.Attributes,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,341 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/geobuff/api/repo"
+	"github.com/geobuff/api/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestCreateTriviaQuestionTemplate(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetMap := repo.GetMap
+	savedGetMappingEntries := repo.GetMappingEntries
+	savedGetMappingAttributes := repo.GetMappingAttributes
+	savedCreate := repo.CreateTriviaQuestionTemplate
+	savedGet := repo.GetTriviaQuestionTemplate
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetMap = savedGetMap
+		repo.GetMappingEntries = savedGetMappingEntries
+		repo.GetMappingAttributes = savedGetMappingAttributes
+		repo.CreateTriviaQuestionTemplate = savedCreate
+		repo.GetTriviaQuestionTemplate = savedGet
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	repo.GetMap = func(className string) (repo.MapDto, error) {
+		if className != "WorldCountries" {
+			return repo.MapDto{}, sql.ErrNoRows
+		}
+		return repo.MapDto{ClassName: className}, nil
+	}
+	repo.GetMappingEntries = func(key string) ([]repo.MappingEntryDto, error) {
+		if key != "world-countries" {
+			return []repo.MappingEntryDto{}, nil
+		}
+		return []repo.MappingEntryDto{{Code: "FR", Name: "France"}}, nil
+	}
+	repo.GetMappingAttributes = func() ([]repo.MappingAttribute, error) {
+		return []repo.MappingAttribute{{ID: 1, Key: "capital", ValueType: repo.MAPPING_ATTRIBUTE_TEXT}}, nil
+	}
+	repo.GetTriviaQuestionTemplate = func(id int) (repo.TriviaQuestionTemplate, error) {
+		return repo.TriviaQuestionTemplate{ID: id}, nil
+	}
+
+	tt := []struct {
+		name   string
+		create func(template repo.TriviaQuestionTemplateDto) (int, error)
+		body   string
+		status int
+	}{
+		{
+			name:   "invalid body",
+			body:   "testing",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "image questions",
+			body:   `{"typeId": 2, "categoryId": 1, "mappingKey": "world-countries", "question": "Which country is this?", "answer": "{name}", "distractors": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "map question without map",
+			body:   `{"typeId": 4, "categoryId": 1, "mappingKey": "world-countries", "question": "Which highlighted region is {name}?", "answer": "{name}", "distractors": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid map",
+			body:   `{"typeId": 4, "categoryId": 1, "mappingKey": "world-countries", "map": "Nowhere", "question": "Which highlighted region is {name}?", "answer": "{name}", "distractors": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid mapping",
+			body:   `{"typeId": 1, "categoryId": 1, "mappingKey": "nowhere", "question": "What is the capital of {name}?", "answer": "{capital}", "distractors": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid placeholder",
+			body:   `{"typeId": 1, "categoryId": 1, "mappingKey": "world-countries", "question": "What is the currency of {name}?", "answer": "{currency}", "distractors": 3}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "error on CreateTriviaQuestionTemplate",
+			create: func(template repo.TriviaQuestionTemplateDto) (int, error) {
+				return 0, errors.New("test")
+			},
+			body:   `{"typeId": 1, "categoryId": 99, "mappingKey": "world-countries", "question": "What is the capital of {name}?", "answer": "{capital}", "distractors": 3}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name: "happy path",
+			create: func(template repo.TriviaQuestionTemplateDto) (int, error) {
+				return 1, nil
+			},
+			body:   `{"typeId": 4, "categoryId": 1, "mappingKey": "world-countries", "map": "WorldCountries", "question": "Which highlighted region is {name}?", "answer": "{name}", "distractors": 3}`,
+			status: http.StatusCreated,
+		},
+		{
+			name: "happy path, default distractors",
+			create: func(template repo.TriviaQuestionTemplateDto) (int, error) {
+				if template.Distractors != utils.DEFAULT_TEMPLATE_DISTRACTORS {
+					return 0, fmt.Errorf("expected %d distractors; got %d", utils.DEFAULT_TEMPLATE_DISTRACTORS, template.Distractors)
+				}
+				return 1, nil
+			},
+			body:   `{"typeId": 1, "categoryId": 1, "mappingKey": "world-countries", "question": "What is the capital of {name}?", "answer": "{capital}"}`,
+			status: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.CreateTriviaQuestionTemplate = tc.create
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			s := getMockServer()
+			s.createTriviaQuestionTemplate(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
+
+func TestGenerateTriviaQuestions(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetTemplate := repo.GetTriviaQuestionTemplate
+	savedGetMap := repo.GetMap
+	savedGetMappingEntries := repo.GetMappingEntries
+	savedSave := repo.SaveGeneratedTriviaQuestions
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetTriviaQuestionTemplate = savedGetTemplate
+		repo.GetMap = savedGetMap
+		repo.GetMappingEntries = savedGetMappingEntries
+		repo.SaveGeneratedTriviaQuestions = savedSave
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	repo.GetMap = func(className string) (repo.MapDto, error) {
+		return repo.MapDto{ClassName: className, Elements: []repo.MapElementDto{{Name: "France"}, {Name: "Germany"}, {Name: "Italy"}}}, nil
+	}
+	repo.GetMappingEntries = func(key string) ([]repo.MappingEntryDto, error) {
+		return []repo.MappingEntryDto{
+			{Code: "FR", Name: "France", SVGName: "France", Grouping: "europe"},
+			{Code: "DE", Name: "Germany", SVGName: "Germany", Grouping: "europe"},
+			{Code: "IT", Name: "Italy", SVGName: "Italy", Grouping: "europe"},
+			{Code: "ES", Name: "Spain", SVGName: "Spain", Grouping: "europe"},
+		}, nil
+	}
+
+	mapTemplate := func(id int) (repo.TriviaQuestionTemplate, error) {
+		return repo.TriviaQuestionTemplate{ID: id, TypeID: repo.QUESTION_TYPE_MAP, Type: "Map", CategoryID: 1, Category: "Geography", MappingKey: "world-countries", Map: "WorldCountries", Question: "Which highlighted country is {name}?", Answer: "{name}", Distractors: 2}, nil
+	}
+
+	tt := []struct {
+		name        string
+		getTemplate func(id int) (repo.TriviaQuestionTemplate, error)
+		save        func(questions []repo.CreateManualTriviaQuestionDto) (int, error)
+		id          string
+		query       string
+		body        string
+		status      int
+		generated   int
+		saved       int
+	}{
+		{
+			name:   "invalid id",
+			id:     "testing",
+			body:   `{}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid count",
+			id:     "1",
+			body:   `{"count": 1000}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "template not found",
+			getTemplate: func(id int) (repo.TriviaQuestionTemplate, error) {
+				return repo.TriviaQuestionTemplate{}, sql.ErrNoRows
+			},
+			id:     "1",
+			body:   `{}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:        "error on SaveGeneratedTriviaQuestions",
+			getTemplate: mapTemplate,
+			save: func(questions []repo.CreateManualTriviaQuestionDto) (int, error) {
+				return 0, errors.New("test")
+			},
+			id:     "1",
+			body:   `{"seed": 1}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:        "happy path, dry run",
+			getTemplate: mapTemplate,
+			save: func(questions []repo.CreateManualTriviaQuestionDto) (int, error) {
+				return 0, errors.New("dry run shouldn't save")
+			},
+			id:        "1",
+			query:     "?dryRun=true",
+			body:      `{"count": 2, "seed": 1}`,
+			status:    http.StatusOK,
+			generated: 2,
+		},
+		{
+			name:        "happy path",
+			getTemplate: mapTemplate,
+			save: func(questions []repo.CreateManualTriviaQuestionDto) (int, error) {
+				for _, question := range questions {
+					if question.Map != "WorldCountries" || question.Highlighted == "Spain" || len(question.Answers) != 3 {
+						return 0, errors.New("unexpected question")
+					}
+				}
+				return len(questions) - 1, nil
+			},
+			id:        "1",
+			body:      `{"seed": 1}`,
+			status:    http.StatusOK,
+			generated: 3,
+			saved:     2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetTriviaQuestionTemplate = tc.getTemplate
+			repo.SaveGeneratedTriviaQuestions = tc.save
+
+			request, err := http.NewRequest("POST", tc.query, bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			request = mux.SetURLVars(request, map[string]string{
+				"id": tc.id,
+			})
+
+			writer := httptest.NewRecorder()
+			s := getMockServer()
+			s.generateTriviaQuestions(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Fatalf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var parsed GeneratedTriviaQuestionsDto
+			if err = json.NewDecoder(result.Body).Decode(&parsed); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if parsed.Seed != 1 || parsed.Generated != tc.generated || parsed.Saved != tc.saved || len(parsed.Questions) != tc.generated {
+				t.Errorf("expected seed 1, %v generated and %v saved; got %+v", tc.generated, tc.saved, parsed)
+			}
+		})
+	}
+}
+
+func TestGenerateTriviaQuestionsIsReproducible(t *testing.T) {
+	savedIsAdmin := IsAdmin
+	savedGetTemplate := repo.GetTriviaQuestionTemplate
+	savedGetMappingEntries := repo.GetMappingEntries
+
+	defer func() {
+		IsAdmin = savedIsAdmin
+		repo.GetTriviaQuestionTemplate = savedGetTemplate
+		repo.GetMappingEntries = savedGetMappingEntries
+	}()
+
+	IsAdmin = func(request *http.Request) (int, error) { return http.StatusOK, nil }
+	repo.GetTriviaQuestionTemplate = func(id int) (repo.TriviaQuestionTemplate, error) {
+		return repo.TriviaQuestionTemplate{ID: id, TypeID: repo.QUESTION_TYPE_FLAG, MappingKey: "world-countries", Question: "Which country does this flag belong to?", Answer: "{name}", Distractors: 3}, nil
+	}
+	repo.GetMappingEntries = func(key string) ([]repo.MappingEntryDto, error) {
+		return []repo.MappingEntryDto{
+			{Code: "FR", Name: "France", FlagUrl: "fr.svg", Grouping: "europe"},
+			{Code: "DE", Name: "Germany", FlagUrl: "de.svg", Grouping: "europe"},
+			{Code: "IT", Name: "Italy", FlagUrl: "it.svg", Grouping: "europe"},
+			{Code: "JP", Name: "Japan", FlagUrl: "jp.svg", Grouping: "asia"},
+			{Code: "KR", Name: "South Korea", FlagUrl: "kr.svg", Grouping: "asia"},
+		}, nil
+	}
+
+	generate := func(body string) GeneratedTriviaQuestionsDto {
+		request, err := http.NewRequest("POST", "?dryRun=true", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("could not create POST request: %v", err)
+		}
+
+		request = mux.SetURLVars(request, map[string]string{
+			"id": "1",
+		})
+
+		writer := httptest.NewRecorder()
+		getMockServer().generateTriviaQuestions(writer, request)
+		result := writer.Result()
+		defer result.Body.Close()
+
+		var parsed GeneratedTriviaQuestionsDto
+		if err = json.NewDecoder(result.Body).Decode(&parsed); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		return parsed
+	}
+
+	first := generate(`{}`)
+	second := generate(`{"seed": ` + fmt.Sprint(first.Seed) + `}`)
+
+	if first.Generated != 5 || !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the returned seed to regenerate the same questions; got %+v and %+v", first, second)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -67,6 +68,13 @@ func GetUser(writer http.ResponseWriter, request *http.Request) {
 	case sql.ErrNoRows:
 		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusNoContent)
 	case nil:
+		// The streak is extra detail, so the user is still returned without it.
+		streak, err := repo.GetUserTriviaStreak(id)
+		if err != nil {
+			log.Printf("failed to get trivia streak for user %d: %v", id, err)
+		}
+
+		user.TriviaStreak = streak
 		writer.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(writer).Encode(user)
 	default:
@@ -212,6 +220,8 @@ func (s Server) updateUserXP(writer http.ResponseWriter, request *http.Request)
 		return
 	}
 
+	evaluateUserBadges(id, repo.BADGE_EVENT_XP_UPDATE)
+
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(increase)
 }
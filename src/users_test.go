@@ -140,40 +140,56 @@ import (
 
 func TestGetUser(t *testing.T) {
 	savedGetUser := repo.GetUser
+	savedGetUserTriviaStreak := repo.GetUserTriviaStreak
 
 	defer func() {
 		repo.GetUser = savedGetUser
+		repo.GetUserTriviaStreak = savedGetUserTriviaStreak
 	}()
 
 	tt := []struct {
-		name    string
-		getUser func(id int) (repo.UserDto, error)
-		id      string
-		status  int
+		name                string
+		getUser             func(id int) (repo.UserDto, error)
+		getUserTriviaStreak func(userID int) (repo.TriviaStreakDto, error)
+		id                  string
+		status              int
 	}{
 		{
-			name:    "invalid id",
-			getUser: repo.GetUser,
-			id:      "testing",
-			status:  http.StatusBadRequest,
+			name:                "invalid id",
+			getUser:             repo.GetUser,
+			getUserTriviaStreak: repo.GetUserTriviaStreak,
+			id:                  "testing",
+			status:              http.StatusBadRequest,
 		},
 		{
-			name:    "valid id, error on GetUser",
-			getUser: func(id int) (repo.UserDto, error) { return repo.UserDto{}, errors.New("test") },
-			id:      "1",
-			status:  http.StatusInternalServerError,
+			name:                "valid id, error on GetUser",
+			getUser:             func(id int) (repo.UserDto, error) { return repo.UserDto{}, errors.New("test") },
+			getUserTriviaStreak: repo.GetUserTriviaStreak,
+			id:                  "1",
+			status:              http.StatusInternalServerError,
+		},
+		{
+			name:                "valid id, error on GetUserTriviaStreak is logged",
+			getUser:             func(id int) (repo.UserDto, error) { return repo.UserDto{}, nil },
+			getUserTriviaStreak: func(userID int) (repo.TriviaStreakDto, error) { return repo.TriviaStreakDto{}, errors.New("test") },
+			id:                  "1",
+			status:              http.StatusOK,
 		},
 		{
 			name:    "happy path",
 			getUser: func(id int) (repo.UserDto, error) { return repo.UserDto{}, nil },
-			id:      "1",
-			status:  http.StatusOK,
+			getUserTriviaStreak: func(userID int) (repo.TriviaStreakDto, error) {
+				return repo.TriviaStreakDto{Current: 2, Longest: 5}, nil
+			},
+			id:     "1",
+			status: http.StatusOK,
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			repo.GetUser = tc.getUser
+			repo.GetUserTriviaStreak = tc.getUserTriviaStreak
 
 			request, err := http.NewRequest("GET", "", nil)
 			if err != nil {
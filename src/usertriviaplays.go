@@ -0,0 +1,174 @@
+package src
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/gorilla/mux"
+)
+
+type TriviaLeaderboardDto struct {
+	Entries []repo.TriviaLeaderboardEntryDto `json:"entries"`
+	HasMore bool                             `json:"hasMore"`
+}
+
+func CreateUserTriviaPlay(writer http.ResponseWriter, request *http.Request) {
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var play repo.CreateUserTriviaPlayDto
+	err = json.Unmarshal(requestBody, &play)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if code, err := ValidUser(request, play.UserID); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	location, err := time.LoadLocation(play.Timezone)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	trivia, err := repo.GetTriviaById(play.TriviaID)
+	if err == sql.ErrNoRows {
+		http.Error(writer, "trivia does not exist", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	if play.Score < 0 || play.Score > trivia.MaxScore {
+		http.Error(writer, "score exceeds maximum allowed for trivia", http.StatusBadRequest)
+		return
+	}
+
+	err = repo.InsertUserTriviaPlay(play, trivia, location)
+	if repo.IsUniqueViolation(err) {
+		http.Error(writer, "trivia already completed by user", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	evaluateUserBadges(play.UserID, repo.BADGE_EVENT_TRIVIA_PLAY)
+
+	streak, err := repo.GetUserTriviaStreak(play.UserID)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
+	json.NewEncoder(writer).Encode(streak)
+}
+
+func GetUserTriviaStreak(writer http.ResponseWriter, request *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(request)["userId"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	streak, err := repo.GetUserTriviaStreak(userID)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(streak)
+}
+
+func AddUserStreakFreezes(writer http.ResponseWriter, request *http.Request) {
+	if code, err := IsAdmin(request); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), code)
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(request)["id"])
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var dto repo.UpdateStreakFreezesDto
+	err = json.Unmarshal(requestBody, &dto)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	if dto.Count <= 0 {
+		http.Error(writer, "count must be greater than zero", http.StatusBadRequest)
+		return
+	}
+
+	err = repo.AddUserStreakFreezes(userID, dto.Count)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetTriviaLeaderboard(writer http.ResponseWriter, request *http.Request) {
+	date := mux.Vars(request)["date"]
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	requestBody, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	var filterParams repo.GetTriviaLeaderboardFilterParams
+	err = json.Unmarshal(requestBody, &filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := repo.GetTriviaLeaderboard(date, filterParams)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch _, err := repo.GetFirstTriviaLeaderboardID(date, filterParams); err {
+	case sql.ErrNoRows:
+		entriesDto := TriviaLeaderboardDto{entries, false}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(entriesDto)
+	case nil:
+		entriesDto := TriviaLeaderboardDto{entries, true}
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(entriesDto)
+	default:
+		http.Error(writer, fmt.Sprintf("%v\n", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,126 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/geobuff/api/repo"
+	"github.com/lib/pq"
+)
+
+func TestCreateUserTriviaPlay(t *testing.T) {
+	savedValidUser := ValidUser
+	savedGetTriviaById := repo.GetTriviaById
+	savedInsertUserTriviaPlay := repo.InsertUserTriviaPlay
+	savedEvaluateUserBadges := repo.EvaluateUserBadges
+	savedGetUserTriviaStreak := repo.GetUserTriviaStreak
+
+	defer func() {
+		ValidUser = savedValidUser
+		repo.GetTriviaById = savedGetTriviaById
+		repo.InsertUserTriviaPlay = savedInsertUserTriviaPlay
+		repo.EvaluateUserBadges = savedEvaluateUserBadges
+		repo.GetUserTriviaStreak = savedGetUserTriviaStreak
+	}()
+
+	ValidUser = func(request *http.Request, id int) (int, error) { return http.StatusOK, nil }
+	repo.EvaluateUserBadges = func(userID int, event string) error { return nil }
+	repo.GetUserTriviaStreak = func(userID int) (repo.TriviaStreakDto, error) {
+		return repo.TriviaStreakDto{Current: 1, Longest: 1}, nil
+	}
+
+	trivia := repo.Trivia{ID: 1, Name: "Trivia", Date: time.Now(), MaxScore: 10}
+
+	tt := []struct {
+		name                 string
+		getTriviaById        func(id int) (repo.Trivia, error)
+		insertUserTriviaPlay func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error
+		body                 string
+		status               int
+	}{
+		{
+			name:                 "invalid body",
+			getTriviaById:        func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error { return nil },
+			body:                 "testing",
+			status:               http.StatusBadRequest,
+		},
+		{
+			name:                 "invalid timezone",
+			getTriviaById:        func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error { return nil },
+			body:                 `{"userId": 1, "triviaId": 1, "score": 5, "timezone": "Middle/Earth"}`,
+			status:               http.StatusBadRequest,
+		},
+		{
+			name:                 "trivia does not exist",
+			getTriviaById:        func(id int) (repo.Trivia, error) { return repo.Trivia{}, sql.ErrNoRows },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error { return nil },
+			body:                 `{"userId": 1, "triviaId": 1, "score": 5, "timezone": "Pacific/Auckland"}`,
+			status:               http.StatusBadRequest,
+		},
+		{
+			name:                 "score exceeds max",
+			getTriviaById:        func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error { return nil },
+			body:                 `{"userId": 1, "triviaId": 1, "score": 11, "timezone": "Pacific/Auckland"}`,
+			status:               http.StatusBadRequest,
+		},
+		{
+			name:          "already played",
+			getTriviaById: func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error {
+				return &pq.Error{Code: "23505"}
+			},
+			body:   `{"userId": 1, "triviaId": 1, "score": 5, "timezone": "Pacific/Auckland"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:          "error on InsertUserTriviaPlay",
+			getTriviaById: func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error {
+				return errors.New("test")
+			},
+			body:   `{"userId": 1, "triviaId": 1, "score": 5, "timezone": "Pacific/Auckland"}`,
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:          "happy path",
+			getTriviaById: func(id int) (repo.Trivia, error) { return trivia, nil },
+			insertUserTriviaPlay: func(play repo.CreateUserTriviaPlayDto, trivia repo.Trivia, location *time.Location) error {
+				if location.String() != "Pacific/Auckland" {
+					return errors.New("unexpected location")
+				}
+				return nil
+			},
+			body:   `{"userId": 1, "triviaId": 1, "score": 10, "timezone": "Pacific/Auckland"}`,
+			status: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.GetTriviaById = tc.getTriviaById
+			repo.InsertUserTriviaPlay = tc.insertUserTriviaPlay
+
+			request, err := http.NewRequest("POST", "", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("could not create POST request: %v", err)
+			}
+
+			writer := httptest.NewRecorder()
+			CreateUserTriviaPlay(writer, request)
+			result := writer.Result()
+			defer result.Body.Close()
+
+			if result.StatusCode != tc.status {
+				t.Errorf("expected status %v; got %v", tc.status, result.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Address struct {
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2"`
+	City        string `json:"city"`
+	Region      string `json:"region"`
+	Postcode    string `json:"postcode"`
+	CountryCode string `json:"countryCode"`
+}
+
+// String formats the address on one line, mostly for places that only show the
+// legacy free text address.
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Line1, a.Line2, a.City, strings.TrimSpace(a.Region + " " + a.Postcode), strings.ToUpper(a.CountryCode)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+type postcodeFormat struct {
+	pattern *regexp.Regexp
+	// inward is the length of the last part of the postcode, which is split off
+	// with a space for countries that write it that way, e.g. SW1A 1AA.
+	inward         int
+	regionRequired bool
+}
+
+var countryCodePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// Countries without a format accept any postcode, or none.
+var postcodeFormats = map[string]postcodeFormat{
+	"nz": {pattern: regexp.MustCompile(`^\d{4}$`)},
+	"au": {pattern: regexp.MustCompile(`^\d{4}$`), regionRequired: true},
+	"us": {pattern: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true},
+	"ca": {pattern: regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`), inward: 3, regionRequired: true},
+	"gb": {pattern: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`), inward: 3},
+	"ie": {pattern: regexp.MustCompile(`^[A-Z]\d[\dW] [A-Z\d]{4}$`), inward: 4},
+	"nl": {pattern: regexp.MustCompile(`^\d{4} [A-Z]{2}$`), inward: 2},
+	"de": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"fr": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"es": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"it": {pattern: regexp.MustCompile(`^\d{5}$`)},
+	"jp": {pattern: regexp.MustCompile(`^\d{3}-\d{4}$`)},
+	"sg": {pattern: regexp.MustCompile(`^\d{6}$`)},
+	"in": {pattern: regexp.MustCompile(`^\d{6}$`)},
+}
+
+// NormaliseAddress trims the fields, lowercases the country and formats the
+// postcode the way the country writes it.
+func NormaliseAddress(address Address) Address {
+	address.Line1 = strings.TrimSpace(address.Line1)
+	address.Line2 = strings.TrimSpace(address.Line2)
+	address.City = strings.TrimSpace(address.City)
+	address.Region = strings.TrimSpace(address.Region)
+	address.CountryCode = strings.ToLower(strings.TrimSpace(address.CountryCode))
+	address.Postcode = strings.ToUpper(strings.Join(strings.Fields(address.Postcode), " "))
+
+	format, found := postcodeFormats[address.CountryCode]
+	if found && format.inward > 0 {
+		compact := strings.ReplaceAll(address.Postcode, " ", "")
+		if len(compact) > format.inward {
+			address.Postcode = compact[:len(compact)-format.inward] + " " + compact[len(compact)-format.inward:]
+		}
+	}
+	return address
+}
+
+// ValidateAddress normalises the address and checks it has everything needed
+// to print a shipping label.
+func ValidateAddress(address Address) (Address, error) {
+	address = NormaliseAddress(address)
+	if address.Line1 == "" {
+		return address, errors.New("address line 1 is required")
+	}
+
+	if address.City == "" {
+		return address, errors.New("city is required")
+	}
+
+	if !countryCodePattern.MatchString(address.CountryCode) {
+		return address, fmt.Errorf("invalid country code %s", address.CountryCode)
+	}
+
+	format, found := postcodeFormats[address.CountryCode]
+	if !found {
+		return address, nil
+	}
+
+	if format.regionRequired && address.Region == "" {
+		return address, fmt.Errorf("region is required for %s addresses", strings.ToUpper(address.CountryCode))
+	}
+
+	if !format.pattern.MatchString(address.Postcode) {
+		return address, fmt.Errorf("invalid postcode %s for %s", address.Postcode, strings.ToUpper(address.CountryCode))
+	}
+	return address, nil
+}
+
+var countryNames = map[string][]string{
+	"nz": {"new zealand", "nz", "aotearoa"},
+	"au": {"australia", "au"},
+	"us": {"united states", "united states of america", "usa", "us"},
+	"ca": {"canada", "ca"},
+	"gb": {"united kingdom", "uk", "gb", "great britain", "england", "scotland", "wales"},
+}
+
+// ParseAddress splits a legacy free text address into fields on a best-effort
+// basis, e.g. "1 Queen St, Auckland Central, Auckland 1010". The address is
+// only marked ok if the parsed result is valid, otherwise the original text is
+// kept in line 1 so nothing is lost.
+func ParseAddress(text, countryCode string) (Address, bool) {
+	countryCode = strings.ToLower(strings.TrimSpace(countryCode))
+	var parts []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) > 1 && isCountryName(parts[len(parts)-1], countryCode) {
+		parts = parts[:len(parts)-1]
+	}
+
+	fallback := Address{Line1: strings.TrimSpace(text), CountryCode: countryCode}
+	if len(parts) < 2 {
+		return fallback, false
+	}
+
+	address := Address{Line1: parts[0], CountryCode: countryCode}
+	last := parts[len(parts)-1]
+	middle := parts[1 : len(parts)-1]
+
+	words := strings.Fields(last)
+	format, found := postcodeFormats[countryCode]
+	for count := 2; found && count >= 1; count-- {
+		if len(words) < count {
+			continue
+		}
+
+		candidate := NormaliseAddress(Address{Postcode: strings.Join(words[len(words)-count:], " "), CountryCode: countryCode})
+		if format.pattern.MatchString(candidate.Postcode) {
+			address.Postcode = candidate.Postcode
+			words = words[:len(words)-count]
+			break
+		}
+	}
+
+	// Regions are written between the city and postcode, e.g. "Sydney NSW 2000".
+	if format.regionRequired && len(words) > 1 {
+		address.Region = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	address.City = strings.Join(words, " ")
+	if address.City == "" && len(middle) > 0 {
+		address.City = middle[len(middle)-1]
+		middle = middle[:len(middle)-1]
+	}
+	address.Line2 = strings.Join(middle, ", ")
+
+	address, err := ValidateAddress(address)
+	if err != nil {
+		return fallback, false
+	}
+	return address, true
+}
+
+func isCountryName(part, countryCode string) bool {
+	part = strings.ToLower(strings.TrimSpace(part))
+	for _, name := range countryNames[countryCode] {
+		if part == name {
+			return true
+		}
+	}
+	return false
+}
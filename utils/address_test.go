@@ -0,0 +1,135 @@
+package utils
+
+import "testing"
+
+func TestValidateAddress(t *testing.T) {
+	tt := []struct {
+		name     string
+		address  Address
+		postcode string
+		valid    bool
+	}{
+		{
+			name:    "missing line 1",
+			address: Address{City: "Auckland", Postcode: "1010", CountryCode: "nz"},
+			valid:   false,
+		},
+		{
+			name:    "missing city",
+			address: Address{Line1: "1 Queen St", Postcode: "1010", CountryCode: "nz"},
+			valid:   false,
+		},
+		{
+			name:    "invalid country code",
+			address: Address{Line1: "1 Queen St", City: "Auckland", Postcode: "1010", CountryCode: "new zealand"},
+			valid:   false,
+		},
+		{
+			name:    "invalid postcode",
+			address: Address{Line1: "1 Queen St", City: "Auckland", Postcode: "10100", CountryCode: "nz"},
+			valid:   false,
+		},
+		{
+			name:    "missing region",
+			address: Address{Line1: "1 George St", City: "Sydney", Postcode: "2000", CountryCode: "au"},
+			valid:   false,
+		},
+		{
+			name:     "valid nz address",
+			address:  Address{Line1: " 1 Queen St ", City: "Auckland", Postcode: " 1010 ", CountryCode: "NZ"},
+			postcode: "1010",
+			valid:    true,
+		},
+		{
+			name:     "gb postcode is formatted",
+			address:  Address{Line1: "10 Downing St", City: "London", Postcode: "sw1a2aa", CountryCode: "gb"},
+			postcode: "SW1A 2AA",
+			valid:    true,
+		},
+		{
+			name:     "country without postcode format",
+			address:  Address{Line1: "1 Queen's Rd", City: "Hong Kong", CountryCode: "hk"},
+			postcode: "",
+			valid:    true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			address, err := ValidateAddress(tc.address)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tc.valid {
+				if err == nil {
+					t.Error("expected error; got nil")
+				}
+				return
+			}
+
+			if address.Postcode != tc.postcode {
+				t.Errorf("expected postcode %v; got %v", tc.postcode, address.Postcode)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tt := []struct {
+		name        string
+		text        string
+		countryCode string
+		expected    Address
+		ok          bool
+	}{
+		{
+			name:        "nz address",
+			text:        "1 Queen St, Auckland Central, Auckland 1010",
+			countryCode: "nz",
+			expected:    Address{Line1: "1 Queen St", Line2: "Auckland Central", City: "Auckland", Postcode: "1010", CountryCode: "nz"},
+			ok:          true,
+		},
+		{
+			name:        "postcode on its own with country",
+			text:        "12 Cuba St\nTe Aro\nWellington\n6011\nNew Zealand",
+			countryCode: "nz",
+			expected:    Address{Line1: "12 Cuba St", Line2: "Te Aro", City: "Wellington", Postcode: "6011", CountryCode: "nz"},
+			ok:          true,
+		},
+		{
+			name:        "au address with region",
+			text:        "1 George St, Sydney NSW 2000",
+			countryCode: "au",
+			expected:    Address{Line1: "1 George St", City: "Sydney", Region: "NSW", Postcode: "2000", CountryCode: "au"},
+			ok:          true,
+		},
+		{
+			name:        "missing postcode",
+			text:        "1 Queen St, Auckland",
+			countryCode: "nz",
+			expected:    Address{Line1: "1 Queen St, Auckland", CountryCode: "nz"},
+			ok:          false,
+		},
+		{
+			name:        "single line",
+			text:        "1 Queen St Auckland 1010",
+			countryCode: "nz",
+			expected:    Address{Line1: "1 Queen St Auckland 1010", CountryCode: "nz"},
+			ok:          false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			address, ok := ParseAddress(tc.text, tc.countryCode)
+			if ok != tc.ok {
+				t.Errorf("expected ok %v; got %v", tc.ok, ok)
+			}
+
+			if address != tc.expected {
+				t.Errorf("expected address %+v; got %+v", tc.expected, address)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Defaults for the local blob store, which keeps files next to the api and
+// serves them from the assets endpoint.
+const (
+	LOCAL_BLOB_STORE_ROOT = "assets"
+	LOCAL_BLOB_STORE_URL  = "/api/assets"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+var ErrInvalidBlobKey = errors.New("invalid blob key")
+
+// IBlobStore stores uploaded files by key, e.g. flags/world-countries/nz.svg.
+// Put returns the public url the file can be loaded from.
+type IBlobStore interface {
+	Put(key, contentType string, data []byte) (string, error)
+	Get(key string) ([]byte, string, error)
+	Delete(key string) error
+}
+
+// IMediaStore is a blob store that can also list what it holds, so files
+// nothing refers to any more can be found and cleaned up. URL gives the
+// public url of a key before anything is stored under it.
+type IMediaStore interface {
+	IBlobStore
+	List(prefix string) ([]string, error)
+	URL(key string) string
+}
+
+// LocalBlobStore keeps files on disk under root. They're served by the api,
+// so baseURL should be the public url of the assets endpoint.
+type LocalBlobStore struct {
+	root    string
+	baseURL string
+}
+
+func NewLocalBlobStore(root, baseURL string) *LocalBlobStore {
+	if root == "" {
+		root = LOCAL_BLOB_STORE_ROOT
+	}
+
+	if baseURL == "" {
+		baseURL = LOCAL_BLOB_STORE_URL
+	}
+	return &LocalBlobStore{root: root, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (s *LocalBlobStore) Put(key, contentType string, data []byte) (string, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a failed upload never leaves half a
+	// file where the old one was.
+	temp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return "", err
+	}
+
+	if err = temp.Close(); err != nil {
+		return "", err
+	}
+
+	if err = os.Rename(temp.Name(), file); err != nil {
+		return "", err
+	}
+	return s.URL(key), nil
+}
+
+func (s *LocalBlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *LocalBlobStore) Get(key string) ([]byte, string, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", ErrBlobNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	return data, mime.TypeByExtension(path.Ext(key)), nil
+}
+
+func (s *LocalBlobStore) Delete(key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(file); errors.Is(err, os.ErrNotExist) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+// List returns the keys of every file under prefix, skipping uploads that
+// are still being written.
+func (s *LocalBlobStore) List(prefix string) ([]string, error) {
+	var keys = []string{}
+	err := filepath.WalkDir(s.root, func(file string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(s.root, file)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(relative); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// path maps a key to a file under root, refusing keys that would escape it.
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if !IsValidBlobKey(key) {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// IsValidBlobKey checks a key is a clean relative path with no hidden parts.
+func IsValidBlobKey(key string) bool {
+	if key == "" || path.Clean(key) != key || path.IsAbs(key) || strings.Contains(key, "\\") {
+		return false
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
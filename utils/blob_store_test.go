@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocalBlobStore(root, "https://api.geobuff.com/api/assets/")
+
+	url, err := store.Put("flags/world-countries/nz.svg", "image/svg+xml", []byte("<svg></svg>"))
+	if err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	if url != "https://api.geobuff.com/api/assets/flags/world-countries/nz.svg" {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	data, contentType, err := store.Get("flags/world-countries/nz.svg")
+	if err != nil || string(data) != "<svg></svg>" || contentType != "image/svg+xml" {
+		t.Errorf("expected stored svg; got %s %s %v", data, contentType, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "flags", "world-countries"))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected only the stored file to be left behind; got %v %v", entries, err)
+	}
+
+	if _, err = store.Put("media/abc.png", "image/png", []byte("png")); err != nil {
+		t.Fatalf("expected no error; got %v", err)
+	}
+
+	keys, err := store.List("media/")
+	if err != nil || len(keys) != 1 || keys[0] != "media/abc.png" {
+		t.Errorf("expected only the media key; got %v %v", keys, err)
+	}
+
+	if err = store.Delete("flags/world-countries/nz.svg"); err != nil {
+		t.Errorf("expected no error; got %v", err)
+	}
+
+	if _, _, err = store.Get("flags/world-countries/nz.svg"); err != ErrBlobNotFound {
+		t.Errorf("expected ErrBlobNotFound; got %v", err)
+	}
+
+	if err = store.Delete("flags/world-countries/nz.svg"); err != ErrBlobNotFound {
+		t.Errorf("expected ErrBlobNotFound; got %v", err)
+	}
+
+	for _, key := range []string{"", "../secret", "flags/../../secret", "/etc/passwd", "flags//nz.svg", "flags/.hidden", "flags\\nz.svg"} {
+		if _, err = store.Put(key, "text/plain", []byte("test")); err != ErrInvalidBlobKey {
+			t.Errorf("expected ErrInvalidBlobKey for %q; got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"database/sql"
+	"math"
+)
+
+// BASE_CURRENCY is the currency merch, shipping and discount prices are stored
+// in. Other currencies either have their own prices or are converted from it.
+// Only currencies with two decimal places are supported.
+const BASE_CURRENCY = "NZD"
+
+// Currency describes how to convert from the base currency. Converted prices
+// are rounded up to the increment and then, if set, to the next price with the
+// given ending, e.g. 0.99.
+type Currency struct {
+	Code              string          `json:"code"`
+	Rate              float64         `json:"rate"`
+	RoundingIncrement float64         `json:"roundingIncrement"`
+	PriceEnding       sql.NullFloat64 `json:"priceEnding"`
+}
+
+// ShippingTier is the price for carts up to the given quantity and weight in
+// grams. A missing limit means there is no limit.
+type ShippingTier struct {
+	MaxQuantity sql.NullInt64
+	MaxWeight   sql.NullInt64
+	Price       float64
+}
+
+// ConvertPrice converts a display price, applying the currency's rounding rules
+// so customers see tidy amounts.
+func ConvertPrice(amount float64, currency Currency) float64 {
+	if currency.Code == BASE_CURRENCY {
+		return amount
+	}
+
+	cents := int64(math.Ceil(amount*currency.Rate*100 - 1e-6))
+	if increment := toCents(currency.RoundingIncrement); increment > 1 {
+		cents = int64(math.Ceil(float64(cents)/float64(increment))) * increment
+	}
+
+	if currency.PriceEnding.Valid {
+		ending := toCents(currency.PriceEnding.Float64)
+		whole := int64(math.Ceil(float64(cents-ending) / 100))
+		cents = whole*100 + ending
+	}
+	return fromCents(cents)
+}
+
+// ConvertAmount converts an amount off, like a discount, to the nearest cent
+// without any price rounding rules.
+func ConvertAmount(amount float64, currency Currency) float64 {
+	if currency.Code == BASE_CURRENCY {
+		return amount
+	}
+	return fromCents(toCents(amount * currency.Rate))
+}
+
+// SelectShippingTier picks the smallest tier that fits the cart. Tiers must be
+// ordered from smallest to largest.
+func SelectShippingTier(tiers []ShippingTier, quantity, weight int) (ShippingTier, bool) {
+	for _, tier := range tiers {
+		if tier.MaxQuantity.Valid && int64(quantity) > tier.MaxQuantity.Int64 {
+			continue
+		}
+
+		if tier.MaxWeight.Valid && int64(weight) > tier.MaxWeight.Int64 {
+			continue
+		}
+		return tier, true
+	}
+	return ShippingTier{}, false
+}
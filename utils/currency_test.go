@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestConvertPrice(t *testing.T) {
+	tt := []struct {
+		name     string
+		amount   float64
+		currency Currency
+		expected float64
+	}{
+		{
+			name:     "base currency",
+			amount:   49.99,
+			currency: Currency{Code: BASE_CURRENCY, Rate: 1},
+			expected: 49.99,
+		},
+		{
+			name:     "rounds up to the cent",
+			amount:   49.99,
+			currency: Currency{Code: "AUD", Rate: 0.9, RoundingIncrement: 0.01},
+			expected: 45,
+		},
+		{
+			name:     "rounds up to increment",
+			amount:   11.99,
+			currency: Currency{Code: "USD", Rate: 0.61, RoundingIncrement: 0.5},
+			expected: 7.5,
+		},
+		{
+			name:     "price ending",
+			amount:   49.99,
+			currency: Currency{Code: "GBP", Rate: 0.49, RoundingIncrement: 0.01, PriceEnding: sql.NullFloat64{Float64: 0.99, Valid: true}},
+			expected: 24.99,
+		},
+		{
+			name:     "price ending bumps to next whole amount",
+			amount:   29.99,
+			currency: Currency{Code: "USD", Rate: 0.6, RoundingIncrement: 0.01, PriceEnding: sql.NullFloat64{Float64: 0.95, Valid: true}},
+			expected: 18.95,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ConvertPrice(tc.amount, tc.currency)
+			if result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestSelectShippingTier(t *testing.T) {
+	tiers := []ShippingTier{
+		{MaxQuantity: sql.NullInt64{Int64: 2, Valid: true}, MaxWeight: sql.NullInt64{Int64: 500, Valid: true}, Price: 5},
+		{MaxWeight: sql.NullInt64{Int64: 2000, Valid: true}, Price: 10},
+	}
+
+	tt := []struct {
+		name     string
+		quantity int
+		weight   int
+		found    bool
+		price    float64
+	}{
+		{name: "small parcel", quantity: 1, weight: 200, found: true, price: 5},
+		{name: "too many items for small tier", quantity: 3, weight: 300, found: true, price: 10},
+		{name: "too heavy for small tier", quantity: 1, weight: 800, found: true, price: 10},
+		{name: "too heavy for all tiers", quantity: 1, weight: 2500, found: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tier, found := SelectShippingTier(tiers, tc.quantity, tc.weight)
+			if found != tc.found {
+				t.Fatalf("expected found %v; got %v", tc.found, found)
+			}
+
+			if tier.Price != tc.price {
+				t.Errorf("expected price %v; got %v", tc.price, tier.Price)
+			}
+		})
+	}
+}
@@ -11,6 +11,9 @@ import (
 
 type IEmailService interface {
 	SendResetToken(email, resetLink string) (*rest.Response, error)
+	SendOrderStatusUpdate(email string, update OrderStatusEmail) (*rest.Response, error)
+	SendOrderAccessLink(email, accessLink string) (*rest.Response, error)
+	SendLowStockAlert(email string, items []LowStockItem, threshold int) (*rest.Response, error)
 }
 
 type EmailService struct{}
@@ -29,3 +32,42 @@ func (e *EmailService) SendResetToken(email, resetLink string) (*rest.Response,
 	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
 	return client.Send(message)
 }
+
+// SendOrderStatusUpdate emails the customer using the template for the new
+// status. Statuses without a template (e.g. pending) are skipped.
+func (e *EmailService) SendOrderStatusUpdate(email string, update OrderStatusEmail) (*rest.Response, error) {
+	subject, plainTextContent, htmlContent, found, err := RenderOrderStatusEmail(update)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	from := mail.NewEmail(os.Getenv("EMAIL_NAME"), os.Getenv("EMAIL_ADDRESS"))
+	to := mail.NewEmail(update.FirstName, email)
+	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+	return client.Send(message)
+}
+
+func (e *EmailService) SendOrderAccessLink(email, accessLink string) (*rest.Response, error) {
+	from := mail.NewEmail(os.Getenv("EMAIL_NAME"), os.Getenv("EMAIL_ADDRESS"))
+	subject := "View Your Orders"
+	to := mail.NewEmail("Customer", email)
+	plainTextContent := fmt.Sprintf("Hi there,\n\nBelow is the link to view your GeoBuff orders. It will expire in 7 days:\n%s\n\nIf you did not request this link please disregard this email.\n\nFrom,\nThe GeoBuff Team", accessLink)
+	htmlContent := fmt.Sprintf("<div><p>Hi there,</p><p>Below is the link to view your GeoBuff orders. It will expire in 7 days:</p><p>%s</p><p>If you did not request this link please disregard this email.</p><p>From,</p><p>The GeoBuff Team</p></div>", accessLink)
+	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+	return client.Send(message)
+}
+
+func (e *EmailService) SendLowStockAlert(email string, items []LowStockItem, threshold int) (*rest.Response, error) {
+	subject, plainTextContent, htmlContent, err := RenderLowStockEmail(items, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	from := mail.NewEmail(os.Getenv("EMAIL_NAME"), os.Getenv("EMAIL_ADDRESS"))
+	to := mail.NewEmail("Admin", email)
+	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+	return client.Send(message)
+}
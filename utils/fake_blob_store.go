@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+type fakeBlob struct {
+	contentType string
+	data        []byte
+}
+
+// FakeBlobStore keeps blobs in memory, for tests and local development
+// without a disk to write to.
+type FakeBlobStore struct {
+	mu      sync.Mutex
+	baseURL string
+	blobs   map[string]fakeBlob
+}
+
+func NewFakeBlobStore(baseURL string) *FakeBlobStore {
+	return &FakeBlobStore{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		blobs:   make(map[string]fakeBlob),
+	}
+}
+
+func (s *FakeBlobStore) Put(key, contentType string, data []byte) (string, error) {
+	if !IsValidBlobKey(key) {
+		return "", ErrInvalidBlobKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = fakeBlob{contentType: contentType, data: append([]byte{}, data...)}
+	return s.URL(key), nil
+}
+
+func (s *FakeBlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *FakeBlobStore) Get(key string) ([]byte, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, found := s.blobs[key]
+	if !found {
+		return nil, "", ErrBlobNotFound
+	}
+	return blob.data, blob.contentType, nil
+}
+
+func (s *FakeBlobStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.blobs[key]; !found {
+		return ErrBlobNotFound
+	}
+	delete(s.blobs, key)
+	return nil
+}
+
+func (s *FakeBlobStore) List(prefix string) ([]string, error) {
+	var keys = []string{}
+	for _, key := range s.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Keys returns the keys of every stored blob.
+func (s *FakeBlobStore) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys = []string{}
+	for key := range s.blobs {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FAKE_WEBHOOK_TOLERANCE matches Stripe's default so stale payloads are
+// rejected the same way in tests.
+const FAKE_WEBHOOK_TOLERANCE = 5 * time.Minute
+
+type fakeCheckout struct {
+	request   CheckoutRequest
+	amount    int64
+	paymentID string
+	closed    bool
+}
+
+type fakeWebhookEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Outcome   string `json:"outcome"`
+	SessionID string `json:"sessionId"`
+	OrderID   int    `json:"orderId"`
+	PaymentID string `json:"paymentId"`
+}
+
+// FakePaymentProvider keeps checkouts and payments in memory and produces
+// signed webhook payloads, so the order flow can be exercised without Stripe.
+type FakePaymentProvider struct {
+	mu            sync.Mutex
+	webhookSecret string
+	nextID        int
+	checkouts     map[string]*fakeCheckout
+	payments      map[string]*Payment
+}
+
+func NewFakePaymentProvider(webhookSecret string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		webhookSecret: webhookSecret,
+		checkouts:     make(map[string]*fakeCheckout),
+		payments:      make(map[string]*Payment),
+	}
+}
+
+func (p *FakePaymentProvider) CreateCheckout(request CheckoutRequest) (CheckoutSession, error) {
+	if len(request.LineItems) == 0 {
+		return CheckoutSession{}, errors.New("checkout has no line items")
+	}
+
+	var amount int64
+	for _, item := range request.LineItems {
+		amount = amount + toCents(item.UnitPrice)*int64(item.Quantity)
+	}
+
+	amount = amount - toCents(request.DiscountAmount)
+	if amount < 0 {
+		return CheckoutSession{}, errors.New("discount exceeds the checkout total")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.newID("cs_fake")
+	p.checkouts[id] = &fakeCheckout{request: request, amount: amount}
+	return CheckoutSession{ID: id}, nil
+}
+
+// Checkout returns the request a session was created with.
+func (p *FakePaymentProvider) Checkout(sessionID string) (CheckoutRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	checkout, found := p.checkouts[sessionID]
+	if !found {
+		return CheckoutRequest{}, false
+	}
+	return checkout.request, true
+}
+
+// CompleteCheckout pays for the session and returns the signed webhook the
+// provider would send.
+func (p *FakePaymentProvider) CompleteCheckout(sessionID string) ([]byte, http.Header, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	checkout, err := p.openCheckout(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	checkout.closed = true
+	checkout.paymentID = p.newID("pi_fake")
+	p.payments[checkout.paymentID] = &Payment{
+		ID:       checkout.paymentID,
+		Status:   PAYMENT_STATUS_SUCCEEDED,
+		Amount:   fromCents(checkout.amount),
+		Currency: checkout.request.Currency,
+	}
+
+	return p.event(PAYMENT_EVENT_CHECKOUT_PAID, sessionID, checkout)
+}
+
+// ExpireCheckout closes the session unpaid and returns the signed webhook.
+func (p *FakePaymentProvider) ExpireCheckout(sessionID string) ([]byte, http.Header, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	checkout, err := p.openCheckout(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	checkout.closed = true
+	return p.event(PAYMENT_EVENT_CHECKOUT_FAILED, sessionID, checkout)
+}
+
+func (p *FakePaymentProvider) ExpireCheckoutSession(sessionID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	checkout, found := p.checkouts[sessionID]
+	if !found {
+		return fmt.Errorf("checkout session %s does not exist", sessionID)
+	}
+
+	if checkout.paymentID != "" {
+		return ErrCheckoutCompleted
+	}
+	checkout.closed = true
+	return nil
+}
+
+func (p *FakePaymentProvider) SignWebhook(payload []byte, timestamp time.Time) http.Header {
+	header := make(http.Header)
+	header.Set("Fake-Signature", fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), p.signature(payload, timestamp.Unix())))
+	return header
+}
+
+func (p *FakePaymentProvider) VerifyWebhook(payload []byte, header http.Header) (PaymentEvent, error) {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header.Get("Fake-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signature = value
+		}
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(p.signature(payload, timestamp))) {
+		return PaymentEvent{}, errors.New("webhook has an invalid signature")
+	}
+
+	if time.Since(time.Unix(timestamp, 0)) > FAKE_WEBHOOK_TOLERANCE {
+		return PaymentEvent{}, errors.New("webhook timestamp is outside the tolerance zone")
+	}
+
+	var event fakeWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return PaymentEvent{}, err
+	}
+
+	return PaymentEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		Outcome:   event.Outcome,
+		SessionID: event.SessionID,
+		OrderID:   event.OrderID,
+		PaymentID: event.PaymentID,
+	}, nil
+}
+
+func (p *FakePaymentProvider) GetPayment(paymentID string) (Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, found := p.payments[paymentID]
+	if !found {
+		return Payment{}, fmt.Errorf("payment %s does not exist", paymentID)
+	}
+	return *payment, nil
+}
+
+func (p *FakePaymentProvider) Refund(paymentID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, found := p.payments[paymentID]
+	if !found {
+		return "", fmt.Errorf("payment %s does not exist", paymentID)
+	}
+
+	if payment.Refunded {
+		return "", ErrPaymentAlreadyRefunded
+	}
+
+	payment.Refunded = true
+	return p.newID("re_fake"), nil
+}
+
+func (p *FakePaymentProvider) openCheckout(sessionID string) (*fakeCheckout, error) {
+	checkout, found := p.checkouts[sessionID]
+	if !found {
+		return nil, fmt.Errorf("checkout session %s does not exist", sessionID)
+	}
+
+	if checkout.closed {
+		return nil, fmt.Errorf("checkout session %s is already closed", sessionID)
+	}
+	return checkout, nil
+}
+
+func (p *FakePaymentProvider) event(outcome, sessionID string, checkout *fakeCheckout) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(fakeWebhookEvent{
+		ID:        p.newID("evt_fake"),
+		Type:      outcome,
+		Outcome:   outcome,
+		SessionID: sessionID,
+		OrderID:   checkout.request.OrderID,
+		PaymentID: checkout.paymentID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return payload, p.SignWebhook(payload, time.Now()), nil
+}
+
+func (p *FakePaymentProvider) signature(payload []byte, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakePaymentProvider) newID(prefix string) string {
+	p.nextID = p.nextID + 1
+	return fmt.Sprintf("%s_%d", prefix, p.nextID)
+}
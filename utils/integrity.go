@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Integrity checks, used as the check name on each issue.
+const (
+	INTEGRITY_ORPHANED_ELEMENT       = "orphanedElement"
+	INTEGRITY_UNMAPPED_REGION        = "unmappedRegion"
+	INTEGRITY_MAP_WITHOUT_MAPPING    = "mapWithoutMapping"
+	INTEGRITY_DUPLICATE_CODE         = "duplicateCode"
+	INTEGRITY_DUPLICATE_NAME         = "duplicateName"
+	INTEGRITY_MISSING_FLAG           = "missingFlag"
+	INTEGRITY_QUIZ_MISSING_MAP       = "quizMissingMap"
+	INTEGRITY_QUIZ_MISSING_MAPPING   = "quizMissingMapping"
+	INTEGRITY_TRIVIA_MISSING_MAP     = "triviaMissingMap"
+	INTEGRITY_TRIVIA_MISSING_ELEMENT = "triviaMissingElement"
+	INTEGRITY_TRIVIA_MISSING_FLAG    = "triviaMissingFlag"
+)
+
+type IntegrityMap struct {
+	ClassName string
+	Key       string
+	Elements  []IntegrityMapElement
+}
+
+type IntegrityMapElement struct {
+	ID   string
+	Name string
+}
+
+type IntegrityMapping struct {
+	Key     string
+	Entries []IntegrityMappingEntry
+}
+
+type IntegrityMappingEntry struct {
+	Code             string
+	Name             string
+	SVGName          string
+	AlternativeNames []string
+}
+
+type IntegrityQuiz struct {
+	ID       int
+	Name     string
+	IsMap    bool
+	HasFlags bool
+	MapSVG   string
+	APIPath  string
+}
+
+type IntegrityTriviaQuestion struct {
+	ID          int
+	Manual      bool
+	Question    string
+	Map         string
+	Highlighted string
+	FlagCodes   []string
+}
+
+// IntegrityData is everything the checks look at, loaded up front so the
+// checks themselves don't touch the database.
+type IntegrityData struct {
+	Maps            []IntegrityMap
+	Mappings        []IntegrityMapping
+	FlagGroups      map[string]bool
+	FlagCodes       map[string]bool
+	Quizzes         []IntegrityQuiz
+	TriviaQuestions []IntegrityTriviaQuestion
+}
+
+type IntegrityIssue struct {
+	Check   string `json:"check"`
+	Entity  string `json:"entity"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+type IntegrityReport struct {
+	Counts map[string]int   `json:"counts"`
+	Issues []IntegrityIssue `json:"issues"`
+}
+
+func (r *IntegrityReport) add(check, entity, key, value, message string, args ...interface{}) {
+	r.Counts[check]++
+	r.Issues = append(r.Issues, IntegrityIssue{
+		Check:   check,
+		Entity:  entity,
+		Key:     key,
+		Value:   value,
+		Message: fmt.Sprintf(message, args...),
+	})
+}
+
+// CheckIntegrity looks for maps, mappings, quizzes and trivia questions that
+// refer to rows that don't exist. Maps and mappings are paired on key, quizzes
+// refer to their map by class name and their mapping by api path.
+func CheckIntegrity(data IntegrityData) IntegrityReport {
+	report := IntegrityReport{Counts: make(map[string]int), Issues: []IntegrityIssue{}}
+
+	mappings := make(map[string]IntegrityMapping)
+	for _, mapping := range data.Mappings {
+		mappings[mapping.Key] = mapping
+	}
+
+	maps := make(map[string]IntegrityMap)
+	for _, svgMap := range data.Maps {
+		maps[svgMap.ClassName] = svgMap
+	}
+
+	flagged := make(map[string]bool)
+	for key := range data.FlagGroups {
+		flagged[key] = true
+	}
+
+	for _, quiz := range data.Quizzes {
+		if quiz.HasFlags {
+			flagged[quiz.APIPath] = true
+		}
+	}
+
+	for _, svgMap := range data.Maps {
+		mapping, found := mappings[svgMap.Key]
+		if !found {
+			report.add(INTEGRITY_MAP_WITHOUT_MAPPING, "map", svgMap.ClassName, svgMap.Key, "map %s has no mapping with key %s", svgMap.ClassName, svgMap.Key)
+			continue
+		}
+		checkMapElements(&report, svgMap, mapping)
+	}
+
+	for _, mapping := range data.Mappings {
+		checkMappingEntries(&report, mapping, data.FlagCodes, flagged[mapping.Key])
+	}
+
+	for _, quiz := range data.Quizzes {
+		key := fmt.Sprint(quiz.ID)
+		if _, found := mappings[quiz.APIPath]; !found && !(quiz.HasFlags && data.FlagGroups[quiz.APIPath]) {
+			report.add(INTEGRITY_QUIZ_MISSING_MAPPING, "quiz", key, quiz.APIPath, "quiz %s uses mapping %s which doesn't exist", quiz.Name, quiz.APIPath)
+		}
+
+		if _, found := maps[quiz.MapSVG]; quiz.IsMap && !found {
+			report.add(INTEGRITY_QUIZ_MISSING_MAP, "quiz", key, quiz.MapSVG, "quiz %s uses map %s which doesn't exist", quiz.Name, quiz.MapSVG)
+		}
+	}
+
+	for _, question := range data.TriviaQuestions {
+		checkTriviaQuestion(&report, question, maps, data.FlagCodes)
+	}
+
+	return report
+}
+
+// checkMapElements reports regions drawn on the map that no mapping entry
+// refers to. Elements without an id are decoration and are skipped.
+func checkMapElements(report *IntegrityReport, svgMap IntegrityMap, mapping IntegrityMapping) {
+	codes := make(map[string]bool)
+	names := make(map[string]bool)
+	for _, entry := range mapping.Entries {
+		codes[strings.ToLower(entry.Code)] = true
+		names[entry.SVGName] = true
+	}
+
+	drawn := make(map[string]bool)
+	for _, element := range svgMap.Elements {
+		drawn[element.Name] = true
+		if element.ID == "" || codes[strings.ToLower(element.ID)] || names[element.Name] {
+			continue
+		}
+		report.add(INTEGRITY_ORPHANED_ELEMENT, "map", svgMap.ClassName, element.ID, "element %s (%s) on map %s has no mapping entry", element.ID, element.Name, svgMap.ClassName)
+	}
+
+	for _, entry := range mapping.Entries {
+		if !drawn[entry.SVGName] {
+			report.add(INTEGRITY_UNMAPPED_REGION, "mapping", mapping.Key, entry.Code, "%s (%s) has no element named %s on map %s", entry.Name, entry.Code, entry.SVGName, svgMap.ClassName)
+		}
+	}
+}
+
+// checkMappingEntries reports codes and names that more than one entry in the
+// group answers to, and codes without a flag where the group is used for flags.
+func checkMappingEntries(report *IntegrityReport, mapping IntegrityMapping, flagCodes map[string]bool, flagged bool) {
+	codes := make(map[string][]string)
+	names := make(map[string][]string)
+	for _, entry := range mapping.Entries {
+		code := strings.ToLower(strings.TrimSpace(entry.Code))
+		codes[code] = append(codes[code], entry.Code)
+
+		own := make(map[string]bool)
+		for _, name := range append([]string{entry.Name}, entry.AlternativeNames...) {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !own[name] {
+				own[name] = true
+				names[name] = append(names[name], entry.Code)
+			}
+		}
+
+		if flagged && !flagCodes[entry.Code] {
+			report.add(INTEGRITY_MISSING_FLAG, "mapping", mapping.Key, entry.Code, "%s (%s) has no flag", entry.Name, entry.Code)
+		}
+	}
+
+	for _, code := range sortedKeys(codes) {
+		if len(codes[code]) > 1 {
+			report.add(INTEGRITY_DUPLICATE_CODE, "mapping", mapping.Key, code, "code %s is used by %d entries", code, len(codes[code]))
+		}
+	}
+
+	for _, name := range sortedKeys(names) {
+		if len(names[name]) > 1 {
+			report.add(INTEGRITY_DUPLICATE_NAME, "mapping", mapping.Key, name, "name %s is shared by %s", name, strings.Join(names[name], ", "))
+		}
+	}
+}
+
+func checkTriviaQuestion(report *IntegrityReport, question IntegrityTriviaQuestion, maps map[string]IntegrityMap, flagCodes map[string]bool) {
+	entity := "triviaQuestion"
+	if question.Manual {
+		entity = "manualTriviaQuestion"
+	}
+	key := fmt.Sprint(question.ID)
+
+	if question.Map != "" {
+		svgMap, found := maps[question.Map]
+		if !found {
+			report.add(INTEGRITY_TRIVIA_MISSING_MAP, entity, key, question.Map, "question %q uses map %s which doesn't exist", question.Question, question.Map)
+		} else if question.Highlighted != "" && !hasElementNamed(svgMap, question.Highlighted) {
+			report.add(INTEGRITY_TRIVIA_MISSING_ELEMENT, entity, key, question.Highlighted, "question %q highlights %s which isn't on map %s", question.Question, question.Highlighted, question.Map)
+		}
+	}
+
+	for _, code := range question.FlagCodes {
+		if code != "" && !flagCodes[code] {
+			report.add(INTEGRITY_TRIVIA_MISSING_FLAG, entity, key, code, "question %q uses flag %s which doesn't exist", question.Question, code)
+		}
+	}
+}
+
+func hasElementNamed(svgMap IntegrityMap, name string) bool {
+	for _, element := range svgMap.Elements {
+		if element.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
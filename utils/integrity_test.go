@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	data := IntegrityData{
+		Maps: []IntegrityMap{
+			{
+				ClassName: "OceaniaCountries",
+				Key:       "oceania-countries",
+				Elements: []IntegrityMapElement{
+					{ID: "nz", Name: "New Zealand"},
+					{ID: "au", Name: "Australia"},
+					{ID: "xx", Name: "Atlantis"},
+					{Name: "Ocean"},
+				},
+			},
+			{ClassName: "MarsCraters", Key: "mars-craters"},
+		},
+		Mappings: []IntegrityMapping{
+			{
+				Key: "oceania-countries",
+				Entries: []IntegrityMappingEntry{
+					{Code: "NZ", Name: "new zealand", SVGName: "New Zealand", AlternativeNames: []string{"aotearoa", "New Zealand"}},
+					{Code: "AU", Name: "australia", SVGName: "Australia"},
+					{Code: "FJ", Name: "fiji", SVGName: "Fiji", AlternativeNames: []string{"Aotearoa"}},
+					{Code: "fj", Name: "fiji islands", SVGName: "Fiji"},
+				},
+			},
+			{Key: "world-capitals", Entries: []IntegrityMappingEntry{{Code: "WLG", Name: "wellington", SVGName: "Wellington"}}},
+		},
+		FlagGroups: map[string]bool{"oceania-countries": true},
+		FlagCodes:  map[string]bool{"NZ": true, "AU": true, "FJ": true},
+		Quizzes: []IntegrityQuiz{
+			{ID: 1, Name: "Oceania", IsMap: true, MapSVG: "OceaniaCountries", APIPath: "oceania-countries"},
+			{ID: 2, Name: "Europe", IsMap: true, MapSVG: "EuropeCountries", APIPath: "europe-countries"},
+			{ID: 3, Name: "Oceania Flags", HasFlags: true, APIPath: "oceania-countries"},
+		},
+		TriviaQuestions: []IntegrityTriviaQuestion{
+			{ID: 1, Question: "Which country is highlighted?", Map: "OceaniaCountries", Highlighted: "New Zealand", FlagCodes: []string{"NZ"}},
+			{ID: 2, Question: "Which country is highlighted?", Map: "OceaniaCountries", Highlighted: "Tonga"},
+			{ID: 3, Manual: true, Question: "Where is Olympus Mons?", Map: "MarsMap"},
+			{ID: 4, Manual: true, Question: "Whose flag is this?", FlagCodes: []string{"TO", "NZ"}},
+		},
+	}
+
+	report := CheckIntegrity(data)
+
+	expected := map[string]int{
+		INTEGRITY_ORPHANED_ELEMENT:       1,
+		INTEGRITY_UNMAPPED_REGION:        2,
+		INTEGRITY_MAP_WITHOUT_MAPPING:    1,
+		INTEGRITY_DUPLICATE_CODE:         1,
+		INTEGRITY_DUPLICATE_NAME:         1,
+		INTEGRITY_MISSING_FLAG:           1,
+		INTEGRITY_QUIZ_MISSING_MAP:       1,
+		INTEGRITY_QUIZ_MISSING_MAPPING:   1,
+		INTEGRITY_TRIVIA_MISSING_MAP:     1,
+		INTEGRITY_TRIVIA_MISSING_ELEMENT: 1,
+		INTEGRITY_TRIVIA_MISSING_FLAG:    1,
+	}
+
+	if !reflect.DeepEqual(report.Counts, expected) {
+		t.Errorf("expected counts %v; got %v", expected, report.Counts)
+	}
+
+	values := make(map[string][]string)
+	for _, issue := range report.Issues {
+		values[issue.Check] = append(values[issue.Check], issue.Value)
+	}
+
+	tt := []struct {
+		check    string
+		expected []string
+	}{
+		{check: INTEGRITY_ORPHANED_ELEMENT, expected: []string{"xx"}},
+		{check: INTEGRITY_UNMAPPED_REGION, expected: []string{"FJ", "fj"}},
+		{check: INTEGRITY_DUPLICATE_CODE, expected: []string{"fj"}},
+		{check: INTEGRITY_DUPLICATE_NAME, expected: []string{"aotearoa"}},
+		{check: INTEGRITY_MISSING_FLAG, expected: []string{"fj"}},
+		{check: INTEGRITY_QUIZ_MISSING_MAPPING, expected: []string{"europe-countries"}},
+		{check: INTEGRITY_TRIVIA_MISSING_ELEMENT, expected: []string{"Tonga"}},
+		{check: INTEGRITY_TRIVIA_MISSING_FLAG, expected: []string{"TO"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.check, func(t *testing.T) {
+			if !reflect.DeepEqual(values[tc.check], tc.expected) {
+				t.Errorf("expected %v; got %v", tc.expected, values[tc.check])
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import "strings"
+
+// iso3166Countries holds the ISO 3166-1 alpha-2 codes, plus XK for Kosovo
+// which is user assigned but used everywhere in place of an official code.
+var iso3166Countries = map[string]bool{}
+
+// iso3166Subdivisions holds the ISO 3166-2 codes for the countries we have
+// flag groups for, keyed by country. They match the codes our maps already use,
+// which for a few countries (e.g. Argentina and France) predate the current
+// standard. Add a country's codes here before adding its flag group.
+var iso3166Subdivisions = map[string]map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bl bm bn bo bq br bs bt bv bw by bz
+		ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm do dz ec ee eg eh er es et fi fj fk fm fo fr
+		ga gb gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw gy hk hm hn hr ht hu id ie il im in io iq ir is it je jm jo jp
+		ke kg kh ki km kn kp kr kw ky kz la lb lc li lk lr ls lt lu lv ly ma mc md me mf mg mh mk ml mm mn mo mp mq mr ms mt
+		mu mv mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pl pm pn pr ps pt pw py qa re ro rs ru rw
+		sa sb sc sd se sg sh si sj sk sl sm sn so sr ss st sv sx sy sz tc td tf tg th tj tk tl tm tn to tr tt tv tw tz ua ug
+		um us uy uz va vc ve vg vi vn vu wf ws xk ye yt za zm zw`) {
+		iso3166Countries[code] = true
+	}
+
+	var country string
+	for _, field := range strings.Fields(`
+		ar: ba cb cc ch cn ct df er fm jy lp lr mn mz nq rn sa sc se sf sj sl tf tm
+		au: act jbt nsw nt qld sa tas vic wa
+		br: ac al am ap ba ce df es go ma mg ms mt pa pb pe pi pr rj rn ro rr rs sc se sp to
+		ca: ab bc mb nb nl ns nt nu on pe qc sk yt
+		co: ama ant ara atl bol boy cal caq cas cau ces cho cor cun dc gua guv hui lag mag met nar nsa put qui ris san sap
+			suc tol vac vau vid
+		de: bb be bw by hb he hh mv ni nw rp sh sl sn st th
+		es: a ab al av b ba bi bu c ca cc ce co cr cs cu gc gi gr gu h hu j l le lo lu m ma ml mu na o or p pm po s sa se sg
+			so ss t te tf to v va vi z za
+		fr: ac ao ar bf bt ce cn if lp nc nd pl pr
+		gb: eng sct wls
+		it: 21 23 25 32 34 36 42 45 52 55 57 62 65 67 72 75 77 78 82 88
+		jp: 01 02 03 04 05 06 07 08 09 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30 31 32 33 34 35 36 37
+			38 39 40 41 42 43 44 45 46 47
+		kr: 11 26 27 28 29 30 31 41 42 43 44 45 46 47 48 49 50
+		ru: ad al alt amu ark ast ba bel bry bu ce che chu cu da in irk iva kam kb kc kda kem kgd kgn kha khm kir kk kl klu
+			ko kos kr krs kya len lip mag me mo mos mow mur nen ngr niz nvs oms ore orl per pnz pri psk ros rya sa sak sam sar
+			se smo spe sta sve ta tam tom tul tve ty tyu ud uly vgg vla vlg vor yan yar yev zab
+		ua: 05 07 09 12 14 18 21 23 26 32 35 43 46 48 51 53 56 59 61 63 65 68 71 74 77
+		us: ak al ar az ca co ct dc de fl ga hi ia id il in ks ky la ma md me mi mn mo ms mt nc nd ne nh nj nm nv ny oh ok
+			or pa ri sc sd tn tx ut va vt wa wi wv wy`) {
+		if strings.HasSuffix(field, ":") {
+			country = strings.TrimSuffix(field, ":")
+			iso3166Subdivisions[country] = map[string]bool{}
+			continue
+		}
+		iso3166Subdivisions[country][field] = true
+	}
+}
+
+// IsISO3166Code reports whether code is an ISO 3166-1 alpha-2 country code,
+// like "nz", or a known ISO 3166-2 subdivision, like "us-ca". Codes are
+// compared in lowercase to match flagEntries.
+func IsISO3166Code(code string) bool {
+	code = strings.ToLower(code)
+	if iso3166Countries[code] {
+		return true
+	}
+
+	country, subdivision, found := strings.Cut(code, "-")
+	return found && iso3166Subdivisions[country][subdivision]
+}
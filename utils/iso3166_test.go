@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestIsISO3166Code(t *testing.T) {
+	tt := []struct {
+		code     string
+		expected bool
+	}{
+		{code: "nz", expected: true},
+		{code: "NZ", expected: true},
+		{code: "xk", expected: true},
+		{code: "us-ca", expected: true},
+		{code: "gb-eng", expected: true},
+		{code: "jp-01", expected: true},
+		{code: "US-NY", expected: true},
+		{code: "au-jbt", expected: true},
+		{code: "us-zz", expected: false},
+		{code: "nz-999", expected: false},
+		{code: "nz-auk", expected: false},
+		{code: "zz", expected: false},
+		{code: "zz-ab", expected: false},
+		{code: "us-", expected: false},
+		{code: "us-abcd", expected: false},
+		{code: "nzl", expected: false},
+		{code: "buff", expected: false},
+		{code: "", expected: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.code, func(t *testing.T) {
+			if result := IsISO3166Code(tc.code); result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
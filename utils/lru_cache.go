@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRUCache holds at most maxEntries values, dropping the least recently used
+// once full, so caches keyed on request params can't grow without bound.
+// Values also expire after the expiry.
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	expiry     time.Duration
+	entries    *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func NewLRUCache(maxEntries int, expiry time.Duration) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		expiry:     expiry,
+		entries:    list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (c *LRUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := item.Value.(*lruEntry)
+	if c.now().After(entry.expires) {
+		c.remove(item)
+		return nil, false
+	}
+
+	c.entries.MoveToFront(item)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.expiry)
+	if item, found := c.items[key]; found {
+		item.Value = &lruEntry{key: key, value: value, expires: expires}
+		c.entries.MoveToFront(item)
+		return
+	}
+
+	c.items[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.entries.Len() > c.maxEntries {
+		c.remove(c.entries.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, found := c.items[key]; found {
+		c.remove(item)
+	}
+}
+
+func (c *LRUCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+func (c *LRUCache) remove(item *list.Element) {
+	c.entries.Remove(item)
+	delete(c.items, item.Value.(*lruEntry).key)
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewLRUCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if value, found := cache.Get("a"); !found || value != 1 {
+		t.Fatalf("expected a to be 1; got %v %v", value, found)
+	}
+
+	cache.Set("c", 3)
+	if _, found := cache.Get("b"); found {
+		t.Errorf("expected least recently used b to be evicted")
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries; got %v", cache.Len())
+	}
+
+	cache.Set("a", 4)
+	if value, _ := cache.Get("a"); value != 4 {
+		t.Errorf("expected a to be replaced with 4; got %v", value)
+	}
+
+	cache.Delete("a")
+	if _, found := cache.Get("a"); found {
+		t.Errorf("expected a to be deleted")
+	}
+
+	cache.Set("d", 5)
+	cache.Flush()
+	if _, found := cache.Get("d"); found || cache.Len() != 0 {
+		t.Errorf("expected flush to remove everything; got %v entries", cache.Len())
+	}
+
+	cache.Set("c", 3)
+	now = now.Add(2 * time.Minute)
+	if _, found := cache.Get("c"); found {
+		t.Errorf("expected c to expire")
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("expected no entries; got %v", cache.Len())
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// Default fuzzy matching rules. An answer may be DEFAULT_MATCH_CHARS_PER_EDIT
+// characters long for each edit it's allowed, up to DEFAULT_MATCH_MAX_DISTANCE,
+// so short names like "Chad" have to be spelled exactly.
+const (
+	DEFAULT_MATCH_CHARS_PER_EDIT = 5
+	DEFAULT_MATCH_MAX_DISTANCE   = 3
+)
+
+// MatchCandidate is something an answer can match, e.g. a mapping entry.
+// Prefixes are optional words that can be put before any of the names, like
+// "the" in "the bahamas".
+type MatchCandidate struct {
+	Key      string
+	Names    []string
+	Prefixes []string
+}
+
+type MatchOptions struct {
+	CharsPerEdit int
+	MaxDistance  int
+}
+
+func DefaultMatchOptions() MatchOptions {
+	return MatchOptions{CharsPerEdit: DEFAULT_MATCH_CHARS_PER_EDIT, MaxDistance: DEFAULT_MATCH_MAX_DISTANCE}
+}
+
+type MatchResult struct {
+	Key        string  `json:"key"`
+	Name       string  `json:"name"`
+	Distance   int     `json:"distance"`
+	Confidence float64 `json:"confidence"`
+}
+
+type matchVariant struct {
+	text      string
+	name      string
+	candidate int
+}
+
+// MatchIndex holds every accepted spelling of each candidate, normalised and
+// grouped by length so an answer is only compared with spellings that are
+// close enough in length to match.
+type MatchIndex struct {
+	candidates []MatchCandidate
+	exact      map[string][]matchVariant
+	byLength   map[int][]matchVariant
+}
+
+// matchKey normalises text for matching. Spaces are dropped as well so
+// "cote divoire" matches "Côte d'Ivoire" exactly.
+func matchKey(text string) string {
+	return strings.ReplaceAll(NormaliseText(text), " ", "")
+}
+
+func NewMatchIndex(candidates []MatchCandidate) *MatchIndex {
+	index := &MatchIndex{
+		candidates: candidates,
+		exact:      make(map[string][]matchVariant),
+		byLength:   make(map[int][]matchVariant),
+	}
+
+	for position, candidate := range candidates {
+		seen := make(map[string]bool)
+		for _, name := range candidate.Names {
+			spellings := []string{name}
+			for _, prefix := range candidate.Prefixes {
+				spellings = append(spellings, prefix+" "+name)
+			}
+
+			for _, spelling := range spellings {
+				key := matchKey(spelling)
+				if key == "" || seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				variant := matchVariant{text: key, name: name, candidate: position}
+				index.exact[key] = append(index.exact[key], variant)
+				length := len([]rune(key))
+				index.byLength[length] = append(index.byLength[length], variant)
+			}
+		}
+	}
+	return index
+}
+
+// Match finds the candidate closest to the answer. The number of edits allowed
+// grows with the length of the answer, and answers that are as close to two
+// different candidates are treated as not matching anything.
+func (i *MatchIndex) Match(answer string, options MatchOptions) (MatchResult, bool) {
+	key := matchKey(answer)
+	if key == "" {
+		return MatchResult{}, false
+	}
+
+	if variants := i.exact[key]; len(variants) > 0 {
+		if !sameCandidate(variants) {
+			return MatchResult{}, false
+		}
+		return MatchResult{Key: i.candidates[variants[0].candidate].Key, Name: variants[0].name, Confidence: 1}, true
+	}
+
+	length := len([]rune(key))
+	allowed := options.MaxDistance
+	if options.CharsPerEdit > 0 && length/options.CharsPerEdit < allowed {
+		allowed = length / options.CharsPerEdit
+	}
+
+	if allowed <= 0 {
+		return MatchResult{}, false
+	}
+
+	best := allowed + 1
+	var closest []matchVariant
+	for size := length - allowed; size <= length+allowed; size++ {
+		for _, variant := range i.byLength[size] {
+			distance := LevenshteinDistance(key, variant.text)
+			if distance > allowed {
+				continue
+			}
+
+			if distance < best {
+				best, closest = distance, []matchVariant{variant}
+			} else if distance == best {
+				closest = append(closest, variant)
+			}
+		}
+	}
+
+	if len(closest) == 0 || !sameCandidate(closest) {
+		return MatchResult{}, false
+	}
+
+	longest := math.Max(float64(length), float64(len([]rune(closest[0].text))))
+	return MatchResult{
+		Key:        i.candidates[closest[0].candidate].Key,
+		Name:       closest[0].name,
+		Distance:   best,
+		Confidence: 1 - float64(best)/longest,
+	}, true
+}
+
+func sameCandidate(variants []matchVariant) bool {
+	for _, variant := range variants[1:] {
+		if variant.candidate != variants[0].candidate {
+			return false
+		}
+	}
+	return true
+}
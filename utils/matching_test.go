@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestMatchIndex(t *testing.T) {
+	index := NewMatchIndex([]MatchCandidate{
+		{Key: "CI", Names: []string{"côte d'ivoire", "Côte d'Ivoire", "ivory coast"}},
+		{Key: "BS", Names: []string{"bahamas"}, Prefixes: []string{"the"}},
+		{Key: "TD", Names: []string{"chad"}},
+		{Key: "AT", Names: []string{"austria"}},
+		{Key: "AU", Names: []string{"australia"}},
+		{Key: "NE", Names: []string{"niger"}},
+		{Key: "NG", Names: []string{"nigeria"}},
+	})
+
+	tt := []struct {
+		name       string
+		answer     string
+		options    MatchOptions
+		key        string
+		distance   int
+		confidence float64
+		found      bool
+	}{
+		{name: "exact", answer: "Chad", options: DefaultMatchOptions(), key: "TD", confidence: 1, found: true},
+		{name: "diacritics and punctuation", answer: "cote divoire", options: DefaultMatchOptions(), key: "CI", confidence: 1, found: true},
+		{name: "alternative name", answer: "Ivory Coast!", options: DefaultMatchOptions(), key: "CI", confidence: 1, found: true},
+		{name: "with prefix", answer: "The Bahamas", options: DefaultMatchOptions(), key: "BS", confidence: 1, found: true},
+		{name: "typo", answer: "austrailia", options: DefaultMatchOptions(), key: "AU", distance: 1, confidence: 0.9, found: true},
+		{name: "typo in prefixed name", answer: "the bahamaz", options: DefaultMatchOptions(), key: "BS", distance: 1, confidence: 0.9, found: true},
+		{name: "short names must be exact", answer: "chda", options: DefaultMatchOptions(), found: false},
+		{name: "too many edits", answer: "astrlia", options: DefaultMatchOptions(), found: false},
+		{name: "looser rules", answer: "chda", options: MatchOptions{CharsPerEdit: 2, MaxDistance: 2}, key: "TD", distance: 2, confidence: 0.5, found: true},
+		{name: "exact only", answer: "austrailia", options: MatchOptions{CharsPerEdit: 5, MaxDistance: 0}, found: false},
+		{name: "ambiguous", answer: "nigera", options: MatchOptions{CharsPerEdit: 2, MaxDistance: 2}, found: false},
+		{name: "empty", answer: "?!", options: DefaultMatchOptions(), found: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, found := index.Match(tc.answer, tc.options)
+			if found != tc.found {
+				t.Fatalf("expected found %v; got %v (%+v)", tc.found, found, result)
+			}
+
+			if !found {
+				return
+			}
+
+			if result.Key != tc.key || result.Distance != tc.distance || result.Confidence != tc.confidence {
+				t.Errorf("expected %v at distance %v with confidence %v; got %+v", tc.key, tc.distance, tc.confidence, result)
+			}
+		})
+	}
+}
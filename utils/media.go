@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MAX_MEDIA_DIMENSION caps the width and height of uploaded images, so an
+// image can't claim a size that would take gigabytes to draw.
+const MAX_MEDIA_DIMENSION = 8192
+
+var mediaExtensions = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+var ErrUnsupportedMedia = errors.New("images must be a png, jpeg, gif or webp")
+
+type MediaInfo struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// InspectMedia works out what kind of image data is from its content rather
+// than the content type it was sent with, and reads its dimensions without
+// decoding the whole image.
+func InspectMedia(data []byte) (MediaInfo, error) {
+	info := MediaInfo{ContentType: http.DetectContentType(data)}
+	extension, found := mediaExtensions[info.ContentType]
+	if !found {
+		return info, ErrUnsupportedMedia
+	}
+	info.Extension = extension
+
+	var err error
+	if info.ContentType == "image/webp" {
+		info.Width, info.Height, err = webpDimensions(data)
+	} else {
+		var config image.Config
+		config, _, err = image.DecodeConfig(bytes.NewReader(data))
+		info.Width, info.Height = config.Width, config.Height
+	}
+
+	if err != nil {
+		return info, fmt.Errorf("invalid %s: %v", extension, err)
+	}
+
+	if info.Width <= 0 || info.Height <= 0 || info.Width > MAX_MEDIA_DIMENSION || info.Height > MAX_MEDIA_DIMENSION {
+		return info, fmt.Errorf("images must be between 1 and %d pixels wide and high", MAX_MEDIA_DIMENSION)
+	}
+	return info, nil
+}
+
+// webpDimensions reads the canvas size from the first chunk of a webp, which
+// is a lossy, lossless or extended header.
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 30 {
+		return 0, 0, errors.New("header too short")
+	}
+
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ":
+		if chunk[3] != 0x9d || chunk[4] != 0x01 || chunk[5] != 0x2a {
+			return 0, 0, errors.New("missing frame start code")
+		}
+		return int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3fff), int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3fff), nil
+	case "VP8L":
+		if chunk[0] != 0x2f {
+			return 0, 0, errors.New("missing lossless signature")
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		return int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16) + 1, int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16) + 1, nil
+	}
+	return 0, 0, errors.New("unknown chunk")
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestInspectMedia(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	var pngData, jpegData, gifData bytes.Buffer
+	png.Encode(&pngData, img)
+	jpeg.Encode(&jpegData, img, nil)
+	gif.Encode(&gifData, img, nil)
+
+	lossy := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 \x00\x00\x00\x00"), 0, 0, 0, 0x9d, 0x01, 0x2a, 40, 0, 30, 0, 0, 0)
+	lossless := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00"), 0x2f, 39, 0x40, 0x07, 0, 0, 0, 0, 0, 0)
+	extended := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8X\x00\x00\x00\x00"), 0, 0, 0, 0, 39, 0, 0, 29, 0, 0)
+
+	tt := []struct {
+		name        string
+		data        []byte
+		contentType string
+		extension   string
+		err         bool
+	}{
+		{name: "png", data: pngData.Bytes(), contentType: "image/png", extension: "png"},
+		{name: "jpeg", data: jpegData.Bytes(), contentType: "image/jpeg", extension: "jpg"},
+		{name: "gif", data: gifData.Bytes(), contentType: "image/gif", extension: "gif"},
+		{name: "lossy webp", data: lossy, contentType: "image/webp", extension: "webp"},
+		{name: "lossless webp", data: lossless, contentType: "image/webp", extension: "webp"},
+		{name: "extended webp", data: extended, contentType: "image/webp", extension: "webp"},
+		{name: "svg", data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), err: true},
+		{name: "html", data: []byte("<html><script>alert(1)</script></html>"), err: true},
+		{name: "truncated png", data: pngData.Bytes()[:20], err: true},
+		{name: "too large", data: append([]byte("RIFF\x00\x00\x00\x00WEBPVP8X\x00\x00\x00\x00"), 0, 0, 0, 0, 0xff, 0xff, 0, 29, 0, 0), err: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := InspectMedia(tc.data)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error; got %v", info)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			if info.ContentType != tc.contentType || info.Extension != tc.extension || info.Width != 40 || info.Height != 30 {
+				t.Errorf("expected %s %s 40x30; got %v", tc.contentType, tc.extension, info)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+type OrderStatusEmail struct {
+	OrderID        int
+	StatusKey      string
+	FirstName      string
+	Carrier        string
+	TrackingNumber string
+	Total          float64
+	Currency       string
+	AccessLink     string
+}
+
+type orderEmailTemplate struct {
+	subject string
+	body    string
+}
+
+// Bodies are written once and rendered as both plain text and escaped HTML.
+var orderEmailTemplates = map[string]orderEmailTemplate{
+	"paid": {
+		subject: "We've received your order",
+		body: `Thanks for your order! We've received payment of ${{printf "%.2f" .Total}} {{.Currency}} for order #{{.OrderID}}.
+We'll let you know as soon as it's on its way.{{if .AccessLink}}
+You can check on your order at any time using this link: {{.AccessLink}}{{end}}`,
+	},
+	"packed": {
+		subject: "Your order is packed",
+		body:    `Order #{{.OrderID}} has been packed and will be handed to the courier shortly.`,
+	},
+	"shipped": {
+		subject: "Your order is on its way",
+		body: `Order #{{.OrderID}} has shipped with {{.Carrier}}.
+Your tracking number is {{.TrackingNumber}}.`,
+	},
+	"delivered": {
+		subject: "Your order has been delivered",
+		body:    `Order #{{.OrderID}} has been delivered. We hope you enjoy it!`,
+	},
+	"cancelled": {
+		subject: "Your order has been cancelled",
+		body:    `Order #{{.OrderID}} has been cancelled and you have not been charged.`,
+	},
+	"refunded": {
+		subject: "Your order has been refunded",
+		body:    `Order #{{.OrderID}} has been refunded. It can take 5-10 business days for the refund to appear on your statement.`,
+	},
+}
+
+// RenderOrderStatusEmail returns the subject, plain text and HTML content for
+// the update. found is false when the status has no template.
+func RenderOrderStatusEmail(update OrderStatusEmail) (string, string, string, bool, error) {
+	template, found := orderEmailTemplates[update.StatusKey]
+	if !found {
+		return "", "", "", false, nil
+	}
+
+	body := "Hi {{.FirstName}},\n\n" + template.body + "\n\nFrom,\nThe GeoBuff Team"
+	text, err := texttemplate.New(update.StatusKey).Parse(body)
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	var plainText bytes.Buffer
+	if err = text.Execute(&plainText, update); err != nil {
+		return "", "", "", false, err
+	}
+
+	html, err := htmltemplate.New(update.StatusKey).Parse("<div><p>" + htmlParagraphs(body) + "</p></div>")
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	var htmlContent bytes.Buffer
+	if err = html.Execute(&htmlContent, update); err != nil {
+		return "", "", "", false, err
+	}
+	return template.subject, plainText.String(), htmlContent.String(), true, nil
+}
+
+func htmlParagraphs(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\n\n", "</p><p>"), "\n", "<br>")
+}
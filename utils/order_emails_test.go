@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderOrderStatusEmail(t *testing.T) {
+	tt := []struct {
+		name     string
+		update   OrderStatusEmail
+		found    bool
+		subject  string
+		contains []string
+	}{
+		{
+			name:   "no template for status",
+			update: OrderStatusEmail{OrderID: 1, StatusKey: "pending", FirstName: "Sam"},
+			found:  false,
+		},
+		{
+			name:     "shipped includes tracking",
+			update:   OrderStatusEmail{OrderID: 12, StatusKey: "shipped", FirstName: "Sam", Carrier: "NZ Post", TrackingNumber: "AB123"},
+			found:    true,
+			subject:  "Your order is on its way",
+			contains: []string{"Hi Sam", "#12", "NZ Post", "AB123"},
+		},
+		{
+			name:     "paid includes total",
+			update:   OrderStatusEmail{OrderID: 3, StatusKey: "paid", FirstName: "Sam", Total: 45.5},
+			found:    true,
+			subject:  "We've received your order",
+			contains: []string{"$45.50", "#3"},
+		},
+		{
+			name:     "paid includes access link",
+			update:   OrderStatusEmail{OrderID: 3, StatusKey: "paid", FirstName: "Sam", Total: 45.5, AccessLink: "https://geobuff.com/orders?token=abc"},
+			found:    true,
+			subject:  "We've received your order",
+			contains: []string{"https://geobuff.com/orders?token=abc"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			subject, plainText, html, found, err := RenderOrderStatusEmail(tc.update)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if found != tc.found {
+				t.Fatalf("expected found %v; got %v", tc.found, found)
+			}
+
+			if subject != tc.subject {
+				t.Errorf("expected subject %v; got %v", tc.subject, subject)
+			}
+
+			for _, val := range tc.contains {
+				if !strings.Contains(plainText, val) || !strings.Contains(html, val) {
+					t.Errorf("expected content to contain %v", val)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderOrderStatusEmailEscapesHTML(t *testing.T) {
+	_, _, html, _, err := RenderOrderStatusEmail(OrderStatusEmail{OrderID: 1, StatusKey: "delivered", FirstName: "<script>"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(html, "<script>") {
+		t.Errorf("expected first name to be escaped; got %v", html)
+	}
+}
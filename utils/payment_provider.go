@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Webhook events are reduced to the outcomes the order flow cares about.
+// Anything else is verified but otherwise ignored.
+const (
+	PAYMENT_EVENT_CHECKOUT_PAID   = "checkout_paid"
+	PAYMENT_EVENT_CHECKOUT_FAILED = "checkout_failed"
+)
+
+const PAYMENT_STATUS_SUCCEEDED = "succeeded"
+
+var ErrPaymentAlreadyRefunded = errors.New("payment has already been refunded")
+
+var ErrCheckoutCompleted = errors.New("checkout session has already been paid")
+
+type PaymentLineItem struct {
+	Name      string
+	UnitPrice float64
+	Quantity  int
+	ImageURL  string
+}
+
+// CheckoutRequest describes a hosted checkout for a pending order. Only the
+// final discount amount is sent so the total charged always matches the quote.
+type CheckoutRequest struct {
+	OrderID        int
+	Email          string
+	Currency       string
+	LineItems      []PaymentLineItem
+	DiscountName   string
+	DiscountAmount float64
+	SuccessURL     string
+	CancelURL      string
+	ExpiresAt      time.Time
+}
+
+type CheckoutSession struct {
+	ID string `json:"id"`
+}
+
+type PaymentEvent struct {
+	ID        string
+	Type      string
+	Outcome   string
+	SessionID string
+	OrderID   int
+	PaymentID string
+}
+
+type Payment struct {
+	ID       string
+	Status   string
+	Amount   float64
+	Currency string
+	Refunded bool
+}
+
+type IPaymentProvider interface {
+	CreateCheckout(request CheckoutRequest) (CheckoutSession, error)
+	VerifyWebhook(payload []byte, header http.Header) (PaymentEvent, error)
+	GetPayment(paymentID string) (Payment, error)
+	Refund(paymentID string) (string, error)
+	ExpireCheckoutSession(sessionID string) error
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrDiscountExpired       = errors.New("discount has expired")
+	ErrDiscountUsageLimit    = errors.New("discount has reached its usage limit")
+	ErrDiscountCustomerLimit = errors.New("discount has already been used by this customer")
+	ErrDiscountNotApplicable = errors.New("discount does not apply to any items in the cart")
+)
+
+type PricingLineInput struct {
+	MerchID   int
+	SizeID    int
+	Quantity  int
+	UnitPrice float64
+}
+
+// PricingDiscountInput describes a discount code along with how many times it
+// has already been used, overall and by the customer placing the order.
+type PricingDiscountInput struct {
+	ID            int
+	MerchID       sql.NullInt64
+	Code          string
+	Amount        float64
+	Expires       sql.NullTime
+	UsageLimit    sql.NullInt64
+	CustomerLimit sql.NullInt64
+	Uses          int
+	CustomerUses  int
+}
+
+// PricingInput amounts must already be in the quote currency.
+type PricingInput struct {
+	Currency string
+	Lines    []PricingLineInput
+	Shipping float64
+	Discount *PricingDiscountInput
+}
+
+type PriceLine struct {
+	MerchID   int     `json:"merchId"`
+	SizeID    int     `json:"sizeId"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unitPrice"`
+	Subtotal  float64 `json:"subtotal"`
+	Discount  float64 `json:"discount"`
+	Total     float64 `json:"total"`
+}
+
+type PriceQuote struct {
+	Lines        []PriceLine `json:"lines"`
+	Subtotal     float64     `json:"subtotal"`
+	Discount     float64     `json:"discount"`
+	Shipping     float64     `json:"shipping"`
+	Total        float64     `json:"total"`
+	DiscountCode string      `json:"discountCode"`
+	Currency     string      `json:"currency"`
+}
+
+type IPricingService interface {
+	Quote(input PricingInput, now time.Time) (PriceQuote, error)
+}
+
+type PricingService struct{}
+
+func NewPricingService() *PricingService {
+	return &PricingService{}
+}
+
+// Quote prices the cart in cents to avoid float drift. Merch discounts come
+// off each matching unit, while cart-wide discounts come off the order total
+// (including shipping) and are not attributed to individual lines.
+func (p *PricingService) Quote(input PricingInput, now time.Time) (PriceQuote, error) {
+	var quote PriceQuote
+	var subtotal, lineDiscount int64
+	lines := make([]PriceLine, 0, len(input.Lines))
+	matched := false
+	for _, val := range input.Lines {
+		if val.Quantity <= 0 {
+			return quote, fmt.Errorf("invalid quantity %d for size %d", val.Quantity, val.SizeID)
+		}
+
+		unitPrice := toCents(val.UnitPrice)
+		lineSubtotal := unitPrice * int64(val.Quantity)
+		var discount int64
+		if input.Discount != nil && input.Discount.MerchID.Valid && int(input.Discount.MerchID.Int64) == val.MerchID {
+			discount = minCents(toCents(input.Discount.Amount)*int64(val.Quantity), lineSubtotal)
+			matched = true
+		}
+
+		subtotal = subtotal + lineSubtotal
+		lineDiscount = lineDiscount + discount
+		lines = append(lines, PriceLine{
+			MerchID:   val.MerchID,
+			SizeID:    val.SizeID,
+			Quantity:  val.Quantity,
+			UnitPrice: fromCents(unitPrice),
+			Subtotal:  fromCents(lineSubtotal),
+			Discount:  fromCents(discount),
+			Total:     fromCents(lineSubtotal - discount),
+		})
+	}
+
+	shipping := toCents(input.Shipping)
+	discount := lineDiscount
+	if input.Discount != nil {
+		if err := CheckDiscountLimits(*input.Discount, now); err != nil {
+			return quote, err
+		}
+
+		if !input.Discount.MerchID.Valid {
+			discount = minCents(toCents(input.Discount.Amount), subtotal+shipping)
+		} else if !matched {
+			return quote, ErrDiscountNotApplicable
+		}
+		quote.DiscountCode = input.Discount.Code
+	}
+
+	quote.Currency = input.Currency
+	if quote.Currency == "" {
+		quote.Currency = BASE_CURRENCY
+	}
+	quote.Lines = lines
+	quote.Subtotal = fromCents(subtotal)
+	quote.Discount = fromCents(discount)
+	quote.Shipping = fromCents(shipping)
+	quote.Total = fromCents(subtotal + shipping - discount)
+	return quote, nil
+}
+
+func CheckDiscountLimits(discount PricingDiscountInput, now time.Time) error {
+	if discount.Expires.Valid && !now.Before(discount.Expires.Time) {
+		return ErrDiscountExpired
+	}
+
+	if discount.UsageLimit.Valid && int64(discount.Uses) >= discount.UsageLimit.Int64 {
+		return ErrDiscountUsageLimit
+	}
+
+	if discount.CustomerLimit.Valid && int64(discount.CustomerUses) >= discount.CustomerLimit.Int64 {
+		return ErrDiscountCustomerLimit
+	}
+	return nil
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+func minCents(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	lines := []PricingLineInput{
+		{MerchID: 1, SizeID: 1, Quantity: 2, UnitPrice: 19.99},
+		{MerchID: 2, SizeID: 4, Quantity: 1, UnitPrice: 4.5},
+	}
+
+	tt := []struct {
+		name     string
+		input    PricingInput
+		subtotal float64
+		discount float64
+		total    float64
+		err      error
+	}{
+		{
+			name:     "no discount",
+			input:    PricingInput{Lines: lines, Shipping: 5.99},
+			subtotal: 44.48,
+			discount: 0,
+			total:    50.47,
+		},
+		{
+			name:     "cart-wide discount",
+			input:    PricingInput{Lines: lines, Shipping: 5.99, Discount: &PricingDiscountInput{Code: "NOSHIP", Amount: 5.99}},
+			subtotal: 44.48,
+			discount: 5.99,
+			total:    44.48,
+		},
+		{
+			name:     "cart-wide discount capped at total",
+			input:    PricingInput{Lines: lines, Shipping: 5.99, Discount: &PricingDiscountInput{Code: "FREE", Amount: 100}},
+			subtotal: 44.48,
+			discount: 50.47,
+			total:    0,
+		},
+		{
+			name:     "merch discount per unit",
+			input:    PricingInput{Lines: lines, Shipping: 5.99, Discount: &PricingDiscountInput{Code: "TEE", MerchID: sql.NullInt64{Int64: 1, Valid: true}, Amount: 5}},
+			subtotal: 44.48,
+			discount: 10,
+			total:    40.47,
+		},
+		{
+			name:  "merch discount not in cart",
+			input: PricingInput{Lines: lines, Shipping: 5.99, Discount: &PricingDiscountInput{Code: "MUG", MerchID: sql.NullInt64{Int64: 3, Valid: true}, Amount: 5}},
+			err:   ErrDiscountNotApplicable,
+		},
+		{
+			name:  "expired discount",
+			input: PricingInput{Lines: lines, Discount: &PricingDiscountInput{Code: "OLD", Amount: 5, Expires: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}}},
+			err:   ErrDiscountExpired,
+		},
+		{
+			name:  "usage limit reached",
+			input: PricingInput{Lines: lines, Discount: &PricingDiscountInput{Code: "LIMITED", Amount: 5, UsageLimit: sql.NullInt64{Int64: 10, Valid: true}, Uses: 10}},
+			err:   ErrDiscountUsageLimit,
+		},
+		{
+			name:  "customer limit reached",
+			input: PricingInput{Lines: lines, Discount: &PricingDiscountInput{Code: "ONCE", Amount: 5, CustomerLimit: sql.NullInt64{Int64: 1, Valid: true}, Uses: 3, CustomerUses: 1}},
+			err:   ErrDiscountCustomerLimit,
+		},
+	}
+
+	ps := NewPricingService()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			quote, err := ps.Quote(tc.input, now)
+			if err != tc.err {
+				t.Fatalf("expected error %v; got %v", tc.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if quote.Subtotal != tc.subtotal {
+				t.Errorf("expected subtotal %v; got %v", tc.subtotal, quote.Subtotal)
+			}
+
+			if quote.Discount != tc.discount {
+				t.Errorf("expected discount %v; got %v", tc.discount, quote.Discount)
+			}
+
+			if quote.Total != tc.total {
+				t.Errorf("expected total %v; got %v", tc.total, quote.Total)
+			}
+		})
+	}
+}
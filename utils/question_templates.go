@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Placeholders every template can use. Any other placeholder is looked up in
+// the entry's attributes, e.g. {capital}.
+const (
+	TEMPLATE_PLACEHOLDER_NAME     = "name"
+	TEMPLATE_PLACEHOLDER_CODE     = "code"
+	TEMPLATE_PLACEHOLDER_GROUPING = "grouping"
+)
+
+const DEFAULT_TEMPLATE_DISTRACTORS = 3
+
+var templatePlaceholder = regexp.MustCompile(`\{([a-zA-Z][a-zA-Z0-9_]*)\}`)
+
+// QuestionTemplate describes how to turn a mapping entry into a question, e.g.
+// "What is the capital of {name}?" with the answer "{capital}". Flag questions
+// require the entry to have a flag and map questions an element to highlight.
+type QuestionTemplate struct {
+	Question       string
+	Answer         string
+	Explainer      string
+	Distractors    int
+	RequireFlag    bool
+	RequireElement bool
+}
+
+type TemplateEntry struct {
+	Code       string
+	Name       string
+	SVGName    string
+	Grouping   string
+	HasFlag    bool
+	HasElement bool
+	Attributes map[string]interface{}
+}
+
+type GeneratedAnswer struct {
+	Text      string `json:"text"`
+	IsCorrect bool   `json:"isCorrect"`
+}
+
+type GeneratedQuestion struct {
+	Entry     TemplateEntry     `json:"-"`
+	Question  string            `json:"question"`
+	Explainer string            `json:"explainer"`
+	Answers   []GeneratedAnswer `json:"answers"`
+}
+
+// TemplatePlaceholders returns the placeholders used in the text.
+func TemplatePlaceholders(text string) []string {
+	var placeholders = []string{}
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+		placeholders = append(placeholders, match[1])
+	}
+	return placeholders
+}
+
+// RenderTemplate fills in the placeholders for the entry. It returns false if
+// the entry is missing a value for any of them.
+func RenderTemplate(text string, entry TemplateEntry) (string, bool) {
+	complete := true
+	result := templatePlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		value := templateValue(match[1:len(match)-1], entry)
+		if value == "" {
+			complete = false
+		}
+		return value
+	})
+	return strings.TrimSpace(result), complete
+}
+
+func templateValue(placeholder string, entry TemplateEntry) string {
+	switch placeholder {
+	case TEMPLATE_PLACEHOLDER_NAME:
+		return entry.Name
+	case TEMPLATE_PLACEHOLDER_CODE:
+		return entry.Code
+	case TEMPLATE_PLACEHOLDER_GROUPING:
+		return entry.Grouping
+	}
+
+	switch value := entry.Attributes[placeholder].(type) {
+	case string:
+		return value
+	case float64:
+		return formatTemplateNumber(value)
+	}
+	return ""
+}
+
+// formatTemplateNumber writes whole numbers with thousands separators, so
+// populations read as 5,123,000 rather than 5.123e+06.
+func formatTemplateNumber(value float64) string {
+	if value != math.Trunc(value) || math.Abs(value) >= 1e15 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	digits := strconv.FormatInt(int64(math.Abs(value)), 10)
+	var builder strings.Builder
+	if value < 0 {
+		builder.WriteRune('-')
+	}
+
+	for index, digit := range digits {
+		if index > 0 && (len(digits)-index)%3 == 0 {
+			builder.WriteRune(',')
+		}
+		builder.WriteRune(digit)
+	}
+	return builder.String()
+}
+
+// GenerateTemplateQuestions asks the template about up to count entries, or
+// every entry that can answer it if count is zero. Distractors are the answers
+// of other entries in the same grouping where possible, which for countries
+// is their continent, and fall back to the rest of the mapping. The same seed
+// and entries always produce the same questions.
+func GenerateTemplateQuestions(template QuestionTemplate, entries []TemplateEntry, count int, seed int64) []GeneratedQuestion {
+	distractors := template.Distractors
+	if distractors <= 0 {
+		distractors = DEFAULT_TEMPLATE_DISTRACTORS
+	}
+
+	sorted := make([]TemplateEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Code < sorted[j].Code
+	})
+
+	var answerable = []TemplateEntry{}
+	answers := make(map[string]string)
+	for _, entry := range sorted {
+		if answer, ok := RenderTemplate(template.Answer, entry); ok && answer != "" {
+			answerable = append(answerable, entry)
+			answers[entry.Code] = answer
+		}
+	}
+
+	var eligible = []TemplateEntry{}
+	for _, entry := range answerable {
+		if template.RequireFlag && !entry.HasFlag || template.RequireElement && !entry.HasElement {
+			continue
+		}
+
+		if _, ok := RenderTemplate(template.Question, entry); !ok {
+			continue
+		}
+		eligible = append(eligible, entry)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	random.Shuffle(len(eligible), func(i, j int) {
+		eligible[i], eligible[j] = eligible[j], eligible[i]
+	})
+
+	var questions = []GeneratedQuestion{}
+	for _, entry := range eligible {
+		if count > 0 && len(questions) == count {
+			break
+		}
+
+		correct := answers[entry.Code]
+		options := pickDistractors(random, entry, correct, answerable, answers, distractors)
+		if len(options) == 0 {
+			continue
+		}
+
+		question, _ := RenderTemplate(template.Question, entry)
+		explainer, _ := RenderTemplate(template.Explainer, entry)
+		generated := GeneratedQuestion{
+			Entry:     entry,
+			Question:  question,
+			Explainer: explainer,
+			Answers:   []GeneratedAnswer{{Text: correct, IsCorrect: true}},
+		}
+
+		for _, option := range options {
+			generated.Answers = append(generated.Answers, GeneratedAnswer{Text: option})
+		}
+
+		random.Shuffle(len(generated.Answers), func(i, j int) {
+			generated.Answers[i], generated.Answers[j] = generated.Answers[j], generated.Answers[i]
+		})
+		questions = append(questions, generated)
+	}
+	return questions
+}
+
+// pickDistractors picks wrong answers from the same grouping first, skipping
+// any that read the same as the correct answer or each other.
+func pickDistractors(random *rand.Rand, entry TemplateEntry, correct string, candidates []TemplateEntry, answers map[string]string, count int) []string {
+	var near, far []string
+	for _, candidate := range candidates {
+		if candidate.Code == entry.Code {
+			continue
+		}
+
+		if entry.Grouping != "" && strings.EqualFold(candidate.Grouping, entry.Grouping) {
+			near = append(near, answers[candidate.Code])
+		} else {
+			far = append(far, answers[candidate.Code])
+		}
+	}
+
+	random.Shuffle(len(near), func(i, j int) { near[i], near[j] = near[j], near[i] })
+	random.Shuffle(len(far), func(i, j int) { far[i], far[j] = far[j], far[i] })
+
+	seen := map[string]bool{NormaliseText(correct): true}
+	var result = []string{}
+	for _, option := range append(near, far...) {
+		if len(result) == count {
+			break
+		}
+
+		normalised := NormaliseText(option)
+		if seen[normalised] {
+			continue
+		}
+		seen[normalised] = true
+		result = append(result, option)
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	entry := TemplateEntry{
+		Code:       "FR",
+		Name:       "France",
+		Grouping:   "europe",
+		Attributes: map[string]interface{}{"capital": "Paris", "population": float64(67391582), "area": 551695.5},
+	}
+
+	tt := []struct {
+		name     string
+		text     string
+		expected string
+		complete bool
+	}{
+		{name: "fields", text: "Which country in {grouping} has the code {code}?", expected: "Which country in europe has the code FR?", complete: true},
+		{name: "text attribute", text: "What is the capital of {name}?", expected: "What is the capital of France?", complete: true},
+		{name: "whole number", text: "{population}", expected: "67,391,582", complete: true},
+		{name: "decimal", text: "{area}", expected: "551695.5", complete: true},
+		{name: "missing attribute", text: "What is the currency of {name}? {currency}", expected: "What is the currency of France?", complete: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, complete := RenderTemplate(tc.text, entry)
+			if result != tc.expected || complete != tc.complete {
+				t.Errorf("expected %q (%v); got %q (%v)", tc.expected, tc.complete, result, complete)
+			}
+		})
+	}
+}
+
+func TestGenerateTemplateQuestions(t *testing.T) {
+	entries := []TemplateEntry{
+		{Code: "FR", Name: "France", Grouping: "europe", HasFlag: true, Attributes: map[string]interface{}{"capital": "Paris"}},
+		{Code: "DE", Name: "Germany", Grouping: "europe", HasFlag: true, Attributes: map[string]interface{}{"capital": "Berlin"}},
+		{Code: "ES", Name: "Spain", Grouping: "europe", Attributes: map[string]interface{}{"capital": "Madrid"}},
+		{Code: "IT", Name: "Italy", Grouping: "europe", HasFlag: true, Attributes: map[string]interface{}{"capital": "Rome"}},
+		{Code: "JP", Name: "Japan", Grouping: "asia", HasFlag: true, Attributes: map[string]interface{}{"capital": "Tokyo"}},
+		{Code: "KR", Name: "South Korea", Grouping: "asia", HasFlag: true, Attributes: map[string]interface{}{"capital": "Seoul"}},
+		{Code: "XX", Name: "Nowhere", Grouping: "asia", HasFlag: true, Attributes: map[string]interface{}{}},
+	}
+
+	template := QuestionTemplate{Question: "What is the capital of {name}?", Answer: "{capital}", Distractors: 2, RequireFlag: true}
+	questions := GenerateTemplateQuestions(template, entries, 0, 42)
+
+	if len(questions) != 5 {
+		t.Fatalf("expected a question for each entry with a flag and capital; got %v", len(questions))
+	}
+
+	capitals := map[string]string{"europe": "Paris Berlin Madrid Rome", "asia": "Tokyo Seoul"}
+	for _, question := range questions {
+		if question.Entry.Code == "ES" || question.Entry.Code == "XX" {
+			t.Errorf("expected %v to be skipped", question.Entry.Code)
+		}
+
+		if len(question.Answers) != 3 {
+			t.Errorf("expected 3 answers for %v; got %v", question.Entry.Code, question.Answers)
+			continue
+		}
+
+		correct := 0
+		near := 0
+		for _, answer := range question.Answers {
+			if answer.IsCorrect {
+				correct++
+				if answer.Text != question.Entry.Attributes["capital"] {
+					t.Errorf("expected correct answer %v; got %v", question.Entry.Attributes["capital"], answer.Text)
+				}
+			} else if containsWord(capitals[question.Entry.Grouping], answer.Text) {
+				near++
+			}
+		}
+
+		if correct != 1 {
+			t.Errorf("expected one correct answer for %v; got %v", question.Entry.Code, correct)
+		}
+
+		if question.Entry.Grouping == "europe" && near != 2 {
+			t.Errorf("expected distractors from the same grouping for %v; got %v", question.Entry.Code, question.Answers)
+		}
+
+		if question.Entry.Grouping == "asia" && near != 1 {
+			t.Errorf("expected one distractor from the same grouping for %v; got %v", question.Entry.Code, question.Answers)
+		}
+	}
+
+	if again := GenerateTemplateQuestions(template, entries, 0, 42); !reflect.DeepEqual(questions, again) {
+		t.Error("expected the same seed to generate the same questions")
+	}
+
+	if limited := GenerateTemplateQuestions(template, entries, 2, 7); len(limited) != 2 {
+		t.Errorf("expected 2 questions; got %v", len(limited))
+	}
+}
+
+func containsWord(words, word string) bool {
+	for _, val := range strings.Fields(words) {
+		if val == word {
+			return true
+		}
+	}
+	return false
+}
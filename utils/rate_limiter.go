@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows each key limit requests per window, e.g. per email or
+// client IP. Keys are kept in an LRU cache so the limiter can't grow without
+// bound.
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   *LRUCache
+	now    func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func NewRateLimiter(limit int, window time.Duration, maxKeys int) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   NewLRUCache(maxKeys, window),
+		now:    time.Now,
+	}
+}
+
+// Allow records a request for the key, returning false once the key has used
+// up its requests for the current window.
+func (r *RateLimiter) Allow(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if cached, found := r.hits.Get(key); found {
+		hits := cached.(*rateWindow)
+		if now.Sub(hits.start) < r.window {
+			if hits.count >= r.limit {
+				return false
+			}
+			hits.count++
+			return true
+		}
+	}
+
+	r.hits.Set(key, &rateWindow{start: now, count: 1})
+	return true
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Hour, 10)
+	limiter.now = func() time.Time { return now }
+
+	for i, expected := range []bool{true, true, false} {
+		if allowed := limiter.Allow("a"); allowed != expected {
+			t.Errorf("expected request %d allowed %v; got %v", i+1, expected, allowed)
+		}
+	}
+
+	if !limiter.Allow("b") {
+		t.Errorf("expected other keys to be allowed")
+	}
+
+	now = now.Add(time.Hour)
+	if !limiter.Allow("a") {
+		t.Errorf("expected requests to be allowed again in the next window")
+	}
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3_REQUEST_TIMEOUT bounds every call to the bucket so a slow provider can't
+// hold an upload open forever.
+const S3_REQUEST_TIMEOUT = 30 * time.Second
+
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PublicURL       string
+}
+
+// S3MediaStore keeps files in an S3 compatible bucket, e.g. AWS, DigitalOcean
+// Spaces or MinIO. Buckets are addressed by path so any endpoint works, and
+// files are expected to be made public by the bucket policy. PublicURL is
+// where they're loaded from, defaulting to the bucket on the endpoint.
+type S3MediaStore struct {
+	client    *http.Client
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	baseURL   string
+	now       func() time.Time
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func NewS3MediaStore(config S3Config) *S3MediaStore {
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	baseURL := strings.TrimRight(config.PublicURL, "/")
+	if baseURL == "" {
+		baseURL = endpoint + "/" + config.Bucket
+	}
+
+	return &S3MediaStore{
+		client:    &http.Client{Timeout: S3_REQUEST_TIMEOUT},
+		endpoint:  endpoint,
+		region:    config.Region,
+		bucket:    config.Bucket,
+		accessKey: config.AccessKeyID,
+		secretKey: config.SecretAccessKey,
+		baseURL:   baseURL,
+		now:       time.Now,
+	}
+}
+
+func (s *S3MediaStore) Put(key, contentType string, data []byte) (string, error) {
+	if !IsValidBlobKey(key) {
+		return "", ErrInvalidBlobKey
+	}
+
+	response, err := s.do(http.MethodPut, key, "", contentType, data)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", readS3Error(response)
+	}
+	return s.URL(key), nil
+}
+
+func (s *S3MediaStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *S3MediaStore) Get(key string) ([]byte, string, error) {
+	if !IsValidBlobKey(key) {
+		return nil, "", ErrInvalidBlobKey
+	}
+
+	response, err := s.do(http.MethodGet, key, "", "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", ErrBlobNotFound
+	} else if response.StatusCode != http.StatusOK {
+		return nil, "", readS3Error(response)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, response.Header.Get("Content-Type"), nil
+}
+
+// Delete removes the file. S3 doesn't say whether there was anything to
+// remove, so unlike the local store it never returns ErrBlobNotFound.
+func (s *S3MediaStore) Delete(key string) error {
+	if !IsValidBlobKey(key) {
+		return ErrInvalidBlobKey
+	}
+
+	response, err := s.do(http.MethodDelete, key, "", "", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return readS3Error(response)
+	}
+	return nil
+}
+
+func (s *S3MediaStore) List(prefix string) ([]string, error) {
+	var keys = []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		response, err := s.do(http.MethodGet, "", s3CanonicalQuery(query), "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			err = readS3Error(response)
+			response.Body.Close()
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3MediaStore) do(method, key, query, contentType string, data []byte) (*http.Response, error) {
+	target := s.endpoint + "/" + s3Escape(s.bucket, false)
+	if key != "" {
+		target += "/" + s3Escape(key, false)
+	}
+
+	if query != "" {
+		target += "?" + query
+	}
+
+	request, err := http.NewRequest(method, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	hash := sha256.Sum256(data)
+	signS3Request(request, hex.EncodeToString(hash[:]), s.accessKey, s.secretKey, s.region, s.now())
+	return s.client.Do(request)
+}
+
+func readS3Error(response *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 64<<10))
+	var result s3Error
+	if err := xml.Unmarshal(body, &result); err != nil || result.Code == "" {
+		return fmt.Errorf("s3 request failed with status %d", response.StatusCode)
+	}
+	return fmt.Errorf("s3 request failed with %s: %s", result.Code, result.Message)
+}
+
+// signS3Request signs the request with AWS Signature Version 4, covering the
+// host and every header already set on it.
+func signS3Request(request *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	var signedHeaders = []string{"host"}
+	for name := range request.Header {
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+	}
+	sort.Strings(signedHeaders)
+
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s", accessKey, scope, strings.Join(signedHeaders, ";"), s3Signature(request, signedHeaders, payloadHash, secretKey, scope)))
+}
+
+func s3Signature(request *http.Request, signedHeaders []string, payloadHash, secretKey, scope string) string {
+	canonical := sha256.Sum256([]byte(s3CanonicalRequest(request, signedHeaders, payloadHash)))
+	stringToSign := "AWS4-HMAC-SHA256\n" + request.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + hex.EncodeToString(canonical[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func s3CanonicalRequest(request *http.Request, signedHeaders []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := request.Header.Get(name)
+		if name == "host" {
+			value = request.Host
+			if value == "" {
+				value = request.URL.Host
+			}
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return strings.Join([]string{request.Method, request.URL.EscapedPath(), s3CanonicalQuery(request.URL.Query()), headers.String(), strings.Join(signedHeaders, ";"), payloadHash}, "\n")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	var names []string
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent encodes everything but unreserved characters, the way
+// the signature expects. Slashes are left alone in paths.
+func s3Escape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' || b == '/' && !encodeSlash {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
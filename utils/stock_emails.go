@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+type LowStockItem struct {
+	MerchName string
+	Size      string
+	Quantity  int
+}
+
+type lowStockEmail struct {
+	Threshold int
+	Items     []LowStockItem
+}
+
+const lowStockEmailBody = `Hi there,
+
+The following sizes have {{.Threshold}} or fewer left in stock:
+{{range .Items}}
+- {{.MerchName}} ({{.Size}}): {{.Quantity}} left{{end}}
+
+From,
+The GeoBuff Team`
+
+// RenderLowStockEmail returns the subject, plain text and HTML content for a
+// low stock alert.
+func RenderLowStockEmail(items []LowStockItem, threshold int) (string, string, string, error) {
+	data := lowStockEmail{threshold, items}
+	text, err := texttemplate.New("low-stock").Parse(lowStockEmailBody)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var plainText bytes.Buffer
+	if err = text.Execute(&plainText, data); err != nil {
+		return "", "", "", err
+	}
+
+	html, err := htmltemplate.New("low-stock").Parse("<div><p>" + htmlParagraphs(lowStockEmailBody) + "</p></div>")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var htmlContent bytes.Buffer
+	if err = html.Execute(&htmlContent, data); err != nil {
+		return "", "", "", err
+	}
+	return "Low stock alert", plainText.String(), htmlContent.String(), nil
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderLowStockEmail(t *testing.T) {
+	items := []LowStockItem{
+		{MerchName: "Tee", Size: "M", Quantity: 2},
+		{MerchName: "Hoodie & Cap", Size: "L", Quantity: 0},
+	}
+
+	subject, plainText, html, err := RenderLowStockEmail(items, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if subject != "Low stock alert" {
+		t.Errorf("expected subject Low stock alert; got %v", subject)
+	}
+
+	for _, val := range []string{"5 or fewer", "Tee (M): 2 left", "(L): 0 left"} {
+		if !strings.Contains(plainText, val) || !strings.Contains(html, val) {
+			t.Errorf("expected content to contain %v", val)
+		}
+	}
+
+	if !strings.Contains(plainText, "Hoodie & Cap") || !strings.Contains(html, "Hoodie &amp; Cap") {
+		t.Error("expected merch name to be escaped in html only")
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"sort"
+	"time"
+)
+
+// CalculateStreaks returns the current and longest runs of consecutive days.
+// Frozen days keep a streak alive without adding to it. The current streak is
+// only kept while the last active day is today or yesterday.
+func CalculateStreaks(played, frozen []time.Time, today time.Time) (int, int) {
+	days := activeDays(played, frozen)
+	if len(days) == 0 {
+		return 0, 0
+	}
+
+	var run, longest int
+	var previous time.Time
+	for index, day := range days {
+		if index > 0 && !day.date.Equal(previous.AddDate(0, 0, 1)) {
+			run = 0
+		}
+
+		if day.played {
+			run = run + 1
+		}
+
+		if run > longest {
+			longest = run
+		}
+		previous = day.date
+	}
+
+	if previous.Before(Day(today).AddDate(0, 0, -1)) {
+		return 0, longest
+	}
+	return run, longest
+}
+
+// MissedDays returns the days between the last active day and today that were
+// neither played nor frozen.
+func MissedDays(played, frozen []time.Time, today time.Time) []time.Time {
+	days := activeDays(played, frozen)
+	if len(days) == 0 {
+		return []time.Time{}
+	}
+
+	var missed = []time.Time{}
+	for day := days[len(days)-1].date.AddDate(0, 0, 1); day.Before(Day(today)); day = day.AddDate(0, 0, 1) {
+		missed = append(missed, day)
+	}
+	return missed
+}
+
+// Day truncates the value to midnight UTC on the same calendar date so that
+// dates from different sources can be compared.
+func Day(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type activeDay struct {
+	date   time.Time
+	played bool
+}
+
+func activeDays(played, frozen []time.Time) []activeDay {
+	days := make(map[time.Time]bool)
+	for _, day := range frozen {
+		if _, found := days[Day(day)]; !found {
+			days[Day(day)] = false
+		}
+	}
+
+	for _, day := range played {
+		days[Day(day)] = true
+	}
+
+	var result = []activeDay{}
+	for date, played := range days {
+		result = append(result, activeDay{date, played})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].date.Before(result[j].date)
+	})
+	return result
+}
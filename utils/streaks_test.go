@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	parsed, _ := time.Parse("2006-01-02", value)
+	return parsed
+}
+
+func dates(values ...string) []time.Time {
+	var result = []time.Time{}
+	for _, value := range values {
+		result = append(result, date(value))
+	}
+	return result
+}
+
+func TestCalculateStreaks(t *testing.T) {
+	tt := []struct {
+		name            string
+		played          []time.Time
+		frozen          []time.Time
+		today           time.Time
+		expectedCurrent int
+		expectedLongest int
+	}{
+		{
+			name:            "no plays",
+			played:          dates(),
+			frozen:          dates(),
+			today:           date("2022-06-10"),
+			expectedCurrent: 0,
+			expectedLongest: 0,
+		},
+		{
+			name:            "played today",
+			played:          dates("2022-06-08", "2022-06-09", "2022-06-10"),
+			frozen:          dates(),
+			today:           date("2022-06-10"),
+			expectedCurrent: 3,
+			expectedLongest: 3,
+		},
+		{
+			name:            "played yesterday",
+			played:          dates("2022-06-08", "2022-06-09"),
+			frozen:          dates(),
+			today:           date("2022-06-10"),
+			expectedCurrent: 2,
+			expectedLongest: 2,
+		},
+		{
+			name:            "streak broken",
+			played:          dates("2022-06-01", "2022-06-02", "2022-06-03", "2022-06-07"),
+			frozen:          dates(),
+			today:           date("2022-06-10"),
+			expectedCurrent: 0,
+			expectedLongest: 3,
+		},
+		{
+			name:            "frozen day keeps streak alive",
+			played:          dates("2022-06-07", "2022-06-08", "2022-06-10"),
+			frozen:          dates("2022-06-09"),
+			today:           date("2022-06-10"),
+			expectedCurrent: 3,
+			expectedLongest: 3,
+		},
+		{
+			name:            "duplicate plays on the same day",
+			played:          []time.Time{date("2022-06-10"), date("2022-06-10").Add(5 * time.Hour)},
+			frozen:          dates(),
+			today:           date("2022-06-10"),
+			expectedCurrent: 1,
+			expectedLongest: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			current, longest := CalculateStreaks(tc.played, tc.frozen, tc.today)
+			if current != tc.expectedCurrent {
+				t.Errorf("expected current %v; got %v", tc.expectedCurrent, current)
+			}
+
+			if longest != tc.expectedLongest {
+				t.Errorf("expected longest %v; got %v", tc.expectedLongest, longest)
+			}
+		})
+	}
+}
+
+func TestMissedDays(t *testing.T) {
+	tt := []struct {
+		name     string
+		played   []time.Time
+		frozen   []time.Time
+		today    time.Time
+		expected int
+	}{
+		{
+			name:     "no plays",
+			played:   dates(),
+			frozen:   dates(),
+			today:    date("2022-06-10"),
+			expected: 0,
+		},
+		{
+			name:     "played yesterday",
+			played:   dates("2022-06-09"),
+			frozen:   dates(),
+			today:    date("2022-06-10"),
+			expected: 0,
+		},
+		{
+			name:     "missed two days",
+			played:   dates("2022-06-07"),
+			frozen:   dates(),
+			today:    date("2022-06-10"),
+			expected: 2,
+		},
+		{
+			name:     "missed day already frozen",
+			played:   dates("2022-06-08"),
+			frozen:   dates("2022-06-09"),
+			today:    date("2022-06-10"),
+			expected: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := MissedDays(tc.played, tc.frozen, tc.today)
+			if len(result) != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, len(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/client"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+type StripePaymentProvider struct {
+	api           *client.API
+	webhookSecret string
+}
+
+func NewStripePaymentProvider(secretKey, webhookSecret string) *StripePaymentProvider {
+	return &StripePaymentProvider{
+		api:           client.New(secretKey, nil),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (p *StripePaymentProvider) CreateCheckout(request CheckoutRequest) (CheckoutSession, error) {
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	for _, item := range request.LineItems {
+		lineItem := stripe.CheckoutSessionLineItemParams{
+			Amount:   stripe.Int64(toCents(item.UnitPrice)),
+			Name:     stripe.String(item.Name),
+			Currency: stripe.String(request.Currency),
+			Quantity: stripe.Int64(int64(item.Quantity)),
+		}
+
+		if item.ImageURL != "" {
+			lineItem.Images = []*string{stripe.String(item.ImageURL)}
+		}
+		lineItems = append(lineItems, &lineItem)
+	}
+
+	var discounts []*stripe.CheckoutSessionDiscountParams
+	if request.DiscountAmount > 0 {
+		c, err := p.api.Coupons.New(&stripe.CouponParams{
+			Name:           stripe.String(request.DiscountName),
+			AmountOff:      stripe.Int64(toCents(request.DiscountAmount)),
+			Currency:       stripe.String(request.Currency),
+			Duration:       stripe.String(string(stripe.CouponDurationOnce)),
+			MaxRedemptions: stripe.Int64(1),
+		})
+		if err != nil {
+			return CheckoutSession{}, stripeError(err)
+		}
+
+		discounts = []*stripe.CheckoutSessionDiscountParams{
+			{
+				Coupon: stripe.String(c.ID),
+			},
+		}
+	}
+
+	orderID := strconv.Itoa(request.OrderID)
+	params := &stripe.CheckoutSessionParams{
+		SuccessURL: stripe.String(request.SuccessURL),
+		CancelURL:  stripe.String(request.CancelURL),
+		PaymentMethodTypes: stripe.StringSlice([]string{
+			"card",
+		}),
+		Mode:              stripe.String(string(stripe.CheckoutSessionModePayment)),
+		LineItems:         lineItems,
+		CustomerEmail:     stripe.String(request.Email),
+		Discounts:         discounts,
+		ClientReferenceID: stripe.String(orderID),
+		ExpiresAt:         stripe.Int64(request.ExpiresAt.Unix()),
+	}
+	params.AddMetadata("orderId", orderID)
+
+	checkoutSession, err := p.api.CheckoutSessions.New(params)
+	if err != nil {
+		return CheckoutSession{}, stripeError(err)
+	}
+	return CheckoutSession{ID: checkoutSession.ID}, nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header and maps checkout session
+// events to payment outcomes. Completed sessions only count as paid once the
+// payment has cleared, delayed methods follow up with async_payment_succeeded.
+func (p *StripePaymentProvider) VerifyWebhook(payload []byte, header http.Header) (PaymentEvent, error) {
+	event, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), p.webhookSecret)
+	if err != nil {
+		return PaymentEvent{}, err
+	}
+
+	result := PaymentEvent{
+		ID:   event.ID,
+		Type: event.Type,
+	}
+
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded", "checkout.session.expired", "checkout.session.async_payment_failed":
+	default:
+		return result, nil
+	}
+
+	var checkoutSession stripe.CheckoutSession
+	if err = json.Unmarshal(event.Data.Raw, &checkoutSession); err != nil {
+		return result, err
+	}
+
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		if checkoutSession.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+			return result, nil
+		}
+		result.Outcome = PAYMENT_EVENT_CHECKOUT_PAID
+	default:
+		result.Outcome = PAYMENT_EVENT_CHECKOUT_FAILED
+	}
+
+	result.SessionID = checkoutSession.ID
+	if checkoutSession.PaymentIntent != nil {
+		result.PaymentID = checkoutSession.PaymentIntent.ID
+	}
+
+	reference := checkoutSession.ClientReferenceID
+	if reference == "" {
+		reference = checkoutSession.Metadata["orderId"]
+	}
+
+	if reference == "" {
+		return result, fmt.Errorf("checkout session %s is missing an order reference", checkoutSession.ID)
+	}
+
+	result.OrderID, err = strconv.Atoi(reference)
+	return result, err
+}
+
+func (p *StripePaymentProvider) GetPayment(paymentID string) (Payment, error) {
+	paymentIntent, err := p.api.PaymentIntents.Get(paymentID, nil)
+	if err != nil {
+		return Payment{}, stripeError(err)
+	}
+
+	payment := Payment{
+		ID:       paymentIntent.ID,
+		Status:   string(paymentIntent.Status),
+		Amount:   fromCents(paymentIntent.AmountReceived),
+		Currency: paymentIntent.Currency,
+	}
+
+	if paymentIntent.Charges != nil {
+		for _, charge := range paymentIntent.Charges.Data {
+			if charge.Refunded {
+				payment.Refunded = true
+			}
+		}
+	}
+	return payment, nil
+}
+
+func (p *StripePaymentProvider) Refund(paymentID string) (string, error) {
+	result, err := p.api.Refunds.New(&stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentID),
+	})
+	if err != nil {
+		return "", stripeError(err)
+	}
+	return result.ID, nil
+}
+
+// ExpireCheckoutSession closes the session so it can no longer be paid. Sessions
+// that have already expired are left as they are, paid ones can't be expired.
+func (p *StripePaymentProvider) ExpireCheckoutSession(sessionID string) error {
+	checkoutSession, err := p.api.CheckoutSessions.Get(sessionID, nil)
+	if err != nil {
+		return stripeError(err)
+	}
+
+	switch checkoutSession.Status {
+	case stripe.CheckoutSessionStatusExpired:
+		return nil
+	case stripe.CheckoutSessionStatusComplete:
+		return ErrCheckoutCompleted
+	}
+
+	if _, err = p.api.CheckoutSessions.Expire(sessionID, nil); err != nil {
+		return stripeError(err)
+	}
+	return nil
+}
+
+// stripeError keeps just Stripe's message, which is safe to show customers.
+func stripeError(err error) error {
+	var serr *stripe.Error
+	if errors.As(err, &serr) {
+		return errors.New(serr.Msg)
+	}
+	return err
+}
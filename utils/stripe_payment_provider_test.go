@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+func TestStripeVerifyWebhook(t *testing.T) {
+	provider := NewStripePaymentProvider("sk_test", "whsec_test")
+
+	tt := []struct {
+		name      string
+		body      string
+		signed    bool
+		err       bool
+		outcome   string
+		orderID   int
+		paymentID string
+	}{
+		{
+			name:   "invalid signature",
+			body:   `{"id": "evt_1", "type": "checkout.session.completed", "data": {"object": {}}}`,
+			signed: false,
+			err:    true,
+		},
+		{
+			name:    "unhandled event type",
+			body:    `{"id": "evt_1", "type": "payment_intent.succeeded", "data": {"object": {}}}`,
+			signed:  true,
+			outcome: "",
+		},
+		{
+			name:    "unpaid session",
+			body:    `{"id": "evt_1", "type": "checkout.session.completed", "data": {"object": {"id": "cs_1", "client_reference_id": "5", "payment_status": "unpaid"}}}`,
+			signed:  true,
+			outcome: "",
+		},
+		{
+			name:   "missing order reference",
+			body:   `{"id": "evt_1", "type": "checkout.session.completed", "data": {"object": {"id": "cs_1", "payment_status": "paid"}}}`,
+			signed: true,
+			err:    true,
+		},
+		{
+			name:      "paid, client reference",
+			body:      `{"id": "evt_1", "type": "checkout.session.completed", "data": {"object": {"id": "cs_1", "client_reference_id": "5", "payment_status": "paid", "payment_intent": "pi_1"}}}`,
+			signed:    true,
+			outcome:   PAYMENT_EVENT_CHECKOUT_PAID,
+			orderID:   5,
+			paymentID: "pi_1",
+		},
+		{
+			name:    "paid, metadata",
+			body:    `{"id": "evt_1", "type": "checkout.session.async_payment_succeeded", "data": {"object": {"id": "cs_1", "metadata": {"orderId": "7"}, "payment_status": "paid"}}}`,
+			signed:  true,
+			outcome: PAYMENT_EVENT_CHECKOUT_PAID,
+			orderID: 7,
+		},
+		{
+			name:    "expired session",
+			body:    `{"id": "evt_2", "type": "checkout.session.expired", "data": {"object": {"id": "cs_1", "client_reference_id": "5", "payment_status": "unpaid"}}}`,
+			signed:  true,
+			outcome: PAYMENT_EVENT_CHECKOUT_FAILED,
+			orderID: 5,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			signature := "t=0,v1=invalid"
+			if tc.signed {
+				now := time.Now()
+				signature = fmt.Sprintf("t=%d,v1=%x", now.Unix(), webhook.ComputeSignature(now, []byte(tc.body), "whsec_test"))
+			}
+
+			header := make(http.Header)
+			header.Set("Stripe-Signature", signature)
+			event, err := provider.VerifyWebhook([]byte(tc.body), header)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if event.Outcome != tc.outcome {
+				t.Errorf("expected outcome %v; got %v", tc.outcome, event.Outcome)
+			}
+
+			if event.OrderID != tc.orderID {
+				t.Errorf("expected order id %v; got %v", tc.orderID, event.OrderID)
+			}
+
+			if event.PaymentID != tc.paymentID {
+				t.Errorf("expected payment id %v; got %v", tc.paymentID, event.PaymentID)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const SVG_NAMESPACE = "http://www.w3.org/2000/svg"
+
+// SVG element types match the mapElementType names they are stored with.
+const (
+	SVG_ELEMENT_PATH     = "path"
+	SVG_ELEMENT_POLYGON  = "polygon"
+	SVG_ELEMENT_POLYLINE = "polyline"
+	SVG_ELEMENT_CIRCLE   = "circle"
+	SVG_ELEMENT_RECT     = "rect"
+	SVG_ELEMENT_LINE     = "line"
+)
+
+type SVGElement struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	D          string `json:"d"`
+	Points     string `json:"points"`
+	X          string `json:"x"`
+	Y          string `json:"y"`
+	Width      string `json:"width"`
+	Height     string `json:"height"`
+	Cx         string `json:"cx"`
+	Cy         string `json:"cy"`
+	R          string `json:"r"`
+	Transform  string `json:"transform"`
+	XlinkHref  string `json:"xlinkHref"`
+	ClipPath   string `json:"clipPath"`
+	ClipPathId string `json:"clipPathId"`
+	X1         string `json:"x1"`
+	Y1         string `json:"y1"`
+	X2         string `json:"x2"`
+	Y2         string `json:"y2"`
+}
+
+// SVGWarning describes something in the document that was skipped or might not
+// render the way it did in the source file.
+type SVGWarning struct {
+	Element string `json:"element"`
+	ID      string `json:"id"`
+	Offset  int64  `json:"offset"`
+	Message string `json:"message"`
+}
+
+type SVGDocument struct {
+	ViewBox  string       `json:"viewBox"`
+	Elements []SVGElement `json:"elements"`
+	Warnings []SVGWarning `json:"warnings"`
+}
+
+// requiredSVGAttributes are the attributes a shape can't be drawn without.
+var requiredSVGAttributes = map[string][]string{
+	SVG_ELEMENT_PATH:     {"d"},
+	SVG_ELEMENT_POLYGON:  {"points"},
+	SVG_ELEMENT_POLYLINE: {"points"},
+	SVG_ELEMENT_CIRCLE:   {"r"},
+	SVG_ELEMENT_RECT:     {"width", "height"},
+	SVG_ELEMENT_LINE:     {"x1", "y1", "x2", "y2"},
+}
+
+// Elements that don't draw anything and can be skipped without a warning.
+var ignoredSVGElements = map[string]bool{
+	"title":          true,
+	"desc":           true,
+	"metadata":       true,
+	"style":          true,
+	"namedview":      true,
+	"linearGradient": true,
+	"radialGradient": true,
+	"stop":           true,
+}
+
+var svgLengthPattern = regexp.MustCompile(`^\s*([0-9.]+)\s*(px)?\s*$`)
+
+type svgGroup struct {
+	name       string
+	id         string
+	title      string
+	transform  string
+	clipPath   string
+	clipPathId string
+	defs       bool
+}
+
+type svgUse struct {
+	element SVGElement
+	href    string
+	offset  int64
+}
+
+// ParseSVG reads the drawable elements out of an SVG document. Groups are
+// flattened, with their transforms prepended to each child's, and elements
+// without an id take their group's so a region drawn with several shapes can
+// be highlighted as one. Shapes in defs are only drawn where a use element
+// references them.
+func ParseSVG(reader io.Reader) (SVGDocument, error) {
+	document := SVGDocument{Elements: []SVGElement{}, Warnings: []SVGWarning{}}
+	decoder := xml.NewDecoder(reader)
+	decoder.Entity = xml.HTMLEntity
+
+	defs := make(map[string]SVGElement)
+	var uses []svgUse
+	var stack []svgGroup
+	current := -1
+	var inTitle, foundRoot bool
+	var title strings.Builder
+
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return document, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			name := token.Name.Local
+			attrs := svgAttributes(token.Attr)
+			parent := svgGroup{}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			if !foundRoot {
+				if name != "svg" {
+					return document, errors.New("document is not an svg")
+				}
+				foundRoot = true
+				document.ViewBox = svgViewBox(attrs, &document)
+				stack = append(stack, svgGroup{name: name})
+				continue
+			}
+
+			if name == "title" {
+				inTitle = true
+				title.Reset()
+				stack = append(stack, svgGroup{name: name})
+				continue
+			}
+
+			group := svgGroup{
+				name:       name,
+				id:         parent.id,
+				title:      parent.title,
+				transform:  joinTransforms(parent.transform, attrs["transform"]),
+				clipPath:   parent.clipPath,
+				clipPathId: parent.clipPathId,
+				defs:       parent.defs,
+			}
+
+			if id := attrs["id"]; id != "" {
+				group.id = id
+				group.title = svgName(attrs)
+			} else if title := svgName(attrs); title != "" {
+				group.title = title
+			}
+
+			if clipPath := attrs["clip-path"]; clipPath != "" {
+				group.clipPath = clipPath
+			}
+
+			if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+				document.warn(name, attrs["id"], offset, "hidden element skipped")
+				decoder.Skip()
+				continue
+			}
+
+			switch {
+			case name == "g" || name == "svg" || name == "a" || name == "switch":
+				if name == "svg" {
+					document.warn(name, attrs["id"], offset, "nested svg flattened, its viewBox is ignored")
+				}
+			case name == "defs":
+				group.defs = true
+			case name == "clipPath":
+				group.clipPathId = attrs["id"]
+				group.transform = attrs["transform"]
+			case name == "use":
+				href := attrs["href"]
+				if href == "" {
+					document.warn(name, attrs["id"], offset, "use element has no href")
+					break
+				}
+
+				element := SVGElement{
+					ID:         attrs["id"],
+					Name:       svgName(attrs),
+					X:          attrs["x"],
+					Y:          attrs["y"],
+					Transform:  group.transform,
+					XlinkHref:  href,
+					ClipPath:   group.clipPath,
+					ClipPathId: group.clipPathId,
+				}
+				if element.ID == "" {
+					element.ID, element.Name = parent.id, parent.title
+				}
+				uses = append(uses, svgUse{element, strings.TrimPrefix(href, "#"), offset})
+			case requiredSVGAttributes[name] != nil:
+				element, ok := document.shape(name, attrs, group, parent, offset)
+				if !ok {
+					break
+				}
+
+				if group.defs && group.clipPathId == "" {
+					defs[attrs["id"]] = element
+					break
+				}
+
+				document.Elements = append(document.Elements, element)
+				current = len(document.Elements) - 1
+			case !ignoredSVGElements[name] && (token.Name.Space == "" || token.Name.Space == SVG_NAMESPACE):
+				document.warn(name, attrs["id"], offset, fmt.Sprintf("unsupported element %s skipped", name))
+				decoder.Skip()
+				continue
+			default:
+				// Editor specific elements, e.g. sodipodi:namedview, and ones
+				// that don't draw anything.
+				decoder.Skip()
+				continue
+			}
+
+			if name != "use" && requiredSVGAttributes[name] == nil {
+				current = -1
+			}
+			stack = append(stack, group)
+		case xml.CharData:
+			if inTitle {
+				title.Write(token)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+
+			closed := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if closed.name != "title" {
+				if requiredSVGAttributes[closed.name] != nil {
+					current = -1
+				}
+				continue
+			}
+
+			inTitle = false
+			text := strings.TrimSpace(title.String())
+			if current >= 0 && document.Elements[current].Name == "" {
+				document.Elements[current].Name = text
+			} else if current < 0 && len(stack) > 1 && stack[len(stack)-1].title == "" {
+				// The document's own title isn't the name of anything in it.
+				stack[len(stack)-1].title = text
+			}
+		}
+	}
+
+	if !foundRoot {
+		return document, errors.New("document is not an svg")
+	}
+
+	for _, use := range uses {
+		target, found := defs[use.href]
+		if !found {
+			target, found = findSVGElement(document.Elements, use.href)
+		}
+
+		if !found {
+			document.warn("use", use.element.ID, use.offset, fmt.Sprintf("use references unknown element %s", use.href))
+			continue
+		}
+		document.Elements = append(document.Elements, resolveSVGUse(use.element, target))
+	}
+	return document, nil
+}
+
+func (d *SVGDocument) warn(element, id string, offset int64, message string) {
+	d.Warnings = append(d.Warnings, SVGWarning{Element: element, ID: id, Offset: offset, Message: message})
+}
+
+func (d *SVGDocument) shape(name string, attrs map[string]string, group, parent svgGroup, offset int64) (SVGElement, bool) {
+	for _, attr := range requiredSVGAttributes[name] {
+		if strings.TrimSpace(attrs[attr]) == "" {
+			d.warn(name, attrs["id"], offset, fmt.Sprintf("%s skipped, missing %s", name, attr))
+			return SVGElement{}, false
+		}
+	}
+
+	element := SVGElement{
+		Type:       name,
+		ID:         attrs["id"],
+		Name:       svgName(attrs),
+		D:          attrs["d"],
+		Points:     attrs["points"],
+		X:          attrs["x"],
+		Y:          attrs["y"],
+		Width:      attrs["width"],
+		Height:     attrs["height"],
+		Cx:         attrs["cx"],
+		Cy:         attrs["cy"],
+		R:          attrs["r"],
+		Transform:  group.transform,
+		ClipPath:   group.clipPath,
+		ClipPathId: group.clipPathId,
+		X1:         attrs["x1"],
+		Y1:         attrs["y1"],
+		X2:         attrs["x2"],
+		Y2:         attrs["y2"],
+	}
+
+	if element.ID == "" && group.clipPathId == "" {
+		element.ID, element.Name = parent.id, parent.title
+	}
+
+	if name == SVG_ELEMENT_RECT && (attrs["rx"] != "" || attrs["ry"] != "") {
+		d.warn(name, element.ID, offset, "rounded corners are not supported")
+	}
+	return element, true
+}
+
+// resolveSVGUse draws the referenced element in place of the use, offset by
+// the use's x and y.
+func resolveSVGUse(use, target SVGElement) SVGElement {
+	element := target
+	element.ID, element.Name = use.ID, use.Name
+	if element.ID == "" {
+		element.ID, element.Name = target.ID, target.Name
+	}
+
+	transform := use.Transform
+	if use.X != "" || use.Y != "" {
+		transform = joinTransforms(transform, fmt.Sprintf("translate(%s %s)", svgNumber(use.X), svgNumber(use.Y)))
+	}
+	element.Transform = joinTransforms(transform, target.Transform)
+	element.XlinkHref = use.XlinkHref
+	element.ClipPath = use.ClipPath
+	element.ClipPathId = use.ClipPathId
+	return element
+}
+
+func findSVGElement(elements []SVGElement, id string) (SVGElement, bool) {
+	for _, element := range elements {
+		if element.ID == id {
+			return element, true
+		}
+	}
+	return SVGElement{}, false
+}
+
+// svgAttributes keys attributes by local name, so xlink:href and href are
+// both read as href.
+func svgAttributes(attrs []xml.Attr) map[string]string {
+	result := make(map[string]string)
+	for _, attr := range attrs {
+		if attr.Name.Local == "href" && result["href"] != "" && attr.Name.Space != "" {
+			continue
+		}
+		result[attr.Name.Local] = strings.TrimSpace(attr.Value)
+	}
+	return result
+}
+
+func svgName(attrs map[string]string) string {
+	for _, key := range []string{"title", "name", "data-name"} {
+		if value := attrs[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// svgViewBox falls back to the width and height when there's no viewBox.
+func svgViewBox(attrs map[string]string, document *SVGDocument) string {
+	if viewBox := strings.Join(strings.Fields(strings.ReplaceAll(attrs["viewBox"], ",", " ")), " "); viewBox != "" {
+		return viewBox
+	}
+
+	width := svgLengthPattern.FindStringSubmatch(attrs["width"])
+	height := svgLengthPattern.FindStringSubmatch(attrs["height"])
+	if width == nil || height == nil {
+		document.warn("svg", attrs["id"], 0, "svg has no viewBox or absolute width and height")
+		return ""
+	}
+	return fmt.Sprintf("0 0 %s %s", width[1], height[1])
+}
+
+func joinTransforms(outer, inner string) string {
+	return strings.TrimSpace(strings.TrimSpace(outer) + " " + strings.TrimSpace(inner))
+}
+
+func svgNumber(value string) string {
+	if value == "" {
+		return "0"
+	}
+	return value
+}
+
+// IsSVGShape reports whether elements of the type can be stored and drawn.
+func IsSVGShape(name string) bool {
+	_, found := requiredSVGAttributes[name]
+	return found
+}
@@ -0,0 +1,526 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Curves and arcs are flattened into this many line segments.
+const (
+	SVG_CURVE_SEGMENTS   = 16
+	SVG_CIRCLE_SEGMENTS  = 48
+	SVG_ARC_SEGMENT_STEP = math.Pi / 18
+)
+
+type svgPoint struct {
+	x float64
+	y float64
+}
+
+type svgSubpath struct {
+	points []svgPoint
+	closed bool
+}
+
+// svgMatrix is an affine transform in SVG's a b c d e f order.
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns the transform that applies n and then m.
+func (m svgMatrix) multiply(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(point svgPoint) svgPoint {
+	return svgPoint{m[0]*point.x + m[2]*point.y + m[4], m[1]*point.x + m[3]*point.y + m[5]}
+}
+
+var svgTransformPattern = regexp.MustCompile(`([a-zA-Z]+)\s*\(([^)]*)\)`)
+
+// parseSVGTransform reads a transform list, e.g. "translate(10 20) scale(2)".
+func parseSVGTransform(transform string) (svgMatrix, error) {
+	result := svgIdentity
+	for _, match := range svgTransformPattern.FindAllStringSubmatch(transform, -1) {
+		args, err := parseSVGNumbers(match[2])
+		if err != nil {
+			return result, fmt.Errorf("invalid transform %s", match[0])
+		}
+
+		var next svgMatrix
+		switch {
+		case match[1] == "matrix" && len(args) == 6:
+			copy(next[:], args)
+		case match[1] == "translate" && len(args) == 1:
+			next = svgMatrix{1, 0, 0, 1, args[0], 0}
+		case match[1] == "translate" && len(args) == 2:
+			next = svgMatrix{1, 0, 0, 1, args[0], args[1]}
+		case match[1] == "scale" && len(args) == 1:
+			next = svgMatrix{args[0], 0, 0, args[0], 0, 0}
+		case match[1] == "scale" && len(args) == 2:
+			next = svgMatrix{args[0], 0, 0, args[1], 0, 0}
+		case match[1] == "rotate" && (len(args) == 1 || len(args) == 3):
+			angle := args[0] * math.Pi / 180
+			next = svgMatrix{math.Cos(angle), math.Sin(angle), -math.Sin(angle), math.Cos(angle), 0, 0}
+			if len(args) == 3 {
+				next = svgMatrix{1, 0, 0, 1, args[1], args[2]}.multiply(next).multiply(svgMatrix{1, 0, 0, 1, -args[1], -args[2]})
+			}
+		case match[1] == "skewX" && len(args) == 1:
+			next = svgMatrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0}
+		case match[1] == "skewY" && len(args) == 1:
+			next = svgMatrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return result, fmt.Errorf("invalid transform %s", match[0])
+		}
+		result = result.multiply(next)
+	}
+	return result, nil
+}
+
+// svgScanner reads numbers out of path data and attribute lists, where the
+// separators between numbers are optional, e.g. "M1.5.5-2".
+type svgScanner struct {
+	value string
+	index int
+}
+
+func (s *svgScanner) skipSeparators() {
+	for s.index < len(s.value) && strings.IndexByte(" \t\r\n,", s.value[s.index]) >= 0 {
+		s.index++
+	}
+}
+
+func (s *svgScanner) done() bool {
+	s.skipSeparators()
+	return s.index >= len(s.value)
+}
+
+func (s *svgScanner) peekNumber() bool {
+	s.skipSeparators()
+	return s.index < len(s.value) && strings.IndexByte("+-.0123456789", s.value[s.index]) >= 0
+}
+
+func (s *svgScanner) number() (float64, error) {
+	s.skipSeparators()
+	start := s.index
+	if s.index < len(s.value) && (s.value[s.index] == '+' || s.value[s.index] == '-') {
+		s.index++
+	}
+
+	digits := s.digits()
+	if s.index < len(s.value) && s.value[s.index] == '.' {
+		s.index++
+		digits = s.digits() || digits
+	}
+
+	if !digits {
+		return 0, fmt.Errorf("expected number at %d", start)
+	}
+
+	if s.index < len(s.value) && (s.value[s.index] == 'e' || s.value[s.index] == 'E') {
+		end := s.index
+		s.index++
+		if s.index < len(s.value) && (s.value[s.index] == '+' || s.value[s.index] == '-') {
+			s.index++
+		}
+
+		if !s.digits() {
+			s.index = end
+		}
+	}
+	return strconv.ParseFloat(s.value[start:s.index], 64)
+}
+
+// flag reads an arc flag, which can be written without a separator after it.
+func (s *svgScanner) flag() (bool, error) {
+	s.skipSeparators()
+	if s.index < len(s.value) && (s.value[s.index] == '0' || s.value[s.index] == '1') {
+		s.index++
+		return s.value[s.index-1] == '1', nil
+	}
+	return false, fmt.Errorf("expected flag at %d", s.index)
+}
+
+func (s *svgScanner) digits() bool {
+	start := s.index
+	for s.index < len(s.value) && s.value[s.index] >= '0' && s.value[s.index] <= '9' {
+		s.index++
+	}
+	return s.index > start
+}
+
+func parseSVGNumbers(value string) ([]float64, error) {
+	scanner := svgScanner{value: value}
+	var numbers []float64
+	for !scanner.done() {
+		number, err := scanner.number()
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+var svgPathArgs = map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7, 'Z': 0}
+
+// parseSVGPath flattens path data into subpaths of straight lines.
+func parseSVGPath(d string) ([]svgSubpath, error) {
+	scanner := svgScanner{value: d}
+	var subpaths []svgSubpath
+	var current svgSubpath
+	var position, start, control svgPoint
+	var previous byte
+
+	finish := func() {
+		if len(current.points) > 0 {
+			subpaths = append(subpaths, current)
+		}
+		current = svgSubpath{}
+	}
+
+	lineTo := func(point svgPoint) {
+		if len(current.points) == 0 {
+			current.points = append(current.points, position)
+		}
+		current.points = append(current.points, point)
+		position = point
+	}
+
+	var command byte
+	for !scanner.done() {
+		if !scanner.peekNumber() {
+			command = scanner.value[scanner.index]
+			scanner.index++
+		} else if command == 0 {
+			return nil, fmt.Errorf("expected command at %d", scanner.index)
+		}
+
+		upper := command &^ 0x20
+		count, found := svgPathArgs[upper]
+		if !found {
+			return nil, fmt.Errorf("unknown path command %c", command)
+		}
+
+		relative := command != upper
+		args := make([]float64, count)
+		flags := make([]bool, 2)
+		for index := range args {
+			var err error
+			if upper == 'A' && (index == 3 || index == 4) {
+				flags[index-3], err = scanner.flag()
+			} else {
+				args[index], err = scanner.number()
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		offset := func(x, y float64) svgPoint {
+			if relative {
+				return svgPoint{position.x + x, position.y + y}
+			}
+			return svgPoint{x, y}
+		}
+
+		switch upper {
+		case 'M':
+			finish()
+			position = offset(args[0], args[1])
+			start = position
+			current.points = []svgPoint{position}
+			// Further coordinate pairs after a move are line commands.
+			command = 'L' | (command & 0x20)
+		case 'L':
+			lineTo(offset(args[0], args[1]))
+		case 'H':
+			x := args[0]
+			if relative {
+				x = position.x + x
+			}
+			lineTo(svgPoint{x, position.y})
+		case 'V':
+			y := args[0]
+			if relative {
+				y = position.y + y
+			}
+			lineTo(svgPoint{position.x, y})
+		case 'C', 'S':
+			first := reflectSVGControl(position, control, previous == 'C' || previous == 'S')
+			if upper == 'C' {
+				first = offset(args[0], args[1])
+				args = args[2:]
+			}
+			second, end := offset(args[0], args[1]), offset(args[2], args[3])
+			from := position
+			for step := 1; step <= SVG_CURVE_SEGMENTS; step++ {
+				lineTo(cubicSVGPoint(from, first, second, end, float64(step)/SVG_CURVE_SEGMENTS))
+			}
+			control = second
+		case 'Q', 'T':
+			middle := reflectSVGControl(position, control, previous == 'Q' || previous == 'T')
+			if upper == 'Q' {
+				middle = offset(args[0], args[1])
+				args = args[2:]
+			}
+			end := offset(args[0], args[1])
+			from := position
+			for step := 1; step <= SVG_CURVE_SEGMENTS; step++ {
+				lineTo(quadraticSVGPoint(from, middle, end, float64(step)/SVG_CURVE_SEGMENTS))
+			}
+			control = middle
+		case 'A':
+			for _, point := range arcSVGPoints(position, args[0], args[1], args[2], flags[0], flags[1], offset(args[5], args[6])) {
+				lineTo(point)
+			}
+		case 'Z':
+			if len(current.points) > 0 {
+				current.closed = true
+				finish()
+			}
+			position = start
+			// Z takes no arguments, so a number after it can't repeat it.
+			command = 0
+		}
+
+		if upper != 'C' && upper != 'S' && upper != 'Q' && upper != 'T' {
+			control = position
+		}
+		previous = upper
+	}
+
+	finish()
+	return subpaths, nil
+}
+
+// reflectSVGControl gives the implied first control point of a smooth curve.
+func reflectSVGControl(position, control svgPoint, smooth bool) svgPoint {
+	if !smooth {
+		return position
+	}
+	return svgPoint{2*position.x - control.x, 2*position.y - control.y}
+}
+
+func cubicSVGPoint(p0, p1, p2, p3 svgPoint, t float64) svgPoint {
+	u := 1 - t
+	return svgPoint{
+		u*u*u*p0.x + 3*u*u*t*p1.x + 3*u*t*t*p2.x + t*t*t*p3.x,
+		u*u*u*p0.y + 3*u*u*t*p1.y + 3*u*t*t*p2.y + t*t*t*p3.y,
+	}
+}
+
+func quadraticSVGPoint(p0, p1, p2 svgPoint, t float64) svgPoint {
+	u := 1 - t
+	return svgPoint{u*u*p0.x + 2*u*t*p1.x + t*t*p2.x, u*u*p0.y + 2*u*t*p1.y + t*t*p2.y}
+}
+
+// arcSVGPoints converts an endpoint arc to its centre form and samples it, as
+// described in the SVG implementation notes.
+func arcSVGPoints(from svgPoint, rx, ry, rotation float64, large, sweep bool, to svgPoint) []svgPoint {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || from == to {
+		return []svgPoint{to}
+	}
+
+	phi := rotation * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (from.x-to.x)/2, (from.y-to.y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	if scale := x1*x1/(rx*rx) + y1*y1/(ry*ry); scale > 1 {
+		rx, ry = rx*math.Sqrt(scale), ry*math.Sqrt(scale)
+	}
+
+	numerator := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	factor := math.Sqrt(math.Max(0, numerator/(rx*rx*y1*y1+ry*ry*x1*x1)))
+	if large == sweep {
+		factor = -factor
+	}
+	cx1, cy1 := factor*rx*y1/ry, -factor*ry*x1/rx
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	start := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	cx := cos*cx1 - sin*cy1 + (from.x+to.x)/2
+	cy := sin*cx1 + cos*cy1 + (from.y+to.y)/2
+	steps := int(math.Ceil(math.Abs(delta) / SVG_ARC_SEGMENT_STEP))
+	points := make([]svgPoint, 0, steps)
+	for step := 1; step < steps; step++ {
+		theta := start + delta*float64(step)/float64(steps)
+		x, y := rx*math.Cos(theta), ry*math.Sin(theta)
+		points = append(points, svgPoint{cos*x - sin*y + cx, sin*x + cos*y + cy})
+	}
+	return append(points, to)
+}
+
+// svgSubpaths flattens any supported element into subpaths in its own
+// coordinate space, before its transform is applied.
+func svgSubpaths(element SVGElement) ([]svgSubpath, error) {
+	number := func(value string) (float64, error) {
+		if strings.TrimSpace(value) == "" {
+			return 0, nil
+		}
+		match := svgLengthPattern.FindStringSubmatch(value)
+		if match == nil {
+			return strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+		return strconv.ParseFloat(match[1], 64)
+	}
+
+	values := func(fields ...string) ([]float64, error) {
+		result := make([]float64, len(fields))
+		for index, field := range fields {
+			value, err := number(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s", element.Type, field)
+			}
+			result[index] = value
+		}
+		return result, nil
+	}
+
+	switch element.Type {
+	case SVG_ELEMENT_PATH:
+		return parseSVGPath(element.D)
+	case SVG_ELEMENT_POLYGON, SVG_ELEMENT_POLYLINE:
+		numbers, err := parseSVGNumbers(element.Points)
+		if err != nil || len(numbers)%2 != 0 {
+			return nil, fmt.Errorf("invalid points %s", element.Points)
+		}
+
+		subpath := svgSubpath{closed: element.Type == SVG_ELEMENT_POLYGON}
+		for index := 0; index < len(numbers); index += 2 {
+			subpath.points = append(subpath.points, svgPoint{numbers[index], numbers[index+1]})
+		}
+		return []svgSubpath{subpath}, nil
+	case SVG_ELEMENT_RECT:
+		v, err := values(element.X, element.Y, element.Width, element.Height)
+		if err != nil {
+			return nil, err
+		}
+		return []svgSubpath{{points: []svgPoint{{v[0], v[1]}, {v[0] + v[2], v[1]}, {v[0] + v[2], v[1] + v[3]}, {v[0], v[1] + v[3]}}, closed: true}}, nil
+	case SVG_ELEMENT_CIRCLE:
+		v, err := values(element.Cx, element.Cy, element.R)
+		if err != nil {
+			return nil, err
+		}
+
+		subpath := svgSubpath{closed: true}
+		for step := 0; step < SVG_CIRCLE_SEGMENTS; step++ {
+			theta := 2 * math.Pi * float64(step) / SVG_CIRCLE_SEGMENTS
+			subpath.points = append(subpath.points, svgPoint{v[0] + v[2]*math.Cos(theta), v[1] + v[2]*math.Sin(theta)})
+		}
+		return []svgSubpath{subpath}, nil
+	case SVG_ELEMENT_LINE:
+		v, err := values(element.X1, element.Y1, element.X2, element.Y2)
+		if err != nil {
+			return nil, err
+		}
+		return []svgSubpath{{points: []svgPoint{{v[0], v[1]}, {v[2], v[3]}}}}, nil
+	}
+	return nil, fmt.Errorf("unsupported element type %s", element.Type)
+}
+
+// transformedSVGSubpaths flattens the element and applies its transform
+// followed by the outer one.
+func transformedSVGSubpaths(element SVGElement, outer svgMatrix) ([]svgSubpath, error) {
+	subpaths, err := svgSubpaths(element)
+	if err != nil {
+		return nil, err
+	}
+
+	transform, err := parseSVGTransform(element.Transform)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := outer.multiply(transform)
+	for _, subpath := range subpaths {
+		for index, point := range subpath.points {
+			subpath.points[index] = matrix.apply(point)
+		}
+	}
+	return subpaths, nil
+}
+
+// SVGBounds is an axis aligned box in user space.
+type SVGBounds struct {
+	MinX float64
+	MinY float64
+	MaxX float64
+	MaxY float64
+}
+
+// ElementBounds returns the box around the given elements once their
+// transforms are applied. Found is false if none of them draw anything.
+func ElementBounds(elements []SVGElement) (SVGBounds, bool, error) {
+	bounds := SVGBounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	found := false
+	for _, element := range elements {
+		subpaths, err := transformedSVGSubpaths(element, svgIdentity)
+		if err != nil {
+			return bounds, false, err
+		}
+
+		for _, subpath := range subpaths {
+			for _, point := range subpath.points {
+				bounds.MinX, bounds.MaxX = math.Min(bounds.MinX, point.x), math.Max(bounds.MaxX, point.x)
+				bounds.MinY, bounds.MaxY = math.Min(bounds.MinY, point.y), math.Max(bounds.MaxY, point.y)
+				found = true
+			}
+		}
+	}
+	return bounds, found, nil
+}
+
+// ViewBox formats the bounds as a viewBox with padding added on every side.
+func (b SVGBounds) ViewBox(padding float64) string {
+	return strings.Join([]string{
+		FormatSVGNumber(b.MinX - padding),
+		FormatSVGNumber(b.MinY - padding),
+		FormatSVGNumber(b.MaxX - b.MinX + 2*padding),
+		FormatSVGNumber(b.MaxY - b.MinY + 2*padding),
+	}, " ")
+}
+
+// FormatSVGNumber writes a coordinate with at most two decimal places and no
+// exponent.
+func FormatSVGNumber(value float64) string {
+	value = math.Round(value*100) / 100
+	if value == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// parseSVGViewBox returns the min x, min y, width and height of a viewBox.
+func parseSVGViewBox(viewBox string) ([4]float64, error) {
+	var result [4]float64
+	numbers, err := parseSVGNumbers(viewBox)
+	if err != nil || len(numbers) != 4 || numbers[2] <= 0 || numbers[3] <= 0 {
+		return result, fmt.Errorf("invalid viewBox %s", viewBox)
+	}
+	copy(result[:], numbers)
+	return result, nil
+}
@@ -0,0 +1,452 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Map colours match the ones the site draws maps with.
+const (
+	MAP_FILL_COLOUR      = "#6dca94"
+	MAP_STROKE_COLOUR    = "#ffffff"
+	MAP_HIGHLIGHT_COLOUR = "#e24f4f"
+)
+
+// MAP_CROP_PADDING is the share of the cropped region's larger side left
+// around it so it isn't drawn against the edge.
+const MAP_CROP_PADDING = 0.05
+
+// MAP_RENDER_SAMPLES is how many rows each pixel is sampled at when
+// rasterising, for anti-aliasing.
+const MAP_RENDER_SAMPLES = 4
+
+type MapRenderOptions struct {
+	Title string
+	// Highlight and Crop hold element ids, or names, in lower case.
+	Highlight map[string]bool
+	Crop      map[string]bool
+	// Fills are colours by lower case element id, e.g. to colour by grouping.
+	Fills      map[string]string
+	Background string
+}
+
+var ErrNoCropElements = errors.New("no elements found to crop to")
+
+var hexColourPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ParseHexColour reads #rgb or #rrggbb, with or without the #.
+func ParseHexColour(value string) (color.NRGBA, error) {
+	match := hexColourPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %s", value)
+	}
+
+	hex := match[1]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	var colour color.NRGBA
+	fmt.Sscanf(hex, "%02x%02x%02x", &colour.R, &colour.G, &colour.B)
+	colour.A = 255
+	return colour, nil
+}
+
+// MapViewBox returns the viewBox to draw, cropped to the Crop elements if set.
+func MapViewBox(viewBox string, elements []SVGElement, options MapRenderOptions) (string, error) {
+	if len(options.Crop) == 0 {
+		if _, err := parseSVGViewBox(viewBox); err != nil {
+			return "", err
+		}
+		return viewBox, nil
+	}
+
+	var cropped []SVGElement
+	for _, element := range elements {
+		if element.ClipPathId == "" && matchesMapElement(options.Crop, element) {
+			cropped = append(cropped, element)
+		}
+	}
+
+	bounds, found, err := ElementBounds(cropped)
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", ErrNoCropElements
+	}
+	return bounds.ViewBox(math.Max(bounds.MaxX-bounds.MinX, bounds.MaxY-bounds.MinY) * MAP_CROP_PADDING), nil
+}
+
+// RenderMapSVG writes the elements back out as a standalone SVG document.
+func RenderMapSVG(viewBox string, elements []SVGElement, options MapRenderOptions) ([]byte, error) {
+	viewBox, err := MapViewBox(viewBox, elements, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, `<svg xmlns="%s" viewBox="%s">`, SVG_NAMESPACE, escapeSVG(viewBox))
+	if options.Title != "" {
+		fmt.Fprintf(&buffer, "<title>%s</title>", escapeSVG(options.Title))
+	}
+
+	if options.Background != "" {
+		values, _ := parseSVGViewBox(viewBox)
+		fmt.Fprintf(&buffer, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`, FormatSVGNumber(values[0]), FormatSVGNumber(values[1]), FormatSVGNumber(values[2]), FormatSVGNumber(values[3]), escapeSVG(options.Background))
+	}
+
+	clipPaths := make(map[string][]SVGElement)
+	var clipPathIds []string
+	for _, element := range elements {
+		if element.ClipPathId == "" {
+			continue
+		}
+
+		if _, found := clipPaths[element.ClipPathId]; !found {
+			clipPathIds = append(clipPathIds, element.ClipPathId)
+		}
+		clipPaths[element.ClipPathId] = append(clipPaths[element.ClipPathId], element)
+	}
+
+	if len(clipPathIds) > 0 {
+		buffer.WriteString("<defs>")
+		for _, id := range clipPathIds {
+			fmt.Fprintf(&buffer, `<clipPath id="%s">`, escapeSVG(id))
+			for _, element := range clipPaths[id] {
+				writeSVGElement(&buffer, element, "")
+			}
+			buffer.WriteString("</clipPath>")
+		}
+		buffer.WriteString("</defs>")
+	}
+
+	fmt.Fprintf(&buffer, `<g fill="%s" stroke="%s" stroke-width="1">`, MAP_FILL_COLOUR, MAP_STROKE_COLOUR)
+	for _, element := range elements {
+		if element.ClipPathId == "" {
+			writeSVGElement(&buffer, element, mapElementFill(element, options))
+		}
+	}
+	buffer.WriteString("</g></svg>")
+	return buffer.Bytes(), nil
+}
+
+func writeSVGElement(buffer *bytes.Buffer, element SVGElement, fill string) {
+	fmt.Fprintf(buffer, "<%s", element.Type)
+	attrs := [][2]string{
+		{"id", element.ID},
+		{"d", element.D},
+		{"points", element.Points},
+		{"x", element.X},
+		{"y", element.Y},
+		{"width", element.Width},
+		{"height", element.Height},
+		{"cx", element.Cx},
+		{"cy", element.Cy},
+		{"r", element.R},
+		{"x1", element.X1},
+		{"y1", element.Y1},
+		{"x2", element.X2},
+		{"y2", element.Y2},
+		{"transform", element.Transform},
+		{"clip-path", element.ClipPath},
+	}
+
+	if fill != "" && fill != MAP_FILL_COLOUR {
+		attrs = append(attrs, [2]string{"fill", fill})
+	}
+
+	if element.ClipPathId == "" {
+		attrs = append(attrs, [2]string{"vector-effect", "non-scaling-stroke"})
+	}
+
+	for _, attr := range attrs {
+		if attr[1] != "" {
+			fmt.Fprintf(buffer, ` %s="%s"`, attr[0], escapeSVG(attr[1]))
+		}
+	}
+
+	if element.Name == "" {
+		buffer.WriteString("/>")
+		return
+	}
+	fmt.Fprintf(buffer, "><title>%s</title></%s>", escapeSVG(element.Name), element.Type)
+}
+
+func escapeSVG(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
+
+func matchesMapElement(keys map[string]bool, element SVGElement) bool {
+	return (element.ID != "" && keys[strings.ToLower(element.ID)]) || (element.Name != "" && keys[strings.ToLower(element.Name)])
+}
+
+// mapElementFill returns the colour to fill an element with. Highlighting
+// takes priority over group colours.
+func mapElementFill(element SVGElement, options MapRenderOptions) string {
+	if element.Type == SVG_ELEMENT_LINE || element.Type == SVG_ELEMENT_POLYLINE {
+		return "none"
+	}
+
+	if matchesMapElement(options.Highlight, element) {
+		return MAP_HIGHLIGHT_COLOUR
+	}
+
+	if fill, found := options.Fills[strings.ToLower(element.ID)]; found {
+		return fill
+	}
+	return MAP_FILL_COLOUR
+}
+
+// RenderMapPNG rasterises the map to a PNG of the given width. If height is 0
+// it's worked out from the viewBox, otherwise the map is centred in the image,
+// e.g. for 1200x630 share cards. Clip paths are applied, other SVG features
+// are drawn the same way the SVG output would be.
+func RenderMapPNG(viewBox string, elements []SVGElement, options MapRenderOptions, width, height int) ([]byte, error) {
+	viewBox, err := MapViewBox(viewBox, elements, options)
+	if err != nil {
+		return nil, err
+	}
+
+	values, _ := parseSVGViewBox(viewBox)
+	if height == 0 {
+		height = int(math.Max(1, math.Round(float64(width)*values[3]/values[2])))
+	}
+
+	scale := math.Min(float64(width)/values[2], float64(height)/values[3])
+	viewport := svgMatrix{
+		scale, 0, 0, scale,
+		(float64(width)-values[2]*scale)/2 - values[0]*scale,
+		(float64(height)-values[3]*scale)/2 - values[1]*scale,
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	if options.Background != "" {
+		background, err := ParseHexColour(options.Background)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	}
+
+	rasteriser := newMapRasteriser(canvas)
+	clips := make(map[string]*image.Alpha)
+	for _, element := range elements {
+		if element.ClipPathId == "" {
+			continue
+		}
+
+		if clips[element.ClipPathId] == nil {
+			clips[element.ClipPathId] = image.NewAlpha(canvas.Bounds())
+		}
+
+		subpaths, err := transformedSVGSubpaths(element, viewport)
+		if err != nil {
+			return nil, err
+		}
+		newMapRasteriser(clips[element.ClipPathId]).fill(subpaths, color.NRGBA{A: 255}, nil)
+	}
+
+	stroke, _ := ParseHexColour(MAP_STROKE_COLOUR)
+	strokeWidth := math.Max(1, float64(width)/1000)
+	for _, element := range elements {
+		if element.ClipPathId != "" {
+			continue
+		}
+
+		subpaths, err := transformedSVGSubpaths(element, viewport)
+		if err != nil {
+			return nil, err
+		}
+
+		clip := clips[strings.TrimSuffix(strings.TrimPrefix(element.ClipPath, "url(#"), ")")]
+		if fill := mapElementFill(element, options); fill != "none" {
+			colour, err := ParseHexColour(fill)
+			if err != nil {
+				return nil, err
+			}
+			rasteriser.fill(subpaths, colour, clip)
+		}
+		rasteriser.fill(strokeSVGSubpaths(subpaths, strokeWidth), stroke, clip)
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, canvas); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// strokeSVGSubpaths outlines each line segment with a rectangle. The
+// rectangles all wind the same way so they union under the nonzero rule.
+func strokeSVGSubpaths(subpaths []svgSubpath, width float64) []svgSubpath {
+	var result []svgSubpath
+	for _, subpath := range subpaths {
+		points := subpath.points
+		if subpath.closed && len(points) > 1 {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+
+		for index := 1; index < len(points); index++ {
+			from, to := points[index-1], points[index]
+			length := math.Hypot(to.x-from.x, to.y-from.y)
+			if length == 0 {
+				continue
+			}
+
+			nx, ny := -(to.y-from.y)/length*width/2, (to.x-from.x)/length*width/2
+			result = append(result, svgSubpath{points: []svgPoint{
+				{from.x + nx, from.y + ny},
+				{to.x + nx, to.y + ny},
+				{to.x - nx, to.y - ny},
+				{from.x - nx, from.y - ny},
+			}, closed: true})
+		}
+	}
+	return result
+}
+
+type mapEdge struct {
+	x0, y0, x1, y1 float64
+	winding        int
+}
+
+type mapCrossing struct {
+	x       float64
+	winding int
+}
+
+// mapRasteriser fills polygons with the nonzero rule, sampling each pixel row
+// several times and covering partial pixels horizontally.
+type mapRasteriser struct {
+	canvas   draw.Image
+	bounds   image.Rectangle
+	coverage []float64
+}
+
+func newMapRasteriser(canvas draw.Image) *mapRasteriser {
+	return &mapRasteriser{canvas: canvas, bounds: canvas.Bounds(), coverage: make([]float64, canvas.Bounds().Dx()+1)}
+}
+
+func (r *mapRasteriser) fill(subpaths []svgSubpath, colour color.NRGBA, clip *image.Alpha) {
+	var edges []mapEdge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, subpath := range subpaths {
+		points := subpath.points
+		for index := range points {
+			from, to := points[index], points[(index+1)%len(points)]
+			minX, maxX = math.Min(minX, from.x), math.Max(maxX, from.x)
+			minY, maxY = math.Min(minY, from.y), math.Max(maxY, from.y)
+			if from.y == to.y {
+				continue
+			}
+
+			if from.y < to.y {
+				edges = append(edges, mapEdge{from.x, from.y, to.x, to.y, 1})
+			} else {
+				edges = append(edges, mapEdge{to.x, to.y, from.x, from.y, -1})
+			}
+		}
+	}
+
+	if len(edges) == 0 {
+		return
+	}
+
+	startY, endY := int(math.Max(0, math.Floor(minY))), int(math.Min(float64(r.bounds.Dy()), math.Ceil(maxY)))
+	startX, endX := int(math.Max(0, math.Floor(minX))), int(math.Min(float64(r.bounds.Dx()), math.Ceil(maxX)))
+	if startX >= endX {
+		return
+	}
+
+	var crossings []mapCrossing
+	for y := startY; y < endY; y++ {
+		for x := startX; x <= endX; x++ {
+			r.coverage[x] = 0
+		}
+
+		for sample := 0; sample < MAP_RENDER_SAMPLES; sample++ {
+			sampleY := float64(y) + (float64(sample)+0.5)/MAP_RENDER_SAMPLES
+			crossings = crossings[:0]
+			for _, edge := range edges {
+				if sampleY < edge.y0 || sampleY >= edge.y1 {
+					continue
+				}
+				x := edge.x0 + (sampleY-edge.y0)*(edge.x1-edge.x0)/(edge.y1-edge.y0)
+				crossings = append(crossings, mapCrossing{x, edge.winding})
+			}
+
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+			winding := 0
+			for index, crossing := range crossings {
+				winding += crossing.winding
+				if winding != 0 && index+1 < len(crossings) {
+					r.span(crossing.x, crossings[index+1].x, 1.0/MAP_RENDER_SAMPLES)
+				}
+			}
+		}
+
+		for x := startX; x < endX; x++ {
+			coverage := math.Min(1, r.coverage[x])
+			if clip != nil {
+				coverage = coverage * float64(clip.AlphaAt(x, y).A) / 255
+			}
+
+			if coverage > 0 {
+				r.blend(x, y, colour, coverage)
+			}
+		}
+	}
+}
+
+// span adds weight to the pixels between from and to, with the pixels at
+// either end covered in proportion.
+func (r *mapRasteriser) span(from, to, weight float64) {
+	width := float64(r.bounds.Dx())
+	from, to = math.Max(0, from), math.Min(width, to)
+	if from >= to {
+		return
+	}
+
+	first, last := int(from), int(to)
+	if first == last {
+		r.coverage[first] += (to - from) * weight
+		return
+	}
+
+	r.coverage[first] += (float64(first+1) - from) * weight
+	for x := first + 1; x < last; x++ {
+		r.coverage[x] += weight
+	}
+	r.coverage[last] += (to - float64(last)) * weight
+}
+
+func (r *mapRasteriser) blend(x, y int, colour color.NRGBA, coverage float64) {
+	alpha := float64(colour.A) / 255 * coverage
+	existing := color.NRGBAModel.Convert(r.canvas.At(x, y)).(color.NRGBA)
+	if existing.A == 0 {
+		r.canvas.Set(x, y, color.NRGBA{colour.R, colour.G, colour.B, uint8(math.Round(alpha * 255))})
+		return
+	}
+
+	under := float64(existing.A) / 255
+	out := alpha + under*(1-alpha)
+	mix := func(top, bottom uint8) uint8 {
+		return uint8(math.Round((float64(top)*alpha + float64(bottom)*under*(1-alpha)) / out))
+	}
+	r.canvas.Set(x, y, color.NRGBA{mix(colour.R, existing.R), mix(colour.G, existing.G), mix(colour.B, existing.B), uint8(math.Round(out * 255))})
+}
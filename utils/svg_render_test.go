@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseSVGPath(t *testing.T) {
+	tt := []struct {
+		name     string
+		d        string
+		subpaths int
+		closed   bool
+		last     svgPoint
+		isError  bool
+	}{
+		{name: "absolute lines", d: "M0 0 L10 0 L10 10 Z", subpaths: 1, closed: true, last: svgPoint{10, 10}},
+		{name: "relative with implicit line", d: "m1 1 5 0 0 5", subpaths: 1, last: svgPoint{6, 6}},
+		{name: "compact numbers", d: "M1.5.5h-1v2", subpaths: 1, last: svgPoint{0.5, 2.5}},
+		{name: "cubic curve", d: "M0 0C0 10 10 10 10 0", subpaths: 1, last: svgPoint{10, 0}},
+		{name: "arc with compact flags", d: "M0 0a5 5 0 104 4", subpaths: 1, last: svgPoint{4, 4}},
+		{name: "two subpaths", d: "M0 0L1 1ZM5 5L6 6Z", subpaths: 2, closed: true, last: svgPoint{6, 6}},
+		{name: "missing command", d: "0 0", isError: true},
+		{name: "unknown command", d: "M0 0 X1 1", isError: true},
+		{name: "missing number", d: "M0 0 L1", isError: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			subpaths, err := parseSVGPath(tc.d)
+			if tc.isError {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error; got %v", err)
+			}
+
+			if len(subpaths) != tc.subpaths {
+				t.Fatalf("expected %v subpaths; got %v", tc.subpaths, len(subpaths))
+			}
+
+			subpath := subpaths[len(subpaths)-1]
+			if subpath.closed != tc.closed {
+				t.Errorf("expected closed %v; got %v", tc.closed, subpath.closed)
+			}
+
+			last := subpath.points[len(subpath.points)-1]
+			if math.Abs(last.x-tc.last.x) > 1e-9 || math.Abs(last.y-tc.last.y) > 1e-9 {
+				t.Errorf("expected last point %v; got %v", tc.last, last)
+			}
+		})
+	}
+}
+
+func TestElementBounds(t *testing.T) {
+	tt := []struct {
+		name     string
+		elements []SVGElement
+		expected SVGBounds
+		found    bool
+	}{
+		{
+			name:     "rect",
+			elements: []SVGElement{{Type: SVG_ELEMENT_RECT, X: "5", Y: "5", Width: "10", Height: "20"}},
+			expected: SVGBounds{5, 5, 15, 25},
+			found:    true,
+		},
+		{
+			name:     "transformed group",
+			elements: []SVGElement{{Type: SVG_ELEMENT_POLYGON, Points: "0,0 10,0 10,10", Transform: "translate(100 50) scale(2)"}},
+			expected: SVGBounds{100, 50, 120, 70},
+			found:    true,
+		},
+		{
+			name: "union of elements",
+			elements: []SVGElement{
+				{Type: SVG_ELEMENT_CIRCLE, Cx: "0", Cy: "0", R: "5"},
+				{Type: SVG_ELEMENT_LINE, X1: "10", Y1: "10", X2: "20", Y2: "30"},
+			},
+			expected: SVGBounds{-5, -5, 20, 30},
+			found:    true,
+		},
+		{
+			name: "nothing drawn",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			bounds, found, err := ElementBounds(tc.elements)
+			if err != nil {
+				t.Fatalf("expected nil error; got %v", err)
+			}
+
+			if found != tc.found {
+				t.Fatalf("expected found %v; got %v", tc.found, found)
+			}
+
+			if found && bounds != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, bounds)
+			}
+		})
+	}
+}
+
+var testMapElements = []SVGElement{
+	{Type: SVG_ELEMENT_RECT, ID: "nz", Name: "New Zealand", X: "0", Y: "0", Width: "10", Height: "10"},
+	{Type: SVG_ELEMENT_PATH, ID: "au", Name: "Australia", D: "M20 0h10v10h-10z"},
+	{Type: SVG_ELEMENT_CIRCLE, ID: "fj", Name: "Fiji & Tonga", Cx: "45", Cy: "5", R: "5"},
+}
+
+func TestRenderMapSVG(t *testing.T) {
+	tt := []struct {
+		name     string
+		options  MapRenderOptions
+		contains []string
+		isError  bool
+	}{
+		{
+			name:     "whole map",
+			options:  MapRenderOptions{Title: "Oceania"},
+			contains: []string{`viewBox="0 0 50 10"`, "<title>Oceania</title>", `<rect id="nz"`, "<title>Fiji &amp; Tonga</title>"},
+		},
+		{
+			name:     "highlight by id or name",
+			options:  MapRenderOptions{Highlight: map[string]bool{"nz": true, "australia": true}},
+			contains: []string{`d="M20 0h10v10h-10z" fill="#e24f4f"`, `height="10" fill="#e24f4f"`},
+		},
+		{
+			name:     "group fills",
+			options:  MapRenderOptions{Fills: map[string]string{"fj": "#4e8fd6"}},
+			contains: []string{`r="5" fill="#4e8fd6"`},
+		},
+		{
+			name:     "crop",
+			options:  MapRenderOptions{Crop: map[string]bool{"au": true}},
+			contains: []string{`viewBox="19.5 -0.5 11 11"`},
+		},
+		{
+			name:    "crop to unknown element",
+			options: MapRenderOptions{Crop: map[string]bool{"xx": true}},
+			isError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := RenderMapSVG("0 0 50 10", testMapElements, tc.options)
+			if tc.isError {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error; got %v", err)
+			}
+
+			if _, err := ParseSVG(bytes.NewReader(result)); err != nil {
+				t.Fatalf("expected output to parse; got %v", err)
+			}
+
+			for _, expected := range tc.contains {
+				if !strings.Contains(string(result), expected) {
+					t.Errorf("expected output to contain %s; got %s", expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMapPNG(t *testing.T) {
+	options := MapRenderOptions{Highlight: map[string]bool{"au": true}, Background: "#000000"}
+	result, err := RenderMapPNG("0 0 50 10", testMapElements, options, 100, 0)
+	if err != nil {
+		t.Fatalf("expected nil error; got %v", err)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(result))
+	if err != nil {
+		t.Fatalf("could not decode png: %v", err)
+	}
+
+	if size := decoded.Bounds().Size(); size.X != 100 || size.Y != 20 {
+		t.Fatalf("expected 100x20; got %vx%v", size.X, size.Y)
+	}
+
+	fill, _ := ParseHexColour(MAP_FILL_COLOUR)
+	highlight, _ := ParseHexColour(MAP_HIGHLIGHT_COLOUR)
+	tt := []struct {
+		name     string
+		x        int
+		y        int
+		expected color.NRGBA
+	}{
+		{name: "filled", x: 10, y: 10, expected: fill},
+		{name: "highlighted", x: 50, y: 10, expected: highlight},
+		{name: "background", x: 70, y: 10, expected: color.NRGBA{A: 255}},
+		{name: "circle", x: 90, y: 10, expected: fill},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := color.NRGBAModel.Convert(decoded.At(tc.x, tc.y)).(color.NRGBA)
+			if got != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseHexColour(t *testing.T) {
+	tt := []struct {
+		value    string
+		expected color.NRGBA
+		isError  bool
+	}{
+		{value: "#6dca94", expected: color.NRGBA{0x6d, 0xca, 0x94, 255}},
+		{value: "fff", expected: color.NRGBA{255, 255, 255, 255}},
+		{value: "#ggg", isError: true},
+		{value: "#12345", isError: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			result, err := ParseHexColour(tc.value)
+			if tc.isError {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
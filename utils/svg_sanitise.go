@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Elements used to draw flags and maps. Anything else, e.g. scripts, foreign
+// objects, animations or editor metadata, is dropped along with everything
+// inside it.
+var allowedSVGElements = map[string]bool{
+	"svg":            true,
+	"g":              true,
+	"defs":           true,
+	"symbol":         true,
+	"use":            true,
+	"title":          true,
+	"desc":           true,
+	"style":          true,
+	"path":           true,
+	"rect":           true,
+	"circle":         true,
+	"ellipse":        true,
+	"line":           true,
+	"polyline":       true,
+	"polygon":        true,
+	"text":           true,
+	"tspan":          true,
+	"image":          true,
+	"lineargradient": true,
+	"radialgradient": true,
+	"stop":           true,
+	"pattern":        true,
+	"clippath":       true,
+	"mask":           true,
+	"marker":         true,
+	"filter":         true,
+	"feblend":        true,
+	"fecolormatrix":  true,
+	"fecomposite":    true,
+	"feflood":        true,
+	"fegaussianblur": true,
+	"femerge":        true,
+	"femergenode":    true,
+	"femorphology":   true,
+	"feoffset":       true,
+}
+
+// Attributes that describe how something looks, keyed by their local name.
+// Namespaced attributes other than xlink:href and xml:space are dropped.
+var allowedSVGAttributes = map[string]bool{
+	"id":                  true,
+	"class":               true,
+	"style":               true,
+	"version":             true,
+	"viewbox":             true,
+	"preserveaspectratio": true,
+	"width":               true,
+	"height":              true,
+	"x":                   true,
+	"y":                   true,
+	"x1":                  true,
+	"y1":                  true,
+	"x2":                  true,
+	"y2":                  true,
+	"cx":                  true,
+	"cy":                  true,
+	"r":                   true,
+	"rx":                  true,
+	"ry":                  true,
+	"fx":                  true,
+	"fy":                  true,
+	"dx":                  true,
+	"dy":                  true,
+	"d":                   true,
+	"points":              true,
+	"transform":           true,
+	"href":                true,
+	"fill":                true,
+	"fill-opacity":        true,
+	"fill-rule":           true,
+	"stroke":              true,
+	"stroke-width":        true,
+	"stroke-opacity":      true,
+	"stroke-linecap":      true,
+	"stroke-linejoin":     true,
+	"stroke-miterlimit":   true,
+	"stroke-dasharray":    true,
+	"stroke-dashoffset":   true,
+	"opacity":             true,
+	"color":               true,
+	"display":             true,
+	"visibility":          true,
+	"overflow":            true,
+	"clip-path":           true,
+	"clip-rule":           true,
+	"clippathunits":       true,
+	"mask":                true,
+	"maskunits":           true,
+	"maskcontentunits":    true,
+	"gradientunits":       true,
+	"gradienttransform":   true,
+	"spreadmethod":        true,
+	"offset":              true,
+	"stop-color":          true,
+	"stop-opacity":        true,
+	"patternunits":        true,
+	"patterncontentunits": true,
+	"patterntransform":    true,
+	"marker-start":        true,
+	"marker-mid":          true,
+	"marker-end":          true,
+	"markerwidth":         true,
+	"markerheight":        true,
+	"markerunits":         true,
+	"refx":                true,
+	"refy":                true,
+	"orient":              true,
+	"filter":              true,
+	"filterunits":         true,
+	"primitiveunits":      true,
+	"in":                  true,
+	"in2":                 true,
+	"result":              true,
+	"stddeviation":        true,
+	"mode":                true,
+	"operator":            true,
+	"type":                true,
+	"values":              true,
+	"k1":                  true,
+	"k2":                  true,
+	"k3":                  true,
+	"k4":                  true,
+	"radius":              true,
+	"flood-color":         true,
+	"flood-opacity":       true,
+	"font-family":         true,
+	"font-size":           true,
+	"font-weight":         true,
+	"font-style":          true,
+	"text-anchor":         true,
+	"dominant-baseline":   true,
+	"letter-spacing":      true,
+	"shape-rendering":     true,
+	"vector-effect":       true,
+	"space":               true,
+}
+
+// Inline images are allowed in hrefs, other documents are not.
+var allowedSVGDataURIs = []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"}
+
+var cssURL = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")]*)`)
+
+// SanitiseSVG rewrites an SVG with only the elements and attributes needed to
+// draw it, and without references to anything outside the document, so it's
+// safe to serve from our own origin or a bucket without a content security
+// policy. Doctypes are dropped too, so entities can't be used to pull in files.
+func SanitiseSVG(reader io.Reader) ([]byte, error) {
+	decoder := xml.NewDecoder(reader)
+	var buffer bytes.Buffer
+	var open []string
+	var css bytes.Buffer
+	skip := 0
+	style := 0
+	root := false
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(token.Name.Local)
+			if !root {
+				if name != "svg" || token.Name.Space != "" {
+					return nil, errors.New("document is not an svg")
+				}
+				root = true
+			} else if len(open) == 0 && skip == 0 {
+				return nil, errors.New("svg has more than one root element")
+			}
+
+			// Elements inside a style are dropped but their text is still
+			// part of the stylesheet, so it's checked as a whole.
+			if style > 0 {
+				style++
+				continue
+			}
+
+			if skip > 0 || token.Name.Space != "" || !allowedSVGElements[name] {
+				skip++
+				continue
+			}
+
+			if name == "style" {
+				style = 1
+				css.Reset()
+			}
+
+			open = append(open, token.Name.Local)
+			buffer.WriteString("<" + token.Name.Local)
+			for _, attr := range token.Attr {
+				if !safeSVGAttribute(attr) {
+					continue
+				}
+				buffer.WriteString(" " + rawSVGName(attr.Name) + `="`)
+				xml.EscapeText(&buffer, []byte(attr.Value))
+				buffer.WriteString(`"`)
+			}
+			buffer.WriteString(">")
+		case xml.EndElement:
+			if style > 1 {
+				style--
+				continue
+			}
+
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			if len(open) == 0 || open[len(open)-1] != rawSVGName(token.Name) {
+				return nil, errors.New("svg has mismatched tags")
+			}
+
+			if style == 1 {
+				if safeCSS(css.String()) {
+					xml.EscapeText(&buffer, css.Bytes())
+				}
+				style = 0
+			}
+			buffer.WriteString("</" + open[len(open)-1] + ">")
+			open = open[:len(open)-1]
+		case xml.CharData:
+			if style > 0 {
+				css.Write(token)
+				continue
+			}
+
+			if skip > 0 || len(open) == 0 {
+				continue
+			}
+			xml.EscapeText(&buffer, token)
+		}
+	}
+
+	if !root {
+		return nil, errors.New("document is not an svg")
+	}
+
+	if len(open) > 0 {
+		return nil, errors.New("svg has unclosed tags")
+	}
+	return buffer.Bytes(), nil
+}
+
+func rawSVGName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// safeSVGAttribute keeps allowed attributes as long as links are to the
+// document itself or an inline image and styles don't load anything. The
+// xmlns and xmlns:xlink declarations are kept for the attributes that use them.
+func safeSVGAttribute(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	switch attr.Name.Space {
+	case "":
+		if name == "xmlns" {
+			return attr.Value == "http://www.w3.org/2000/svg"
+		}
+	case "xmlns":
+		return name == "xlink" && attr.Value == "http://www.w3.org/1999/xlink"
+	case "xlink":
+		if name != "href" {
+			return false
+		}
+	case "xml":
+		if name != "space" {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if !allowedSVGAttributes[name] {
+		return false
+	}
+
+	value := strings.ToLower(strings.Join(strings.Fields(attr.Value), ""))
+	if name == "href" {
+		return safeSVGReference(value)
+	}
+	return safeCSS(value)
+}
+
+func safeSVGReference(value string) bool {
+	if value == "" || strings.HasPrefix(value, "#") {
+		return true
+	}
+
+	for _, prefix := range allowedSVGDataURIs {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeCSS rejects styles that could load anything other than a reference to
+// the document. Escapes are rejected outright, as they can spell out any
+// function or at-rule without it being seen here.
+func safeCSS(value string) bool {
+	lower := strings.ToLower(value)
+	for _, blocked := range []string{`\`, "@import", "image-set", "image(", "expression(", "javascript:", "-moz-binding", "behavior:"} {
+		if strings.Contains(lower, blocked) {
+			return false
+		}
+	}
+
+	for _, match := range cssURL.FindAllStringSubmatch(value, -1) {
+		if !safeSVGReference(strings.ToLower(strings.TrimSpace(match[1]))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitiseSVG(t *testing.T) {
+	tt := []struct {
+		name     string
+		svg      string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "clean svg",
+			svg:      `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 3 2"><rect width="3" height="2" fill="#fff"/></svg>`,
+			expected: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 3 2"><rect width="3" height="2" fill="#fff"></rect></svg>`,
+		},
+		{
+			name:     "scripts and handlers",
+			svg:      `<svg onload="alert(1)"><script>alert(1)</script><rect onclick="alert(1)" width="1"/><foreignObject><div>hi</div></foreignObject></svg>`,
+			expected: `<svg><rect width="1"></rect></svg>`,
+		},
+		{
+			name:     "external references",
+			svg:      `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#star"/><use href="https://example.com/a.svg#x"/><image href="data:image/png;base64,AAAA"/><image xlink:href="data:text/html;base64,AAAA"/><a href="javascript:alert(1)"><rect/></a></svg>`,
+			expected: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#star"></use><use></use><image href="data:image/png;base64,AAAA"></image><image></image></svg>`,
+		},
+		{
+			name:     "styles",
+			svg:      `<svg><style>@import url(https://example.com/a.css);</style><style>.a{fill:url(#g)}</style><rect style="fill:url(https://example.com/x)"/><rect fill="url(#g)"/></svg>`,
+			expected: `<svg><style></style><style>.a{fill:url(#g)}</style><rect></rect><rect fill="url(#g)"></rect></svg>`,
+		},
+		{
+			name:     "style with child element",
+			svg:      `<svg><style><a/>@import url(https://evil/x.css)</style><style>@imp<a/>ort "https://evil/x.css"</style><style>.a{<b>fill</b>:red}</style></svg>`,
+			expected: `<svg><style></style><style></style><style>.a{fill:red}</style></svg>`,
+		},
+		{
+			name:     "escaped css",
+			svg:      `<svg><style>@\69mport "https://evil/x.css";</style><rect style="fill:u\72l(https://evil/p)"/></svg>`,
+			expected: `<svg><style></style><rect></rect></svg>`,
+		},
+		{
+			name:     "image set",
+			svg:      `<svg><style>.a{fill:image-set("https://evil/i.png" 1x)}</style><rect style="fill:-webkit-image-set('https://evil/i.png' 1x)"/></svg>`,
+			expected: `<svg><style></style><rect></rect></svg>`,
+		},
+		{
+			name:     "elements and attributes not allowed",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" inkscape:version="1.0"><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="href" values="javascript:alert(1)"/><rect/></a><metadata><rdf/></metadata><inkscape:grid/><feImage href="#a"/><g data-x="1" fill="red"><path d="M0 0h1"/></g></svg>`,
+			expected: `<svg xmlns="http://www.w3.org/2000/svg"><g fill="red"><path d="M0 0h1"></path></g></svg>`,
+		},
+		{
+			name: "entities",
+			svg:  `<!DOCTYPE svg [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><svg><text>&xxe;</text></svg>`,
+			err:  true,
+		},
+		{
+			name: "not an svg",
+			svg:  `<html><script>alert(1)</script></html>`,
+			err:  true,
+		},
+		{
+			name: "unclosed",
+			svg:  `<svg><rect>`,
+			err:  true,
+		},
+		{
+			name: "empty",
+			svg:  "",
+			err:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := SanitiseSVG(strings.NewReader(tc.svg))
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error; got %s", result)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error; got %v", err)
+			}
+
+			if string(result) != tc.expected {
+				t.Errorf("expected %s; got %s", tc.expected, result)
+			}
+		})
+	}
+}
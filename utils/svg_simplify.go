@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Map detail levels. Full is the geometry as imported, the others are
+// simplified copies stored alongside it.
+const (
+	MAP_DETAIL_FULL   = "full"
+	MAP_DETAIL_MEDIUM = "medium"
+	MAP_DETAIL_LOW    = "low"
+)
+
+// MapDetailTolerances are how far simplified geometry may stray from the
+// original, as a share of the viewBox diagonal.
+var MapDetailTolerances = map[string]float64{
+	MAP_DETAIL_MEDIUM: 0.0005,
+	MAP_DETAIL_LOW:    0.002,
+}
+
+// SimplifiedMapDetails are the levels stored for each element, in order.
+var SimplifiedMapDetails = []string{MAP_DETAIL_MEDIUM, MAP_DETAIL_LOW}
+
+func IsMapDetail(detail string) bool {
+	_, found := MapDetailTolerances[detail]
+	return found || detail == MAP_DETAIL_FULL
+}
+
+// SimplifySVGElement reduces an element's path or points to the given detail
+// level. Curves are flattened, points closer than the tolerance to the line
+// between their neighbours are dropped (Douglas-Peucker), coordinates are
+// rounded to the tolerance and paths are written with relative commands.
+// Rings smaller than the tolerance are dropped unless it would leave the
+// element empty. Other element types are returned as they are.
+func SimplifySVGElement(element SVGElement, viewBox, detail string) (SVGElement, error) {
+	if detail == MAP_DETAIL_FULL {
+		return element, nil
+	}
+
+	fraction, found := MapDetailTolerances[detail]
+	if !found {
+		return element, fmt.Errorf("invalid detail %s", detail)
+	}
+
+	switch element.Type {
+	case SVG_ELEMENT_PATH, SVG_ELEMENT_POLYGON, SVG_ELEMENT_POLYLINE:
+	default:
+		return element, nil
+	}
+
+	values, err := parseSVGViewBox(viewBox)
+	if err != nil {
+		return element, err
+	}
+	tolerance := math.Hypot(values[2], values[3]) * fraction
+
+	// The tolerance is in the viewBox's units, scale it into the element's
+	// own so transformed elements are simplified by the same amount.
+	transform, err := parseSVGTransform(element.Transform)
+	if err != nil {
+		return element, err
+	}
+
+	if scale := math.Sqrt(math.Abs(transform[0]*transform[3] - transform[1]*transform[2])); scale > 0 {
+		tolerance = tolerance / scale
+	}
+
+	subpaths, err := svgSubpaths(element)
+	if err != nil {
+		return element, err
+	}
+
+	simplified := simplifySVGSubpaths(subpaths, tolerance)
+	decimals := int(math.Max(0, math.Ceil(-math.Log10(tolerance/2))))
+	if element.Type == SVG_ELEMENT_PATH {
+		element.D = formatSVGPath(simplified, decimals)
+	} else if len(simplified) > 0 {
+		element.Points = formatSVGPoints(simplified[0].points, decimals)
+	}
+	return element, nil
+}
+
+func simplifySVGSubpaths(subpaths []svgSubpath, tolerance float64) []svgSubpath {
+	var result []svgSubpath
+	largest, largestSize := -1, -1.0
+	for index, subpath := range subpaths {
+		var points []svgPoint
+		if subpath.closed {
+			points = simplifySVGRing(subpath.points, tolerance)
+		} else {
+			points = douglasPeucker(subpath.points, tolerance)
+		}
+
+		bounds := SVGBounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		for _, point := range subpath.points {
+			bounds.MinX, bounds.MaxX = math.Min(bounds.MinX, point.x), math.Max(bounds.MaxX, point.x)
+			bounds.MinY, bounds.MaxY = math.Min(bounds.MinY, point.y), math.Max(bounds.MaxY, point.y)
+		}
+
+		size := math.Max(bounds.MaxX-bounds.MinX, bounds.MaxY-bounds.MinY)
+		if size > largestSize {
+			largest, largestSize = index, size
+		}
+
+		if size < tolerance || (subpath.closed && len(points) < 3) {
+			continue
+		}
+		result = append(result, svgSubpath{points: points, closed: subpath.closed})
+	}
+
+	if len(result) == 0 && largest >= 0 {
+		result = append(result, svgSubpath{points: subpaths[largest].points, closed: subpaths[largest].closed})
+	}
+	return result
+}
+
+// simplifySVGRing splits a closed ring at the point furthest from its start so
+// neither half is a loop, which Douglas-Peucker can't simplify.
+func simplifySVGRing(points []svgPoint, tolerance float64) []svgPoint {
+	if len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+
+	if len(points) < 4 {
+		return points
+	}
+
+	furthest, distance := 0, -1.0
+	for index, point := range points {
+		if d := math.Hypot(point.x-points[0].x, point.y-points[0].y); d > distance {
+			furthest, distance = index, d
+		}
+	}
+
+	first := douglasPeucker(points[:furthest+1], tolerance)
+	second := douglasPeucker(append(points[furthest:len(points):len(points)], points[0]), tolerance)
+	return append(first, second[1:len(second)-1]...)
+}
+
+func douglasPeucker(points []svgPoint, tolerance float64) []svgPoint {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		furthest, distance := -1, tolerance
+		for index := span[0] + 1; index < span[1]; index++ {
+			if d := segmentDistance(points[index], points[span[0]], points[span[1]]); d > distance {
+				furthest, distance = index, d
+			}
+		}
+
+		if furthest >= 0 {
+			keep[furthest] = true
+			stack = append(stack, [2]int{span[0], furthest}, [2]int{furthest, span[1]})
+		}
+	}
+
+	var result []svgPoint
+	for index, point := range points {
+		if keep[index] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+func segmentDistance(point, from, to svgPoint) float64 {
+	dx, dy := to.x-from.x, to.y-from.y
+	if dx == 0 && dy == 0 {
+		return math.Hypot(point.x-from.x, point.y-from.y)
+	}
+
+	t := math.Max(0, math.Min(1, ((point.x-from.x)*dx+(point.y-from.y)*dy)/(dx*dx+dy*dy)))
+	return math.Hypot(point.x-(from.x+t*dx), point.y-(from.y+t*dy))
+}
+
+// svgNumberWriter joins numbers with as few separators as can still be read
+// back, e.g. "1-2.5.5".
+type svgNumberWriter struct {
+	builder  strings.Builder
+	decimals int
+	previous string
+}
+
+func (w *svgNumberWriter) command(command byte) {
+	w.builder.WriteByte(command)
+	w.previous = ""
+}
+
+func (w *svgNumberWriter) number(value float64) {
+	text := strconv.FormatFloat(value, 'f', -1, 64)
+	if text == "-0" {
+		text = "0"
+	}
+
+	if strings.HasPrefix(text, "0.") {
+		text = text[1:]
+	} else if strings.HasPrefix(text, "-0.") {
+		text = "-" + text[2:]
+	}
+
+	separated := strings.HasPrefix(text, "-") || (strings.HasPrefix(text, ".") && strings.Contains(w.previous, "."))
+	if w.previous != "" && !separated {
+		w.builder.WriteByte(' ')
+	}
+	w.builder.WriteString(text)
+	w.previous = text
+}
+
+func (w *svgNumberWriter) round(value float64) float64 {
+	scale := math.Pow(10, float64(w.decimals))
+	return math.Round(value*scale) / scale
+}
+
+// formatSVGPath writes subpaths with relative commands. Deltas are taken
+// between rounded points so rounding errors don't add up along the path.
+func formatSVGPath(subpaths []svgSubpath, decimals int) string {
+	writer := svgNumberWriter{decimals: decimals}
+	var position svgPoint
+	for index, subpath := range subpaths {
+		start := svgPoint{writer.round(subpath.points[0].x), writer.round(subpath.points[0].y)}
+		if index == 0 {
+			writer.command('M')
+			writer.number(start.x)
+			writer.number(start.y)
+		} else {
+			writer.command('m')
+			writer.number(writer.round(start.x - position.x))
+			writer.number(writer.round(start.y - position.y))
+		}
+
+		position = start
+		drawing := false
+		for _, point := range subpath.points[1:] {
+			rounded := svgPoint{writer.round(point.x), writer.round(point.y)}
+			if rounded == position {
+				continue
+			}
+
+			if !drawing {
+				writer.command('l')
+				drawing = true
+			}
+			writer.number(writer.round(rounded.x - position.x))
+			writer.number(writer.round(rounded.y - position.y))
+			position = rounded
+		}
+
+		if subpath.closed {
+			writer.command('z')
+			position = start
+		}
+	}
+	return writer.builder.String()
+}
+
+func formatSVGPoints(points []svgPoint, decimals int) string {
+	writer := svgNumberWriter{decimals: decimals}
+	values := make([]string, 0, len(points))
+	for _, point := range points {
+		values = append(values, fmt.Sprintf("%s,%s", strconv.FormatFloat(writer.round(point.x), 'f', -1, 64), strconv.FormatFloat(writer.round(point.y), 'f', -1, 64)))
+	}
+	return strings.Join(values, " ")
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSimplifySVGElement(t *testing.T) {
+	var circle strings.Builder
+	for step := 0; step < 360; step++ {
+		theta := float64(step) * math.Pi / 180
+		command := "L"
+		if step == 0 {
+			command = "M"
+		}
+		fmt.Fprintf(&circle, "%s%.6f %.6f", command, 500+200*math.Cos(theta), 500+200*math.Sin(theta))
+	}
+	circle.WriteString("Z")
+
+	tt := []struct {
+		name      string
+		element   SVGElement
+		detail    string
+		maxPoints int
+		expected  string
+		isError   bool
+	}{
+		{
+			name:    "invalid detail",
+			element: SVGElement{Type: SVG_ELEMENT_PATH, D: "M0 0L1 1"},
+			detail:  "extreme",
+			isError: true,
+		},
+		{
+			name:     "full detail is untouched",
+			element:  SVGElement{Type: SVG_ELEMENT_PATH, D: "M0.123456 0L1 1"},
+			detail:   MAP_DETAIL_FULL,
+			expected: "M0.123456 0L1 1",
+		},
+		{
+			name:     "circles are untouched",
+			element:  SVGElement{Type: SVG_ELEMENT_CIRCLE, Cx: "1", Cy: "1", R: "1"},
+			detail:   MAP_DETAIL_LOW,
+			expected: "",
+		},
+		{
+			name:     "collinear points dropped and relative commands",
+			element:  SVGElement{Type: SVG_ELEMENT_PATH, D: "M0 0L100 0L200 0L200 100L0 100Z"},
+			detail:   MAP_DETAIL_MEDIUM,
+			expected: "M0 0l200 0 0 100-200 0z",
+		},
+		{
+			name:     "tiny islands dropped",
+			element:  SVGElement{Type: SVG_ELEMENT_PATH, D: "M0 0H200V100H0ZM500 500h0.1v0.1h-0.1z"},
+			detail:   MAP_DETAIL_LOW,
+			expected: "M0 0l200 0 0 100-200 0z",
+		},
+		{
+			name:      "medium circle",
+			element:   SVGElement{Type: SVG_ELEMENT_PATH, D: circle.String()},
+			detail:    MAP_DETAIL_MEDIUM,
+			maxPoints: 90,
+		},
+		{
+			name:      "low circle",
+			element:   SVGElement{Type: SVG_ELEMENT_PATH, D: circle.String()},
+			detail:    MAP_DETAIL_LOW,
+			maxPoints: 45,
+		},
+		{
+			name:     "polygon points",
+			element:  SVGElement{Type: SVG_ELEMENT_POLYGON, Points: "0,0 50.0001,0 100,0 100,100"},
+			detail:   MAP_DETAIL_LOW,
+			expected: "0,0 100,0 100,100",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := SimplifySVGElement(tc.element, "0 0 1000 1000", tc.detail)
+			if tc.isError {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected nil error; got %v", err)
+			}
+
+			geometry := result.D
+			if tc.element.Type == SVG_ELEMENT_POLYGON {
+				geometry = result.Points
+			}
+
+			if tc.maxPoints == 0 {
+				if geometry != tc.expected {
+					t.Errorf("expected %v; got %v", tc.expected, geometry)
+				}
+				return
+			}
+
+			subpaths, err := svgSubpaths(result)
+			if err != nil {
+				t.Fatalf("expected simplified path to parse; got %v", err)
+			}
+
+			if points := len(subpaths[0].points); points > tc.maxPoints || points < 8 {
+				t.Errorf("expected between 8 and %v points; got %v", tc.maxPoints, points)
+			}
+
+			originalBounds, _, _ := ElementBounds([]SVGElement{tc.element})
+			bounds, _, _ := ElementBounds([]SVGElement{result})
+			tolerance := math.Hypot(1000, 1000) * MapDetailTolerances[tc.detail]
+			if math.Abs(bounds.MinX-originalBounds.MinX) > tolerance || math.Abs(bounds.MaxY-originalBounds.MaxY) > tolerance {
+				t.Errorf("expected bounds within %v of %v; got %v", tolerance, originalBounds, bounds)
+			}
+
+			if len(result.D) >= len(tc.element.D)/4 {
+				t.Errorf("expected path to shrink; got %v of %v bytes", len(result.D), len(tc.element.D))
+			}
+		})
+	}
+}
+
+func TestFormatSVGPath(t *testing.T) {
+	subpaths := []svgSubpath{
+		{points: []svgPoint{{0.5, 0.25}, {1, 0.75}, {1, 0.75}, {0.25, 0.5}}, closed: true},
+		{points: []svgPoint{{2, 2}, {3, 2.5}}},
+	}
+
+	expected := "M.5.25l.5.5-.75-.25zm1.5 1.75l1 .5"
+	if result := formatSVGPath(subpaths, 2); result != expected {
+		t.Errorf("expected %v; got %v", expected, result)
+	}
+
+	parsed, err := parseSVGPath(expected)
+	if err != nil {
+		t.Fatalf("expected formatted path to parse; got %v", err)
+	}
+
+	if last := parsed[1].points[1]; last != (svgPoint{3, 2.5}) {
+		t.Errorf("expected last point {3 2.5}; got %v", last)
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSVG(t *testing.T) {
+	tt := []struct {
+		name     string
+		svg      string
+		err      bool
+		viewBox  string
+		elements []SVGElement
+		warnings []string
+	}{
+		{
+			name: "not an svg",
+			svg:  `<html><body></body></html>`,
+			err:  true,
+		},
+		{
+			name: "malformed xml",
+			svg:  `<svg viewBox="0 0 10 10"><path d="M0 0"</svg>`,
+			err:  true,
+		},
+		{
+			name:    "minified with id inside another attribute",
+			svg:     `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 50"><path data-note="id=fake" id="nz" title="New Zealand" d="M1 1L2 2z"/><polygon id="au" title="Australia" points="0,0 1,1 1,0"/></svg>`,
+			viewBox: "0 0 100 50",
+			elements: []SVGElement{
+				{Type: "path", ID: "nz", Name: "New Zealand", D: "M1 1L2 2z"},
+				{Type: "polygon", ID: "au", Name: "Australia", Points: "0,0 1,1 1,0"},
+			},
+		},
+		{
+			name: "multi-line attributes and title elements",
+			svg: `<svg width="200px" height="100">
+				<circle
+					id="fj"
+					cx="10" cy="20"
+					r="3">
+					<title>Fiji</title>
+				</circle>
+				<line id="dateline" x1="5" y1="0" x2="5" y2="100"/>
+			</svg>`,
+			viewBox: "0 0 200 100",
+			elements: []SVGElement{
+				{Type: "circle", ID: "fj", Name: "Fiji", Cx: "10", Cy: "20", R: "3"},
+				{Type: "line", ID: "dateline", X1: "5", Y1: "0", X2: "5", Y2: "100"},
+			},
+		},
+		{
+			name: "nested groups keep transforms and ids",
+			svg: `<svg viewBox="0,0,10,10" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape">
+				<g transform="translate(1 2)" inkscape:label="Layer 1">
+					<g id="nz" transform="scale(2)">
+						<title>New Zealand</title>
+						<path d="M0 0h1v1z"/>
+						<rect id="chatham" x="1" y="1" width="2" height="3" transform="rotate(45)"/>
+					</g>
+					<polyline points="0,0 1,1"/>
+				</g>
+			</svg>`,
+			viewBox: "0 0 10 10",
+			elements: []SVGElement{
+				{Type: "path", ID: "nz", Name: "New Zealand", D: "M0 0h1v1z", Transform: "translate(1 2) scale(2)"},
+				{Type: "rect", ID: "chatham", X: "1", Y: "1", Width: "2", Height: "3", Transform: "translate(1 2) scale(2) rotate(45)"},
+				{Type: "polyline", Points: "0,0 1,1", Transform: "translate(1 2)"},
+			},
+		},
+		{
+			name: "use resolves defs",
+			svg: `<svg viewBox="0 0 10 10" xmlns:xlink="http://www.w3.org/1999/xlink">
+				<defs>
+					<circle id="dot" r="1"/>
+					<clipPath id="clip"><rect width="5" height="5"/></clipPath>
+				</defs>
+				<use id="sg" title="Singapore" xlink:href="#dot" x="3" y="4"/>
+				<use href="#missing"/>
+				<path id="tv" d="M0 0" clip-path="url(#clip)"/>
+			</svg>`,
+			viewBox: "0 0 10 10",
+			elements: []SVGElement{
+				{Type: "rect", Width: "5", Height: "5", ClipPathId: "clip"},
+				{Type: "path", ID: "tv", D: "M0 0", ClipPath: "url(#clip)"},
+				{Type: "circle", ID: "sg", Name: "Singapore", R: "1", Transform: "translate(3 4)", XlinkHref: "#dot"},
+			},
+			warnings: []string{"use references unknown element missing"},
+		},
+		{
+			name: "unsupported and broken elements are warned about",
+			svg: `<svg viewBox="0 0 10 10">
+				<ellipse id="e" rx="1" ry="2"/>
+				<text>Label</text>
+				<path id="empty"/>
+				<path id="hidden" d="M0 0" display="none"/>
+			</svg>`,
+			viewBox:  "0 0 10 10",
+			elements: []SVGElement{},
+			warnings: []string{"unsupported element ellipse skipped", "unsupported element text skipped", "path skipped, missing d", "hidden element skipped"},
+		},
+		{
+			name:     "no size",
+			svg:      `<svg width="100%"></svg>`,
+			viewBox:  "",
+			elements: []SVGElement{},
+			warnings: []string{"svg has no viewBox or absolute width and height"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			document, err := ParseSVG(strings.NewReader(tc.svg))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error; got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if document.ViewBox != tc.viewBox {
+				t.Errorf("expected viewBox %v; got %v", tc.viewBox, document.ViewBox)
+			}
+
+			if len(document.Elements) != len(tc.elements) {
+				t.Fatalf("expected %v elements; got %+v", len(tc.elements), document.Elements)
+			}
+
+			for index, element := range tc.elements {
+				if document.Elements[index] != element {
+					t.Errorf("expected element %+v; got %+v", element, document.Elements[index])
+				}
+			}
+
+			if len(document.Warnings) != len(tc.warnings) {
+				t.Fatalf("expected %v warnings; got %+v", len(tc.warnings), document.Warnings)
+			}
+
+			for index, warning := range tc.warnings {
+				if document.Warnings[index].Message != warning {
+					t.Errorf("expected warning %v; got %v", warning, document.Warnings[index].Message)
+				}
+			}
+		})
+	}
+}
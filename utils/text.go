@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormaliseText decomposes the text (NFKD), strips diacritics and punctuation,
+// lowercases it and collapses whitespace so that values can be compared loosely.
+func NormaliseText(text string) string {
+	var builder strings.Builder
+	space := false
+	for _, value := range norm.NFKD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, value):
+			continue
+		case unicode.IsLetter(value) || unicode.IsNumber(value):
+			if space && builder.Len() > 0 {
+				builder.WriteRune(' ')
+			}
+			builder.WriteRune(unicode.ToLower(value))
+			space = false
+		default:
+			space = true
+		}
+	}
+	return builder.String()
+}
+
+// LevenshteinDistance returns the number of single rune edits needed to turn a into b.
+func LevenshteinDistance(a, b string) int {
+	source := []rune(a)
+	target := []rune(b)
+	if len(source) == 0 {
+		return len(target)
+	}
+	if len(target) == 0 {
+		return len(source)
+	}
+
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+// TextSimilarity compares the normalised forms of a and b and returns a score
+// between 0 (nothing in common) and 1 (identical).
+func TextSimilarity(a, b string) float64 {
+	first := NormaliseText(a)
+	second := NormaliseText(b)
+	if first == second {
+		return 1
+	}
+
+	length := len([]rune(first))
+	if other := len([]rune(second)); other > length {
+		length = other
+	}
+	return 1 - float64(LevenshteinDistance(first, second))/float64(length)
+}
+
+func min(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
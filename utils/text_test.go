@@ -0,0 +1,120 @@
+package utils
+
+import "testing"
+
+func TestNormaliseText(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "strips diacritics",
+			input:    "Côte d'Ivoire",
+			expected: "cote d ivoire",
+		},
+		{
+			name:     "collapses whitespace and punctuation",
+			input:    "  What is   the capital of France?  ",
+			expected: "what is the capital of france",
+		},
+		{
+			name:     "empty string",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := NormaliseText(tc.input)
+			if result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestLevenshteinDistance(t *testing.T) {
+	tt := []struct {
+		name     string
+		a        string
+		b        string
+		expected int
+	}{
+		{
+			name:     "identical",
+			a:        "geobuff",
+			b:        "geobuff",
+			expected: 0,
+		},
+		{
+			name:     "empty source",
+			a:        "",
+			b:        "abc",
+			expected: 3,
+		},
+		{
+			name:     "single substitution",
+			a:        "kitten",
+			b:        "sitten",
+			expected: 1,
+		},
+		{
+			name:     "mixed edits",
+			a:        "kitten",
+			b:        "sitting",
+			expected: 3,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := LevenshteinDistance(tc.a, tc.b)
+			if result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	tt := []struct {
+		name  string
+		a     string
+		b     string
+		above float64
+		below float64
+	}{
+		{
+			name:  "only punctuation and case differ",
+			a:     "What is the capital of France?",
+			b:     "what is the capital of france",
+			above: 0.99,
+			below: 1.01,
+		},
+		{
+			name:  "small typo",
+			a:     "What is the capital of France?",
+			b:     "What is the captial of France?",
+			above: 0.9,
+			below: 1,
+		},
+		{
+			name:  "different questions",
+			a:     "What is the capital of France?",
+			b:     "Which country has the largest population?",
+			above: 0,
+			below: 0.5,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := TextSimilarity(tc.a, tc.b)
+			if result < tc.above || result > tc.below {
+				t.Errorf("expected between %v and %v; got %v", tc.above, tc.below, result)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"regexp"
 	"unicode"
 
 	"github.com/go-playground/validator"
@@ -10,8 +11,11 @@ type IValidationService interface {
 	GetValidator() *validator.Validate
 	UsernameValid(username string) bool
 	PasswordValid(password string) bool
+	RouteValid(route string) bool
 }
 
+var routePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
 type ValidationService struct {
 	Validator *validator.Validate
 }
@@ -24,6 +28,7 @@ func NewValidationService() *ValidationService {
 
 	vs.Validator.RegisterValidation("username", vs.usernameValidation)
 	vs.Validator.RegisterValidation("password", vs.passwordValidation)
+	vs.Validator.RegisterValidation("route", vs.routeValidation)
 	return &vs
 }
 
@@ -78,3 +83,13 @@ func (v *ValidationService) PasswordValid(password string) bool {
 	}
 	return true
 }
+
+func (v *ValidationService) routeValidation(fl validator.FieldLevel) bool {
+	return v.RouteValid(fl.Field().String())
+}
+
+// RouteValid checks that a route is a lowercase, hyphenated slug so it can be
+// used as-is in site URLs.
+func (v *ValidationService) RouteValid(route string) bool {
+	return len(route) <= 100 && routePattern.MatchString(route)
+}
@@ -89,3 +89,47 @@ func TestPasswordValid(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteValid(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: false,
+		},
+		{
+			name:     "contains uppercase",
+			input:    "Geobuff-Tee",
+			expected: false,
+		},
+		{
+			name:     "contains slash",
+			input:    "geobuff/tee",
+			expected: false,
+		},
+		{
+			name:     "trailing hyphen",
+			input:    "geobuff-tee-",
+			expected: false,
+		},
+		{
+			name:     "happy path",
+			input:    "geobuff-tee-2",
+			expected: true,
+		},
+	}
+
+	vs := NewValidationService()
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result := vs.RouteValid(tc.input)
+			if result != tc.expected {
+				t.Errorf("expected %v; got %v", tc.expected, result)
+			}
+		})
+	}
+}